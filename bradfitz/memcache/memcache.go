@@ -0,0 +1,310 @@
+// Package memcache is a drop-in replacement for
+// github.com/bradfitz/gomemcache/memcache backed by
+// github.com/skinass/gomemcache/memcache.
+//
+// It exposes the same types, functions and sentinel errors, so switching
+// only takes changing the import path. Code that needs the binary
+// protocol, SASL or the other extensions should move to the backing
+// package, whose errors are the same values as the ones re-exported here.
+package memcache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache"
+)
+
+// Similar to:
+// https://godoc.org/google.golang.org/appengine/memcache
+var (
+	// ErrCacheMiss means that a Get failed because the item wasn't present.
+	ErrCacheMiss = memcache.ErrCacheMiss
+
+	// ErrCASConflict means that a CompareAndSwap call failed due to the
+	// cached value being modified between the Get and the CompareAndSwap.
+	// If the cached value was simply evicted rather than replaced,
+	// ErrNotStored will be returned instead.
+	ErrCASConflict = memcache.ErrCASConflict
+
+	// ErrNotStored means that a conditional write operation (i.e. Add or
+	// CompareAndSwap) failed because the condition was not satisfied.
+	ErrNotStored = memcache.ErrNotStored
+
+	// ErrServer means that a server error occurred.
+	ErrServerError = memcache.ErrServerError
+
+	// ErrNoStats means that no statistics were available.
+	ErrNoStats = memcache.ErrNoStats
+
+	// ErrMalformedKey is returned when an invalid key is used.
+	// Keys must be at maximum 250 bytes long and not
+	// contain whitespace or control characters.
+	ErrMalformedKey = memcache.ErrMalformedKey
+
+	// ErrNoServers is returned when no servers are configured or available.
+	ErrNoServers = memcache.ErrNoServers
+)
+
+const (
+	// DefaultTimeout is the default socket read/write timeout.
+	DefaultTimeout = memcache.DefaultTimeout
+
+	// DefaultMaxIdleConns is the default maximum number of idle connections
+	// kept for any single address.
+	DefaultMaxIdleConns = memcache.DefaultMaxIdleConns
+)
+
+// ServerSelector is the interface that selects a memcache server
+// as a function of the item's key.
+type ServerSelector = memcache.ServerSelector
+
+// ServerList is a simple ServerSelector. Its zero value is usable.
+type ServerList = memcache.ServerList
+
+// ConnectTimeoutError is the error type used when it takes
+// too long to connect to the desired host.
+type ConnectTimeoutError = memcache.ConnectTimeoutError
+
+// Item is an item to be got or stored in a memcached server.
+type Item struct {
+	// Key is the Item's key (250 bytes maximum).
+	Key string
+
+	// Value is the Item's value.
+	Value []byte
+
+	// Flags are server-opaque flags whose semantics are entirely
+	// up to the app.
+	Flags uint32
+
+	// Expiration is the cache expiration time, in seconds: either a relative
+	// time from now (up to 1 month), or an absolute Unix epoch time.
+	// Zero means the Item has no expiration time.
+	Expiration int32
+
+	// CasID is the compare and swap ID.
+	//
+	// It's populated by get requests and then the same value is
+	// required for a CompareAndSwap request to succeed.
+	CasID uint64
+}
+
+func (it *Item) toItem() *memcache.Item {
+	return &memcache.Item{
+		Key:        it.Key,
+		Value:      it.Value,
+		Flags:      it.Flags,
+		Expiration: it.Expiration,
+		Casid:      it.CasID,
+	}
+}
+
+func fromItem(it *memcache.Item) *Item {
+	return &Item{
+		Key:        it.Key,
+		Value:      it.Value,
+		Flags:      it.Flags,
+		Expiration: it.Expiration,
+		CasID:      it.Casid,
+	}
+}
+
+// Client is a memcache client.
+// It is safe for unlocked use by multiple concurrent goroutines.
+type Client struct {
+	// Timeout specifies the socket read/write timeout.
+	// If zero, DefaultTimeout is used.
+	Timeout time.Duration
+
+	// MaxIdleConns specifies the maximum number of idle connections that will
+	// be maintained per address. If less than one, DefaultMaxIdleConns will be
+	// used.
+	//
+	// Consider your expected traffic rates and latency carefully. This should
+	// be set to a number higher than your peak parallel requests.
+	MaxIdleConns int
+
+	mc *memcache.Client
+
+	// mu guards the Timeout and MaxIdleConns last applied to mc.
+	mu           sync.Mutex
+	timeout      time.Duration
+	maxIdleConns int
+}
+
+// New returns a memcache client using the provided server(s)
+// with equal weight. If a server is listed multiple times,
+// it gets a proportional amount of weight.
+func New(server ...string) *Client {
+	ss := new(ServerList)
+	ss.SetServers(server...)
+	return NewFromSelector(ss)
+}
+
+// NewFromSelector returns a new Client using the provided ServerSelector.
+func NewFromSelector(ss ServerSelector) *Client {
+	// The original client flushes without asking. Only a nil selector is
+	// refused, whose operations fail anyway.
+	mc, err := memcache.NewClient(nil, memcache.WithSelector(ss), memcache.WithAllowFlush())
+	if err != nil {
+		mc = memcache.NewFromSelector(ss)
+	}
+	return &Client{mc: mc}
+}
+
+// client returns the backing client, after applying Timeout and
+// MaxIdleConns to it if they changed since the last call.
+func (c *Client) client() *memcache.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Timeout != c.timeout || c.MaxIdleConns != c.maxIdleConns {
+		c.timeout, c.maxIdleConns = c.Timeout, c.MaxIdleConns
+		cfg := memcache.Config{Timeout: c.Timeout, MaxIdleConns: c.MaxIdleConns}
+		if cfg.Timeout < 0 {
+			cfg.Timeout = 0
+		}
+		if cfg.MaxIdleConns < 0 {
+			cfg.MaxIdleConns = 0
+		}
+		c.mc.ApplyConfig(cfg)
+	}
+	return c.mc
+}
+
+// bareErr returns ErrNoServers for the *memcache.NoServersError of the
+// backing client, which callers of the original package compare against
+// the sentinel.
+func bareErr(err error) error {
+	if _, ok := err.(*memcache.NoServersError); ok {
+		return ErrNoServers
+	}
+	return err
+}
+
+// FlushAll removes all the items in the cache.
+func (c *Client) FlushAll() error {
+	return bareErr(c.client().FlushAll(memcache.ConfirmFlush))
+}
+
+// Get gets the item for the given key. ErrCacheMiss is returned for a
+// memcache cache miss. The key must be at most 250 bytes in length.
+func (c *Client) Get(key string) (*Item, error) {
+	it, err := c.client().Get(key)
+	if err != nil {
+		return nil, bareErr(err)
+	}
+	return fromItem(it), nil
+}
+
+// Touch updates the expiry for the given key. The seconds parameter is either
+// a Unix timestamp or, if seconds is less than 1 month, the number of seconds
+// into the future at which time the item will expire. Zero means the item has
+// no expiration time. ErrCacheMiss is returned if the key is not in the cache.
+// The key must be at most 250 bytes in length.
+func (c *Client) Touch(key string, seconds int32) error {
+	return bareErr(c.client().Touch(key, seconds))
+}
+
+// GetMulti is a batch version of Get. The returned map from keys to
+// items may have fewer elements than the input slice, due to memcache
+// cache misses. Each key must be at most 250 bytes in length.
+// If no error is returned, the returned map will also be non-nil.
+func (c *Client) GetMulti(keys []string) (map[string]*Item, error) {
+	m, err := c.client().GetMulti(keys)
+	err = bareErr(err)
+	if m == nil {
+		return nil, err
+	}
+	items := make(map[string]*Item, len(m))
+	for key, it := range m {
+		items[key] = fromItem(it)
+	}
+	return items, err
+}
+
+// Set writes the given item, unconditionally.
+func (c *Client) Set(item *Item) error {
+	return bareErr(c.client().Set(item.toItem()))
+}
+
+// Add writes the given item, if no value already exists for its
+// key. ErrNotStored is returned if that condition is not met.
+func (c *Client) Add(item *Item) error {
+	return bareErr(c.client().Add(item.toItem()))
+}
+
+// Replace writes the given item, but only if the server *does*
+// already hold data for this key
+func (c *Client) Replace(item *Item) error {
+	return bareErr(c.client().Replace(item.toItem()))
+}
+
+// Append appends the given item to the existing item, if a value already
+// exists for its key. ErrNotStored is returned if that condition is not met.
+func (c *Client) Append(item *Item) error {
+	return bareErr(c.client().Append(item.toItem()))
+}
+
+// Prepend prepends the given item to the existing item, if a value already
+// exists for its key. ErrNotStored is returned if that condition is not met.
+func (c *Client) Prepend(item *Item) error {
+	return bareErr(c.client().Prepend(item.toItem()))
+}
+
+// CompareAndSwap writes the given item that was previously returned
+// by Get, if the value was neither modified nor evicted between the
+// Get and the CompareAndSwap calls. The item's Key should not change
+// between calls but all other item fields may differ. ErrCASConflict
+// is returned if the value was modified in between the
+// calls. ErrNotStored is returned if the value was evicted in between
+// the calls.
+func (c *Client) CompareAndSwap(item *Item) error {
+	return bareErr(c.client().CompareAndSwap(item.toItem()))
+}
+
+// Delete deletes the item with the provided key. The error ErrCacheMiss is
+// returned if the item didn't already exist in the cache.
+func (c *Client) Delete(key string) error {
+	return bareErr(c.client().Delete(key))
+}
+
+// DeleteAll deletes all items in the cache.
+func (c *Client) DeleteAll() error {
+	return bareErr(c.client().DeleteAll(memcache.ConfirmFlush))
+}
+
+// Ping checks all instances if they are alive. Returns error if any
+// of them is down.
+func (c *Client) Ping() error {
+	return bareErr(c.client().Ping())
+}
+
+// Increment atomically increments key by delta. The return value is
+// the new value after being incremented or an error. If the value
+// didn't exist in memcached the error is ErrCacheMiss. The value in
+// memcached must be an decimal number, or an error will be returned.
+// On 64-bit overflow, the new value wraps around.
+func (c *Client) Increment(key string, delta uint64) (newValue uint64, err error) {
+	newValue, err = c.client().Increment(key, delta)
+	return newValue, bareErr(err)
+}
+
+// Decrement atomically decrements key by delta. The return value is
+// the new value after being decremented or an error. If the value
+// didn't exist in memcached the error is ErrCacheMiss. The value in
+// memcached must be an decimal number, or an error will be returned.
+// On underflow, the new value is capped at zero and does not wrap
+// around.
+func (c *Client) Decrement(key string, delta uint64) (newValue uint64, err error) {
+	newValue, err = c.client().Decrement(key, delta)
+	return newValue, bareErr(err)
+}
+
+// Close closes any open connections.
+//
+// After Close, the Client may still be used, but its connections are no
+// longer pooled.
+func (c *Client) Close() error {
+	return c.client().Close()
+}
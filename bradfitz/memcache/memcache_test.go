@@ -0,0 +1,66 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestClient(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.Timeout = time.Second
+	c.MaxIdleConns = 4
+
+	if err := c.Set(&Item{Key: "foo", Value: []byte("bar"), Flags: 7}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	it, err := c.Get("foo")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(it.Value) != "bar" || it.Flags != 7 || it.CasID == 0 {
+		t.Errorf("Get = %+v", it)
+	}
+
+	it.Value = []byte("baz")
+	if err := c.CompareAndSwap(it); err != nil {
+		t.Fatalf("CompareAndSwap: %v", err)
+	}
+	if err := c.CompareAndSwap(it); err != ErrCASConflict {
+		t.Errorf("stale CompareAndSwap: got %v, want ErrCASConflict", err)
+	}
+	if err := c.Add(&Item{Key: "foo", Value: []byte("x")}); err != ErrNotStored {
+		t.Errorf("Add of an existing key: got %v, want ErrNotStored", err)
+	}
+
+	m, err := c.GetMulti([]string{"foo", "missing"})
+	if err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if len(m) != 1 || string(m["foo"].Value) != "baz" {
+		t.Errorf("GetMulti = %v", m)
+	}
+
+	if err := c.Delete("foo"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := c.Get("foo"); err != ErrCacheMiss {
+		t.Errorf("Get after Delete: got %v, want ErrCacheMiss", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestNoServersSentinel(t *testing.T) {
+	c := New()
+	if _, err := c.Get("foo"); err != ErrNoServers {
+		t.Errorf("Get without servers error = %v, want ErrNoServers", err)
+	}
+	if err := c.Set(&Item{Key: "foo"}); err != ErrNoServers {
+		t.Errorf("Set without servers error = %v, want ErrNoServers", err)
+	}
+}
@@ -0,0 +1,61 @@
+package memcache
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestAppendOrSet(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	if err := c.Append(&Item{Key: "log", Value: []byte("a")}); err != ErrNotStored {
+		t.Fatalf("Append on missing key: got %v, want ErrNotStored", err)
+	}
+	if err := c.AppendOrSet(&Item{Key: "log", Value: []byte("a")}); err != nil {
+		t.Fatalf("AppendOrSet(create): %v", err)
+	}
+	if err := c.AppendOrSet(&Item{Key: "log", Value: []byte("b")}); err != nil {
+		t.Fatalf("AppendOrSet(append): %v", err)
+	}
+	if err := c.Prepend(&Item{Key: "log", Value: []byte("_")}); err != nil {
+		t.Fatalf("Prepend: %v", err)
+	}
+	it, err := c.Get("log")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := string(it.Value); got != "_ab" {
+		t.Errorf("value = %q, want %q", got, "_ab")
+	}
+}
+
+func TestAppendOrSetConcurrent(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.AppendOrSet(&Item{Key: "ring", Value: []byte("x")}); err != nil {
+				t.Errorf("AppendOrSet: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	it, err := c.Get("ring")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(it.Value) != n {
+		t.Errorf("len(value) = %d, want %d", len(it.Value), n)
+	}
+}
@@ -0,0 +1,91 @@
+package memcache
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditRecord is the account of a mutating operation an AuditLog passes to
+// its sink. Its JSON encoding is the format of JSONAuditSink.
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	Op         string    `json:"op"`
+	Key        string    `json:"key,omitempty"`
+	Size       int       `json:"size,omitempty"`
+	Expiration int32     `json:"exp,omitempty"`
+	Caller     string    `json:"caller,omitempty"`
+	Server     string    `json:"server,omitempty"`
+	Err        string    `json:"err,omitempty"` // empty on success
+}
+
+// auditedOps are the operations an AuditLog records: those changing what
+// the cache holds.
+var auditedOps = map[string]bool{
+	"set": true, "add": true, "replace": true, "cas": true,
+	"append": true, "prepend": true, "delete": true,
+	"incr": true, "decr": true, "touch": true, "flush_all": true,
+}
+
+// AuditLog passes the mutating operations of a client to a sink, for
+// environments that must account for the data held in the cache. Register
+// its Observe method with Client.AddHook.
+//
+// Keys are sampled by hash, so that the whole history of a sampled key is
+// recorded. Operations on the whole cache, such as flush_all, are always
+// recorded.
+type AuditLog struct {
+	threshold uint64 // keys whose hash is below it are recorded
+	sink      func(AuditRecord)
+}
+
+// NewAuditLog returns an audit log passing the operations on the given
+// fraction of keys, between 0 and 1, to sink. The sink is called on the
+// goroutine of each operation, so it must be fast and safe for concurrent
+// use.
+func NewAuditLog(rate float64, sink func(AuditRecord)) *AuditLog {
+	if rate > 1 {
+		rate = 1
+	}
+	return &AuditLog{threshold: uint64(rate * (1 << 32)), sink: sink}
+}
+
+// Observe records ev if it is a mutating operation on a sampled key. It is
+// a Hook.
+func (l *AuditLog) Observe(ev OpEvent) {
+	if !auditedOps[ev.Op] {
+		return
+	}
+	if ev.Key != "" && uint64(crc32.ChecksumIEEE([]byte(ev.Key))) >= l.threshold {
+		return
+	}
+	rec := AuditRecord{
+		Time:       ev.Start,
+		Op:         ev.Op,
+		Key:        ev.Key,
+		Size:       ev.Size,
+		Expiration: ev.Expiration,
+		Caller:     ev.Caller,
+	}
+	if ev.Server != nil {
+		rec.Server = ev.Server.String()
+	}
+	if ev.Err != nil {
+		rec.Err = ev.Err.Error()
+	}
+	l.sink(rec)
+}
+
+// JSONAuditSink returns a sink for NewAuditLog writing each record to w as
+// a line of JSON. Write errors are dropped.
+func JSONAuditSink(w io.Writer) func(AuditRecord) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(rec AuditRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(rec)
+	}
+}
@@ -0,0 +1,67 @@
+package memcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestAuditLog(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.allowFlush = true
+	var buf bytes.Buffer
+	c.AddHook(NewAuditLog(1, JSONAuditSink(&buf)).Observe)
+
+	if err := c.Set(&Item{Key: "k", Value: []byte("abc"), Expiration: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetMulti([]*Item{{Key: "m", Value: []byte("xy")}}); err != nil {
+		t.Fatal(err)
+	}
+	c.Delete("absent")
+	if err := c.FlushAll(ConfirmFlush); err != nil {
+		t.Fatal(err)
+	}
+
+	var recs []AuditRecord
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var rec AuditRecord
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 4 {
+		t.Fatalf("got %d records, want 4 without the get: %+v", len(recs), recs)
+	}
+	if r := recs[0]; r.Op != "set" || r.Key != "k" || r.Size != 3 || r.Expiration != 60 || r.Server != s.Addr() || r.Err != "" {
+		t.Errorf("record of Set = %+v", r)
+	}
+	if r := recs[1]; r.Op != "set" || r.Key != "m" || r.Size != 2 {
+		t.Errorf("record of SetMulti = %+v", r)
+	}
+	if r := recs[2]; r.Op != "delete" || r.Err != ErrCacheMiss.Error() {
+		t.Errorf("record of a Delete miss = %+v", r)
+	}
+	if r := recs[3]; r.Op != "flush_all" {
+		t.Errorf("record of FlushAll = %+v", r)
+	}
+}
+
+func TestAuditLogSampling(t *testing.T) {
+	var n int
+	l := NewAuditLog(0, func(AuditRecord) { n++ })
+	l.Observe(OpEvent{Op: "set", Key: "k"})
+	l.Observe(OpEvent{Op: "flush_all"})
+	if n != 1 {
+		t.Errorf("audit log with a zero rate recorded %d operations, want only the flush", n)
+	}
+}
@@ -0,0 +1,79 @@
+package memcache
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+// opRecorder records the binary opcodes of each write to its connections.
+type opRecorder struct {
+	mu     sync.Mutex
+	writes [][]byte
+}
+
+func (r *opRecorder) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{Conn: nc, r: r}, nil
+}
+
+type recordingConn struct {
+	net.Conn
+	r *opRecorder
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	var ops []byte
+	for b := p; len(b) >= 24; {
+		ops = append(ops, b[1])
+		n := 24 + int(binary.BigEndian.Uint32(b[8:]))
+		if n > len(b) {
+			break
+		}
+		b = b[n:]
+	}
+	c.r.mu.Lock()
+	c.r.writes = append(c.r.writes, ops)
+	c.r.mu.Unlock()
+	return c.Conn.Write(p)
+}
+
+func TestAuthPipelinedAndCached(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	var rec opRecorder
+	c, err := NewClient([]string{s.Addr()}, WithProtocol("binary"), WithAuth("user", "pass"), WithDialContext(rec.dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	addr, err := c.selector.PickServer("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		cn, err := c.getConn(addr, true)
+		if err != nil {
+			t.Fatalf("connection %d: %v", i, err)
+		}
+		cn.discard()
+	}
+
+	const authList, authStart = 0x20, 0x21
+	want := [][]byte{
+		{authList, authStart}, // both in one round trip
+		{authStart},           // mechanisms remembered
+	}
+	if !reflect.DeepEqual(rec.writes, want) {
+		t.Errorf("auth writes = %v, want %v", rec.writes, want)
+	}
+}
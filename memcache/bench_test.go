@@ -0,0 +1,110 @@
+package memcache
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+// benchSizes are the value sizes the client benchmarks run with.
+var benchSizes = []int{100, 10 << 10, 1 << 20}
+
+func sizeName(n int) string {
+	switch {
+	case n >= 1<<20:
+		return strconv.Itoa(n>>20) + "MB"
+	case n >= 1<<10:
+		return strconv.Itoa(n>>10) + "KB"
+	}
+	return strconv.Itoa(n) + "B"
+}
+
+// benchClients runs fn as a sub-benchmark for each protocol and value
+// size, with a client of a fresh in-memory server.
+func benchClients(b *testing.B, fn func(b *testing.B, c *Client, value []byte)) {
+	for _, proto := range []string{"text", "binary"} {
+		for _, size := range benchSizes {
+			b.Run(proto+"/"+sizeName(size), func(b *testing.B) {
+				s := memcachetest.NewServer(b)
+				defer s.Close()
+				c := New(s.Addr())
+				if proto == "binary" {
+					c = NewBinary(s.Addr())
+				}
+				defer c.Close()
+				value := make([]byte, size)
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				fn(b, c, value)
+			})
+		}
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	benchClients(b, func(b *testing.B, c *Client, value []byte) {
+		item := &Item{Key: "bench", Value: value}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := c.Set(item); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	benchClients(b, func(b *testing.B, c *Client, value []byte) {
+		if err := c.Set(&Item{Key: "bench", Value: value}); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.Get("bench"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	benchClients(b, func(b *testing.B, c *Client, value []byte) {
+		if err := c.Set(&Item{Key: "bench", Value: value}); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := c.Get("bench"); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkGetMulti(b *testing.B) {
+	benchClients(b, func(b *testing.B, c *Client, value []byte) {
+		keys := make([]string, 20)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("bench-%d", i)
+			if err := c.Set(&Item{Key: keys[i], Value: value}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.SetBytes(int64(len(value) * len(keys)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m, err := c.GetMulti(keys)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(m) != len(keys) {
+				b.Fatalf("GetMulti returned %d items, want %d", len(m), len(keys))
+			}
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package memcache
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+)
+
+// ClusterErrors is returned by a Broadcaster when some of its clusters
+// fail. It maps each failed cluster name to its error.
+type ClusterErrors map[string]error
+
+func (e ClusterErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 1 {
+		return fmt.Sprintf("memcache: cluster %q: %v", names[0], e[names[0]])
+	}
+	return fmt.Sprintf("memcache: %d clusters failed, first %q: %v", len(names), names[0], e[names[0]])
+}
+
+// Broadcaster sends invalidations to several clusters at once, each
+// reached with its own Client, rather than to one cluster only. It is
+// meant for invalidating cached copies everywhere, such as in every
+// datacenter, after the source of truth changes.
+type Broadcaster struct {
+	clients map[string]*Client
+}
+
+// NewBroadcaster returns a Broadcaster for the clusters of clients, keyed
+// by name.
+func NewBroadcaster(clients map[string]*Client) *Broadcaster {
+	return &Broadcaster{clients: clients}
+}
+
+// Broadcaster returns a Broadcaster for the client's servers, named "",
+// and for each of its pools, by name. A client with only pools is left
+// out.
+func (c *Client) Broadcaster() *Broadcaster {
+	clients := make(map[string]*Client, len(c.named)+1)
+	hasServers := false
+	c.selector.Each(func(net.Addr) error {
+		hasServers = true
+		return nil
+	})
+	if hasServers {
+		clients[""] = c
+	}
+	for name, pc := range c.named {
+		clients[name] = pc
+	}
+	return NewBroadcaster(clients)
+}
+
+// each calls fn concurrently with the client of each cluster, returning a
+// ClusterErrors for the clusters where fn failed other than with a miss.
+func (b *Broadcaster) each(fn func(*Client) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(ClusterErrors)
+	)
+	for name, c := range b.clients {
+		name, c := name, c
+		wg.Add(1)
+		c.goFunc(func() {
+			defer wg.Done()
+			if err := fn(c); err != nil && err != ErrCacheMiss {
+				mu.Lock()
+				errs[name] = err
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Delete deletes key in every cluster. Clusters that don't have it are
+// not errors.
+func (b *Broadcaster) Delete(key string) error {
+	return b.each(func(c *Client) error { return c.Delete(key) })
+}
+
+// DeleteMulti deletes keys in every cluster. Clusters that miss some of
+// them are not errors.
+func (b *Broadcaster) DeleteMulti(keys []string) error {
+	return b.each(func(c *Client) error {
+		err := c.DeleteMulti(keys)
+		if kerrs, ok := err.(KeyErrors); ok {
+			for key, err := range kerrs {
+				if err == ErrCacheMiss {
+					delete(kerrs, key)
+				}
+			}
+			if len(kerrs) == 0 {
+				return nil
+			}
+		}
+		return err
+	})
+}
+
+// Touch updates the expiry of key in every cluster, as Client.Touch.
+// Clusters that don't have it are not errors.
+func (b *Broadcaster) Touch(key string, seconds int32) error {
+	return b.each(func(c *Client) error { return c.Touch(key, seconds) })
+}
@@ -0,0 +1,53 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestBroadcaster(t *testing.T) {
+	main, east, west := memcachetest.NewServer(t), memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer main.Close()
+	defer east.Close()
+	defer west.Close()
+	c, err := NewClient([]string{main.Addr()},
+		WithPool("east", Config{Servers: []string{east.Addr()}}),
+		WithPool("west", Config{Servers: []string{west.Addr()}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	clusters := map[string]*Client{"": c, "east": c.Pool("east"), "west": c.Pool("west")}
+
+	for _, cc := range clusters {
+		if err := cc.Set(&Item{Key: "user:1", Value: []byte("stale")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := clusters["west"].Delete("user:1"); err != nil {
+		t.Fatal(err)
+	}
+	b := c.Broadcaster()
+	if err := b.Delete("user:1"); err != nil {
+		t.Errorf("Delete with a cluster missing the key: %v", err)
+	}
+	for name, cc := range clusters {
+		if _, err := cc.Get("user:1"); err != ErrCacheMiss {
+			t.Errorf("cluster %q still has the key: %v", name, err)
+		}
+	}
+	if err := b.DeleteMulti([]string{"a", "b"}); err != nil {
+		t.Errorf("DeleteMulti of missing keys: %v", err)
+	}
+
+	east.Close()
+	clusters["east"].Close()
+	err = b.Touch("user:1", 10)
+	var cerrs ClusterErrors
+	if !errors.As(err, &cerrs) || len(cerrs) != 1 || cerrs["east"] == nil {
+		t.Errorf("Touch with a cluster down error = %v, want a failure of east only", err)
+	}
+}
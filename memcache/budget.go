@@ -0,0 +1,67 @@
+package memcache
+
+import "time"
+
+// PhaseBudgets splits Timeout between the phases of an operation, in
+// proportion to their weights, so that a slow phase cannot use up the time
+// the others need. With budgets, each phase must end by a checkpoint: the
+// dial after its share of Timeout, authentication after the shares of the
+// dial and itself, the write after those three shares, and the read after
+// the whole Timeout. Time a phase leaves unused, or that a pooled
+// connection saves by skipping the dial and authentication, carries over
+// to the later phases. AuthTimeout is ignored.
+//
+// The zero value disables budgets: the dial and the operation each get a
+// full Timeout, and authentication AuthTimeout.
+type PhaseBudgets struct {
+	Dial, Auth, Write, Read float64
+}
+
+func (b PhaseBudgets) enabled() bool { return b != PhaseBudgets{} }
+
+func (b PhaseBudgets) validate() error {
+	if b.Dial < 0 || b.Auth < 0 || b.Write < 0 || b.Read < 0 {
+		return configError("negative weight in PhaseBudgets %+v", b)
+	}
+	if b.Read == 0 && b.enabled() {
+		return configError("PhaseBudgets leave no time to read")
+	}
+	return nil
+}
+
+// checkpoints are the offsets, from the start of an operation, by which
+// each phase must end.
+type checkpoints struct {
+	dial, auth, write, read time.Duration
+}
+
+// checkpoints returns the checkpoints of an operation under the client's
+// budgets, and whether it has any.
+func (c *Client) checkpoints() (checkpoints, bool) {
+	c.cfgMu.RLock()
+	b := c.phaseBudgets
+	c.cfgMu.RUnlock()
+	if !b.enabled() {
+		return checkpoints{}, false
+	}
+	timeout := c.netTimeout()
+	total := b.Dial + b.Auth + b.Write + b.Read
+	share := func(w float64) time.Duration { return time.Duration(float64(timeout) * w / total) }
+	return checkpoints{
+		dial:  share(b.Dial),
+		auth:  share(b.Dial + b.Auth),
+		write: share(b.Dial + b.Auth + b.Write),
+		read:  timeout,
+	}, true
+}
+
+// startOp sets the deadlines of the operation about to run on cn, which
+// started at start.
+func (cn *conn) startOp(start time.Time, cp checkpoints, budgeted bool) {
+	if !budgeted {
+		cn.extendDeadline()
+		return
+	}
+	cn.nc.SetWriteDeadline(start.Add(cp.write))
+	cn.nc.SetReadDeadline(start.Add(cp.read))
+}
@@ -0,0 +1,57 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestCheckpoints(t *testing.T) {
+	c, err := NewClient([]string{"127.0.0.1:1"}, WithTimeout(time.Second), WithPhaseBudgets(PhaseBudgets{Dial: 1, Auth: 1, Write: 1, Read: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, ok := c.checkpoints()
+	want := checkpoints{dial: 200 * time.Millisecond, auth: 400 * time.Millisecond, write: 600 * time.Millisecond, read: time.Second}
+	if !ok || cp != want {
+		t.Errorf("checkpoints() = %+v, %v, want %+v", cp, ok, want)
+	}
+	if _, ok := New("127.0.0.1:1").checkpoints(); ok {
+		t.Error("client without budgets has checkpoints")
+	}
+	for _, b := range []PhaseBudgets{{Dial: -1, Read: 1}, {Dial: 1}} {
+		if _, err := NewClient([]string{"127.0.0.1:1"}, WithPhaseBudgets(b)); err == nil {
+			t.Errorf("NewClient accepted PhaseBudgets %+v", b)
+		}
+	}
+}
+
+// TestPhaseBudgetsSetup checks that a slow connection setup fails once it
+// uses more than its share of the timeout.
+func TestPhaseBudgetsSetup(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	// The label fetch is the first response, and it is slow.
+	s.SetFaults(memcachetest.Faults{SlowRate: 1, Delay: 150 * time.Millisecond})
+
+	opts := []Option{WithTimeout(400 * time.Millisecond), WithClientName("app")}
+	c, err := NewClient([]string{s.Addr()}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("k"); err != ErrCacheMiss {
+		t.Errorf("Get without budgets error = %v, want ErrCacheMiss", err)
+	}
+	c.Close()
+
+	opts = append(opts, WithPhaseBudgets(PhaseBudgets{Dial: 1, Auth: 1, Write: 1, Read: 5}))
+	c, err = NewClient([]string{s.Addr()}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Get("k"); err == nil || err == ErrCacheMiss {
+		t.Errorf("Get with a 100ms setup budget error = %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,42 @@
+package memcache
+
+import "context"
+
+// Cacher is the cache operations of Client. Applications depending on it
+// rather than on *Client can substitute a fake in tests, or wrap the
+// client in decorators adding metrics or another tier, which embed the
+// Cacher they wrap and override the methods they change.
+//
+// Cacher leaves out the methods configuring or inspecting a Client, such
+// as ApplyConfig, AddHook or PoolStats, and the helpers built on the
+// operations, such as Memoize.
+type Cacher interface {
+	Get(key string) (*Item, error)
+	GetContext(ctx context.Context, key string, opts ...CallOption) (*Item, error)
+	GetMulti(keys []string) (map[string]*Item, error)
+	GetMultiContext(ctx context.Context, keys []string) (map[string]*Item, error)
+	GetMultiOrdered(keys []string) ([]*Item, error)
+	GetMultiFunc(keys []string, fn func(*Item) error) error
+	GetAndTouchMulti(keys []string, seconds int32) (map[string]*Item, error)
+
+	Set(item *Item) error
+	SetMulti(items []*Item) error
+	Add(item *Item) error
+	Replace(item *Item) error
+	Append(item *Item) error
+	Prepend(item *Item) error
+	CompareAndSwap(item *Item) error
+	Touch(key string, seconds int32) error
+	Increment(key string, delta uint64) (uint64, error)
+	Decrement(key string, delta uint64) (uint64, error)
+
+	Delete(key string) error
+	DeleteMulti(keys []string) error
+	DeleteAll(confirm string) error
+	FlushAll(confirm string) error
+
+	Ping() error
+	Close() error
+}
+
+var _ Cacher = (*Client)(nil)
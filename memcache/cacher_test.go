@@ -0,0 +1,38 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+// countingCacher is a decorator counting the Gets of the Cacher it wraps.
+type countingCacher struct {
+	Cacher
+	gets int
+}
+
+func (c *countingCacher) Get(key string) (*Item, error) {
+	c.gets++
+	return c.Cacher.Get(key)
+}
+
+func TestCacherDecorator(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	cc := &countingCacher{Cacher: New(s.Addr())}
+	var cache Cacher = cc
+	if err := cache.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	it, err := cache.Get("k")
+	if err != nil || string(it.Value) != "v" {
+		t.Fatalf("Get = %v, %v", it, err)
+	}
+	if _, err := cache.Get("missing"); err != ErrCacheMiss {
+		t.Errorf("Get(missing) error = %v, want ErrCacheMiss", err)
+	}
+	if cc.gets != 2 {
+		t.Errorf("decorator saw %d gets, want 2", cc.gets)
+	}
+}
@@ -0,0 +1,228 @@
+// Package cachestore implements the store interface of cache abstraction
+// libraries such as gocache on top of a memcache.Client, so that their
+// cache managers, loaders and chains can use memcached through this
+// client and its binary protocol, SASL and pooling features.
+//
+// Store has the methods of gocache's StoreInterface: Get, GetWithTTL,
+// Set, Delete, Invalidate, Clear and GetType, with keys and values typed
+// as interface{} and options of its own that mirror gocache's.
+package cachestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache"
+)
+
+// Type is the store type reported by GetType.
+const Type = "memcache"
+
+// DefaultTagTTL is how long the key lists of tags are kept.
+const DefaultTagTTL = 720 * time.Hour
+
+// maxTagRetries bounds the attempts to update the key list of a tag
+// that others update concurrently.
+const maxTagRetries = 10
+
+// ErrUnsupportedValue is returned by Set for values that are neither a
+// []byte nor a string.
+var ErrUnsupportedValue = errors.New("cachestore: value must be a []byte or a string")
+
+// Options holds the settings of a Set.
+type Options struct {
+	Expiration time.Duration
+	Tags       []string
+}
+
+// An Option adjusts a Set.
+type Option func(*Options)
+
+// WithExpiration makes Set store the value for d instead of the store's
+// default expiration.
+func WithExpiration(d time.Duration) Option {
+	return func(o *Options) { o.Expiration = d }
+}
+
+// WithTags attaches tags to the key, so that Invalidate can delete all
+// the keys of a tag at once.
+func WithTags(tags ...string) Option {
+	return func(o *Options) { o.Tags = append(o.Tags, tags...) }
+}
+
+// InvalidateOptions holds the settings of an Invalidate.
+type InvalidateOptions struct {
+	Tags []string
+}
+
+// An InvalidateOption adjusts an Invalidate.
+type InvalidateOption func(*InvalidateOptions)
+
+// WithInvalidateTags makes Invalidate delete the keys of tags.
+func WithInvalidateTags(tags ...string) InvalidateOption {
+	return func(o *InvalidateOptions) { o.Tags = append(o.Tags, tags...) }
+}
+
+// Store is a cache store backed by memcached. Values are stored as given,
+// as bytes. It is safe for concurrent use by multiple goroutines.
+type Store struct {
+	client  *memcache.Client
+	options []Option
+
+	// TagTTL is how long the key list of a tag is kept after its last
+	// update. If zero, DefaultTagTTL is used. It must be set before use.
+	TagTTL time.Duration
+}
+
+// NewStore returns a Store keeping values through c, with options as the
+// defaults of every Set.
+func NewStore(c *memcache.Client, options ...Option) *Store {
+	return &Store{client: c, options: options}
+}
+
+// Get returns the value stored under key, as a []byte, or
+// memcache.ErrCacheMiss.
+func (s *Store) Get(ctx context.Context, key interface{}) (interface{}, error) {
+	it, err := s.client.GetContext(ctx, keyString(key))
+	if err != nil {
+		return nil, err
+	}
+	return it.Value, nil
+}
+
+// GetWithTTL is like Get, but also returns the time the value has left
+// to live. memcached doesn't report it, so it is always zero, as with
+// gocache's own memcache store.
+func (s *Store) GetWithTTL(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+	v, err := s.Get(ctx, key)
+	return v, 0, err
+}
+
+// Set stores value, a []byte or a string, under key.
+func (s *Store) Set(ctx context.Context, key, value interface{}, options ...Option) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return ErrUnsupportedValue
+	}
+	var o Options
+	for _, opt := range append(append([]Option(nil), s.options...), options...) {
+		opt(&o)
+	}
+	k := keyString(key)
+	if err := s.client.SetContext(ctx, &memcache.Item{Key: k, Value: data, Expiration: memcache.Expiration(o.Expiration, time.Now())}); err != nil {
+		return err
+	}
+	for _, tag := range o.Tags {
+		if err := s.tag(tag, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete deletes the value stored under key. Deleting an absent key is
+// not an error.
+func (s *Store) Delete(ctx context.Context, key interface{}) error {
+	if err := s.client.DeleteContext(ctx, keyString(key)); err != nil && err != memcache.ErrCacheMiss {
+		return err
+	}
+	return nil
+}
+
+// Invalidate deletes the keys of the tags named by options.
+func (s *Store) Invalidate(ctx context.Context, options ...InvalidateOption) error {
+	var o InvalidateOptions
+	for _, opt := range options {
+		opt(&o)
+	}
+	for _, tag := range o.Tags {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		it, err := s.client.Get(tagKey(tag))
+		if err == memcache.ErrCacheMiss {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, key := range strings.Split(string(it.Value), ",") {
+			if err := s.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+		if err := s.client.Delete(tagKey(tag)); err != nil && err != memcache.ErrCacheMiss {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear removes every item of the cache. It requires the client to allow
+// flushes, as memcache.Client.FlushAll does.
+func (s *Store) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.client.FlushAll(memcache.ConfirmFlush)
+}
+
+// GetType returns Type.
+func (s *Store) GetType() string {
+	return Type
+}
+
+// tag adds key to the key list of tag.
+func (s *Store) tag(tag, key string) error {
+	ttl := s.TagTTL
+	if ttl == 0 {
+		ttl = DefaultTagTTL
+	}
+	exp := memcache.Expiration(ttl, time.Now())
+	for i := 0; i < maxTagRetries; i++ {
+		it, err := s.client.Get(tagKey(tag))
+		if err == memcache.ErrCacheMiss {
+			err = s.client.Add(&memcache.Item{Key: tagKey(tag), Value: []byte(key), Expiration: exp})
+			if err == memcache.ErrNotStored {
+				continue // tagged concurrently
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		for _, k := range strings.Split(string(it.Value), ",") {
+			if k == key {
+				return nil
+			}
+		}
+		it.Value = append(it.Value, ","+key...)
+		it.Expiration = exp
+		err = s.client.CompareAndSwap(it)
+		if err != memcache.ErrCASConflict && err != memcache.ErrCacheMiss {
+			return err
+		}
+	}
+	return fmt.Errorf("cachestore: tag %q is updated too often", tag)
+}
+
+func tagKey(tag string) string {
+	return "gocache_tag_" + tag
+}
+
+// keyString returns key as a string: itself if it is one, and otherwise
+// its formatting with fmt.
+func keyString(key interface{}) string {
+	if k, ok := key.(string); ok {
+		return k
+	}
+	return fmt.Sprint(key)
+}
@@ -0,0 +1,66 @@
+package cachestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache"
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestStore(t *testing.T) {
+	mc := memcachetest.NewServer(t)
+	defer mc.Close()
+	c, err := memcache.NewClient([]string{mc.Addr()}, memcache.WithAllowFlush())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(c, WithExpiration(time.Minute))
+	ctx := context.Background()
+
+	if err := s.Set(ctx, "a", []byte("1"), WithTags("t")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, 42, "2", WithTags("t", "u")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "b", 3); err != ErrUnsupportedValue {
+		t.Errorf("Set of an int error = %v, want ErrUnsupportedValue", err)
+	}
+	v, ttl, err := s.GetWithTTL(ctx, 42)
+	if err != nil || string(v.([]byte)) != "2" || ttl != 0 {
+		t.Errorf("GetWithTTL = %v, %v, %v, want 2", v, ttl, err)
+	}
+
+	if err := s.Invalidate(ctx, WithInvalidateTags("t")); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []interface{}{"a", 42} {
+		if _, err := s.Get(ctx, key); err != memcache.ErrCacheMiss {
+			t.Errorf("Get(%v) after invalidating its tag error = %v, want ErrCacheMiss", key, err)
+		}
+	}
+
+	if err := s.Set(ctx, "c", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "c"); err != nil {
+		t.Errorf("Delete of an absent key: %v", err)
+	}
+	if err := s.Set(ctx, "d", "4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "d"); err != memcache.ErrCacheMiss {
+		t.Errorf("Get after Clear error = %v, want ErrCacheMiss", err)
+	}
+	if s.GetType() != Type {
+		t.Errorf("GetType = %q", s.GetType())
+	}
+}
@@ -0,0 +1,214 @@
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/skinass/gomemcache/memcache/proto/text"
+)
+
+// Capabilities are the features of a server, detected with a version
+// command the first time the client needs them and kept for the client's
+// lifetime. The client checks them to pick the commands it sends:
+// GetAndTouchMulti falls back to a get and a touch, Keys skips the LRU
+// crawler, and Touch and TTL fail with a *NotSupportedError, without
+// sending anything, on servers lacking the commands they need.
+//
+// The features are inferred from the version the server reports. A
+// server whose version can't be parsed, such as a proxy, is assumed to
+// support them all.
+type Capabilities struct {
+	Version string // as reported by the server
+
+	Touch       bool // touch, memcached 1.4.8 or later
+	GetAndTouch bool // gat and gats, memcached 1.5.3 or later
+	MetaDump    bool // lru_crawler metadump, memcached 1.4.31 or later
+	Meta        bool // meta commands, memcached 1.6 and the text protocol
+
+	TLS       bool   // the connections are encrypted
+	SASLMechs string // the SASL mechanisms listed when authenticating
+}
+
+// Capabilities returns the capabilities of the server at addr, detecting
+// them if the client hasn't yet. It fails if addr is not one of the
+// client's servers.
+func (c *Client) Capabilities(addr string) (Capabilities, error) {
+	server, err := c.serverAddr(addr)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	var caps Capabilities
+	err = c.withAddrRw(server, func(rw *bufio.ReadWriter) (err error) {
+		caps, err = c.capabilities(server, rw)
+		return err
+	})
+	return caps, err
+}
+
+// capabilities returns the capabilities of the server at addr, asking it
+// for its version over rw unless they are already known.
+func (c *Client) capabilities(addr net.Addr, rw *bufio.ReadWriter) (Capabilities, error) {
+	key := addr.String()
+	c.lk.Lock()
+	caps, ok := c.caps[key]
+	c.lk.Unlock()
+	if ok {
+		return caps, nil
+	}
+	version, err := c.cmdRunner.Version(rw)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	caps = c.inferCapabilities(version)
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	caps.SASLMechs = c.authMechs[key]
+	if c.caps == nil {
+		c.caps = make(map[string]Capabilities)
+	}
+	c.caps[key] = caps
+	return caps, nil
+}
+
+// withCapableRw is like withAddrRw, but fails with a *NotSupportedError,
+// without sending anything, if the server can't run op.
+func (c *Client) withCapableRw(addr net.Addr, op string, fn func(*bufio.ReadWriter) error) error {
+	var lacking bool
+	err := c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+		caps, err := c.capabilities(addr, rw)
+		if err != nil {
+			return err
+		}
+		if !caps.supports(op) {
+			lacking = true
+			return nil
+		}
+		return fn(rw)
+	})
+	if lacking {
+		return c.notSupported(op, addr)
+	}
+	return err
+}
+
+// inferCapabilities returns the capabilities of a server of the given
+// version.
+func (c *Client) inferCapabilities(version string) Capabilities {
+	v, ok := parseVersion(version)
+	atLeast := func(want ...int) bool {
+		if !ok {
+			return true
+		}
+		for i, n := range want {
+			if v[i] != n {
+				return v[i] > n
+			}
+		}
+		return true
+	}
+	return Capabilities{
+		Version:     version,
+		Touch:       atLeast(1, 4, 8),
+		GetAndTouch: atLeast(1, 5, 3),
+		MetaDump:    atLeast(1, 4, 31),
+		Meta:        atLeast(1, 6, 0) && c.cmdRunner.ProtoType() == text.ProtoType,
+		TLS:         c.tlsConfig != nil,
+	}
+}
+
+// parseVersion parses the leading major.minor.patch of version, ignoring
+// any suffix, as in "1.6.21-debug".
+func parseVersion(version string) (v [3]int, ok bool) {
+	f := strings.SplitN(version, ".", 3)
+	if len(f) != 3 {
+		return v, false
+	}
+	if i := strings.IndexFunc(f[2], func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		f[2] = f[2][:i]
+	}
+	for i, s := range f {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+// supports reports whether a server with caps can run op, named as in
+// Client.Supports. Ops that don't depend on the server are supported.
+func (caps Capabilities) supports(op string) bool {
+	switch op {
+	case "touch":
+		return caps.Touch
+	case "gat":
+		return caps.GetAndTouch || caps.Touch
+	case "ttl":
+		return caps.Meta
+	case "metadump":
+		return caps.MetaDump
+	}
+	return true
+}
+
+// textOnly lists the ops only the text protocol supports.
+var textOnly = map[string]bool{"ttl": true, "keys": true, "metadump": true, "cachedump": true}
+
+// NotSupportedError is returned, without contacting the server, by the
+// operations the client's protocol or the server can't run. Server is nil
+// when the protocol is to blame. It is ErrUnknownCommand to errors.Is.
+type NotSupportedError struct {
+	Op     string   // the operation, as named by Client.Supports
+	Proto  string   // the client's protocol
+	Server net.Addr // the server lacking the command, if any
+}
+
+func (e *NotSupportedError) Error() string {
+	if e.Server == nil {
+		return fmt.Sprintf("memcache: %s is not supported by the %s protocol", e.Op, e.Proto)
+	}
+	return fmt.Sprintf("memcache: %s is not supported by server %s", e.Op, e.Server)
+}
+
+// Is reports whether target is ErrUnknownCommand.
+func (e *NotSupportedError) Is(target error) bool {
+	return target == ErrUnknownCommand
+}
+
+// notSupported returns the *NotSupportedError of op on server, or on the
+// client's protocol if server is nil.
+func (c *Client) notSupported(op string, server net.Addr) error {
+	return &NotSupportedError{Op: op, Proto: c.cmdRunner.ProtoType(), Server: server}
+}
+
+// checkProto returns a *NotSupportedError if op is not supported by the
+// client's protocol.
+func (c *Client) checkProto(op string) error {
+	if textOnly[op] && c.cmdRunner.ProtoType() != text.ProtoType {
+		return c.notSupported(op, nil)
+	}
+	return nil
+}
+
+// Supports reports whether op can run on the client's protocol and every
+// server of the client, for feature gating. op is named as in OpEvent, or
+// is one of "ttl", "keys", "metadump" and "cachedump" for the methods of
+// those names. The capabilities of the servers not contacted yet are
+// detected; those that can't be reached are left out.
+func (c *Client) Supports(op string) bool {
+	if c.checkProto(op) != nil {
+		return false
+	}
+	supported := true
+	c.eachServer(func(addr net.Addr) error {
+		if caps, err := c.Capabilities(addr.String()); err == nil && !caps.supports(op) {
+			supported = false
+		}
+		return nil
+	})
+	return supported
+}
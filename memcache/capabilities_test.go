@@ -0,0 +1,115 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestCapabilities(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for name, c := range protoClients(s) {
+		caps, err := c.Capabilities(s.Addr())
+		if err != nil {
+			t.Fatalf("%s: Capabilities: %v", name, err)
+		}
+		want := Capabilities{Version: "1.6.0-fake", Touch: true, GetAndTouch: true, MetaDump: true, Meta: name == "text"}
+		if caps != want {
+			t.Errorf("%s: Capabilities = %+v, want %+v", name, caps, want)
+		}
+	}
+	if _, err := New(s.Addr()).Capabilities("127.0.0.1:1"); err == nil {
+		t.Error("Capabilities of a server not of the client succeeded")
+	}
+}
+
+func TestCapabilitiesOldServer(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	s.SetVersion("1.4.5")
+	for name, c := range protoClients(s) {
+		if err := c.Set(&Item{Key: name, Value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+		err := c.Touch(name, 60)
+		if nerr, ok := err.(*NotSupportedError); !ok || nerr.Op != "touch" || nerr.Server.String() != s.Addr() {
+			t.Errorf("%s: Touch on 1.4.5 error = %v, want a *NotSupportedError", name, err)
+		}
+		if !errors.Is(err, ErrUnknownCommand) {
+			t.Errorf("%s: %v is not ErrUnknownCommand", name, err)
+		}
+		if _, err := c.TTL(name); !errors.Is(err, ErrUnknownCommand) {
+			t.Errorf("%s: TTL on 1.4.5 error = %v, want ErrUnknownCommand", name, err)
+		}
+		if n := c.PoolStats()[s.Addr()].OpenConns; n != 1 {
+			t.Errorf("%s: %d connections open, want the one kept after the unsupported commands", name, n)
+		}
+	}
+}
+
+func TestCapabilitiesFallbacks(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	// Touch, but neither gat nor the LRU crawler.
+	s.SetVersion("1.4.20")
+	for name, c := range protoClients(s) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"a", "b"} {
+				if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m, err := c.GetAndTouchMulti([]string{"a", "b", "missing"}, 60)
+			if err != nil || len(m) != 2 {
+				t.Errorf("GetAndTouchMulti = %v, %v, want both items", m, err)
+			}
+			if name != "text" {
+				return
+			}
+			var keys []string
+			err = c.ForEachServer(context.Background(), func(_ net.Addr, cn *Conn) error {
+				return cn.listKeys(func(km KeyMeta) error {
+					keys = append(keys, km.Key)
+					return nil
+				})
+			})
+			if err != nil {
+				t.Fatalf("listing keys: %v", err)
+			}
+			if len(keys) != 2 {
+				t.Errorf("Keys = %v, want the keys listed by cachedump", keys)
+			}
+		})
+	}
+}
+
+func TestSupports(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	s.SetVersion("1.4.5")
+	text, binary := New(s.Addr()), NewBinary(s.Addr())
+	for _, tt := range []struct {
+		c    *Client
+		op   string
+		want bool
+	}{
+		{text, "get", true},
+		{text, "touch", false},
+		{text, "gat", false},
+		{text, "keys", true},
+		{binary, "keys", false},
+		{binary, "ttl", false},
+		{binary, "set", true},
+	} {
+		if got := tt.c.Supports(tt.op); got != tt.want {
+			t.Errorf("%s Supports(%q) = %v, want %v", tt.c.ProtoType(), tt.op, got, tt.want)
+		}
+	}
+	if _, err := text.GetAndTouchMulti([]string{"k"}, 60); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("GetAndTouchMulti on 1.4.5 error = %v, want ErrUnknownCommand", err)
+	}
+}
@@ -0,0 +1,109 @@
+package memcache
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+var soakDuration = flag.Duration("soak", 500*time.Millisecond, "how long TestSoak hammers the faulty server")
+
+// TestSoak runs concurrent operations against a server injecting every
+// kind of fault, then checks that the client recovers once the faults stop
+// and that closing it leaves no connection or goroutine behind.
+func TestSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping soak test in short mode")
+	}
+	for _, proto := range []string{"text", "binary"} {
+		t.Run(proto, func(t *testing.T) { soak(t, proto) })
+	}
+}
+
+func soak(t *testing.T, proto string) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	baseline := runtime.NumGoroutine()
+
+	c, err := NewClient([]string{s.Addr()}, WithProtocol(proto), WithTimeout(50*time.Millisecond), WithMaxIdleConns(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetFaults(memcachetest.Faults{
+		DisconnectRate: 0.02,
+		SlowRate:       0.02,
+		Delay:          100 * time.Millisecond,
+		PartialRate:    0.02,
+		GarbageRate:    0.02,
+		Seed:           1,
+	})
+
+	var ops, failures int64
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(*soakDuration)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+			var n, failed int64
+			for time.Now().Before(deadline) {
+				key := fmt.Sprintf("key-%d", rnd.Intn(50))
+				var err error
+				switch rnd.Intn(4) {
+				case 0:
+					err = c.Set(&Item{Key: key, Value: make([]byte, rnd.Intn(8192))})
+				case 1:
+					_, err = c.Get(key)
+				case 2:
+					_, err = c.GetMulti([]string{key, key + "-a", key + "-b"})
+				case 3:
+					err = c.Delete(key)
+				}
+				n++
+				if err != nil && err != ErrCacheMiss {
+					failed++
+				}
+			}
+			mu.Lock()
+			ops += n
+			failures += failed
+			mu.Unlock()
+		}(w)
+	}
+	wg.Wait()
+	t.Logf("%d operations, %d failed", ops, failures)
+	if failures == 0 {
+		t.Errorf("no operation failed; faults were not injected")
+	}
+
+	// Without faults, the client must recover. Idle connections whose
+	// stream was corrupted may each fail once before being dropped.
+	s.SetFaults(memcachetest.Faults{})
+	ok := 0
+	waitFor(t, "the client to recover", func() bool {
+		key := fmt.Sprintf("after-%d", ok)
+		if c.Set(&Item{Key: key, Value: []byte("v")}) != nil {
+			ok = 0
+			return false
+		}
+		if _, err := c.Get(key); err != nil {
+			ok = 0
+			return false
+		}
+		ok++
+		return ok == 20
+	})
+
+	c.Close()
+	waitFor(t, "server connections to close", func() bool { return s.Conns() == 0 })
+	waitFor(t, "the client to release everything", func() bool { return c.Debug() == DebugInfo{} })
+	waitFor(t, "goroutines to exit", func() bool { return runtime.NumGoroutine() <= baseline })
+}
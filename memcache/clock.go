@@ -0,0 +1,20 @@
+package memcache
+
+import "time"
+
+// Clock tells the time. The client reads it for the time-dependent logic
+// it runs itself, such as converting long TTLs to absolute expirations or
+// expiring in-process entries, so that tests can control time instead of
+// sleeping. Socket deadlines and operation latencies always use the
+// system clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// now returns the current time of the client's clock.
+func (c *Client) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
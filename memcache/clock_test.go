@@ -0,0 +1,72 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestFakeClockExpiry(t *testing.T) {
+	clock := memcachetest.NewFakeClock(time.Unix(1e9, 0))
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	s.SetClock(clock)
+
+	for name, proto := range map[string]Option{"text": WithProtocol("text"), "binary": WithProtocol("binary")} {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient([]string{s.Addr()}, proto, WithClock(clock))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Set(&Item{Key: "k", Value: []byte("v"), Expiration: 2}); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			if err := c.Touch("k", 10); err != nil {
+				t.Fatalf("Touch: %v", err)
+			}
+			clock.Advance(5 * time.Second)
+			if _, err := c.Get("k"); err != nil {
+				t.Fatalf("Get after the original expiration: %v", err)
+			}
+			clock.Advance(6 * time.Second)
+			if _, err := c.Get("k"); err != ErrCacheMiss {
+				t.Fatalf("Get after the touched expiration: got %v, want ErrCacheMiss", err)
+			}
+		})
+	}
+}
+
+func TestGroupFakeClock(t *testing.T) {
+	clock := memcachetest.NewFakeClock(time.Unix(1e9, 0))
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	s.SetClock(clock)
+	c, err := NewClient([]string{s.Addr()}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var loads int
+	g := NewGroup(c, 10, time.Minute, GetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		loads++
+		return []byte("v"), nil
+	}))
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := g.Get(ctx, "k"); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("getter called %d times before expiry, want 1", loads)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := g.Get(ctx, "k"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if loads != 2 {
+		t.Errorf("getter called %d times after expiry, want 2", loads)
+	}
+}
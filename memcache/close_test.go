@@ -0,0 +1,63 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCloseQuits(t *testing.T) {
+	for _, newClient := range []func(...string) *Client{New, NewBinary} {
+		s := memcachetest.NewServer(t)
+		c := newClient(s.Addr())
+		if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+			t.Fatalf("%s: Set: %v", c.ProtoType(), err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatalf("%s: Close: %v", c.ProtoType(), err)
+		}
+		waitFor(t, "connections to close", func() bool { return s.Conns() == 0 })
+		if got := s.Quits(); got != 1 {
+			t.Errorf("%s: server saw %d quits, want 1", c.ProtoType(), got)
+		}
+		s.Close()
+	}
+}
+
+func TestRecycledConnQuits(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := NewBinary(s.Addr())
+	c.MaxIdleConns = 1
+
+	// Check out two connections at once so that releasing the second one
+	// goes over the idle limit.
+	addr, err := c.selector.PickServer("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn1, err := c.getConn(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn2, err := c.getConn(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn1.release()
+	cn2.release()
+	waitFor(t, "the extra connection to quit", func() bool { return s.Quits() == 1 && s.Conns() == 1 })
+}
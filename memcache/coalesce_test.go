@@ -0,0 +1,67 @@
+package memcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+	"github.com/skinass/gomemcache/memcache/proto/bin"
+	"github.com/skinass/gomemcache/memcache/proto/text"
+)
+
+func TestWriteCoalescing(t *testing.T) {
+	for _, proto := range []string{text.ProtoType, bin.ProtoType} {
+		t.Run(proto, func(t *testing.T) {
+			s := memcachetest.NewServer(t)
+			defer s.Close()
+			// Only a full batch is written: the delay outlasts the test.
+			c, err := NewClient([]string{s.Addr()}, WithProtocol(proto), WithBatchLimits(10, 0),
+				WithWriteCoalescing(WriteCoalescing{Delay: time.Minute}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					if err := c.Set(&Item{Key: fmt.Sprint("k", i), Value: []byte("v")}); err != nil {
+						t.Errorf("Set: %v", err)
+					}
+				}(i)
+			}
+			wg.Wait()
+			if n := c.PoolStats()[s.Addr()].Recycled; n != 1 {
+				t.Errorf("10 sets used a connection %d times, want once", n)
+			}
+			m, err := c.GetMulti([]string{"k0", "k9"})
+			if err != nil || len(m) != 2 {
+				t.Errorf("GetMulti = %v, %v, want both coalesced items", m, err)
+			}
+		})
+	}
+}
+
+func TestWriteCoalescingFlush(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithWriteCoalescing(WriteCoalescing{Delay: time.Millisecond, MaxBytes: 100}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(&Item{Key: "small", Value: []byte("v")}); err != nil {
+		t.Errorf("Set written after the delay: %v", err)
+	}
+	if err := c.Set(&Item{Key: "big", Value: make([]byte, 200)}); err != nil {
+		t.Errorf("Set written at MaxBytes: %v", err)
+	}
+	if err := c.Add(&Item{Key: "small", Value: []byte("v")}); err != ErrNotStored {
+		t.Errorf("Add of an existing key error = %v, want ErrNotStored", err)
+	}
+
+	if _, err := NewClient([]string{s.Addr()}, WithWriteCoalescing(WriteCoalescing{Delay: -1})); err == nil {
+		t.Error("negative WriteCoalescing.Delay accepted")
+	}
+}
@@ -0,0 +1,39 @@
+package memcache
+
+import "encoding/json"
+
+// Codec converts between Go values and the bytes stored in memcache.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is a Codec that uses encoding/json. It is the default codec.
+var JSONCodec Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// SetCodec sets the Codec used by helpers that store Go values, such as
+// Memoize. If never set, JSONCodec is used.
+func (c *Client) SetCodec(codec Codec) {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+	c.codec = codec
+}
+
+// valueCodec returns the codec for the value of key: the codec of its
+// profile, or else the client's Codec.
+func (c *Client) valueCodec(key string) Codec {
+	if codec := c.profile(key).Codec; codec != nil {
+		return codec
+	}
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
+	if c.codec != nil {
+		return c.codec
+	}
+	return JSONCodec
+}
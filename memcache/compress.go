@@ -0,0 +1,82 @@
+package memcache
+
+import (
+	"bytes"
+	"compress/flate"
+	"io/ioutil"
+)
+
+// A Compressor compresses encoded values. Wrap a Codec with CompressCodec
+// to compress the values it produces. It must be safe for concurrent use.
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// NewFlateCompressor returns a Compressor producing raw DEFLATE streams at
+// the given compression level, as defined by compress/flate.
+//
+// If dict is not empty, it is used as a preset dictionary: the compressor
+// starts out knowing it, so that small values sharing its content, such as
+// JSON documents with the same field names, compress well. Values must be
+// decompressed with the same dictionary they were compressed with.
+func NewFlateCompressor(level int, dict []byte) (Compressor, error) {
+	// Check the level once rather than on every Compress.
+	if _, err := flate.NewWriterDict(ioutil.Discard, level, dict); err != nil {
+		return nil, err
+	}
+	return &flateCompressor{level: level, dict: dict}, nil
+}
+
+type flateCompressor struct {
+	level int
+	dict  []byte
+}
+
+func (f *flateCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriterDict(&buf, f.level, f.dict)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (f *flateCompressor) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReaderDict(bytes.NewReader(data), f.dict)
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// CompressCodec returns a Codec that compresses the values encoded by
+// codec with comp.
+func CompressCodec(codec Codec, comp Compressor) Codec {
+	return compressCodec{codec, comp}
+}
+
+type compressCodec struct {
+	codec Codec
+	comp  Compressor
+}
+
+func (c compressCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.comp.Compress(data)
+}
+
+func (c compressCodec) Unmarshal(data []byte, v interface{}) error {
+	data, err := c.comp.Decompress(data)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(data, v)
+}
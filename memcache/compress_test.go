@@ -0,0 +1,83 @@
+package memcache
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+type profile struct {
+	UserID    int    `json:"user_id"`
+	Name      string `json:"display_name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+func TestFlateCompressorDict(t *testing.T) {
+	dict := []byte(`{"user_id":,"display_name":"","avatar_url":"https://cdn.example.com/avatars/"}`)
+	plain, err := NewFlateCompressor(flate.BestCompression, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withDict, err := NewFlateCompressor(flate.BestCompression, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"user_id":42,"display_name":"gopher","avatar_url":"https://cdn.example.com/avatars/42.png"}`)
+
+	small, err := withDict.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	big, err := plain.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(small) >= len(big) {
+		t.Errorf("compressed with the dictionary to %d bytes, without to %d", len(small), len(big))
+	}
+	got, err := withDict.Decompress(small)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Decompress = %q, %v, want %q", got, err, data)
+	}
+
+	if _, err := NewFlateCompressor(42, nil); err == nil {
+		t.Error("NewFlateCompressor accepted an invalid level")
+	}
+}
+
+func TestPrefixCodec(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	comp, err := NewFlateCompressor(flate.DefaultCompression, []byte(`"display_name"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient([]string{s.Addr()}, WithPrefixCodec("profile:", CompressCodec(JSONCodec, comp)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	want := profile{UserID: 42, Name: "gopher"}
+	for _, key := range []string{"profile:42", "plain:42"} {
+		var got profile
+		err := c.Memoize(ctx, key, time.Minute, &got, func(context.Context) (interface{}, error) { return want, nil })
+		if err != nil || got != want {
+			t.Errorf("Memoize(%q) = %+v, %v, want %+v", key, got, err, want)
+		}
+	}
+
+	it, err := c.Get("profile:42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.HasPrefix(it.Value, []byte("{")) {
+		t.Errorf("value under the compressed prefix is stored as plain JSON: %q", it.Value)
+	}
+	if it, err := c.Get("plain:42"); err != nil || !bytes.HasPrefix(it.Value, []byte("{")) {
+		t.Errorf("value under another prefix = %v, %v, want plain JSON", it, err)
+	}
+}
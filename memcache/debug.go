@@ -0,0 +1,40 @@
+package memcache
+
+import "sync/atomic"
+
+// DebugInfo is a snapshot of the resources a Client holds.
+type DebugInfo struct {
+	// OpenConns is the number of open connections, idle or in use.
+	OpenConns int
+
+	// IdleConns is the number of open connections waiting in the idle
+	// pool.
+	IdleConns int
+
+	// Goroutines is the number of goroutines started by the client that
+	// are still running, such as the per-server fetches of GetMulti and
+	// the loads of Memoize.
+	Goroutines int
+}
+
+// Debug reports what the client currently holds open. Once a closed client
+// has no operations left in flight, it holds nothing, which tests can use
+// to detect leaks.
+func (c *Client) Debug() DebugInfo {
+	var d DebugInfo
+	for _, ps := range c.PoolStats() {
+		d.OpenConns += ps.OpenConns
+		d.IdleConns += ps.IdleConns
+	}
+	d.Goroutines = int(atomic.LoadInt32(&c.goroutines) + atomic.LoadInt32(&c.flight.running) + atomic.LoadInt32(&c.touchFlight.running))
+	return d
+}
+
+// goFunc runs fn in a new goroutine accounted for by Debug.
+func (c *Client) goFunc(fn func()) {
+	atomic.AddInt32(&c.goroutines, 1)
+	go func() {
+		defer atomic.AddInt32(&c.goroutines, -1)
+		fn()
+	}()
+}
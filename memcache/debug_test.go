@@ -0,0 +1,67 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestDebugNoLeaksAfterClose(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for name, c := range protoClients(s) {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for w := 0; w < 4; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					key := fmt.Sprintf("%s-%d", name, w)
+					c.Set(&Item{Key: key, Value: []byte("v")})
+					c.GetMulti([]string{key, key + "-missing"})
+					c.DeleteMulti([]string{key})
+					var v string
+					c.Memoize(context.Background(), key+"-memo", time.Minute, &v, func(context.Context) (interface{}, error) {
+						return "memo", nil
+					})
+				}(w)
+			}
+			wg.Wait()
+
+			// Connections over the idle limit are closed in the background.
+			waitFor(t, "only idle connections", func() bool {
+				d := c.Debug()
+				return d.OpenConns > 0 && d.IdleConns == d.OpenConns && d.Goroutines == 0
+			})
+			c.Close()
+			waitFor(t, "the client to release everything", func() bool { return c.Debug() == DebugInfo{} })
+			waitFor(t, "the server connections to close", func() bool { return s.Conns() == 0 })
+		})
+	}
+}
+
+func TestDebugConnInUse(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	addr, err := c.selector.PickServer("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, err := c.getConn(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Debug(), (DebugInfo{OpenConns: 1}); got != want {
+		t.Errorf("Debug() with a connection in use = %+v, want %+v", got, want)
+	}
+
+	// A connection in use when the client is closed is closed on release.
+	c.Close()
+	cn.release()
+	waitFor(t, "the client to release everything", func() bool { return c.Debug() == DebugInfo{} })
+}
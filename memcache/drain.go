@@ -0,0 +1,128 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"sync/atomic"
+)
+
+// ErrDrained is the reason reported in a NoServersError for the servers
+// taken out of rotation with Client.Drain.
+var ErrDrained = errors.New("memcache: server drained")
+
+// Drain takes the server at addr out of rotation for maintenance: the
+// keys it owns are spread over the other servers of their selector until
+// Undrain puts it back, without changing the server list, so the keys of
+// the other servers keep their place. Operations already using the server
+// finish, after which its connections are closed rather than kept idle.
+//
+// Operations addressing every server, such as FlushAll and Stats, still
+// reach a drained server, and the pools returned by Pool are drained
+// separately. Drain fails if addr is not one of the client's servers.
+func (c *Client) Drain(addr string) error {
+	found := false
+	c.eachServer(func(a net.Addr) error {
+		found = found || a.String() == addr
+		return nil
+	})
+	if !found {
+		return fmt.Errorf("memcache: cannot drain %s, which is not a server of the client", addr)
+	}
+
+	c.drainMu.Lock()
+	if c.drained == nil {
+		c.drained = make(map[string]bool)
+	}
+	if !c.drained[addr] {
+		c.drained[addr] = true
+		atomic.AddInt32(&c.drainCount, 1)
+	}
+	c.drainMu.Unlock()
+
+	c.lk.Lock()
+	idle := c.freeconn[addr]
+	delete(c.freeconn, addr)
+	c.lk.Unlock()
+	for _, cn := range idle {
+		cn.closeLater()
+	}
+	return nil
+}
+
+// Undrain puts a server taken out of rotation with Drain back, so that it
+// owns its keys again.
+func (c *Client) Undrain(addr string) {
+	c.drainMu.Lock()
+	defer c.drainMu.Unlock()
+	if c.drained[addr] {
+		delete(c.drained, addr)
+		atomic.AddInt32(&c.drainCount, -1)
+	}
+}
+
+// Drained returns the addresses of the servers taken out of rotation.
+func (c *Client) Drained() []string {
+	c.drainMu.RLock()
+	defer c.drainMu.RUnlock()
+	addrs := make([]string, 0, len(c.drained))
+	for addr := range c.drained {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func (c *Client) isDrained(addr string) bool {
+	if atomic.LoadInt32(&c.drainCount) == 0 {
+		return false
+	}
+	c.drainMu.RLock()
+	defer c.drainMu.RUnlock()
+	return c.drained[addr]
+}
+
+// outOfRotation returns why addr is out of rotation, ErrDrained or
+// ErrEjected, or nil if it is not.
+func (c *Client) outOfRotation(addr string) error {
+	if c.isDrained(addr) {
+		return ErrDrained
+	}
+	if c.isEjected(addr) {
+		return ErrEjected
+	}
+	return nil
+}
+
+// undrained returns addr if it is in rotation, and otherwise the server
+// taking over skey, the key as sent, among the servers of ss neither
+// drained nor ejected by Config.FailureDetector.
+func (c *Client) undrained(ss ServerSelector, addr net.Addr, skey string) (net.Addr, error) {
+	if c.outOfRotation(addr.String()) == nil {
+		return addr, nil
+	}
+	nse := new(NoServersError)
+	var up []net.Addr
+	ss.Each(func(a net.Addr) error {
+		nse.Servers = append(nse.Servers, a.String())
+		if reason := c.outOfRotation(a.String()); reason != nil {
+			if nse.Reasons == nil {
+				nse.Reasons = make(map[string]error)
+			}
+			nse.Reasons[a.String()] = reason
+		} else {
+			up = append(up, a)
+		}
+		return nil
+	})
+	if len(up) == 0 {
+		return nil, nse
+	}
+	var h uint32
+	if sl, ok := ss.(*ServerList); ok {
+		h = sl.hashKey(skey)
+	} else {
+		h = crc32.ChecksumIEEE([]byte(skey))
+	}
+	return up[h%uint32(len(up))], nil
+}
@@ -0,0 +1,93 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestDrain(t *testing.T) {
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		s := memcachetest.NewServer(t)
+		defer s.Close()
+		addrs = append(addrs, s.Addr())
+	}
+	c := New(addrs...)
+	var keys []string
+	for i := 0; i < 100; i++ {
+		keys = append(keys, fmt.Sprint("key", i))
+	}
+	route := func() map[string]string {
+		plan, err := c.ExplainGetMulti(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := make(map[string]string)
+		for addr, routes := range plan {
+			for _, r := range routes {
+				m[r.Key] = addr
+			}
+		}
+		return m
+	}
+	before := route()
+	if _, err := c.Get(keys[0]); err != nil && err != ErrCacheMiss {
+		t.Fatal(err)
+	}
+
+	drained := before[keys[0]]
+	if err := c.Drain(drained); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Drained(); len(got) != 1 || got[0] != drained {
+		t.Errorf("Drained = %v, want [%s]", got, drained)
+	}
+	after := route()
+	moved := make(map[string]bool)
+	for _, key := range keys {
+		switch {
+		case after[key] == drained:
+			t.Errorf("%s still routed to the drained server", key)
+		case before[key] == drained:
+			moved[after[key]] = true
+		case after[key] != before[key]:
+			t.Errorf("%s moved from %s to %s, though its server is not drained", key, before[key], after[key])
+		}
+	}
+	if len(moved) != 2 {
+		t.Errorf("the drained keys went to %d servers, want both others", len(moved))
+	}
+	if err := c.Set(&Item{Key: keys[0], Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if it, err := c.Get(keys[0]); err != nil || string(it.Value) != "v" {
+		t.Errorf("Get on a drained key's new server = %v, %v", it, err)
+	}
+	if idle := c.PoolStats()[drained].IdleConns; idle != 0 {
+		t.Errorf("drained server keeps %d idle connections", idle)
+	}
+
+	c.Undrain(drained)
+	for key, addr := range route() {
+		if addr != before[key] {
+			t.Errorf("after Undrain, %s routed to %s, want %s", key, addr, before[key])
+		}
+	}
+
+	if err := c.Drain("127.0.0.1:1"); err == nil {
+		t.Error("Drain of an unknown server succeeded")
+	}
+	for _, addr := range addrs {
+		if err := c.Drain(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := c.Get(keys[0])
+	var nse *NoServersError
+	if !errors.As(err, &nse) || !errors.Is(err, ErrNoServers) || len(nse.Reasons) != 3 || nse.Reasons[drained] != ErrDrained {
+		t.Errorf("Get with all servers drained: %v", err)
+	}
+}
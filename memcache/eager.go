@@ -0,0 +1,70 @@
+package memcache
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// openMinConns opens n connections to each server of the client, running
+// the same authentication and labeling as connections opened on first
+// use, and adds them to the idle pools. It fails only if no server could
+// be reached, with the error of one of them; servers that are merely down
+// are connected to lazily once they are back.
+func (c *Client) openMinConns(n int) error {
+	var addrs []net.Addr
+	c.eachServer(func(addr net.Addr) error {
+		addrs = append(addrs, addr)
+		return nil
+	})
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reached  int
+		firstErr error
+	)
+	for _, addr := range addrs {
+		addr := addr
+		wg.Add(1)
+		c.goFunc(func() {
+			defer wg.Done()
+			var err error
+			for i := 0; i < n && err == nil; i++ {
+				var cn *conn
+				if cn, err = c.getConn(addr, true); err == nil {
+					c.addIdleConn(cn)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				reached++
+			} else if firstErr == nil {
+				firstErr = err
+			}
+		})
+	}
+	wg.Wait()
+	if reached == 0 {
+		return fmt.Errorf("memcache: no server reachable: %v", firstErr)
+	}
+	return nil
+}
+
+// addIdleConn adds the new connection cn to the idle pool of its server.
+// Unlike putFreeConn, it does not count cn as recycled.
+func (c *Client) addIdleConn(cn *conn) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if c.freeconn == nil {
+		c.freeconn = make(map[string][]*conn)
+	}
+	addr := cn.addr.String()
+	cn.idleSince = time.Now()
+	c.freeconn[addr] = append(c.freeconn[addr], cn)
+}
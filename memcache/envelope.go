@@ -0,0 +1,99 @@
+package memcache
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrStaleSchema is returned by Envelope.Unmarshal for values written at a
+// schema version it has no migration for, with a codec it doesn't know,
+// or without an envelope. Helpers such as Memoize treat such values as
+// misses.
+var ErrStaleSchema = errors.New("memcache: cached value has an unknown schema")
+
+// envelopeMagic starts every envelope, telling enveloped values apart from
+// bare ones.
+const envelopeMagic = 0xE5
+
+// envelopeHeaderLen is the size of the magic byte, the big-endian schema
+// version and the codec id that precede the payload.
+const envelopeHeaderLen = 4
+
+// A Migration decodes payload, written at an older schema version with
+// codec, into v, which has the current layout. It typically decodes into
+// the old type and converts.
+type Migration func(payload []byte, codec Codec, v interface{}) error
+
+// Envelope is a Codec storing each value with the version of its schema
+// and the id of the codec that encoded it, so that applications can change
+// the layout of cached structs, or their codec, without old entries
+// failing to decode after a deploy. Old entries are upgraded on read by
+// the registered migrations, or else reported as ErrStaleSchema.
+//
+// RegisterCodec and RegisterMigration must be called before the Envelope
+// is used; it is then safe for concurrent use.
+type Envelope struct {
+	version    uint16
+	codecID    byte
+	codecs     map[byte]Codec
+	migrations map[uint16]Migration
+}
+
+// NewEnvelope returns an Envelope writing values at schema version with
+// codec, recorded as codecID.
+func NewEnvelope(version uint16, codecID byte, codec Codec) *Envelope {
+	return &Envelope{
+		version:    version,
+		codecID:    codecID,
+		codecs:     map[byte]Codec{codecID: codec},
+		migrations: make(map[uint16]Migration),
+	}
+}
+
+// RegisterCodec lets the envelope read values encoded by codec, recorded
+// as id, such as the codec used before a switch.
+func (e *Envelope) RegisterCodec(id byte, codec Codec) {
+	e.codecs[id] = codec
+}
+
+// RegisterMigration makes the envelope read the values written at schema
+// version from with m.
+func (e *Envelope) RegisterMigration(from uint16, m Migration) {
+	e.migrations[from] = m
+}
+
+// Marshal encodes v with the envelope's codec and wraps it.
+func (e *Envelope) Marshal(v interface{}) ([]byte, error) {
+	payload, err := e.codecs[e.codecID].Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, envelopeHeaderLen+len(payload))
+	data[0] = envelopeMagic
+	binary.BigEndian.PutUint16(data[1:], e.version)
+	data[3] = e.codecID
+	copy(data[envelopeHeaderLen:], payload)
+	return data, nil
+}
+
+// Unmarshal unwraps data and decodes it into v, migrating it if it was
+// written at an older schema version.
+func (e *Envelope) Unmarshal(data []byte, v interface{}) error {
+	if len(data) < envelopeHeaderLen || data[0] != envelopeMagic {
+		return ErrStaleSchema
+	}
+	version := binary.BigEndian.Uint16(data[1:])
+	codec, ok := e.codecs[data[3]]
+	if !ok {
+		return ErrStaleSchema
+	}
+	payload := data[envelopeHeaderLen:]
+	if version == e.version {
+		return codec.Unmarshal(payload, v)
+	}
+	m, ok := e.migrations[version]
+	if !ok {
+		return ErrStaleSchema
+	}
+	return m(payload, codec, v)
+}
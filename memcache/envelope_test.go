@@ -0,0 +1,85 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+type userV1 struct{ Name string }
+
+type userV2 struct{ First, Last string }
+
+func TestEnvelope(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+	ctx := context.Background()
+
+	v1 := NewEnvelope(1, 'j', JSONCodec)
+	c.SetCodec(v1)
+	var u1 userV1
+	if err := c.Memoize(ctx, "user", 0, &u1, func(context.Context) (interface{}, error) {
+		return userV1{"Ada Lovelace"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A deploy changes the layout and migrates version 1 entries.
+	v2 := NewEnvelope(2, 'j', JSONCodec)
+	v2.RegisterMigration(1, func(payload []byte, codec Codec, v interface{}) error {
+		var old userV1
+		if err := codec.Unmarshal(payload, &old); err != nil {
+			return err
+		}
+		u := v.(*userV2)
+		u.First, u.Last = "Ada", old.Name[len("Ada "):]
+		return nil
+	})
+	c.SetCodec(v2)
+	var u2 userV2
+	if err := c.Memoize(ctx, "user", 0, &u2, func(context.Context) (interface{}, error) {
+		t.Error("migrated entry was recomputed")
+		return userV2{}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if u2 != (userV2{"Ada", "Lovelace"}) {
+		t.Errorf("migrated value = %+v", u2)
+	}
+
+	// Without a migration, old entries are misses.
+	c.SetCodec(NewEnvelope(3, 'j', JSONCodec))
+	called := false
+	if err := c.Memoize(ctx, "user", 0, &u2, func(context.Context) (interface{}, error) {
+		called = true
+		return userV2{"Grace", "Hopper"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !called || u2 != (userV2{"Grace", "Hopper"}) {
+		t.Errorf("stale entry was not recomputed: called %v, value %+v", called, u2)
+	}
+}
+
+func TestEnvelopeCodecs(t *testing.T) {
+	e := NewEnvelope(1, 'j', JSONCodec)
+	data, err := e.Marshal(userV1{"x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := NewEnvelope(1, 'g', JSONCodec)
+	var u userV1
+	if err := other.Unmarshal(data, &u); err != ErrStaleSchema {
+		t.Errorf("Unmarshal with an unknown codec error = %v, want ErrStaleSchema", err)
+	}
+	other.RegisterCodec('j', JSONCodec)
+	if err := other.Unmarshal(data, &u); err != nil || u.Name != "x" {
+		t.Errorf("Unmarshal with a registered codec = %+v, %v", u, err)
+	}
+	if err := e.Unmarshal([]byte(`{"Name":"x"}`), &u); err != ErrStaleSchema {
+		t.Errorf("Unmarshal of a bare value error = %v, want ErrStaleSchema", err)
+	}
+}
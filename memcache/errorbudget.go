@@ -0,0 +1,178 @@
+package memcache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrorBudget disables the cache for a while when too many operations
+// fail with a network error or a timeout. A sick cache that times out
+// slows every request down, while no cache only costs the backend the
+// misses: once disabled, the keyed reads of the client fail at once with
+// ErrCacheMiss, without contacting a server, GetMulti returns no items,
+// and after Cooldown the client tries the servers again. Writes and
+// deletes fail with ErrCacheDisabled instead, so that callers don't take
+// a dropped invalidation for a key already gone.
+// Operations addressing every server, such as FlushAll and Stats, are
+// not affected.
+type ErrorBudget struct {
+	// MaxErrorRate is the fraction of the operations over the last
+	// Window that may fail before the cache is disabled. Zero turns the
+	// budget off.
+	MaxErrorRate float64
+
+	// Window is the period, in one-second buckets, the error rate is
+	// computed over, and MinOps the number of operations it must hold for
+	// the rate to be considered. If zero, DefaultErrorWindow and
+	// DefaultErrorMinOps are used.
+	Window time.Duration
+	MinOps int
+
+	// Cooldown is how long the cache stays disabled. If zero,
+	// DefaultErrorCooldown is used.
+	Cooldown time.Duration
+
+	// OnDisable, if set, is called with the error rate when the cache is
+	// disabled, and OnEnable when it is enabled again.
+	OnDisable func(rate float64)
+	OnEnable  func()
+}
+
+// Defaults of the ErrorBudget settings.
+const (
+	DefaultErrorWindow   = 10 * time.Second
+	DefaultErrorMinOps   = 100
+	DefaultErrorCooldown = 30 * time.Second
+)
+
+func (b ErrorBudget) validate() error {
+	switch {
+	case b.MaxErrorRate < 0 || b.MaxErrorRate >= 1:
+		return configError("ErrorBudget.MaxErrorRate %v is not in [0, 1)", b.MaxErrorRate)
+	case b.Window < 0:
+		return configError("negative ErrorBudget.Window %v", b.Window)
+	case b.MinOps < 0:
+		return configError("negative ErrorBudget.MinOps %d", b.MinOps)
+	case b.Cooldown < 0:
+		return configError("negative ErrorBudget.Cooldown %v", b.Cooldown)
+	}
+	return nil
+}
+
+// ErrCacheDisabled is returned by the keyed writes and deletes, such as
+// Set and Delete, while the cache is disabled by Config.ErrorBudget.
+var ErrCacheDisabled = errors.New("memcache: cache disabled by its error budget")
+
+// shortCircuitError returns the error of op while the cache is disabled.
+func shortCircuitError(op string) error {
+	switch op {
+	case "get", "gat":
+		return ErrCacheMiss
+	}
+	return ErrCacheDisabled
+}
+
+// errorBreaker tracks the error rate of a client against its ErrorBudget.
+type errorBreaker struct {
+	budget ErrorBudget
+
+	disabled int32 // read atomically to skip the lock when zero
+
+	mu      sync.Mutex
+	buckets []errorBucket
+	until   time.Time
+}
+
+type errorBucket struct {
+	sec       int64
+	ops, errs int
+}
+
+func newErrorBreaker(b ErrorBudget) *errorBreaker {
+	if b.Window == 0 {
+		b.Window = DefaultErrorWindow
+	}
+	if b.MinOps == 0 {
+		b.MinOps = DefaultErrorMinOps
+	}
+	if b.Cooldown == 0 {
+		b.Cooldown = DefaultErrorCooldown
+	}
+	n := int((b.Window + time.Second - 1) / time.Second)
+	return &errorBreaker{budget: b, buckets: make([]errorBucket, n)}
+}
+
+// observe accounts for the result err of an operation at now, and
+// disables the cache if the budget is spent.
+func (b *errorBreaker) observe(err error, now time.Time) {
+	if atomic.LoadInt32(&b.disabled) != 0 {
+		return
+	}
+	b.mu.Lock()
+	sec := now.Unix()
+	bk := &b.buckets[int(sec%int64(len(b.buckets)))]
+	if bk.sec != sec {
+		*bk = errorBucket{sec: sec}
+	}
+	bk.ops++
+	if isNetworkError(err) {
+		bk.errs++
+	}
+	var ops, errs int
+	for _, bk := range b.buckets {
+		if bk.sec > sec-int64(len(b.buckets)) {
+			ops += bk.ops
+			errs += bk.errs
+		}
+	}
+	rate := float64(errs) / float64(ops)
+	disable := ops >= b.budget.MinOps && rate > b.budget.MaxErrorRate
+	if disable {
+		b.until = now.Add(b.budget.Cooldown)
+		atomic.StoreInt32(&b.disabled, 1)
+	}
+	b.mu.Unlock()
+
+	if disable && b.budget.OnDisable != nil {
+		b.budget.OnDisable(rate)
+	}
+}
+
+// tripped reports whether the cache is disabled at now, enabling it
+// again with a clean slate once the cooldown is over.
+func (b *errorBreaker) tripped(now time.Time) bool {
+	if atomic.LoadInt32(&b.disabled) == 0 {
+		return false
+	}
+	b.mu.Lock()
+	if now.Before(b.until) {
+		b.mu.Unlock()
+		return true
+	}
+	enable := atomic.CompareAndSwapInt32(&b.disabled, 1, 0)
+	if enable {
+		for i := range b.buckets {
+			b.buckets[i] = errorBucket{}
+		}
+	}
+	b.mu.Unlock()
+
+	if enable && b.budget.OnEnable != nil {
+		b.budget.OnEnable()
+	}
+	return false
+}
+
+// Disabled reports whether the client has disabled the cache for
+// exceeding its Config.ErrorBudget.
+func (c *Client) Disabled() bool {
+	return c.shortCircuit()
+}
+
+// shortCircuit reports whether keyed operations should fail at once, as
+// shortCircuitError says.
+func (c *Client) shortCircuit() bool {
+	return c.errorBreaker != nil && c.errorBreaker.tripped(c.now())
+}
@@ -0,0 +1,80 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestErrorBudget(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	addr := s.Addr()
+	s.Close()
+	clock := memcachetest.NewFakeClock(time.Unix(1e9, 0))
+	var disabled, enabled int
+	c, err := NewClient([]string{addr}, WithClock(clock), WithErrorBudget(ErrorBudget{
+		MaxErrorRate: 0.5,
+		MinOps:       3,
+		Cooldown:     time.Minute,
+		OnDisable:    func(float64) { disabled++ },
+		OnEnable:     func() { enabled++ },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get("k"); err == nil || err == ErrCacheMiss {
+			t.Fatalf("Get %d on a down server error = %v, want a network error", i, err)
+		}
+	}
+	if !c.Disabled() || disabled != 1 {
+		t.Fatalf("Disabled() = %v after %d disables, want the budget spent", c.Disabled(), disabled)
+	}
+	if _, err := c.Get("k"); err != ErrCacheMiss {
+		t.Errorf("Get on the disabled cache error = %v, want ErrCacheMiss", err)
+	}
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != ErrCacheDisabled {
+		t.Errorf("Set on the disabled cache error = %v, want ErrCacheDisabled", err)
+	}
+	if err := c.Delete("k"); err != ErrCacheDisabled {
+		t.Errorf("Delete on the disabled cache error = %v, want ErrCacheDisabled", err)
+	}
+	if err := c.DeleteMulti([]string{"a", "b"}); err != ErrCacheDisabled {
+		t.Errorf("DeleteMulti on the disabled cache error = %v, want ErrCacheDisabled", err)
+	}
+	if m, err := c.GetMulti([]string{"a", "b"}); err != nil || len(m) != 0 {
+		t.Errorf("GetMulti on the disabled cache = %v, %v, want no items", m, err)
+	}
+
+	clock.Advance(time.Minute)
+	if c.Disabled() || enabled != 1 {
+		t.Errorf("Disabled() = %v after the cooldown with %d enables, want enabled", c.Disabled(), enabled)
+	}
+	if _, err := c.Get("k"); err == nil || err == ErrCacheMiss {
+		t.Errorf("Get after the cooldown error = %v, want a network error", err)
+	}
+	if c.Disabled() {
+		t.Error("disabled again before MinOps operations")
+	}
+}
+
+func TestErrorBudgetHealthy(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithErrorBudget(ErrorBudget{MaxErrorRate: 0.1, MinOps: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := c.Get("k"); err != ErrCacheMiss {
+			t.Fatalf("Get error = %v, want ErrCacheMiss", err)
+		}
+	}
+	if c.Disabled() {
+		t.Error("misses disabled the cache")
+	}
+	if _, err := NewClient([]string{s.Addr()}, WithErrorBudget(ErrorBudget{MaxErrorRate: 1})); err == nil {
+		t.Error("MaxErrorRate of 1 accepted")
+	}
+}
@@ -0,0 +1,172 @@
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TTL returns how long the item stored under key has left to live, or a
+// negative duration if it doesn't expire. ErrCacheMiss is returned if
+// there is no such item. It uses a meta get, which needs memcached 1.6 or
+// later, and is only supported by the text protocol: otherwise it fails
+// with a *NotSupportedError.
+func (c *Client) TTL(key string) (time.Duration, error) {
+	if err := c.checkProto("ttl"); err != nil {
+		return 0, err
+	}
+	var ttl time.Duration
+	err := c.withKeyAddr("get", key, func(addr net.Addr, skey string) error {
+		return c.withCapableRw(addr, "ttl", func(rw *bufio.ReadWriter) (err error) {
+			ttl, err = c.metaTTL(rw, skey)
+			return err
+		})
+	})
+	return ttl, err
+}
+
+// metaGetter is implemented by the commander of the text protocol, for
+// the meta gets of TTL and LocalCache.
+type metaGetter interface {
+	MetaGet(rw *bufio.ReadWriter, key string, flags ...string) (map[byte]string, error)
+}
+
+// metaGet runs a meta get of key with flags, which only the text protocol
+// supports.
+func (c *Client) metaGet(rw *bufio.ReadWriter, key string, flags ...string) (map[byte]string, error) {
+	mg, ok := c.cmdRunner.(metaGetter)
+	if !ok {
+		return nil, ErrUnknownCommand
+	}
+	return mg.MetaGet(rw, key, flags...)
+}
+
+// metaTTL asks for the remaining TTL of key with "mg <key> t".
+func (c *Client) metaTTL(rw *bufio.ReadWriter, key string) (time.Duration, error) {
+	f, err := c.metaGet(rw, key, "t")
+	if err != nil {
+		return 0, err
+	}
+	tok, ok := f['t']
+	if !ok {
+		return 0, fmt.Errorf("memcache: no TTL in the mg response to %q", key)
+	}
+	secs, err := strconv.ParseInt(tok, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("memcache: malformed TTL %q", tok)
+	}
+	if secs < 0 {
+		return -1, nil
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+// ExpiryWatcher polls the remaining TTL of a set of keys and calls a
+// function for those about to expire, so that critical keys can be
+// refreshed before they lapse rather than after a miss. Keys found
+// missing are reported as well, with a zero duration. A key is reported
+// at every poll until it is refreshed or unwatched.
+type ExpiryWatcher struct {
+	client *Client
+	lead   time.Duration
+	fn     func(key string, left time.Duration)
+
+	mu   sync.Mutex
+	keys map[string]bool
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// DefaultExpiryInterval is the interval of the ExpiryWatchers created
+// with none.
+const DefaultExpiryInterval = 10 * time.Second
+
+// NewExpiryWatcher returns a watcher checking the keys it watches through
+// c every interval, DefaultExpiryInterval if it is not positive, and
+// calling fn, one key at a time, with those having lead or less left to
+// live. Close must be called to stop it.
+func NewExpiryWatcher(c *Client, lead, interval time.Duration, fn func(key string, left time.Duration)) *ExpiryWatcher {
+	if interval <= 0 {
+		interval = DefaultExpiryInterval
+	}
+	w := &ExpiryWatcher{
+		client: c,
+		lead:   lead,
+		fn:     fn,
+		keys:   make(map[string]bool),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	c.goFunc(func() { w.run(interval) })
+	return w
+}
+
+// Watch adds keys to the watched ones.
+func (w *ExpiryWatcher) Watch(keys ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, key := range keys {
+		w.keys[key] = true
+	}
+}
+
+// Unwatch removes keys from the watched ones.
+func (w *ExpiryWatcher) Unwatch(keys ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, key := range keys {
+		delete(w.keys, key)
+	}
+}
+
+// Close stops the watcher, waiting for a poll in progress to finish. It
+// may be called several times, concurrently.
+func (w *ExpiryWatcher) Close() error {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+	return nil
+}
+
+func (w *ExpiryWatcher) run(interval time.Duration) {
+	defer close(w.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+			w.poll()
+		}
+	}
+}
+
+// poll checks every watched key once. Keys whose TTL can't be read are
+// skipped until the next poll.
+func (w *ExpiryWatcher) poll() {
+	w.mu.Lock()
+	keys := make([]string, 0, len(w.keys))
+	for key := range w.keys {
+		keys = append(keys, key)
+	}
+	w.mu.Unlock()
+	for _, key := range keys {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+		left, err := w.client.TTL(key)
+		switch {
+		case err == ErrCacheMiss:
+			w.fn(key, 0)
+		case err == nil && left >= 0 && left <= w.lead:
+			w.fn(key, left)
+		}
+	}
+}
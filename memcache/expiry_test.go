@@ -0,0 +1,96 @@
+package memcache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestTTL(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	if err := c.Set(&Item{Key: "exp", Value: []byte("v"), Expiration: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(&Item{Key: "forever", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := c.TTL("exp"); err != nil || ttl <= 55*time.Second || ttl > time.Minute {
+		t.Errorf("TTL(exp) = %v, %v, want about a minute", ttl, err)
+	}
+	if ttl, err := c.TTL("forever"); err != nil || ttl >= 0 {
+		t.Errorf("TTL(forever) = %v, %v, want a negative duration", ttl, err)
+	}
+	if _, err := c.TTL("absent"); err != ErrCacheMiss {
+		t.Errorf("TTL(absent) error = %v, want ErrCacheMiss", err)
+	}
+	if _, err := NewBinary(s.Addr()).TTL("exp"); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("TTL over the binary protocol error = %v, want ErrUnknownCommand", err)
+	}
+}
+
+func TestExpiryWatcher(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	for key, exp := range map[string]int32{"soon": 5, "later": 3600, "forever": 0} {
+		if err := c.Set(&Item{Key: key, Value: []byte("v"), Expiration: exp}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	reported := make(map[string]time.Duration)
+	w := NewExpiryWatcher(c, time.Minute, 10*time.Millisecond, func(key string, left time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported[key] = left
+	})
+	defer w.Close()
+	w.Watch("soon", "later", "forever", "gone")
+	waitFor(t, "near-expiry reports", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reported) >= 2
+	})
+	w.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if left, ok := reported["soon"]; !ok || left <= 0 || left > 5*time.Second {
+		t.Errorf("soon reported with %v, %v, want up to 5s left", left, ok)
+	}
+	if left, ok := reported["gone"]; !ok || left != 0 {
+		t.Errorf("gone reported with %v, %v, want a zero duration", left, ok)
+	}
+	if _, ok := reported["later"]; ok {
+		t.Error("a key far from expiry was reported")
+	}
+	if _, ok := reported["forever"]; ok {
+		t.Error("a key without expiration was reported")
+	}
+}
+
+func TestExpiryWatcherClose(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+
+	// A watcher without an interval polls at the default one.
+	w := NewExpiryWatcher(c, time.Minute, 0, func(string, time.Duration) {})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Close()
+		}()
+	}
+	wg.Wait()
+	w.Close()
+}
@@ -0,0 +1,50 @@
+package memcache
+
+import "net"
+
+// KeyRoute describes where GetMulti would fetch a key from.
+type KeyRoute struct {
+	Key     string // the key as given
+	SentKey string // the key as sent, after the policy and key encoding
+	Server  net.Addr
+
+	// Hash is the hash of SentKey that chose Server, among the undrained
+	// servers if its own is drained, if the key's selector is a
+	// ServerList. HasHash is false for other selectors,
+	// whose hashing is their own.
+	Hash    uint32
+	HasHash bool
+}
+
+// ExplainGetMulti returns how GetMulti would partition keys between the
+// servers, without contacting them: the route of each key, grouped by
+// server address in the order of keys. It helps understanding and testing
+// the distribution of keys, such as which keys make a shard hot.
+//
+// The keys go through the policy, validation and key encoding as they
+// would for GetMulti, and the first key those reject fails the call.
+// Unlike operations, ExplainGetMulti does not wait for servers when a
+// selector has none.
+func (c *Client) ExplainGetMulti(keys []string) (map[string][]KeyRoute, error) {
+	plan := make(map[string][]KeyRoute)
+	for _, key := range keys {
+		skey, err := c.prepareKey("get", key)
+		if err != nil {
+			return nil, err
+		}
+		ss := c.selectorFor(key)
+		addr, err := ss.PickServer(skey)
+		if err == nil {
+			addr, err = c.undrained(ss, addr, skey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		r := KeyRoute{Key: key, SentKey: skey, Server: addr}
+		if sl, ok := ss.(*ServerList); ok {
+			r.Hash, r.HasHash = sl.hashKey(skey), true
+		}
+		plan[addr.String()] = append(plan[addr.String()], r)
+	}
+	return plan, nil
+}
@@ -0,0 +1,49 @@
+package memcache
+
+import (
+	"hash/crc32"
+	"testing"
+)
+
+func TestExplainGetMulti(t *testing.T) {
+	addrs := []string{"127.0.0.1:1", "127.0.0.1:2", "127.0.0.1:3"}
+	c, err := NewClient(addrs, WithKeyEncoding(KeyEncodingPercent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"a", "b b", "c", "d", "e", "f"}
+	plan, err := c.ExplainGetMulti(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for server, routes := range plan {
+		for _, r := range routes {
+			n++
+			if r.Server.String() != server {
+				t.Errorf("key %q routed to %s is listed under %s", r.Key, r.Server, server)
+			}
+			if !r.HasHash || r.Hash != crc32.ChecksumIEEE([]byte(r.SentKey)) {
+				t.Errorf("key %q hash = %d, %v, want the CRC-32 of %q", r.Key, r.Hash, r.HasHash, r.SentKey)
+			}
+			if want := addrs[r.Hash%3]; server != want {
+				t.Errorf("key %q is planned on %s, want %s", r.Key, server, want)
+			}
+			addr, _ := c.selector.PickServer(r.SentKey)
+			if addr.String() != server {
+				t.Errorf("key %q is planned on %s, but GetMulti would use %s", r.Key, server, addr)
+			}
+			if r.Key == "b b" && r.SentKey != "b%20b" {
+				t.Errorf("key %q was planned as sent as %q, want the encoded key", r.Key, r.SentKey)
+			}
+		}
+	}
+	if n != len(keys) {
+		t.Errorf("plan has %d keys, want %d", n, len(keys))
+	}
+
+	if _, err := c.ExplainGetMulti([]string{string(make([]byte, 300))}); err == nil {
+		t.Error("ExplainGetMulti accepted an illegal key")
+	}
+}
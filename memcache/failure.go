@@ -0,0 +1,183 @@
+package memcache
+
+import (
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrEjected is the reason reported in a NoServersError for the servers
+// that Config.FailureDetector suspects to be down.
+var ErrEjected = errors.New("memcache: server ejected by the failure detector")
+
+// A FailureDetector decides which servers are taken out of rotation, as
+// by Client.Drain, for appearing to be down. The client reports the
+// outcome of each operation on a server, err being nil unless the server
+// could not be reached or failed to answer, and consults Suspect when
+// picking the server of a key. The keys of suspected servers are spread
+// over the others.
+//
+// Since suspected servers receive no operations, a detector should stop
+// suspecting them now and then to let an operation find out whether they
+// are back. A FailureDetector must be safe for concurrent use.
+type FailureDetector interface {
+	Report(addr string, err error, now time.Time)
+	Suspect(addr string, now time.Time) bool
+}
+
+// PhiAccrualDetector is a FailureDetector implementing the phi accrual
+// failure detector of Hayashibara et al. Rather than ejecting a server
+// after a number of failures, it learns the usual interval between the
+// successful operations on each server and, once an operation fails,
+// suspects the server when the silence since the last success becomes
+// too unlikely under that distribution. A server is thus ejected quickly
+// when its traffic stops abruptly, but not for a late answer on a
+// jittery network.
+//
+// Its fields must be set before use.
+type PhiAccrualDetector struct {
+	// Threshold is the suspicion level phi, the negated decimal
+	// logarithm of the probability that the server is merely late, above
+	// which it is suspected.
+	Threshold float64
+
+	// WindowSize is the number of intervals between successes the
+	// distribution is learned from.
+	WindowSize int
+
+	// MinStdDev is the smallest standard deviation of the intervals
+	// assumed, so that a server answering at a steady pace isn't ejected
+	// for a small delay.
+	MinStdDev time.Duration
+
+	// RetryInterval is how often a suspected server is let through for
+	// one operation to check whether it is back.
+	RetryInterval time.Duration
+
+	mu      sync.Mutex
+	servers map[string]*phiState
+}
+
+// phiState is what a PhiAccrualDetector knows of a server.
+type phiState struct {
+	last      time.Time       // the last success, or first report
+	intervals []time.Duration // between successes, a ring of WindowSize
+	next      int             // the next slot of intervals to overwrite
+	failing   bool            // an operation failed since the last success
+	suspected bool
+	lastProbe time.Time
+}
+
+// Defaults of the PhiAccrualDetector settings.
+const (
+	DefaultPhiWindowSize    = 100
+	DefaultPhiMinStdDev     = 50 * time.Millisecond
+	DefaultPhiRetryInterval = time.Second
+)
+
+// NewPhiAccrualDetector returns a detector suspecting servers at the
+// given phi threshold, 8 being a common choice, with the default settings.
+func NewPhiAccrualDetector(threshold float64) *PhiAccrualDetector {
+	return &PhiAccrualDetector{
+		Threshold:     threshold,
+		WindowSize:    DefaultPhiWindowSize,
+		MinStdDev:     DefaultPhiMinStdDev,
+		RetryInterval: DefaultPhiRetryInterval,
+	}
+}
+
+// Report records the outcome of an operation on addr.
+func (d *PhiAccrualDetector) Report(addr string, err error, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.servers == nil {
+		d.servers = make(map[string]*phiState)
+	}
+	st := d.servers[addr]
+	if st == nil {
+		d.servers[addr] = &phiState{last: now, failing: err != nil}
+		return
+	}
+	if err != nil {
+		st.failing = true
+		return
+	}
+	if !st.failing {
+		if len(st.intervals) < d.WindowSize {
+			st.intervals = append(st.intervals, now.Sub(st.last))
+		} else if len(st.intervals) > 0 {
+			st.intervals[st.next] = now.Sub(st.last)
+			st.next = (st.next + 1) % len(st.intervals)
+		}
+	}
+	st.last, st.failing, st.suspected = now, false, false
+}
+
+// Suspect reports whether addr is suspected to be down. Once every
+// RetryInterval, a suspected server is reported as up for one call.
+func (d *PhiAccrualDetector) Suspect(addr string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := d.servers[addr]
+	if st == nil || !st.failing || d.phi(st, now) <= d.Threshold {
+		return false
+	}
+	if !st.suspected {
+		st.suspected, st.lastProbe = true, now
+		return true
+	}
+	if now.Sub(st.lastProbe) >= d.RetryInterval {
+		st.lastProbe = now
+		return false
+	}
+	return true
+}
+
+// phi returns the suspicion level of the server of st at now.
+func (d *PhiAccrualDetector) phi(st *phiState, now time.Time) float64 {
+	var mean, variance float64
+	if n := float64(len(st.intervals)); n > 0 {
+		for _, iv := range st.intervals {
+			mean += float64(iv)
+		}
+		mean /= n
+		for _, iv := range st.intervals {
+			variance += (float64(iv) - mean) * (float64(iv) - mean)
+		}
+		variance /= n
+	}
+	stddev := math.Max(math.Sqrt(variance), float64(d.MinStdDev))
+	elapsed := float64(now.Sub(st.last))
+
+	// The logistic approximation of the normal distribution's CDF used by
+	// Akka's detector.
+	y := (elapsed - mean) / stddev
+	e := math.Exp(-y * (1.5976 + 0.070566*y*y))
+	if elapsed > mean {
+		return -math.Log10(e / (1 + e))
+	}
+	return -math.Log10(1 - 1/(1+e))
+}
+
+// reportOutcome tells Config.FailureDetector the outcome err of an
+// operation on addr. Waiting for a pooled connection says nothing of the
+// server and isn't reported.
+func (c *Client) reportOutcome(addr net.Addr, err error) {
+	if c.failureDetector == nil {
+		return
+	}
+	if te, ok := err.(*TimeoutError); ok && te.Phase == PhasePoolWait {
+		return
+	}
+	if err != nil && !isNetworkError(err) {
+		err = nil // the server answered
+	}
+	c.failureDetector.Report(addr.String(), err, c.now())
+}
+
+// isEjected reports whether Config.FailureDetector suspects addr.
+func (c *Client) isEjected(addr string) bool {
+	return c.failureDetector != nil && c.failureDetector.Suspect(addr, c.now())
+}
@@ -0,0 +1,126 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestPhiAccrualDetector(t *testing.T) {
+	d := NewPhiAccrualDetector(8)
+	now := time.Unix(1e9, 0)
+	for i := 0; i < 50; i++ {
+		d.Report("a", nil, now)
+		now = now.Add(10 * time.Millisecond)
+	}
+	if d.Suspect("a", now.Add(time.Hour)) {
+		t.Error("a server without failures is suspected")
+	}
+	d.Report("a", errors.New("timeout"), now)
+	if d.Suspect("a", now.Add(100*time.Millisecond)) {
+		t.Error("suspected after a short silence")
+	}
+	now = now.Add(2 * time.Second)
+	if !d.Suspect("a", now) {
+		t.Fatal("not suspected after a long silence")
+	}
+	if !d.Suspect("a", now.Add(500*time.Millisecond)) {
+		t.Error("probe let through before RetryInterval")
+	}
+	now = now.Add(time.Second)
+	if d.Suspect("a", now) {
+		t.Error("no probe let through after RetryInterval")
+	}
+	if !d.Suspect("a", now) {
+		t.Error("more than one probe let through")
+	}
+	d.Report("a", nil, now)
+	if d.Suspect("a", now.Add(time.Hour)) {
+		t.Error("suspected after a success")
+	}
+}
+
+func TestPhiAccrualJitter(t *testing.T) {
+	d := NewPhiAccrualDetector(8)
+	now := time.Unix(1e9, 0)
+	for i := 0; i < 100; i++ {
+		d.Report("a", nil, now)
+		now = now.Add(time.Duration(50+i%5*100) * time.Millisecond)
+	}
+	// With intervals up to 450ms, a silence of that order is ordinary.
+	d.Report("a", errors.New("timeout"), now)
+	if d.Suspect("a", now.Add(500*time.Millisecond)) {
+		t.Error("a jittery server is suspected within its usual intervals")
+	}
+	if !d.Suspect("a", now.Add(5*time.Second)) {
+		t.Error("a jittery server is not suspected after a long silence")
+	}
+}
+
+// lastFailure suspects the servers whose last operation failed.
+type lastFailure struct {
+	mu     sync.Mutex
+	failed map[string]bool
+}
+
+func (d *lastFailure) Report(addr string, err error, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failed[addr] = err != nil
+}
+
+func (d *lastFailure) Suspect(addr string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.failed[addr]
+}
+
+func TestFailureDetectorEjects(t *testing.T) {
+	up, down := memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer up.Close()
+	downAddr := down.Addr()
+	down.Close()
+
+	fd := &lastFailure{failed: make(map[string]bool)}
+	ss := new(ServerList)
+	if err := ss.SetServers(up.Addr(), downAddr); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(nil, WithSelector(ss), WithFailureDetector(fd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := ""
+	for i := 0; key == ""; i++ {
+		k := fmt.Sprint("k", i)
+		if addr, _ := ss.PickServer(k); addr.String() == downAddr {
+			key = k
+		}
+	}
+	if err := c.Set(&Item{Key: key, Value: []byte("v")}); err == nil {
+		t.Fatal("Set on a down server succeeded")
+	}
+	if !fd.Suspect(downAddr, time.Now()) {
+		t.Fatal("the failure was not reported")
+	}
+	if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+		t.Fatalf("Set after the server was ejected: %v", err)
+	}
+	if _, err := New(up.Addr()).Get(key); err != nil {
+		t.Errorf("key of the ejected server not moved to the other: %v", err)
+	}
+	if fd.Suspect(up.Addr(), time.Now()) {
+		t.Error("the server answering is suspected")
+	}
+
+	fd.Report(up.Addr(), errors.New("down"), time.Now())
+	_, err = c.Get(key)
+	nse, ok := err.(*NoServersError)
+	if !ok || nse.Reasons[downAddr] != ErrEjected {
+		t.Errorf("Get with every server ejected error = %v, want a *NoServersError with ErrEjected", err)
+	}
+}
@@ -0,0 +1,139 @@
+package memcache
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+)
+
+// A CallOption adjusts a single call.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	fallback    bool
+	parallelism int
+
+	warmRate      RateLimit
+	progressEvery int
+	progress      func(WarmStats)
+}
+
+// FallbackOnError makes a read that fails with a timeout or a network
+// error retry once on the next server of the selector, if the context
+// still allows it. The other server rarely holds the item, so this turns
+// the errors of a browned-out server into misses while it recovers.
+func FallbackOnError() CallOption {
+	return func(o *callOptions) { o.fallback = true }
+}
+
+// GetContext is like Get, but gives up when ctx is done and accepts
+// per-call options. Under WithRequestMemo, results already fetched within
+// the request are returned without contacting the server, and under
+// WithKeyRewrite, the rewritten key is fetched.
+func (c *Client) GetContext(ctx context.Context, key string, opts ...CallOption) (item *Item, err error) {
+	caller := key
+	key = rewriteKey(ctx, key)
+	memo := requestMemoFrom(ctx)
+	if memo != nil {
+		if item, ok := memo.get(c, key); ok {
+			if item == nil {
+				return nil, ErrCacheMiss
+			}
+			item.Key = caller
+			return item, nil
+		}
+	}
+	var server net.Addr
+	defer c.traceContext(ctx, "get", key, time.Now(), &server, &err)
+	if c.override(key).Miss {
+		return nil, ErrCacheMiss
+	}
+	c.mirrorGets([]string{key})
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	err = c.withKeyAddr("get", key, func(addr net.Addr, skey string) error {
+		server = addr
+		item, err = c.getContext(ctx, addr, skey)
+		if err == nil || !o.fallback || !isNetworkError(err) || ctx.Err() != nil {
+			return err
+		}
+		next, ok := nextServer(c.selectorFor(key), addr)
+		if !ok {
+			return err
+		}
+		server = next
+		item, err = c.getContext(ctx, next, skey)
+		return err
+	})
+	if err == nil && item == nil {
+		err = ErrCacheMiss
+	}
+	if err != nil && ctx.Err() == nil {
+		item, err = c.standbyGet(key, err)
+	}
+	if item != nil {
+		item.Key = caller
+	}
+	if memo != nil && (err == nil || err == ErrCacheMiss) {
+		memo.put(c, key, item)
+	}
+	return item, err
+}
+
+// getContext gets key from addr, giving up when ctx is done. The fetch
+// then finishes in the background within the socket timeout.
+func (c *Client) getContext(ctx context.Context, addr net.Addr, key string) (*Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type result struct {
+		item *Item
+		err  error
+	}
+	ch := make(chan result, 1)
+	c.goFunc(func() {
+		var r result
+		r.err = c.getFromAddr(addr, []string{key}, func(it *Item) { r.item = it })
+		ch <- r
+	})
+	select {
+	case r := <-ch:
+		return r.item, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// nextServer returns the server following addr among the servers of ss,
+// if there is another one.
+func nextServer(ss ServerSelector, addr net.Addr) (net.Addr, bool) {
+	var addrs []net.Addr
+	ss.Each(func(a net.Addr) error {
+		addrs = append(addrs, a)
+		return nil
+	})
+	for i, a := range addrs {
+		if a.String() != addr.String() {
+			continue
+		}
+		for j := 1; j < len(addrs); j++ {
+			if next := addrs[(i+j)%len(addrs)]; next.String() != addr.String() {
+				return next, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// isNetworkError reports whether err comes from the connection to a server
+// rather than from the server's answer.
+func isNetworkError(err error) bool {
+	switch err.(type) {
+	case net.Error, *ConnectTimeoutError:
+		return true
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,58 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestGetContextFallbackOnError(t *testing.T) {
+	down, up := memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer up.Close()
+	c := New(down.Addr(), up.Addr())
+	defer c.Close()
+
+	var key string
+	for i := 0; key == ""; i++ {
+		addr, err := c.selector.PickServer(fmt.Sprint("key-", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr.String() == down.Addr() {
+			key = fmt.Sprint("key-", i)
+		}
+	}
+	if err := New(up.Addr()).Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	down.Close()
+
+	ctx := context.Background()
+	if _, err := c.GetContext(ctx, key); !isNetworkError(err) {
+		t.Errorf("GetContext without fallback error = %v, want a network error", err)
+	}
+	it, err := c.GetContext(ctx, key, FallbackOnError())
+	if err != nil || string(it.Value) != "v" {
+		t.Errorf("GetContext with fallback = %v, %v, want the item of the other server", it, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.GetContext(canceled, key, FallbackOnError()); err != context.Canceled {
+		t.Errorf("GetContext with a canceled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestNextServer(t *testing.T) {
+	c := New("127.0.0.1:1", "127.0.0.1:2", "127.0.0.1:1")
+	addr, _ := c.selector.PickServer("k")
+	next, ok := nextServer(c.selector, addr)
+	if !ok || next.String() == addr.String() {
+		t.Errorf("nextServer(%v) = %v, %v, want another server", addr, next, ok)
+	}
+	if _, ok := nextServer(New("127.0.0.1:1").selector, addr); ok {
+		t.Error("nextServer found another server in a single server list")
+	}
+}
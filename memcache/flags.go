@@ -0,0 +1,96 @@
+package memcache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Bits of Item.Flags reserved for the features of the client and of layers
+// built on it, so that they don't reuse each other's bits. Applications
+// should keep their own flags in the bits no feature reserves, which
+// ReservedFlags lists.
+const (
+	// FlagCompressed marks values compressed by the client.
+	FlagCompressed uint32 = 1 << 31
+	// FlagEncrypted marks values encrypted by the client.
+	FlagEncrypted uint32 = 1 << 30
+	// FlagChunked marks the first item of a value split into several.
+	FlagChunked uint32 = 1 << 29
+	// FlagCodecMask holds the id of the codec of the value.
+	FlagCodecMask uint32 = 0xF << 24
+)
+
+// flagRegistry records the owner of each reserved bit of Item.Flags.
+var flagRegistry = struct {
+	sync.RWMutex
+	owners map[string]uint32
+}{owners: map[string]uint32{
+	"compression": FlagCompressed,
+	"encryption":  FlagEncrypted,
+	"chunking":    FlagChunked,
+	"codec":       FlagCodecMask,
+}}
+
+// ReserveFlags reserves the bits of mask in Item.Flags for the feature
+// owner, such as a layer storing its own metadata in the flags. It fails
+// if another feature already reserved any of them. It is meant to be
+// called from init functions.
+func ReserveFlags(owner string, mask uint32) error {
+	flagRegistry.Lock()
+	defer flagRegistry.Unlock()
+	if _, ok := flagRegistry.owners[owner]; ok {
+		return fmt.Errorf("memcache: flags of %q already reserved", owner)
+	}
+	for other, bits := range flagRegistry.owners {
+		if bits&mask != 0 {
+			return fmt.Errorf("memcache: flags %#x of %q collide with %#x of %q", mask, owner, bits, other)
+		}
+	}
+	flagRegistry.owners[owner] = mask
+	return nil
+}
+
+// ReservedFlags returns the bits of Item.Flags reserved by each feature.
+func ReservedFlags() map[string]uint32 {
+	flagRegistry.RLock()
+	defer flagRegistry.RUnlock()
+	m := make(map[string]uint32, len(flagRegistry.owners))
+	for owner, bits := range flagRegistry.owners {
+		m[owner] = bits
+	}
+	return m
+}
+
+// FlagConflictError is returned, with Config.CheckFlags, for the writes
+// whose flags use bits reserved by a feature.
+type FlagConflictError struct {
+	Key    string
+	Flags  uint32
+	Owners []string // the features whose bits are used
+}
+
+func (e *FlagConflictError) Error() string {
+	return fmt.Sprintf("memcache: flags %#x of key %q use bits reserved for %v", e.Flags, e.Key, e.Owners)
+}
+
+// checkFlags returns a *FlagConflictError if the flags of item use
+// reserved bits.
+func checkFlags(item *Item) error {
+	if item.Flags == 0 {
+		return nil
+	}
+	flagRegistry.RLock()
+	defer flagRegistry.RUnlock()
+	var owners []string
+	for owner, bits := range flagRegistry.owners {
+		if item.Flags&bits != 0 {
+			owners = append(owners, owner)
+		}
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	sort.Strings(owners)
+	return &FlagConflictError{Key: item.Key, Flags: item.Flags, Owners: owners}
+}
@@ -0,0 +1,45 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestReserveFlags(t *testing.T) {
+	if err := ReserveFlags("test-collision", FlagChunked|1); err == nil {
+		t.Error("ReserveFlags accepted bits of the chunking feature")
+	}
+	if err := ReserveFlags("test-layer", 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReserveFlags("test-layer", 1<<19); err == nil {
+		t.Error("ReserveFlags accepted a second reservation for the same owner")
+	}
+	if got := ReservedFlags()["test-layer"]; got != 1<<20 {
+		t.Errorf("ReservedFlags()[test-layer] = %#x, want %#x", got, 1<<20)
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithCheckFlags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Set(&Item{Key: "app", Flags: 0xFF}); err != nil {
+		t.Errorf("Set with application flags: %v", err)
+	}
+	err = c.Set(&Item{Key: "stomp", Flags: FlagCompressed | 1})
+	var fce *FlagConflictError
+	if !errors.As(err, &fce) || len(fce.Owners) != 1 || fce.Owners[0] != "compression" {
+		t.Errorf("Set with a reserved bit error = %v, want a conflict with compression", err)
+	}
+	if err := c.SetMulti([]*Item{{Key: "a"}, {Key: "b", Flags: FlagCodecMask}}); !errors.As(err, &fce) {
+		t.Errorf("SetMulti with a reserved bit error = %v, want a *FlagConflictError", err)
+	}
+}
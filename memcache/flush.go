@@ -0,0 +1,20 @@
+package memcache
+
+import "errors"
+
+// ErrOperationNotPermitted is returned by FlushAll and DeleteAll unless the
+// client allows flushes and the call carries ConfirmFlush.
+var ErrOperationNotPermitted = errors.New("memcache: operation not permitted")
+
+// ConfirmFlush is the confirmation FlushAll and DeleteAll require, so that
+// emptying the whole cache is never done by accident.
+const ConfirmFlush = "flush all items"
+
+// checkFlush returns ErrOperationNotPermitted unless flushes are allowed
+// and confirm is ConfirmFlush.
+func (c *Client) checkFlush(confirm string) error {
+	if !c.allowFlush || confirm != ConfirmFlush {
+		return ErrOperationNotPermitted
+	}
+	return nil
+}
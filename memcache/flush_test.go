@@ -0,0 +1,50 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestFlushGuard(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.FlushAll(ConfirmFlush); err != ErrOperationNotPermitted {
+		t.Errorf("FlushAll without AllowFlush error = %v, want ErrOperationNotPermitted", err)
+	}
+	if err := c.DeleteAll(ConfirmFlush); err != ErrOperationNotPermitted {
+		t.Errorf("DeleteAll without AllowFlush error = %v, want ErrOperationNotPermitted", err)
+	}
+
+	c.allowFlush = true
+	if err := c.FlushAll("yes"); err != ErrOperationNotPermitted {
+		t.Errorf("FlushAll with a wrong confirmation error = %v, want ErrOperationNotPermitted", err)
+	}
+	if _, err := c.Get("k"); err != nil {
+		t.Fatalf("Get after refused flushes: %v", err)
+	}
+
+	if err := c.FlushAll(ConfirmFlush); err != nil {
+		t.Fatalf("FlushAll: %v", err)
+	}
+	if _, err := c.Get("k"); err != ErrCacheMiss {
+		t.Errorf("Get after FlushAll error = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestWithAllowFlush(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithAllowFlush())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteAll(ConfirmFlush); err != nil {
+		t.Errorf("DeleteAll: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package memcache
+
+import (
+	"sync"
+	"time"
+)
+
+// FlushCause tells a warmup callback why FlushDetector triggered it.
+type FlushCause string
+
+const (
+	// FlushCauseCommand means the cache was flushed through the client.
+	FlushCauseCommand FlushCause = "flush_all"
+
+	// FlushCauseMissSpike means the hit ratio collapsed, as it does when
+	// the cache is flushed behind the client's back or a server restarts.
+	FlushCauseMissSpike FlushCause = "miss_spike"
+)
+
+// DefaultFlushCooldown is the default FlushDetector.Cooldown.
+const DefaultFlushCooldown = time.Minute
+
+// FlushDetector triggers a cache warmup when the cache appears to have
+// been emptied, so that an accidental flush_all or a server restart
+// doesn't turn directly into a load spike on the source of truth.
+// Register it with the client it watches, or add its Observe method with
+// Client.AddHook to watch a client without using its Clock.
+//
+// The warmup callback runs on its own goroutine, at most once per
+// Cooldown. It is triggered by a successful FlushAll or DeleteAll through
+// the client, and by the hit ratio over the detection window falling
+// below the configured minimum.
+type FlushDetector struct {
+	// Cooldown is the minimum time between two warmups. It must be set
+	// before use.
+	Cooldown time.Duration
+
+	alarm  *HitRatioAlarm
+	warmup func(FlushCause)
+	client *Client // whose clock tells the time, if registered
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewFlushDetector returns a detector calling warmup when a flush is seen
+// or the hit ratio over window drops below minHitRatio.
+func NewFlushDetector(minHitRatio float64, window time.Duration, warmup func(FlushCause)) *FlushDetector {
+	d := &FlushDetector{Cooldown: DefaultFlushCooldown, warmup: warmup}
+	d.alarm = NewHitRatioAlarm(minHitRatio, window, 0, nil)
+	d.alarm.onAlarm = func(float64) { d.trigger(FlushCauseMissSpike) }
+	return d
+}
+
+// Register adds Observe to the hooks of c, and makes the detector time
+// its Cooldown with c's Clock. It must be called before use.
+func (d *FlushDetector) Register(c *Client) {
+	d.client = c
+	c.AddHook(d.Observe)
+}
+
+// now returns the time of the registered client's clock, or else of the
+// system clock.
+func (d *FlushDetector) now() time.Time {
+	if d.client == nil {
+		return time.Now()
+	}
+	return d.client.now()
+}
+
+// Alarm returns the hit ratio alarm used to detect miss spikes, to allow
+// tuning its MinGets before use.
+func (d *FlushDetector) Alarm() *HitRatioAlarm {
+	return d.alarm
+}
+
+// Observe accounts for ev. It is a Hook.
+func (d *FlushDetector) Observe(ev OpEvent) {
+	if ev.Op == "flush_all" {
+		if ev.Err == nil {
+			d.trigger(FlushCauseCommand)
+		}
+		return
+	}
+	d.alarm.Observe(ev)
+}
+
+func (d *FlushDetector) trigger(cause FlushCause) {
+	now := d.now()
+	d.mu.Lock()
+	if !d.last.IsZero() && now.Sub(d.last) < d.Cooldown {
+		d.mu.Unlock()
+		return
+	}
+	d.last = now
+	d.mu.Unlock()
+	go d.warmup(cause)
+}
@@ -0,0 +1,82 @@
+package memcache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestFlushDetector(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.allowFlush = true
+
+	causes := make(chan FlushCause, 4)
+	d := NewFlushDetector(0.5, 10*time.Second, func(cause FlushCause) { causes <- cause })
+	d.Alarm().MinGets = 10
+	c.AddHook(d.Observe)
+
+	if err := c.FlushAll(ConfirmFlush); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cause := <-causes:
+		if cause != FlushCauseCommand {
+			t.Errorf("cause = %q, want %q", cause, FlushCauseCommand)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("warmup not triggered by FlushAll")
+	}
+
+	// Within the cooldown, a miss spike doesn't trigger another warmup.
+	for i := 0; i < 20; i++ {
+		c.Get("missing")
+	}
+	select {
+	case cause := <-causes:
+		t.Fatalf("warmup triggered again within the cooldown (%q)", cause)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	d2 := NewFlushDetector(0.5, 10*time.Second, func(cause FlushCause) { causes <- cause })
+	d2.Alarm().MinGets = 10
+	for i := 0; i < 20; i++ {
+		d2.Observe(OpEvent{Op: "get", Key: "missing", Start: time.Now(), Err: ErrCacheMiss})
+	}
+	select {
+	case cause := <-causes:
+		if cause != FlushCauseMissSpike {
+			t.Errorf("cause = %q, want %q", cause, FlushCauseMissSpike)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("warmup not triggered by a miss spike")
+	}
+}
+
+func TestFlushDetectorClock(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	clock := memcachetest.NewFakeClock(time.Unix(1e9, 0))
+	c, err := NewClient([]string{s.Addr()}, WithClock(clock), WithAllowFlush())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warmups int32
+	d := NewFlushDetector(0.5, 10*time.Second, func(FlushCause) { atomic.AddInt32(&warmups, 1) })
+	d.Register(c)
+
+	for i := 0; i < 3; i++ {
+		if err := c.FlushAll(ConfirmFlush); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(d.Cooldown / 2)
+	}
+	waitFor(t, "the warmups", func() bool { return atomic.LoadInt32(&warmups) == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&warmups); n != 2 {
+		t.Errorf("3 flushes over 1.5 cooldowns of the client's clock triggered %d warmups, want 2", n)
+	}
+}
@@ -0,0 +1,164 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultParallelism is the number of servers ForEachServer works on at
+// once unless the Parallelism call option says otherwise.
+const DefaultParallelism = 8
+
+// Parallelism makes ForEachServer work on up to n servers at once.
+func Parallelism(n int) CallOption {
+	return func(o *callOptions) { o.parallelism = n }
+}
+
+// ServerErrors is returned by ForEachServer when some of the servers
+// fail. It maps each failed server address to its error.
+type ServerErrors map[string]error
+
+func (e ServerErrors) Error() string {
+	addrs := make([]string, 0, len(e))
+	for addr := range e {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	if len(addrs) == 1 {
+		return fmt.Sprintf("memcache: server %s: %v", addrs[0], e[addrs[0]])
+	}
+	return fmt.Sprintf("memcache: %d servers failed, first %s: %v", len(addrs), addrs[0], e[addrs[0]])
+}
+
+// Conn is a connection to one server, lent to a ForEachServer callback
+// for administrative commands. It must not be used once the callback has
+// returned. Keys are sent as given, bypassing the client's policy, key
+// encoding and profiles.
+type Conn struct {
+	cn  *conn
+	ctx context.Context
+}
+
+// rw returns the connection's buffers after extending its deadline by
+// the client's timeout, but not past the deadline of the context.
+func (c *Conn) rw() *bufio.ReadWriter {
+	deadline := time.Now().Add(c.cn.c.netTimeout())
+	if d, ok := c.ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.cn.nc.SetDeadline(deadline)
+	return c.cn.rw
+}
+
+// Stats returns the statistics of group of the server, as Client.Stats.
+func (c *Conn) Stats(group string) (map[string]string, error) {
+	st := make(map[string]string)
+	err := c.cn.c.cmdRunner.Stats(c.rw(), group, func(name, value string) {
+		st[name] = value
+	})
+	return st, err
+}
+
+// FlushAll removes all the items of the server.
+func (c *Conn) FlushAll() error {
+	return c.cn.c.cmdRunner.FlushAll(c.rw())
+}
+
+// Ping checks that the server answers.
+func (c *Conn) Ping() error {
+	return c.cn.c.cmdRunner.Ping(c.rw())
+}
+
+// Get gets the item stored under key on the server. ErrCacheMiss is
+// returned if there is none.
+func (c *Conn) Get(key string) (*Item, error) {
+	var item *Item
+	err := c.cn.c.cmdRunner.Get(c.rw(), []string{key}, func(it *Item) { item = it })
+	if err == nil && item == nil {
+		err = ErrCacheMiss
+	}
+	return item, err
+}
+
+// Set writes item to the server, whatever server its key belongs to.
+func (c *Conn) Set(item *Item) error {
+	return c.cn.c.cmdRunner.Populate(c.rw(), "set", item)
+}
+
+// ForEachServer calls fn with a connection to each server of the client,
+// including those of its profiles, for administrative operations such as
+// collecting statistics, flushing, warming up or checking versions. Up to
+// DefaultParallelism servers are worked on at once; the Parallelism call
+// option changes that.
+//
+// Servers not yet started when ctx is done fail with the context's
+// error. If some servers fail, ForEachServer returns a ServerErrors once
+// all the others are done.
+func (c *Client) ForEachServer(ctx context.Context, fn func(addr net.Addr, conn *Conn) error, opts ...CallOption) error {
+	o := callOptions{parallelism: DefaultParallelism}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.parallelism < 1 {
+		o.parallelism = 1
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(ServerErrors)
+		sem  = make(chan struct{}, o.parallelism)
+	)
+	c.eachServer(func(addr net.Addr) error {
+		var err error
+		select {
+		case sem <- struct{}{}:
+			if err = ctx.Err(); err != nil {
+				<-sem
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			mu.Lock()
+			errs[addr.String()] = err
+			mu.Unlock()
+			return nil
+		}
+		wg.Add(1)
+		c.goFunc(func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := c.onServer(ctx, addr, fn); err != nil {
+				mu.Lock()
+				errs[addr.String()] = err
+				mu.Unlock()
+			}
+		})
+		return nil
+	})
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// onServer calls fn with a connection to addr.
+func (c *Client) onServer(ctx context.Context, addr net.Addr, fn func(net.Addr, *Conn) error) (err error) {
+	defer func() {
+		err = c.timeoutError(addr, "", err)
+		c.reportOutcome(addr, err)
+	}()
+	cn, err := c.getConnContext(ctx, addr, false)
+	if err != nil {
+		return err
+	}
+	defer cn.condRelease(&err)
+	return fn(addr, &Conn{cn: cn, ctx: ctx})
+}
@@ -0,0 +1,95 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestForEachServer(t *testing.T) {
+	s1, s2, s3 := memcachetest.NewServer(t), memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	defer s3.Close()
+	addrs := []string{s1.Addr(), s2.Addr(), s3.Addr()}
+	for name, c := range map[string]*Client{"text": New(addrs...), "binary": NewBinary(addrs...)} {
+		t.Run(name, func(t *testing.T) {
+			defer c.Close()
+			var (
+				mu       sync.Mutex
+				versions = make(map[string]string)
+			)
+			err := c.ForEachServer(context.Background(), func(addr net.Addr, conn *Conn) error {
+				st, err := conn.Stats("")
+				if err != nil {
+					return err
+				}
+				if err := conn.Set(&Item{Key: "warm", Value: []byte(addr.String())}); err != nil {
+					return err
+				}
+				mu.Lock()
+				versions[addr.String()] = st["version"]
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range []*memcachetest.Server{s1, s2, s3} {
+				if versions[s.Addr()] == "" {
+					t.Errorf("no version for %s", s.Addr())
+				}
+				direct := New(s.Addr())
+				it, err := direct.Get("warm")
+				if err != nil || string(it.Value) != s.Addr() {
+					t.Errorf("server %s was not warmed up: %v", s.Addr(), err)
+				}
+				direct.Close()
+			}
+		})
+	}
+}
+
+func TestForEachServerErrors(t *testing.T) {
+	s1, s2 := memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	c := New(s1.Addr(), s2.Addr())
+	defer c.Close()
+
+	var running, most int32
+	boom := errors.New("boom")
+	err := c.ForEachServer(context.Background(), func(addr net.Addr, conn *Conn) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if n > atomic.LoadInt32(&most) {
+			atomic.StoreInt32(&most, n)
+		}
+		if addr.String() == s2.Addr() {
+			return boom
+		}
+		return conn.Ping()
+	}, Parallelism(1))
+	var serr ServerErrors
+	if !errors.As(err, &serr) || len(serr) != 1 || serr[s2.Addr()] != boom {
+		t.Errorf("ForEachServer error = %v, want %v for %s only", err, boom, s2.Addr())
+	}
+	if most != 1 {
+		t.Errorf("%d servers ran at once, want 1", most)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = c.ForEachServer(ctx, func(net.Addr, *Conn) error {
+		t.Error("fn called with a done context")
+		return nil
+	})
+	if !errors.As(err, &serr) || len(serr) != 2 || serr[s1.Addr()] != context.Canceled {
+		t.Errorf("ForEachServer with a done context error = %v, want context.Canceled for both servers", err)
+	}
+}
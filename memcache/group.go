@@ -0,0 +1,94 @@
+package memcache
+
+import (
+	"context"
+	"time"
+)
+
+// A Getter loads the data for a key from the source of truth.
+type Getter interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// A GetterFunc implements Getter with a function.
+type GetterFunc func(ctx context.Context, key string) ([]byte, error)
+
+// Get calls f(ctx, key).
+func (f GetterFunc) Get(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// Group is a two-tier read-through cache in the style of groupcache: a
+// small in-process LRU holds hot keys, memcached is the tier shared by all
+// processes, and the Getter fills both on a miss. Concurrent loads of the
+// same key within a Group are collapsed into one.
+//
+// Values returned by Get are shared with the hot cache and must not be
+// modified.
+type Group struct {
+	client *Client
+	getter Getter
+	ttl    time.Duration
+
+	hot    *lruCache
+	flight flightGroup
+}
+
+// NewGroup returns a Group that loads missing keys with getter and stores
+// them in memcache through c for ttl. Up to hotEntries values are also kept
+// in process, for at most ttl; zero disables the hot cache.
+func NewGroup(c *Client, hotEntries int, ttl time.Duration, getter Getter) *Group {
+	return &Group{
+		client: c,
+		getter: getter,
+		ttl:    ttl,
+		hot:    newLRUCache(hotEntries),
+	}
+}
+
+// Get returns the data for key from the first tier that has it, loading
+// and storing it if no tier does. Errors from memcache are not fatal; the
+// getter is used instead.
+func (g *Group) Get(ctx context.Context, key string) ([]byte, error) {
+	if v, ok := g.hot.get(key, g.client.now()); ok {
+		return v.([]byte), nil
+	}
+	f := g.flight.do(key, func() ([]byte, error) {
+		if it, err := g.client.Get(key); err == nil {
+			return it.Value, nil
+		} else if err == ErrMalformedKey {
+			return nil, err
+		}
+		v, err := g.getter.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		g.client.Set(&Item{Key: key, Value: v, Expiration: Expiration(g.ttl, g.client.now())})
+		return v, nil
+	})
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	var expires time.Time
+	if g.ttl > 0 {
+		expires = g.client.now().Add(g.ttl)
+	}
+	g.hot.add(key, f.val, expires)
+	return f.val, nil
+}
+
+// Forget drops key from the hot cache and from memcache, so that the next
+// Get loads it again.
+func (g *Group) Forget(key string) error {
+	g.hot.remove(key)
+	err := g.client.Delete(key)
+	if err == ErrCacheMiss {
+		return nil
+	}
+	return err
+}
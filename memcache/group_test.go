@@ -0,0 +1,72 @@
+package memcache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestGroup(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	ctx := context.Background()
+
+	var loads int32
+	getter := GetterFunc(func(_ context.Context, key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		return []byte("v:" + key), nil
+	})
+
+	g1 := NewGroup(c, 10, time.Minute, getter)
+	v, err := g1.Get(ctx, "k")
+	if err != nil || string(v) != "v:k" {
+		t.Fatalf("Get = %q, %v; want v:k, nil", v, err)
+	}
+	if g1.hot.len() != 1 {
+		t.Errorf("hot cache holds %d entries, want 1", g1.hot.len())
+	}
+
+	// A second group, standing in for another process, is filled from
+	// memcache without calling the getter.
+	g2 := NewGroup(c, 10, time.Minute, getter)
+	if v, err := g2.Get(ctx, "k"); err != nil || string(v) != "v:k" {
+		t.Fatalf("Get from shared tier = %q, %v", v, err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("getter called %d times, want 1", n)
+	}
+
+	if err := g1.Forget("k"); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	if _, err := g1.Get(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 2 {
+		t.Errorf("getter called %d times after Forget, want 2", n)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	now := time.Now()
+	c := newLRUCache(2)
+	c.add("a", []byte("1"), time.Time{})
+	c.add("b", []byte("2"), now.Add(time.Second))
+	c.get("a", now)
+	c.add("c", []byte("3"), time.Time{})
+
+	if _, ok := c.get("b", now); ok {
+		t.Error("least recently used entry b was not evicted")
+	}
+	if _, ok := c.get("a", now); !ok {
+		t.Error("entry a was evicted")
+	}
+	c.add("d", []byte("4"), now.Add(time.Second))
+	if _, ok := c.get("d", now.Add(2*time.Second)); ok {
+		t.Error("expired entry d was returned")
+	}
+}
@@ -0,0 +1,137 @@
+package memcache
+
+import (
+	"sync"
+	"time"
+)
+
+// HitRatioAlarm watches the rolling hit ratio of retrievals and calls
+// OnAlarm when it stays below a threshold for a sustained period. A
+// sudden drop is often the first sign of a flush, an eviction storm or a
+// hashing mismatch between clients. Register its Observe method with
+// Client.AddHook.
+//
+// The ratio is computed over the last Window of traffic, in one-second
+// buckets, and is only considered once the window holds at least MinGets
+// retrievals. After firing, the alarm stays quiet until the ratio
+// recovers, at which point OnRecover is called if set.
+type HitRatioAlarm struct {
+	threshold float64
+	sustain   time.Duration
+
+	// MinGets is the number of retrievals the window must hold for the
+	// ratio to be considered. It must be set before use.
+	MinGets uint64
+
+	// OnRecover, if set, is called with the current ratio when the ratio
+	// rises back to the threshold after an alarm. It must be set before
+	// use.
+	OnRecover func(ratio float64)
+
+	onAlarm func(ratio float64)
+
+	mu         sync.Mutex
+	buckets    []hitBucket
+	belowSince time.Time
+	firing     bool
+}
+
+type hitBucket struct {
+	sec        int64
+	gets, hits uint64
+}
+
+// DefaultMinGets is the default HitRatioAlarm.MinGets.
+const DefaultMinGets = 100
+
+// NewHitRatioAlarm returns an alarm calling onAlarm when the hit ratio
+// over the last window stays below threshold for sustain.
+func NewHitRatioAlarm(threshold float64, window, sustain time.Duration, onAlarm func(ratio float64)) *HitRatioAlarm {
+	n := int((window + time.Second - 1) / time.Second)
+	if n < 1 {
+		n = 1
+	}
+	return &HitRatioAlarm{
+		threshold: threshold,
+		sustain:   sustain,
+		MinGets:   DefaultMinGets,
+		onAlarm:   onAlarm,
+		buckets:   make([]hitBucket, n),
+	}
+}
+
+// Observe accounts for ev and checks the alarm condition. It is a Hook.
+func (a *HitRatioAlarm) Observe(ev OpEvent) {
+	if ev.Op != "get" || (ev.Err != nil && ev.Err != ErrCacheMiss) {
+		return
+	}
+	now := ev.Start.Add(ev.Duration)
+
+	a.mu.Lock()
+	sec := now.Unix()
+	b := &a.buckets[int(sec%int64(len(a.buckets)))]
+	if b.sec != sec {
+		*b = hitBucket{sec: sec}
+	}
+	b.gets++
+	if ev.Err == nil {
+		b.hits++
+	}
+	fire, recovered, ratio := a.check(now)
+	a.mu.Unlock()
+
+	if fire && a.onAlarm != nil {
+		a.onAlarm(ratio)
+	}
+	if recovered && a.OnRecover != nil {
+		a.OnRecover(ratio)
+	}
+}
+
+// Ratio returns the hit ratio over the window ending at now, and the
+// number of retrievals it is based on.
+func (a *HitRatioAlarm) Ratio(now time.Time) (ratio float64, gets uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.ratio(now)
+}
+
+func (a *HitRatioAlarm) ratio(now time.Time) (float64, uint64) {
+	var gets, hits uint64
+	oldest := now.Unix() - int64(len(a.buckets)) + 1
+	for _, b := range a.buckets {
+		if b.sec >= oldest {
+			gets += b.gets
+			hits += b.hits
+		}
+	}
+	if gets == 0 {
+		return 0, 0
+	}
+	return float64(hits) / float64(gets), gets
+}
+
+// check updates the alarm state at now and reports whether it just fired
+// or recovered. It must be called with a.mu held.
+func (a *HitRatioAlarm) check(now time.Time) (fire, recovered bool, ratio float64) {
+	ratio, gets := a.ratio(now)
+	if gets < a.MinGets {
+		return false, false, ratio
+	}
+	if ratio >= a.threshold {
+		a.belowSince = time.Time{}
+		if a.firing {
+			a.firing = false
+			return false, true, ratio
+		}
+		return false, false, ratio
+	}
+	if a.belowSince.IsZero() {
+		a.belowSince = now
+	}
+	if !a.firing && now.Sub(a.belowSince) >= a.sustain {
+		a.firing = true
+		return true, false, ratio
+	}
+	return false, false, ratio
+}
@@ -0,0 +1,50 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHitRatioAlarm(t *testing.T) {
+	var alarms, recoveries int
+	a := NewHitRatioAlarm(0.5, 2*time.Second, 3*time.Second, func(float64) { alarms++ })
+	a.MinGets = 10
+	a.OnRecover = func(float64) { recoveries++ }
+
+	start := time.Unix(1000, 0)
+	// traffic feeds n retrievals per second for secs seconds from at,
+	// hitting on the given fraction of them.
+	traffic := func(at time.Time, secs, n int, hitRate float64) time.Time {
+		for s := 0; s < secs; s++ {
+			for i := 0; i < n; i++ {
+				var err error
+				if float64(i) >= hitRate*float64(n) {
+					err = ErrCacheMiss
+				}
+				a.Observe(OpEvent{Op: "get", Start: at, Err: err})
+			}
+			at = at.Add(time.Second)
+		}
+		return at
+	}
+
+	at := traffic(start, 5, 20, 0.9)
+	if alarms != 0 {
+		t.Fatalf("alarm fired on a healthy hit ratio")
+	}
+	at = traffic(at, 3, 20, 0.1)
+	if alarms != 0 {
+		t.Fatalf("alarm fired before the drop was sustained")
+	}
+	at = traffic(at, 3, 20, 0.1)
+	if alarms != 1 {
+		t.Fatalf("alarm fired %d times during a sustained drop, want 1", alarms)
+	}
+	at = traffic(at, 3, 20, 0.9)
+	if recoveries != 1 {
+		t.Fatalf("recovered %d times, want 1", recoveries)
+	}
+	if r, gets := a.Ratio(at.Add(-time.Second)); r < 0.89 || gets != 40 {
+		t.Errorf("Ratio = %v over %d gets, want 0.9 over 40", r, gets)
+	}
+}
@@ -0,0 +1,115 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// OpEvent describes one completed operation.
+type OpEvent struct {
+	// Op names the operation: "get", "set", "add", "replace", "cas",
+	// "append", "prepend", "delete", "incr", "decr", "touch" or "gat"
+	// (get and touch). Each key
+	// of a GetMulti is reported as a separate "get", and likewise for the
+	// other batch operations. FlushAll and
+	// DeleteAll are reported as "flush_all" with an empty Key.
+	Op string
+
+	Key string
+
+	// Start is when the operation began and Duration how long it took.
+	// The keys of a batch operation share the duration of the whole call.
+	Start    time.Time
+	Duration time.Duration
+
+	// Err is the operation's result. A "get" of an absent key reports
+	// ErrCacheMiss, and timeouts are reported as a *TimeoutError naming
+	// the phase that ran out of time.
+	Err error
+
+	// Caller is the tag attached with WithCaller to the context of the
+	// operation, if any. Only the operations taking a context carry one.
+	Caller string
+
+	// Server is the server the operation was sent to, or nil if it
+	// addressed every server or failed before picking one.
+	Server net.Addr
+
+	// Size and Expiration are the length of the value and the expiration
+	// of the item stored by a write, as given by the caller. Touch reports
+	// the expiration it sets. They are zero for the other operations.
+	Size       int
+	Expiration int32
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context tagging the operations made with it as
+// coming from caller, such as a feature or an endpoint name. The hooks
+// see the tag in OpEvent.Caller, so that the load on a shared cache can
+// be attributed to call sites by tagging the request context once rather
+// than at each call.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom returns the tag attached to ctx with WithCaller, or "".
+func CallerFrom(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// A Hook is called after every operation the client performs.
+// Hooks run synchronously on the calling goroutine, so they must be fast
+// and safe for concurrent use.
+type Hook func(OpEvent)
+
+// AddHook registers h to observe every operation. Hooks are called in the
+// order they were added.
+func (c *Client) AddHook(h Hook) {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+	// Copy so that emit can use the slice it read without holding cfgMu.
+	hooks := make([]Hook, len(c.hooks), len(c.hooks)+1)
+	copy(hooks, c.hooks)
+	c.hooks = append(hooks, h)
+}
+
+// trace reports the operation op on key, started at start, with the
+// result *err. It is meant to be deferred with start evaluated at the
+// beginning of the operation.
+func (c *Client) trace(op, key string, start time.Time, err *error) {
+	c.emit(OpEvent{Op: op, Key: key, Start: start, Duration: time.Since(start), Err: *err})
+}
+
+// traceServer is like trace, for operations sent to the server *server.
+func (c *Client) traceServer(op, key string, start time.Time, server *net.Addr, err *error) {
+	c.emit(OpEvent{Op: op, Key: key, Start: start, Duration: time.Since(start), Err: *err, Server: *server})
+}
+
+// traceWrite is like traceServer, for writes of size bytes expiring at
+// exp.
+func (c *Client) traceWrite(op, key string, size int, exp int32, start time.Time, server *net.Addr, err *error) {
+	c.emit(OpEvent{Op: op, Key: key, Start: start, Duration: time.Since(start), Err: *err, Server: *server, Size: size, Expiration: exp})
+}
+
+// traceContext is like traceServer, for operations made with ctx.
+func (c *Client) traceContext(ctx context.Context, op, key string, start time.Time, server *net.Addr, err *error) {
+	c.emit(OpEvent{Op: op, Key: key, Start: start, Duration: time.Since(start), Err: *err, Server: *server, Caller: CallerFrom(ctx)})
+}
+
+func (c *Client) emit(ev OpEvent) {
+	if c.errorBreaker != nil {
+		c.errorBreaker.observe(ev.Err, c.now())
+	}
+	if c.tenants != nil {
+		c.countUsage(ev)
+	}
+	c.cfgMu.RLock()
+	hooks := c.hooks
+	c.cfgMu.RUnlock()
+	for _, h := range hooks {
+		h(ev)
+	}
+}
@@ -0,0 +1,46 @@
+package memcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestCallerTag(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	var mu sync.Mutex
+	callers := make(map[string]string) // by key
+	c.AddHook(func(ev OpEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		callers[ev.Op+" "+ev.Key] = ev.Caller
+	})
+
+	ctx := WithCaller(context.Background(), "checkout")
+	if got := CallerFrom(ctx); got != "checkout" {
+		t.Fatalf("CallerFrom = %q", got)
+	}
+	c.Set(&Item{Key: "a", Value: []byte("1")})
+	c.GetContext(ctx, "a")
+	c.GetMultiContext(WithCaller(ctx, "search"), []string{"b", "c"})
+	c.GetContext(context.Background(), "d")
+
+	want := map[string]string{
+		"set a": "",
+		"get a": "checkout",
+		"get b": "search",
+		"get c": "search",
+		"get d": "",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for op, caller := range want {
+		if got, ok := callers[op]; !ok || got != caller {
+			t.Errorf("%s: Caller = %q (seen %v), want %q", op, got, ok, caller)
+		}
+	}
+}
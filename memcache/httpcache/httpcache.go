@@ -0,0 +1,365 @@
+// Package httpcache provides an http.RoundTripper that caches responses
+// in memcached.
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache"
+)
+
+const (
+	// DefaultChunkSize is the default size of the pieces a cached response
+	// is split into, chosen to stay under memcached's default 1MB item
+	// size limit.
+	DefaultChunkSize = 1000 * 1000
+
+	// DefaultMaxSize is the default limit on the size of a response that
+	// is cached, including its headers.
+	DefaultMaxSize = 16 * DefaultChunkSize
+
+	// XFromCache is the header set on responses served from the cache.
+	XFromCache = "X-From-Cache"
+)
+
+// Transport is an http.RoundTripper that serves GET requests from
+// memcached when possible, and stores cacheable responses there.
+//
+// Responses are keyed by URL and by the request headers named in the
+// response's Vary header. They are cached only when Cache-Control
+// max-age or s-maxage (or Expires) gives them an explicit lifetime, and
+// never when marked no-store, no-cache or private. Responses larger than
+// ChunkSize are stored as several items.
+//
+// Requests carrying an Authorization header bypass the cache unless
+// CacheAuthorized is set, since the cache is shared by every user of the
+// Transport.
+//
+// Cache failures are not reported; the request is passed on to the
+// underlying transport instead.
+type Transport struct {
+	// Client is the memcache client used to store responses.
+	Client *memcache.Client
+
+	// Transport is the underlying RoundTripper. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// KeyPrefix is prepended to every key written by the Transport.
+	KeyPrefix string
+
+	// ChunkSize is the maximum size of a single memcache item. If zero,
+	// DefaultChunkSize is used.
+	ChunkSize int
+
+	// MaxSize is the maximum size of a response to cache. If zero,
+	// DefaultMaxSize is used.
+	MaxSize int
+
+	// Clock, if set, replaces the system clock for freshness and
+	// expiration computations.
+	Clock memcache.Clock
+
+	// CacheAuthorized makes the Transport cache the responses to
+	// requests carrying an Authorization header that RFC 7234 section 3.2
+	// lets a shared cache store: those marked public, s-maxage or
+	// must-revalidate. They are then served to any request for the URL,
+	// whoever it authenticates.
+	CacheAuthorized bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	authorized := req.Header.Get("Authorization") != ""
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || authorized && !t.CacheAuthorized {
+		return t.transport().RoundTrip(req)
+	}
+	reqCC := parseCacheControl(req.Header)
+	_, noCache := reqCC["no-cache"]
+	_, noStore := reqCC["no-store"]
+	if !noCache && !noStore {
+		if resp := t.lookup(req); resp != nil {
+			return resp, nil
+		}
+	}
+
+	resp, err := t.transport().RoundTrip(req)
+	if err != nil || noStore {
+		return resp, err
+	}
+	ttl := lifetime(resp, t.now())
+	if ttl <= 0 || resp.Header.Get("Vary") == "*" || authorized && !sharedWithAuth(resp) {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(t.maxSize())+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > t.maxSize() {
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	dump, err := httputil.DumpResponse(resp, true)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err == nil && len(dump) <= t.maxSize() {
+		t.store(req, resp.Header, dump, ttl)
+	}
+	return resp, nil
+}
+
+func (t *Transport) now() time.Time {
+	if t.Clock != nil {
+		return t.Clock.Now()
+	}
+	return time.Now()
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) chunkSize() int {
+	if t.ChunkSize > 0 {
+		return t.ChunkSize
+	}
+	return DefaultChunkSize
+}
+
+func (t *Transport) maxSize() int {
+	if t.MaxSize > 0 {
+		return t.MaxSize
+	}
+	return DefaultMaxSize
+}
+
+// urlKey returns the key under which the Vary header names of the cached
+// response for req are stored.
+func (t *Transport) urlKey(req *http.Request) string {
+	return t.KeyPrefix + hashKey("vary "+req.URL.String())
+}
+
+// variantKey returns the key of the cached response matching req's values
+// of the given Vary header names.
+func (t *Transport) variantKey(req *http.Request, vary []string) string {
+	var b strings.Builder
+	b.WriteString(req.URL.String())
+	for _, name := range vary {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(strings.Join(req.Header[name], ","))
+	}
+	return t.KeyPrefix + hashKey(b.String())
+}
+
+func (t *Transport) lookup(req *http.Request) *http.Response {
+	it, err := t.Client.Get(t.urlKey(req))
+	if err != nil {
+		return nil
+	}
+	key := t.variantKey(req, splitVary(string(it.Value)))
+	head, err := t.Client.Get(key)
+	if err != nil {
+		return nil
+	}
+
+	// The head item's value starts with the generation of the response,
+	// followed by its first chunk, and its flags hold the number of
+	// chunks it was split into; the remaining chunks are stored under
+	// keys numbered within the generation, so that the head of one store
+	// is never completed with the chunks of another.
+	if len(head.Value) < generationLen {
+		return nil
+	}
+	gen, dump := string(head.Value[:generationLen]), head.Value[generationLen:]
+	if n := int(head.Flags); n > 1 {
+		keys := make([]string, 0, n-1)
+		for i := 1; i < n; i++ {
+			keys = append(keys, chunkKey(key, gen, i))
+		}
+		chunks, err := t.Client.GetMulti(keys)
+		if err != nil || len(chunks) != len(keys) {
+			return nil
+		}
+		buf := make([]byte, 0, n*len(dump))
+		buf = append(buf, dump...)
+		for _, k := range keys {
+			buf = append(buf, chunks[k].Value...)
+		}
+		dump = buf
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+	if err != nil {
+		return nil
+	}
+	resp.Header.Set(XFromCache, "1")
+	return resp
+}
+
+func (t *Transport) store(req *http.Request, header http.Header, dump []byte, ttl time.Duration) {
+	exp := memcache.Expiration(ttl, t.now())
+	vary := splitVary(strings.Join(header["Vary"], ","))
+	key := t.variantKey(req, vary)
+
+	gen, err := newGeneration()
+	if err != nil {
+		return
+	}
+	// The head item holds the generation along with the first chunk.
+	size := t.chunkSize()
+	first := size - generationLen
+	if first < 1 {
+		first = 1
+	}
+	if first > len(dump) {
+		first = len(dump)
+	}
+	rest := dump[first:]
+	n := 1 + (len(rest)+size-1)/size
+	// Write the trailing chunks before the head item so that readers never
+	// see a head whose chunks are missing.
+	for i := 1; i < n; i++ {
+		end := i * size
+		if end > len(rest) {
+			end = len(rest)
+		}
+		err := t.Client.Set(&memcache.Item{Key: chunkKey(key, gen, i), Value: rest[(i-1)*size : end], Expiration: exp})
+		if err != nil {
+			return
+		}
+	}
+	value := make([]byte, 0, generationLen+first)
+	value = append(append(value, gen...), dump[:first]...)
+	err = t.Client.Set(&memcache.Item{Key: key, Value: value, Flags: uint32(n), Expiration: exp})
+	if err != nil {
+		return
+	}
+	t.Client.Set(&memcache.Item{Key: t.urlKey(req), Value: []byte(strings.Join(vary, ",")), Expiration: exp})
+}
+
+// generationLen is the length of the generations newGeneration returns.
+const generationLen = 16
+
+// newGeneration returns a random generation telling the chunks of a
+// stored response apart from those of the other stores of its key.
+func newGeneration() (string, error) {
+	var b [generationLen / 2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+func chunkKey(key, gen string, i int) string {
+	return key + "." + gen + "." + strconv.Itoa(i)
+}
+
+// hashKey maps s to a fixed-length string that is always a legal key.
+func hashKey(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitVary returns the canonical header names listed in a Vary value,
+// sorted so that the order in which the server lists them doesn't matter.
+func splitVary(v string) []string {
+	var names []string
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseCacheControl(h http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, part := range strings.Split(h.Get("Cache-Control"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			cc[strings.ToLower(part[:i])] = strings.Trim(part[i+1:], `"`)
+		} else {
+			cc[strings.ToLower(part)] = ""
+		}
+	}
+	return cc
+}
+
+// sharedWithAuth reports whether resp, the response to a request carrying
+// an Authorization header, may be stored by a shared cache.
+func sharedWithAuth(resp *http.Response) bool {
+	cc := parseCacheControl(resp.Header)
+	for _, d := range []string{"public", "s-maxage", "must-revalidate"} {
+		if _, ok := cc[d]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lifetime returns how long resp may be cached, or zero if it must not be.
+func lifetime(resp *http.Response, now time.Time) time.Duration {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMovedPermanently,
+		http.StatusNotFound, http.StatusGone:
+	default:
+		return 0
+	}
+	cc := parseCacheControl(resp.Header)
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[d]; ok {
+			return 0
+		}
+	}
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[d]; ok {
+			secs, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || secs <= 0 {
+				return 0
+			}
+			return time.Duration(secs) * time.Second
+		}
+	}
+	if v := resp.Header.Get("Expires"); v != "" {
+		exp, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		date := now
+		if d, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			date = d
+		}
+		return exp.Sub(date)
+	}
+	return 0
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,209 @@
+package httpcache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache"
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestTransport(t *testing.T) {
+	mc := memcachetest.NewServer(t)
+	defer mc.Close()
+
+	var hits int32
+	big := strings.Repeat("x", 2500)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			fmt.Fprintf(w, "%s-%d", r.Header.Get("Accept-Language"), n)
+			return
+		case "/big":
+			w.Header().Set("Cache-Control", "max-age=60")
+			fmt.Fprint(w, big)
+			return
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		fmt.Fprintf(w, "body-%d", n)
+	}))
+	defer origin.Close()
+
+	hc := &http.Client{Transport: &Transport{
+		Client:    memcache.New(mc.Addr()),
+		ChunkSize: 1024,
+	}}
+	get := func(path, lang string) (string, bool) {
+		req, _ := http.NewRequest("GET", origin.URL+path, nil)
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		resp, err := hc.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("GET %s: reading body: %v", path, err)
+		}
+		return string(b), resp.Header.Get(XFromCache) != ""
+	}
+
+	if body, cached := get("/", ""); body != "body-1" || cached {
+		t.Errorf("first GET / = %q (cached %v), want body-1 from origin", body, cached)
+	}
+	if body, cached := get("/", ""); body != "body-1" || !cached {
+		t.Errorf("second GET / = %q (cached %v), want body-1 from cache", body, cached)
+	}
+
+	get("/private", "")
+	if _, cached := get("/private", ""); cached {
+		t.Error("private response was served from cache")
+	}
+
+	en, _ := get("/vary", "en")
+	de, _ := get("/vary", "de")
+	if en == de {
+		t.Fatalf("variants share a body: %q", en)
+	}
+	if body, cached := get("/vary", "en"); body != en || !cached {
+		t.Errorf("GET /vary (en) = %q (cached %v), want %q from cache", body, cached, en)
+	}
+
+	get("/big", "")
+	if body, cached := get("/big", ""); body != big || !cached {
+		t.Errorf("GET /big: got %d bytes (cached %v), want %d bytes from cache", len(body), cached, len(big))
+	}
+}
+
+func TestTransportAuthorization(t *testing.T) {
+	mc := memcachetest.NewServer(t)
+	defer mc.Close()
+
+	var hits int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/public" {
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		fmt.Fprintf(w, "%s-%d", r.Header.Get("Authorization"), n)
+	}))
+	defer origin.Close()
+
+	tr := &Transport{Client: memcache.New(mc.Addr())}
+	get := func(path, auth string) (string, bool) {
+		req, _ := http.NewRequest("GET", origin.URL+path, nil)
+		req.Header.Set("Authorization", auth)
+		resp, err := (&http.Client{Transport: tr}).Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b), resp.Header.Get(XFromCache) != ""
+	}
+
+	for _, path := range []string{"/", "/public"} {
+		get(path, "alice")
+		if body, cached := get(path, "bob"); cached {
+			t.Errorf("GET %s as bob served %q from the cache", path, body)
+		}
+	}
+
+	tr.CacheAuthorized = true
+	get("/", "alice")
+	if body, cached := get("/", "bob"); cached {
+		t.Errorf("GET / as bob served %q from the cache with CacheAuthorized", body)
+	}
+	alice, _ := get("/public", "alice")
+	if body, cached := get("/public", "bob"); body != alice || !cached {
+		t.Errorf("GET /public as bob = %q (cached %v), want %q from cache with CacheAuthorized", body, cached, alice)
+	}
+}
+
+func TestTransportConcurrentStores(t *testing.T) {
+	mc := memcachetest.NewServer(t)
+	defer mc.Close()
+
+	var body atomic.Value
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, body.Load())
+	}))
+	defer origin.Close()
+
+	tr := &Transport{Client: memcache.New(mc.Addr()), ChunkSize: 1024}
+	get := func(noCache bool) (string, bool) {
+		req, _ := http.NewRequest("GET", origin.URL, nil)
+		if noCache {
+			req.Header.Set("Cache-Control", "no-cache")
+		}
+		resp, err := (&http.Client{Transport: tr}).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b), resp.Header.Get(XFromCache) != ""
+	}
+
+	// Interleave two stores of the URL: the head of the first one ends
+	// up over the chunks of the second.
+	a, b := strings.Repeat("a", 2500), strings.Repeat("b", 2500)
+	body.Store(a)
+	get(true)
+	req, _ := http.NewRequest("GET", origin.URL, nil)
+	head, err := tr.Client.Get(tr.variantKey(req, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Store(b)
+	get(true)
+	if err := tr.Client.Set(head); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, cached := get(false); cached && got != a {
+		t.Errorf("GET served a spliced body from the cache: %d bytes, %d of them b", len(got), strings.Count(got, "b"))
+	}
+}
+
+func TestLifetime(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status int
+		header http.Header
+		want   time.Duration
+	}{
+		{200, http.Header{"Cache-Control": {"max-age=30"}}, 30 * time.Second},
+		{200, http.Header{"Cache-Control": {"max-age=30, s-maxage=90"}}, 90 * time.Second},
+		{200, http.Header{"Cache-Control": {"no-store, max-age=30"}}, 0},
+		{200, http.Header{}, 0},
+		{500, http.Header{"Cache-Control": {"max-age=30"}}, 0},
+		{200, http.Header{
+			"Date":    {now.Format(http.TimeFormat)},
+			"Expires": {now.Add(time.Hour).Format(http.TimeFormat)},
+		}, time.Hour},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: tt.header}
+		if got := lifetime(resp, now); got != tt.want {
+			t.Errorf("lifetime(%d, %v) = %v, want %v", tt.status, tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package memcache
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// A Transcoder converts between Go values and the value and flags of
+// items following the conventions of another memcache client, so that
+// services written in several languages can read each other's entries.
+// Unlike a Codec, it records the type of the value in the flags and
+// decodes according to them.
+type Transcoder interface {
+	Encode(v interface{}) (value []byte, flags uint32, err error)
+	Decode(value []byte, flags uint32, v interface{}) error
+}
+
+// UnsupportedEncodingError is returned by the Transcoders for values
+// whose encoding Go can't handle, such as Python pickles or PHP's
+// serialize, so that callers can skip or recompute such entries.
+type UnsupportedEncodingError struct {
+	Scheme   string // the convention, such as "pylibmc"
+	Flags    uint32 // the flags of the value, or zero when encoding
+	Encoding string // what the flags or the Go value call for
+}
+
+func (e *UnsupportedEncodingError) Error() string {
+	if e.Flags == 0 {
+		return fmt.Sprintf("memcache: %s cannot encode %s", e.Scheme, e.Encoding)
+	}
+	return fmt.Sprintf("memcache: %s value with flags %#x is %s, which is not supported", e.Scheme, e.Flags, e.Encoding)
+}
+
+// EncodeItem returns an item storing v under key, encoded with t.
+func EncodeItem(t Transcoder, key string, v interface{}) (*Item, error) {
+	value, flags, err := t.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return &Item{Key: key, Value: value, Flags: flags}, nil
+}
+
+// DecodeItem decodes the value of it with t into v, which must be a
+// non-nil pointer.
+func DecodeItem(t Transcoder, it *Item, v interface{}) error {
+	return t.Decode(it.Value, it.Flags, v)
+}
+
+// Flags of python-memcached and pylibmc, which share the first four.
+const (
+	pyFlagPickle     = 1 << 0
+	pyFlagInteger    = 1 << 1
+	pyFlagLong       = 1 << 2
+	pyFlagCompressed = 1 << 3
+)
+
+// PythonMemcachedTranscoder follows python-memcached: bytes are stored as
+// is, strings as UTF-8 marked as text, integers and booleans as decimal
+// numbers, and zlib-compressed values are decompressed. Pickled values,
+// which other types call for, are not supported.
+var PythonMemcachedTranscoder Transcoder = pythonTranscoder{scheme: "python-memcached", text: 1 << 4}
+
+// PylibmcTranscoder follows pylibmc, which is like python-memcached but
+// marks booleans and text with flags of its own.
+var PylibmcTranscoder Transcoder = pythonTranscoder{scheme: "pylibmc", boolean: 1 << 4, text: 1 << 5}
+
+type pythonTranscoder struct {
+	scheme        string
+	boolean, text uint32
+}
+
+func (t pythonTranscoder) Encode(v interface{}) ([]byte, uint32, error) {
+	switch x := v.(type) {
+	case []byte:
+		return x, 0, nil
+	case string:
+		return []byte(x), t.text, nil
+	case bool:
+		n := 0
+		if x {
+			n = 1
+		}
+		if t.boolean != 0 {
+			return []byte(strconv.Itoa(n)), t.boolean, nil
+		}
+		return []byte(strconv.Itoa(n)), pyFlagInteger, nil
+	}
+	if n, ok := integer(v); ok {
+		return []byte(n), pyFlagInteger, nil
+	}
+	return nil, 0, &UnsupportedEncodingError{Scheme: t.scheme, Encoding: fmt.Sprintf("%T without pickle", v)}
+}
+
+func (t pythonTranscoder) Decode(value []byte, flags uint32, v interface{}) error {
+	if flags&pyFlagCompressed != 0 {
+		var err error
+		if value, err = inflate(value); err != nil {
+			return err
+		}
+	}
+	switch {
+	case flags&pyFlagPickle != 0:
+		return &UnsupportedEncodingError{Scheme: t.scheme, Flags: flags, Encoding: "a pickle"}
+	case flags&(pyFlagInteger|pyFlagLong) != 0:
+		return decodeInteger(value, v)
+	case t.boolean != 0 && flags&t.boolean != 0:
+		return assign(v, string(value) == "1")
+	case flags&t.text != 0:
+		return assign(v, string(value))
+	}
+	return assign(v, value)
+}
+
+// Types of PHP's Memcached extension, in the low four bits of the flags,
+// and its internal flags, in the next ones.
+const (
+	phpTypeMask       = 0xF
+	phpTypeString     = 0
+	phpTypeLong       = 1
+	phpTypeDouble     = 2
+	phpTypeBool       = 3
+	phpTypeSerialized = 4
+	phpTypeIgbinary   = 5
+	phpTypeJSON       = 6
+	phpTypeMsgpack    = 7
+
+	phpFlagCompressed = 1 << 4
+	phpFlagZlib       = 1 << 5
+)
+
+// PHPMemcachedTranscoder follows PHP's Memcached extension: strings are
+// stored as is, integers, floats and booleans in their PHP string form,
+// and other values as JSON. Compressed values are decompressed if they
+// use zlib. Values stored with PHP's serialize, igbinary or msgpack are
+// not supported.
+var PHPMemcachedTranscoder Transcoder = phpTranscoder{}
+
+type phpTranscoder struct{}
+
+func (phpTranscoder) Encode(v interface{}) ([]byte, uint32, error) {
+	switch x := v.(type) {
+	case []byte:
+		return x, phpTypeString, nil
+	case string:
+		return []byte(x), phpTypeString, nil
+	case bool:
+		if x {
+			return []byte("1"), phpTypeBool, nil
+		}
+		return []byte(""), phpTypeBool, nil
+	case float32:
+		return []byte(strconv.FormatFloat(float64(x), 'g', -1, 32)), phpTypeDouble, nil
+	case float64:
+		return []byte(strconv.FormatFloat(x, 'g', -1, 64)), phpTypeDouble, nil
+	}
+	if n, ok := integer(v); ok {
+		return []byte(n), phpTypeLong, nil
+	}
+	data, err := json.Marshal(v)
+	return data, phpTypeJSON, err
+}
+
+func (phpTranscoder) Decode(value []byte, flags uint32, v interface{}) error {
+	if flags&phpFlagCompressed != 0 {
+		if flags&phpFlagZlib == 0 {
+			return &UnsupportedEncodingError{Scheme: "php-memcached", Flags: flags, Encoding: "compressed with fastlz"}
+		}
+		// The compressed data follows the length of the original.
+		if len(value) < 4 {
+			return fmt.Errorf("memcache: short compressed php-memcached value")
+		}
+		var err error
+		if value, err = inflate(value[4:]); err != nil {
+			return err
+		}
+	}
+	switch flags & phpTypeMask {
+	case phpTypeString:
+		return assign(v, value)
+	case phpTypeLong:
+		return decodeInteger(value, v)
+	case phpTypeDouble:
+		f, err := strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return err
+		}
+		return assign(v, f)
+	case phpTypeBool:
+		return assign(v, string(value) == "1")
+	case phpTypeJSON:
+		return json.Unmarshal(value, v)
+	case phpTypeSerialized:
+		return &UnsupportedEncodingError{Scheme: "php-memcached", Flags: flags, Encoding: "PHP serialized"}
+	case phpTypeIgbinary:
+		return &UnsupportedEncodingError{Scheme: "php-memcached", Flags: flags, Encoding: "igbinary"}
+	case phpTypeMsgpack:
+		return &UnsupportedEncodingError{Scheme: "php-memcached", Flags: flags, Encoding: "msgpack"}
+	}
+	return &UnsupportedEncodingError{Scheme: "php-memcached", Flags: flags, Encoding: "of an unknown type"}
+}
+
+// integer returns the decimal form of v if it is an integer.
+func integer(v interface{}) (string, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), true
+	}
+	return "", false
+}
+
+func decodeInteger(value []byte, v interface{}) error {
+	s := string(bytes.TrimSpace(value))
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return assign(v, n)
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("memcache: malformed integer value %q", value)
+	}
+	return assign(v, n)
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// assign stores x, a []byte, string, int64, uint64, float64 or bool, in
+// the value v points to, converting it to the type of that value.
+func assign(v interface{}, x interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("memcache: decoding into non-pointer %T", v)
+	}
+	dst := rv.Elem()
+	src := reflect.ValueOf(x)
+	mismatch := func() error {
+		return fmt.Errorf("memcache: cannot decode %T into %s", x, dst.Type())
+	}
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return mismatch()
+		}
+		dst.Set(src)
+	case reflect.String:
+		switch x := x.(type) {
+		case string:
+			dst.SetString(x)
+		case []byte:
+			dst.SetString(string(x))
+		default:
+			return mismatch()
+		}
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() != reflect.Uint8 {
+			return mismatch()
+		}
+		switch x := x.(type) {
+		case []byte:
+			dst.SetBytes(x)
+		case string:
+			dst.SetBytes([]byte(x))
+		default:
+			return mismatch()
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch x := x.(type) {
+		case int64:
+			n = x
+		case uint64:
+			if x > math.MaxInt64 {
+				return mismatch()
+			}
+			n = int64(x)
+		default:
+			return mismatch()
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("memcache: %d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		switch x := x.(type) {
+		case uint64:
+			n = x
+		case int64:
+			if x < 0 {
+				return mismatch()
+			}
+			n = uint64(x)
+		default:
+			return mismatch()
+		}
+		if dst.OverflowUint(n) {
+			return fmt.Errorf("memcache: %d overflows %s", n, dst.Type())
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		switch x := x.(type) {
+		case float64:
+			dst.SetFloat(x)
+		case int64:
+			dst.SetFloat(float64(x))
+		default:
+			return mismatch()
+		}
+	case reflect.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			return mismatch()
+		}
+		dst.SetBool(b)
+	default:
+		return mismatch()
+	}
+	return nil
+}
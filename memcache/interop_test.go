@@ -0,0 +1,111 @@
+package memcache
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func deflate(t *testing.T, data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestTranscoders(t *testing.T) {
+	phpZlib := make([]byte, 4)
+	binary.LittleEndian.PutUint32(phpZlib, 5)
+	phpZlib = append(phpZlib, deflate(t, []byte("hello"))...)
+
+	tests := []struct {
+		name  string
+		t     Transcoder
+		value string
+		flags uint32
+		want  interface{} // decoded into a new value of its type
+
+		// decodeOnly is set for the forms Encode does not produce.
+		decodeOnly bool
+	}{
+		{"python bytes", PythonMemcachedTranscoder, "raw", 0, []byte("raw"), false},
+		{"python text", PythonMemcachedTranscoder, "héllo", 1 << 4, "héllo", false},
+		{"python int", PythonMemcachedTranscoder, "42", 1 << 1, int64(42), false},
+		{"python long", PythonMemcachedTranscoder, "18446744073709551615", 1 << 2, uint64(18446744073709551615), true},
+		{"python zlib", PythonMemcachedTranscoder, string(deflate(t, []byte("big"))), 1 << 3, []byte("big"), true},
+		{"pylibmc bool", PylibmcTranscoder, "1", 1 << 4, true, false},
+		{"pylibmc text", PylibmcTranscoder, "txt", 1 << 5, "txt", false},
+		{"php string", PHPMemcachedTranscoder, "s", 0, "s", false},
+		{"php long", PHPMemcachedTranscoder, "-7", 1, -7, false},
+		{"php double", PHPMemcachedTranscoder, "1.5", 2, 1.5, false},
+		{"php false", PHPMemcachedTranscoder, "", 3, false, false},
+		{"php json", PHPMemcachedTranscoder, `{"a":1}`, 6, map[string]int{"a": 1}, false},
+		{"php zlib", PHPMemcachedTranscoder, string(phpZlib), 1<<4 | 1<<5, "hello", true},
+	}
+	for _, tt := range tests {
+		got := reflect.New(reflect.TypeOf(tt.want))
+		it := &Item{Value: []byte(tt.value), Flags: tt.flags}
+		if err := DecodeItem(tt.t, it, got.Interface()); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.Elem().Interface(), tt.want) {
+			t.Errorf("%s: decoded %#v, want %#v", tt.name, got.Elem().Interface(), tt.want)
+		}
+
+		// The value encodes back to what the other client wrote.
+		if tt.decodeOnly {
+			continue
+		}
+		enc, err := EncodeItem(tt.t, "k", tt.want)
+		if err != nil {
+			t.Errorf("%s: Encode: %v", tt.name, err)
+			continue
+		}
+		if string(enc.Value) != tt.value || enc.Flags != tt.flags {
+			t.Errorf("%s: encoded %q/%#x, want %q/%#x", tt.name, enc.Value, enc.Flags, tt.value, tt.flags)
+		}
+	}
+}
+
+func TestTranscoderUnsupported(t *testing.T) {
+	var v interface{}
+	for _, tt := range []struct {
+		t     Transcoder
+		flags uint32
+	}{
+		{PythonMemcachedTranscoder, 1},
+		{PylibmcTranscoder, 1 | 1<<3},
+		{PHPMemcachedTranscoder, 4},
+		{PHPMemcachedTranscoder, 5},
+		{PHPMemcachedTranscoder, 1<<4 | 1<<6},
+	} {
+		value := []byte("x")
+		if tt.flags&(1<<3) != 0 {
+			value = deflate(t, value)
+		}
+		err := tt.t.Decode(value, tt.flags, &v)
+		var ue *UnsupportedEncodingError
+		if !errors.As(err, &ue) || ue.Flags != tt.flags {
+			t.Errorf("Decode with flags %#x: %v, want an UnsupportedEncodingError", tt.flags, err)
+		}
+	}
+	if _, _, err := PythonMemcachedTranscoder.Encode(struct{}{}); err == nil {
+		t.Error("python-memcached encoded a struct without pickle")
+	}
+
+	var n int8
+	if err := PHPMemcachedTranscoder.Decode([]byte("1000"), 1, &n); err == nil {
+		t.Error("decoding 1000 into an int8 succeeded")
+	}
+	var s string
+	if err := PHPMemcachedTranscoder.Decode([]byte("1"), 1, &s); err == nil {
+		t.Error("decoding a long into a string succeeded")
+	}
+}
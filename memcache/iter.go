@@ -0,0 +1,78 @@
+//go:build go1.23
+
+package memcache
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net"
+)
+
+// errStopped stops the listings and fetches of iterators whose consumer
+// has broken out of its loop.
+var errStopped = errors.New("memcache: iteration stopped")
+
+// Items returns an iterator over the items found for keys, fetched as by
+// GetMultiFunc, in the order they arrive. Missing keys are skipped. If
+// the fetch fails, the last pair holds the error and a nil item. Breaking
+// out of the loop early drops the items still arriving.
+func (c *Client) Items(keys []string) iter.Seq2[*Item, error] {
+	return func(yield func(*Item, error) bool) {
+		items := make(chan *Item)
+		done := make(chan struct{})
+		errc := make(chan error, 1)
+		go func() {
+			errc <- c.GetMultiFunc(keys, func(it *Item) error {
+				select {
+				case items <- it:
+					return nil
+				case <-done:
+					return errStopped
+				}
+			})
+			close(items)
+		}()
+		defer close(done)
+		for it := range items {
+			if !yield(it, nil) {
+				return
+			}
+		}
+		if err := <-errc; err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Keys returns an iterator over the metadata of the items held by the
+// servers of the client, listed one server after the other with
+// Conn.MetaDump, or with Conn.CacheDump on the servers refusing
+// lru_crawler. A server that fails yields a ServerErrors naming it, and
+// the listing goes on with the next one. Keys is only supported by the
+// text protocol.
+//
+// The listing of each server is read in full before it is yielded, so
+// that the loop body runs without a connection or a lock of the client
+// held, and may use the client freely.
+func (c *Client) Keys(ctx context.Context) iter.Seq2[KeyMeta, error] {
+	return func(yield func(KeyMeta, error) bool) {
+		for _, addr := range c.servers() {
+			var kms []KeyMeta
+			err := c.onServer(ctx, addr, func(_ net.Addr, cn *Conn) error {
+				return cn.listKeys(func(km KeyMeta) error {
+					kms = append(kms, km)
+					return nil
+				})
+			})
+			for _, km := range kms {
+				if !yield(km, nil) {
+					return
+				}
+			}
+			if err != nil && !yield(KeyMeta{Server: addr}, ServerErrors{addr.String(): err}) {
+				return
+			}
+		}
+	}
+}
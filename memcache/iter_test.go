@@ -0,0 +1,120 @@
+//go:build go1.23
+
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestItems(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		if err := c.Set(&Item{Key: key, Value: []byte(key)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make(map[string]bool)
+	for it, err := range c.Items([]string{"a", "b", "c", "missing"}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[it.Key] = true
+	}
+	if len(got) != 3 || !got["a"] || !got["b"] || !got["c"] {
+		t.Errorf("Items yielded %v, want a, b and c", got)
+	}
+
+	n := 0
+	for range c.Items([]string{"a", "b", "c"}) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("loop broken after the first item ran %d times", n)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+	for _, key := range []string{"a", "b"} {
+		if err := c.Set(&Item{Key: key, Value: []byte(key)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var keys []string
+	for km, err := range c.Keys(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, km.Key)
+	}
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("Keys yielded %v, want a and b", keys)
+	}
+
+	for range c.Keys(context.Background()) {
+		break
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Errorf("Get after breaking out of Keys: %v", err)
+	}
+
+	bc := NewBinary(s.Addr())
+	defer bc.Close()
+	for _, err := range bc.Keys(context.Background()) {
+		if err == nil {
+			t.Error("Keys on the binary protocol succeeded")
+		}
+	}
+}
+
+func TestKeysLoopUsesClient(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	ss := new(ServerList)
+	ss.SetServers(s.Addr())
+	c, err := NewWithOptions(Config{Selector: ss, MaxOpenConns: 1, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, key := range []string{"a", "b"} {
+		if err := c.Set(&Item{Key: key, Value: []byte(key)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The loop body changes the servers and takes the only connection
+	// the client may open.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for km, err := range c.Keys(context.Background()) {
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ss.SetServers(s.Addr())
+			if _, err := c.Get(km.Key); err != nil {
+				t.Errorf("Get(%q) in the loop: %v", km.Key, err)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Keys blocked the client its loop body uses")
+	}
+}
@@ -0,0 +1,59 @@
+package memcache
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// A KeyEncoding makes a client encode every key before sending it, so
+// that keys may contain spaces and control bytes. Encoded keys still must
+// fit in 250 bytes. The items the client returns carry the caller's keys.
+type KeyEncoding int
+
+const (
+	// KeyEncodingNone sends keys as they are and rejects those that are
+	// not legal memcached keys with ErrMalformedKey.
+	KeyEncodingNone KeyEncoding = iota
+
+	// KeyEncodingPercent escapes spaces, control bytes and '%' as %XX,
+	// leaving other keys readable on the server.
+	KeyEncodingPercent
+
+	// KeyEncodingBase64 sends keys in unpadded URL-safe base64.
+	KeyEncodingBase64
+)
+
+func (e KeyEncoding) encode(key string) string {
+	switch e {
+	case KeyEncodingPercent:
+		return percentEncode(key)
+	case KeyEncodingBase64:
+		return base64.RawURLEncoding.EncodeToString([]byte(key))
+	}
+	return key
+}
+
+func percentEncode(key string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		k := key[i]
+		if k > ' ' && k != 0x7f && k != '%' {
+			if b.Len() > 0 {
+				b.WriteByte(k)
+			}
+			continue
+		}
+		if b.Len() == 0 {
+			b.Grow(len(key) + 2)
+			b.WriteString(key[:i])
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[k>>4])
+		b.WriteByte(hex[k&0xf])
+	}
+	if b.Len() == 0 {
+		return key
+	}
+	return b.String()
+}
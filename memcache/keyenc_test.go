@@ -0,0 +1,57 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestPercentEncode(t *testing.T) {
+	tests := []struct{ key, want string }{
+		{"plain", "plain"},
+		{"with space", "with%20space"},
+		{" lead", "%20lead"},
+		{"100%", "100%25"},
+		{"tab\tnl\n\x7f", "tab%09nl%0A%7F"},
+		{"ünicode", "ünicode"},
+	}
+	for _, tt := range tests {
+		if got := percentEncode(tt.key); got != tt.want {
+			t.Errorf("percentEncode(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestKeyEncoding(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for _, enc := range []KeyEncoding{KeyEncodingPercent, KeyEncodingBase64} {
+		c, err := NewClient([]string{s.Addr()}, WithProtocol("binary"), WithKeyEncoding(enc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := []string{"user input", "line\nbreak", "50%"}
+		for _, key := range keys {
+			if err := c.Set(&Item{Key: key, Value: []byte(key)}); err != nil {
+				t.Fatalf("encoding %d: Set(%q): %v", enc, key, err)
+			}
+		}
+		it, err := c.Get("user input")
+		if err != nil || it.Key != "user input" {
+			t.Errorf("encoding %d: Get = %+v, %v, want the item under the caller's key", enc, it, err)
+		}
+		m, err := c.GetMulti(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			if it := m[key]; it == nil || string(it.Value) != key {
+				t.Errorf("encoding %d: GetMulti()[%q] = %+v", enc, key, it)
+			}
+		}
+	}
+
+	if _, err := NewClient([]string{s.Addr()}, WithKeyEncoding(KeyEncoding(42))); err == nil {
+		t.Error("NewClient accepted an unknown KeyEncoding")
+	}
+}
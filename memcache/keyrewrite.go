@@ -0,0 +1,81 @@
+package memcache
+
+import "context"
+
+type keyRewriteKey struct{}
+
+// WithKeyRewrite returns a context under which the operations taking a
+// context, GetContext, GetMultiContext, SetContext, DeleteContext,
+// Memoize and TouchOrSet, use rewrite(key) in place of each key, such as
+// the key with the suffix of an experiment bucket, so that the variants of
+// an experiment are cached apart without changing the call sites. The
+// client treats the rewritten key as if the caller had passed it, except
+// that the items returned, and the keys of the GetMultiContext map, are
+// the caller's.
+//
+// rewrite must map different keys to different keys. Under nested
+// WithKeyRewrite contexts, the outer rewrite applies first.
+func WithKeyRewrite(ctx context.Context, rewrite func(key string) string) context.Context {
+	if outer := keyRewriteFrom(ctx); outer != nil {
+		inner := rewrite
+		rewrite = func(key string) string { return inner(outer(key)) }
+	}
+	return context.WithValue(ctx, keyRewriteKey{}, rewrite)
+}
+
+func keyRewriteFrom(ctx context.Context) func(string) string {
+	rewrite, _ := ctx.Value(keyRewriteKey{}).(func(string) string)
+	return rewrite
+}
+
+// rewriteKey returns key as rewritten under ctx.
+func rewriteKey(ctx context.Context, key string) string {
+	if rewrite := keyRewriteFrom(ctx); rewrite != nil {
+		return rewrite(key)
+	}
+	return key
+}
+
+// SetContext is like Set, with the key rewritten under WithKeyRewrite.
+func (c *Client) SetContext(ctx context.Context, item *Item) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if rewrite := keyRewriteFrom(ctx); rewrite != nil {
+		it := *item
+		it.Key = rewrite(item.Key)
+		item = &it
+	}
+	return c.Set(item)
+}
+
+// DeleteContext is like Delete, with the key rewritten under
+// WithKeyRewrite.
+func (c *Client) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Delete(rewriteKey(ctx, key))
+}
+
+// getMultiRewritten is GetMultiContext under rewrite: it fetches the
+// rewritten keys and maps the items back to the caller's.
+func (c *Client) getMultiRewritten(ctx context.Context, keys []string, rewrite func(string) string) (map[string]*Item, error) {
+	caller := make(map[string]string, len(keys))
+	rewritten := make([]string, len(keys))
+	for i, key := range keys {
+		rewritten[i] = rewrite(key)
+		caller[rewritten[i]] = key
+	}
+	var noRewrite func(string) string
+	m, err := c.GetMultiContext(context.WithValue(ctx, keyRewriteKey{}, noRewrite), rewritten)
+	if m == nil {
+		return nil, err
+	}
+	out := make(map[string]*Item, len(m))
+	for key, it := range m {
+		it.Key = caller[key]
+		out[it.Key] = it
+	}
+	return out, err
+}
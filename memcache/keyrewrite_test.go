@@ -0,0 +1,62 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestKeyRewrite(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	bucket := func(b string) func(string) string {
+		return func(key string) string { return key + "#" + b }
+	}
+	ctx := WithKeyRewrite(context.Background(), bucket("b"))
+
+	for _, key := range []string{"k1", "k2"} {
+		if err := c.SetContext(ctx, &Item{Key: key, Value: []byte(key)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Get(key + "#b"); err != nil {
+			t.Errorf("rewritten key of %q not stored: %v", key, err)
+		}
+	}
+	if _, err := c.Get("k1"); err != ErrCacheMiss {
+		t.Errorf("Get of the caller's key error = %v, want ErrCacheMiss", err)
+	}
+
+	it, err := c.GetContext(ctx, "k1")
+	if err != nil || it.Key != "k1" || string(it.Value) != "k1" {
+		t.Errorf("GetContext = %+v, %v, want the item under the caller's key", it, err)
+	}
+	m, err := c.GetMultiContext(ctx, []string{"k1", "k2", "k3"})
+	if err != nil || len(m) != 2 || m["k1"].Key != "k1" || string(m["k2"].Value) != "k2" {
+		t.Errorf("GetMultiContext = %v, %v, want k1 and k2 under the caller's keys", m, err)
+	}
+
+	var v string
+	if err := c.Memoize(ctx, "memo", 0, &v, func(context.Context) (interface{}, error) { return "x", nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("memo#b"); err != nil {
+		t.Errorf("Memoize did not store the rewritten key: %v", err)
+	}
+
+	if err := c.DeleteContext(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("k1#b"); err != ErrCacheMiss {
+		t.Errorf("Get after DeleteContext error = %v, want ErrCacheMiss", err)
+	}
+
+	nested := WithKeyRewrite(ctx, bucket("c"))
+	if err := c.SetContext(nested, &Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("k#b#c"); err != nil {
+		t.Errorf("nested rewrites not composed: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package memcache
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestClientName(t *testing.T) {
+	for _, proto := range []string{"text", "binary"} {
+		s := memcachetest.NewServer(t)
+		c, err := NewClient([]string{s.Addr()}, WithProtocol(proto), WithClientName("render"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := c.Get("k"); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := s.Labels(), []string{"render"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: server labels = %q, want %q, once for the one connection", proto, got, want)
+		}
+		c.Close()
+		s.Close()
+	}
+
+	if _, err := NewClient([]string{"127.0.0.1:1"}, WithClientName("has space")); err == nil {
+		t.Error("NewClient accepted a ClientName unusable in a key")
+	}
+}
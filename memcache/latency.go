@@ -0,0 +1,201 @@
+package memcache
+
+import (
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// LatencyPercentiles summarizes the durations of a set of operations.
+type LatencyPercentiles struct {
+	Count         uint64
+	P50, P95, P99 time.Duration
+	Max           time.Duration
+}
+
+// LatencySnapshot holds the latency percentiles of the operations in a
+// LatencyStats window, by op name (as in OpEvent.Op) and by server
+// address.
+type LatencySnapshot struct {
+	Ops     map[string]LatencyPercentiles
+	Servers map[string]LatencyPercentiles
+}
+
+// LatencyStats keeps rolling latency percentiles per operation and per
+// server, since averages hide the tail latencies users feel. Register its
+// Observe method with Client.AddHook.
+//
+// Durations are counted in a log-linear histogram whose buckets are
+// within 12.5% of each other, so the percentiles are approximate. The
+// window advances in steps of a sixth of its length.
+type LatencyStats struct {
+	step time.Duration
+
+	mu      sync.Mutex
+	ops     map[string]*latencyWindow
+	servers map[string]*latencyWindow
+}
+
+const latencySteps = 6
+
+// Histogram buckets: durations under 8µs have a bucket per microsecond,
+// and each following power of two is split in 8.
+const (
+	latencySubBuckets = 8
+	latencyMaxExp     = 40
+	latencyBuckets    = latencySubBuckets + (latencyMaxExp-3+1)*latencySubBuckets
+)
+
+type latencyWindow [latencySteps]latencySlot
+
+type latencySlot struct {
+	step    int64 // index of the step since the epoch the slot counts
+	count   uint64
+	max     time.Duration
+	buckets [latencyBuckets]uint32
+}
+
+// NewLatencyStats returns LatencyStats over the last window of traffic.
+func NewLatencyStats(window time.Duration) *LatencyStats {
+	step := window / latencySteps
+	if step <= 0 {
+		step = 1
+	}
+	return &LatencyStats{
+		step:    step,
+		ops:     make(map[string]*latencyWindow),
+		servers: make(map[string]*latencyWindow),
+	}
+}
+
+// Observe records the duration of ev. It is a Hook.
+func (l *LatencyStats) Observe(ev OpEvent) {
+	step := ev.Start.Add(ev.Duration).UnixNano() / int64(l.step)
+	i := latencyBucket(ev.Duration)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.window(l.ops, ev.Op).add(step, i, ev.Duration)
+	if ev.Server != nil {
+		l.window(l.servers, ev.Server.String()).add(step, i, ev.Duration)
+	}
+}
+
+func (l *LatencyStats) window(m map[string]*latencyWindow, name string) *latencyWindow {
+	w := m[name]
+	if w == nil {
+		w = new(latencyWindow)
+		m[name] = w
+	}
+	return w
+}
+
+func (w *latencyWindow) add(step int64, bucket int, d time.Duration) {
+	s := &w[int(step%latencySteps)]
+	if s.step != step {
+		*s = latencySlot{step: step}
+	}
+	s.count++
+	s.buckets[bucket]++
+	if d > s.max {
+		s.max = d
+	}
+}
+
+// Snapshot returns the percentiles over the window ending at now.
+// Operations and servers without traffic in the window are absent.
+func (l *LatencyStats) Snapshot(now time.Time) LatencySnapshot {
+	oldest := now.UnixNano()/int64(l.step) - latencySteps + 1
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LatencySnapshot{
+		Ops:     percentiles(l.ops, oldest),
+		Servers: percentiles(l.servers, oldest),
+	}
+}
+
+// Reset forgets all recorded durations.
+func (l *LatencyStats) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ops = make(map[string]*latencyWindow)
+	l.servers = make(map[string]*latencyWindow)
+}
+
+func percentiles(m map[string]*latencyWindow, oldest int64) map[string]LatencyPercentiles {
+	out := make(map[string]LatencyPercentiles)
+	var sum [latencyBuckets]uint64
+	for name, w := range m {
+		var p LatencyPercentiles
+		sum = [latencyBuckets]uint64{}
+		for i := range w {
+			s := &w[i]
+			if s.step < oldest || s.count == 0 {
+				continue
+			}
+			p.Count += s.count
+			if s.max > p.Max {
+				p.Max = s.max
+			}
+			for b, n := range s.buckets {
+				sum[b] += uint64(n)
+			}
+		}
+		if p.Count == 0 {
+			continue
+		}
+		p.P50 = quantile(&sum, p.Count, 0.50, p.Max)
+		p.P95 = quantile(&sum, p.Count, 0.95, p.Max)
+		p.P99 = quantile(&sum, p.Count, 0.99, p.Max)
+		out[name] = p
+	}
+	return out
+}
+
+// quantile returns the duration under which a fraction q of the count
+// durations counted in buckets fall, capped at max.
+func quantile(buckets *[latencyBuckets]uint64, count uint64, q float64, max time.Duration) time.Duration {
+	rank := uint64(q*float64(count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range buckets {
+		if seen += n; seen >= rank {
+			if d := latencyValue(i); d < max {
+				return d
+			}
+			return max
+		}
+	}
+	return max
+}
+
+// latencyBucket returns the histogram bucket of d.
+func latencyBucket(d time.Duration) int {
+	us := uint64(d / time.Microsecond)
+	if d < 0 {
+		us = 0
+	}
+	if us < latencySubBuckets {
+		return int(us)
+	}
+	exp := bits.Len64(us) - 1 // at least 3
+	if exp > latencyMaxExp {
+		return latencyBuckets - 1
+	}
+	sub := int(us>>uint(exp-3)) - latencySubBuckets
+	return latencySubBuckets + (exp-3)*latencySubBuckets + sub
+}
+
+// latencyValue returns the midpoint of bucket i.
+func latencyValue(i int) time.Duration {
+	if i < latencySubBuckets {
+		return time.Duration(i)*time.Microsecond + time.Microsecond/2
+	}
+	exp := (i-latencySubBuckets)/latencySubBuckets + 3
+	sub := (i - latencySubBuckets) % latencySubBuckets
+	width := uint64(1) << uint(exp-3)
+	lower := uint64(latencySubBuckets+sub) * width
+	return time.Duration(lower)*time.Microsecond + time.Duration(width)*time.Microsecond/2
+}
@@ -0,0 +1,97 @@
+package memcache
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestLatencyStats(t *testing.T) {
+	l := NewLatencyStats(time.Minute)
+	t0 := time.Unix(1e9, 0)
+	a := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 11211}
+	b := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 11211}
+	// 1ms to 100ms on a, 1000 x 200µs on b.
+	for i := 1; i <= 100; i++ {
+		l.Observe(OpEvent{Op: "get", Start: t0, Duration: time.Duration(i) * time.Millisecond, Server: a})
+	}
+	for i := 0; i < 1000; i++ {
+		l.Observe(OpEvent{Op: "set", Start: t0, Duration: 200 * time.Microsecond, Server: b})
+	}
+
+	near := func(got, want time.Duration) bool {
+		diff := got - want
+		if diff < 0 {
+			diff = -diff
+		}
+		return diff <= want/8
+	}
+	snap := l.Snapshot(t0)
+	get := snap.Ops["get"]
+	if get.Count != 100 || get.Max != 100*time.Millisecond ||
+		!near(get.P50, 50*time.Millisecond) || !near(get.P95, 95*time.Millisecond) || !near(get.P99, 99*time.Millisecond) {
+		t.Errorf("get percentiles = %+v", get)
+	}
+	if set := snap.Ops["set"]; set.Count != 1000 || !near(set.P50, 200*time.Microsecond) || set.P99 > set.Max {
+		t.Errorf("set percentiles = %+v", set)
+	}
+	if s := snap.Servers[a.String()]; s != get {
+		t.Errorf("server %s = %+v, want the get percentiles", a, s)
+	}
+	if s := snap.Servers[b.String()]; s.Count != 1000 {
+		t.Errorf("server %s = %+v", b, s)
+	}
+
+	// A minute later the traffic has left the window.
+	l.Observe(OpEvent{Op: "get", Start: t0.Add(time.Minute), Duration: time.Millisecond})
+	snap = l.Snapshot(t0.Add(time.Minute))
+	if get := snap.Ops["get"]; get.Count != 1 || get.Max != time.Millisecond {
+		t.Errorf("get percentiles after a minute = %+v", get)
+	}
+	if len(snap.Servers) != 0 {
+		t.Errorf("servers after a minute = %+v", snap.Servers)
+	}
+}
+
+func TestLatencyBuckets(t *testing.T) {
+	prev := -1
+	for d := time.Duration(0); d < time.Hour; d = d*9/8 + time.Microsecond {
+		i := latencyBucket(d)
+		if i < prev {
+			t.Fatalf("bucket of %v = %d, below %d", d, i, prev)
+		}
+		prev = i
+		if v := latencyValue(i); d >= 8*time.Microsecond && (v < d*7/8 || v > d*9/8) {
+			t.Errorf("bucket of %v has value %v", d, v)
+		}
+	}
+	if i := latencyBucket(1000 * time.Hour); i != latencyBuckets-1 {
+		t.Errorf("bucket of 1000h = %d, want the last", i)
+	}
+}
+
+func TestOpEventServer(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	servers := make(map[string]string)
+	c.AddHook(func(ev OpEvent) {
+		if ev.Server != nil {
+			servers[ev.Op] = ev.Server.String()
+		}
+	})
+	c.Set(&Item{Key: "k", Value: []byte("1")})
+	c.Get("k")
+	c.GetMulti([]string{"k"})
+	c.Increment("k", 1)
+	c.Touch("k", 10)
+	c.Delete("k")
+	c.DeleteMulti([]string{"k"})
+	for _, op := range []string{"set", "get", "incr", "touch", "delete"} {
+		if servers[op] != s.Addr() {
+			t.Errorf("%s reported server %q, want %s", op, servers[op], s.Addr())
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package memcache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrLeaseHeld is returned by LeaseGet when another caller holds the
+	// lease to fill a missing key and the value did not appear in time.
+	ErrLeaseHeld = errors.New("memcache: lease held by another caller")
+
+	// ErrLeaseLost is returned by LeaseSet when the lease expired or was
+	// taken over before the value was stored.
+	ErrLeaseLost = errors.New("memcache: lease lost")
+
+	// ErrLeaseKeyTooLong is returned by LeaseGet for the keys that don't
+	// fit in 250 bytes once prefixed with LeaseKeyPrefix and encoded.
+	ErrLeaseKeyTooLong = errors.New("memcache: key too long for a lease")
+)
+
+// LeaseKeyPrefix starts the keys under which leases are kept, followed by
+// the key they are for.
+const LeaseKeyPrefix = "_lease:"
+
+// leasePoll is how often LeaseGet looks for the value while another
+// caller holds the lease.
+const leasePoll = 10 * time.Millisecond
+
+// A Lease is the right to fill a missing key, granted by LeaseGet to one
+// caller at a time.
+type Lease struct {
+	Key   string
+	token string
+}
+
+// LeaseGet gets key, like Get, but coordinates the callers that miss, in
+// the manner of mcrouter's leases, so that only one of them recomputes the
+// value while the others wait instead of stampeding the source of truth.
+//
+// On a miss, the first caller is granted a lease for leaseTTL and gets it
+// along with ErrCacheMiss; it should compute the value and store it with
+// LeaseSet. The other callers poll for the value for up to wait, and get
+// ErrLeaseHeld if it doesn't appear. Leases are emulated with an add of
+// the key LeaseKeyPrefix+key, so they work with any memcached.
+//
+// leaseTTL must be positive, so that the lease of a caller that crashed
+// expires, or LeaseGet fails with ErrInvalidArgs. Keys too long to be
+// prefixed fail with ErrLeaseKeyTooLong.
+func (c *Client) LeaseGet(key string, leaseTTL, wait time.Duration) (*Item, *Lease, error) {
+	if leaseTTL <= 0 {
+		return nil, nil, ErrInvalidArgs
+	}
+	if len(c.keyEncoding.encode(LeaseKeyPrefix+key)) > 250 {
+		return nil, nil, ErrLeaseKeyTooLong
+	}
+	it, err := c.Get(key)
+	if err != ErrCacheMiss {
+		return it, nil, err
+	}
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, nil, err
+	}
+	lease := &Lease{Key: key, token: hex.EncodeToString(b[:])}
+	err = c.Add(&Item{
+		Key:        LeaseKeyPrefix + key,
+		Value:      []byte(lease.token),
+		Expiration: Expiration(leaseTTL, c.now()),
+	})
+	switch err {
+	case nil:
+		return nil, lease, ErrCacheMiss
+	case ErrNotStored:
+	default:
+		return nil, nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		time.Sleep(leasePoll)
+		if it, err := c.Get(key); err != ErrCacheMiss {
+			return it, nil, err
+		}
+	}
+	return nil, nil, ErrLeaseHeld
+}
+
+// LeaseSet stores item, whose key must be the lease's, if the lease is
+// still held, and releases it. It fails with ErrLeaseLost, without
+// storing item, if the lease expired or another caller was granted one
+// since.
+func (c *Client) LeaseSet(lease *Lease, item *Item) error {
+	if item.Key != lease.Key {
+		return ErrInvalidArgs
+	}
+	held, err := c.Get(LeaseKeyPrefix + lease.Key)
+	if err == ErrCacheMiss || err == nil && string(held.Value) != lease.token {
+		return ErrLeaseLost
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.Set(item); err != nil {
+		return err
+	}
+	// Release with a CAS, so as not to delete a lease granted meanwhile.
+	held.Value, held.Expiration = nil, -1
+	if err := c.CompareAndSwap(held); err != nil && err != ErrCASConflict && err != ErrNotStored {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package memcache
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestLease(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for name, c := range protoClients(s) {
+		t.Run(name, func(t *testing.T) {
+			defer c.Close()
+			key := "lease-" + name
+			var fills int32
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					it, lease, err := c.LeaseGet(key, time.Second, time.Second)
+					if lease != nil {
+						atomic.AddInt32(&fills, 1)
+						time.Sleep(30 * time.Millisecond) // the expensive computation
+						if err := c.LeaseSet(lease, &Item{Key: key, Value: []byte("v")}); err != nil {
+							t.Error(err)
+						}
+						return
+					}
+					if err != nil || string(it.Value) != "v" {
+						t.Errorf("waiting caller got %v, %v", it, err)
+					}
+				}()
+			}
+			wg.Wait()
+			if fills != 1 {
+				t.Errorf("%d callers were granted the lease, want 1", fills)
+			}
+			if _, err := c.Get(LeaseKeyPrefix + key); err != ErrCacheMiss {
+				t.Errorf("lease was not released: %v", err)
+			}
+		})
+	}
+}
+
+func TestLeaseLost(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+
+	_, lease, err := c.LeaseGet("k", time.Second, 0)
+	if lease == nil || err != ErrCacheMiss {
+		t.Fatalf("LeaseGet = %v, %v; want a lease", lease, err)
+	}
+	if _, other, err := c.LeaseGet("k", time.Second, 0); other != nil || err != ErrLeaseHeld {
+		t.Errorf("second LeaseGet = %v, %v; want ErrLeaseHeld", other, err)
+	}
+	// The lease expires, and another caller is granted a new one.
+	c.Delete(LeaseKeyPrefix + "k")
+	_, newer, _ := c.LeaseGet("k", time.Second, 0)
+	if newer == nil {
+		t.Fatal("no lease granted after the first one expired")
+	}
+	if err := c.LeaseSet(lease, &Item{Key: "k", Value: []byte("late")}); err != ErrLeaseLost {
+		t.Errorf("LeaseSet with a lost lease error = %v, want ErrLeaseLost", err)
+	}
+	if err := c.LeaseSet(newer, &Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Errorf("LeaseSet with the current lease: %v", err)
+	}
+}
+
+func TestLeaseGetArgs(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		if _, lease, err := c.LeaseGet("k", ttl, 0); lease != nil || err != ErrInvalidArgs {
+			t.Errorf("LeaseGet with leaseTTL %v = %v, %v; want ErrInvalidArgs", ttl, lease, err)
+		}
+	}
+	long := strings.Repeat("k", 250-len(LeaseKeyPrefix)+1)
+	if _, lease, err := c.LeaseGet(long, time.Second, 0); lease != nil || err != ErrLeaseKeyTooLong {
+		t.Errorf("LeaseGet of a %d-byte key = %v, %v; want ErrLeaseKeyTooLong", len(long), lease, err)
+	}
+	if _, lease, err := c.LeaseGet(long[1:], time.Second, 0); lease == nil || err != ErrCacheMiss {
+		t.Errorf("LeaseGet of a %d-byte key = %v, %v; want a lease", len(long)-1, lease, err)
+	}
+}
@@ -0,0 +1,41 @@
+package memcache
+
+import "fmt"
+
+// RequestTooLargeError is returned, before anything is sent, by the batch
+// operations over more keys than Config.MaxBatchKeys and the SetMulti
+// calls storing more value bytes than Config.MaxBatchBytes.
+type RequestTooLargeError struct {
+	Op        string // the operation, as in OpEvent
+	Unit      string // "keys" or "bytes"
+	Size, Max int64
+}
+
+func (e *RequestTooLargeError) Error() string {
+	return fmt.Sprintf("memcache: %s of %d %s exceeds the maximum of %d", e.Op, e.Size, e.Unit, e.Max)
+}
+
+// checkBatchKeys returns a *RequestTooLargeError if the batch operation op
+// over n keys exceeds Config.MaxBatchKeys.
+func (c *Client) checkBatchKeys(op string, n int) error {
+	if c.maxBatchKeys > 0 && n > c.maxBatchKeys {
+		return &RequestTooLargeError{Op: op, Unit: "keys", Size: int64(n), Max: int64(c.maxBatchKeys)}
+	}
+	return nil
+}
+
+// checkBatchBytes returns a *RequestTooLargeError if the values of items
+// exceed Config.MaxBatchBytes.
+func (c *Client) checkBatchBytes(op string, items []*Item) error {
+	if c.maxBatchBytes <= 0 {
+		return nil
+	}
+	var n int64
+	for _, item := range items {
+		n += int64(len(item.Value))
+	}
+	if n > c.maxBatchBytes {
+		return &RequestTooLargeError{Op: op, Unit: "bytes", Size: n, Max: c.maxBatchBytes}
+	}
+	return nil
+}
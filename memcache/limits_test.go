@@ -0,0 +1,44 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestBatchLimits(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithBatchLimits(2, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var rerr *RequestTooLargeError
+	if _, err := c.GetMulti([]string{"a", "b", "c"}); !errors.As(err, &rerr) || rerr.Unit != "keys" || rerr.Size != 3 || rerr.Max != 2 {
+		t.Errorf("GetMulti of 3 keys error = %v, want a *RequestTooLargeError", err)
+	}
+	if err := c.DeleteMulti([]string{"a", "b", "c"}); !errors.As(err, &rerr) {
+		t.Errorf("DeleteMulti of 3 keys error = %v, want a *RequestTooLargeError", err)
+	}
+	big := []*Item{{Key: "a", Value: []byte("abc")}, {Key: "b", Value: []byte("de")}}
+	if err := c.SetMulti(big); !errors.As(err, &rerr) || rerr.Unit != "bytes" || rerr.Size != 5 {
+		t.Errorf("SetMulti of 5 bytes error = %v, want a *RequestTooLargeError", err)
+	}
+	if _, err := c.Get("a"); err != ErrCacheMiss {
+		t.Errorf("Get after a refused SetMulti error = %v, want ErrCacheMiss", err)
+	}
+
+	if err := c.SetMulti([]*Item{{Key: "a", Value: []byte("ab")}, {Key: "b", Value: []byte("cd")}}); err != nil {
+		t.Errorf("SetMulti within the limits: %v", err)
+	}
+	if m, err := c.GetMulti([]string{"a", "b"}); err != nil || len(m) != 2 {
+		t.Errorf("GetMulti within the limits = %v, %v", m, err)
+	}
+
+	if _, err := NewClient([]string{s.Addr()}, WithBatchLimits(-1, 0)); err == nil {
+		t.Error("NewClient accepted a negative MaxBatchKeys")
+	}
+}
@@ -0,0 +1,94 @@
+package memcache
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// A capped list is stored as a plain memcache value holding a sequence of
+// elements, each prefixed by its length as a uvarint. The oldest element
+// comes first; pushes drop elements from the front to stay under the cap.
+
+// PushCapped appends element to the list stored under key, creating the
+// list if needed, and drops the oldest elements until the encoded list
+// fits in maxBytes. The update is done with CompareAndSwap, so concurrent
+// pushes to the same key are not lost. Every push stores the list for
+// ttl, so that it expires ttl after the last one; a zero ttl selects the
+// TTL of the key's profile. ErrValueTooLarge is returned if the element
+// alone doesn't fit in maxBytes.
+func (c *Client) PushCapped(key string, element []byte, maxBytes int, ttl time.Duration) error {
+	enc := encodeListElement(nil, element)
+	if len(enc) > maxBytes {
+		return ErrValueTooLarge
+	}
+	if ttl == 0 {
+		ttl = c.profile(key).TTL
+	}
+	for i := 0; i < maxCASRetries; i++ {
+		exp := Expiration(ttl, c.now())
+		it, err := c.Get(key)
+		if err == ErrCacheMiss {
+			err = c.Add(&Item{Key: key, Value: enc, Expiration: exp})
+			if err == ErrNotStored {
+				continue
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		val := make([]byte, 0, len(it.Value)+len(enc))
+		val = append(append(val, it.Value...), enc...)
+		if val, err = trimList(val, maxBytes); err != nil {
+			return err
+		}
+		it.Value = val
+		it.Expiration = exp
+		err = c.CompareAndSwap(it)
+		if err == ErrCASConflict || err == ErrNotStored {
+			continue
+		}
+		return err
+	}
+	return ErrCASConflict
+}
+
+// Members returns the elements of the list stored under key, oldest
+// first. ErrCacheMiss is returned if the list doesn't exist.
+func (c *Client) Members(key string) ([][]byte, error) {
+	it, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var members [][]byte
+	for b := it.Value; len(b) > 0; {
+		n, l := binary.Uvarint(b)
+		if l <= 0 || n > uint64(len(b)-l) {
+			return nil, ErrMalformedList
+		}
+		members = append(members, b[l:l+int(n)])
+		b = b[l+int(n):]
+	}
+	return members, nil
+}
+
+func encodeListElement(dst, element []byte) []byte {
+	var lb [binary.MaxVarintLen64]byte
+	l := binary.PutUvarint(lb[:], uint64(len(element)))
+	dst = append(dst, lb[:l]...)
+	return append(dst, element...)
+}
+
+// trimList drops leading elements of the encoded list b until it is at
+// most maxBytes long.
+func trimList(b []byte, maxBytes int) ([]byte, error) {
+	for len(b) > maxBytes {
+		n, l := binary.Uvarint(b)
+		if l <= 0 || n > uint64(len(b)-l) {
+			return nil, ErrMalformedList
+		}
+		b = b[l+int(n):]
+	}
+	return b, nil
+}
@@ -0,0 +1,75 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestPushCapped(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	if _, err := c.Members("recent"); err != ErrCacheMiss {
+		t.Fatalf("Members on missing list: got %v, want ErrCacheMiss", err)
+	}
+
+	// Each element encodes to 3 bytes, so a 10 byte cap holds three.
+	for i := 0; i < 5; i++ {
+		if err := c.PushCapped("recent", []byte(fmt.Sprintf("e%d", i)), 10, 0); err != nil {
+			t.Fatalf("PushCapped(%d): %v", i, err)
+		}
+	}
+	members, err := c.Members("recent")
+	if err != nil {
+		t.Fatalf("Members: %v", err)
+	}
+	got := fmt.Sprintf("%q", members)
+	if want := `["e2" "e3" "e4"]`; got != want {
+		t.Errorf("Members = %s, want %s", got, want)
+	}
+
+	if err := c.PushCapped("recent", make([]byte, 20), 10, 0); err != ErrValueTooLarge {
+		t.Errorf("PushCapped(oversized): got %v, want ErrValueTooLarge", err)
+	}
+}
+
+func TestPushCappedTTL(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	clock := memcachetest.NewFakeClock(time.Now())
+	s.SetClock(clock)
+	c := New(s.Addr())
+	defer c.Close()
+
+	// Each push keeps the list for a minute from then.
+	for i := 0; i < 3; i++ {
+		if err := c.PushCapped("recent", []byte("e"), 10, time.Minute); err != nil {
+			t.Fatalf("PushCapped(%d): %v", i, err)
+		}
+		clock.Advance(40 * time.Second)
+		if _, err := c.Members("recent"); err != nil {
+			t.Fatalf("Members %d after a push: %v", i, err)
+		}
+	}
+	clock.Advance(time.Minute)
+	if _, err := c.Members("recent"); err != ErrCacheMiss {
+		t.Errorf("Members after the TTL: got %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestMembersMalformed(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	if err := c.Set(&Item{Key: "bogus", Value: []byte{0x05, 'a'}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Members("bogus"); err != ErrMalformedList {
+		t.Errorf("Members: got %v, want ErrMalformedList", err)
+	}
+}
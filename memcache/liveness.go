@@ -0,0 +1,47 @@
+package memcache
+
+import (
+	"bufio"
+	"time"
+)
+
+// Liveness checks that the server at addr answers, with the cheapest
+// command of the protocol, a noop in the binary protocol and a version
+// in the text one, and returns the round trip time. It fails if addr is
+// not one of the client's servers.
+func (c *Client) Liveness(addr string) (time.Duration, error) {
+	server, err := c.serverAddr(addr)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	err = c.withAddrRw(server, func(rw *bufio.ReadWriter) error {
+		return c.cmdRunner.Barrier(rw)
+	})
+	return time.Since(start), err
+}
+
+// Barrier returns once the server has answered every request sent before
+// it on the connection, such as the quiet requests of a pipelined batch,
+// with the cheap command Liveness uses.
+func (c *Conn) Barrier() error {
+	return c.cn.c.cmdRunner.Barrier(c.rw())
+}
+
+// checkIdle reports whether cn, taken from the idle pool, may be reused.
+// Under Config.IdleCheck, a connection idle for longer must first pass a
+// barrier; one failing it is discarded.
+func (c *Client) checkIdle(cn *conn) bool {
+	if c.idleCheck <= 0 || time.Since(cn.idleSince) < c.idleCheck {
+		return true
+	}
+	cn.extendDeadline()
+	if err := c.cmdRunner.Barrier(cn.rw); err != nil {
+		cn.discard()
+		c.lk.Lock()
+		c.pool(cn.addr).staleConns++
+		c.lk.Unlock()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestLiveness(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for name, c := range protoClients(s) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := c.Liveness(s.Addr()); err != nil {
+				t.Errorf("Liveness: %v", err)
+			}
+			if _, err := c.Liveness("127.0.0.1:1"); err == nil {
+				t.Error("Liveness of a foreign address succeeded")
+			}
+			err := c.ForEachServer(context.Background(), func(_ net.Addr, cn *Conn) error {
+				if err := cn.Set(&Item{Key: name, Value: []byte("v")}); err != nil {
+					return err
+				}
+				return cn.Barrier()
+			})
+			if err != nil {
+				t.Errorf("Barrier: %v", err)
+			}
+		})
+	}
+}
+
+func TestIdleCheck(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithIdleCheck(time.Nanosecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	c.lk.Lock()
+	for _, cn := range c.freeconn[s.Addr()] {
+		cn.nc.Close()
+	}
+	c.lk.Unlock()
+	if _, err := c.Get("k"); err != nil {
+		t.Fatalf("Get after the idle connection broke: %v", err)
+	}
+	if st := c.PoolStats()[s.Addr()]; st.StaleConns != 1 || st.OpenConns != 1 {
+		t.Errorf("PoolStats = %+v, want 1 stale connection replaced", st)
+	}
+	if _, err := c.Get("k"); err != nil {
+		t.Fatalf("Get reusing a checked connection: %v", err)
+	}
+	if st := c.PoolStats()[s.Addr()]; st.StaleConns != 1 {
+		t.Errorf("StaleConns = %d, want a healthy connection to pass the check", st.StaleConns)
+	}
+}
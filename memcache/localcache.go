@@ -0,0 +1,145 @@
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// LocalCache keeps recently read items in process, along with the keys
+// found missing, for near-local latency with bounded staleness. An entry
+// is served as is for the freshness window after it was read or last
+// validated. Past it, the entry is validated against memcache with a
+// meta get that returns the item's CAS but not its value: the entry is
+// served again if the CAS is unchanged, and the item is fetched anew
+// otherwise. Over the binary protocol, or from servers without meta
+// commands, the item is fetched at every validation.
+//
+// Items returned by Get are shared with the cache and must not be
+// modified. Writes made through other clients or processes are seen at
+// the first validation after them, so at most fresh late.
+type LocalCache struct {
+	client *Client
+	fresh  time.Duration
+	lru    *lruCache
+}
+
+// localEntry is the cached result of reading a key: the item, or nil if
+// the key was missing.
+type localEntry struct {
+	item    *Item
+	checked time.Time
+}
+
+// NewLocalCache returns a LocalCache reading through c and holding up to
+// maxEntries keys, validated once they are older than fresh.
+func NewLocalCache(c *Client, maxEntries int, fresh time.Duration) *LocalCache {
+	return &LocalCache{
+		client: c,
+		fresh:  fresh,
+		lru:    newLRUCache(maxEntries),
+	}
+}
+
+// Get returns the item stored under key, or ErrCacheMiss, from the local
+// cache while it is fresh or still valid, and from memcache otherwise.
+func (lc *LocalCache) Get(key string) (*Item, error) {
+	now := lc.client.now()
+	if v, ok := lc.lru.get(key, now); ok {
+		e := v.(*localEntry)
+		if now.Sub(e.checked) < lc.fresh {
+			return e.result()
+		}
+		if lc.validate(key, e) {
+			lc.lru.add(key, &localEntry{item: e.item, checked: now}, time.Time{})
+			return e.result()
+		}
+	}
+	it, err := lc.client.Get(key)
+	switch err {
+	case nil, ErrCacheMiss:
+		lc.lru.add(key, &localEntry{item: it, checked: now}, time.Time{})
+	default:
+		lc.lru.remove(key)
+	}
+	return it, err
+}
+
+// Set stores item through the client and drops its key from the local
+// cache, so that the next Get reads it back with its new CAS.
+func (lc *LocalCache) Set(item *Item) error {
+	defer lc.lru.remove(item.Key)
+	return lc.client.Set(item)
+}
+
+// Delete deletes key through the client and drops it from the local cache.
+func (lc *LocalCache) Delete(key string) error {
+	defer lc.lru.remove(key)
+	return lc.client.Delete(key)
+}
+
+// Forget drops key from the local cache only.
+func (lc *LocalCache) Forget(key string) {
+	lc.lru.remove(key)
+}
+
+// Len returns the number of keys held in the local cache.
+func (lc *LocalCache) Len() int {
+	return lc.lru.len()
+}
+
+// validate reports whether e still matches what memcache holds for key.
+// It is false if that can't be told, and the item must then be fetched.
+func (lc *LocalCache) validate(key string, e *localEntry) bool {
+	cas, ok, err := lc.client.casOf(key)
+	if !ok {
+		return false
+	}
+	if e.item == nil {
+		return err == ErrCacheMiss
+	}
+	return err == nil && cas == e.item.Casid
+}
+
+func (e *localEntry) result() (*Item, error) {
+	if e.item == nil {
+		return nil, ErrCacheMiss
+	}
+	return e.item, nil
+}
+
+// casOf returns the CAS of the item stored under key with a meta get.
+// ok is false if the server of key can't run it.
+func (c *Client) casOf(key string) (cas uint64, ok bool, err error) {
+	err = c.withKeyAddr("get", key, func(addr net.Addr, skey string) error {
+		return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+			caps, err := c.capabilities(addr, rw)
+			if err != nil || !caps.Meta {
+				return err
+			}
+			ok = true
+			cas, err = c.metaCAS(rw, skey)
+			return err
+		})
+	})
+	return cas, ok, err
+}
+
+// metaCAS asks for the CAS of key with "mg <key> c".
+func (c *Client) metaCAS(rw *bufio.ReadWriter, key string) (uint64, error) {
+	f, err := c.metaGet(rw, key, "c")
+	if err != nil {
+		return 0, err
+	}
+	tok, ok := f['c']
+	if !ok {
+		return 0, fmt.Errorf("memcache: no CAS in the mg response to %q", key)
+	}
+	cas, err := strconv.ParseUint(tok, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("memcache: malformed CAS %q", tok)
+	}
+	return cas, nil
+}
@@ -0,0 +1,97 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestLocalCache(t *testing.T) {
+	clock := memcachetest.NewFakeClock(time.Unix(1e9, 0))
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for name, writer := range protoClients(s) {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient([]string{s.Addr()}, WithProtocol(name), WithClock(clock))
+			if err != nil {
+				t.Fatal(err)
+			}
+			lc := NewLocalCache(c, 10, time.Second)
+			if err := writer.Set(&Item{Key: "k", Value: []byte("1")}); err != nil {
+				t.Fatal(err)
+			}
+			first, err := lc.Get("k")
+			if err != nil || string(first.Value) != "1" {
+				t.Fatalf("Get = %v, %v, want 1", first, err)
+			}
+			if _, err := lc.Get("absent"); err != ErrCacheMiss {
+				t.Fatalf("Get(absent) error = %v, want ErrCacheMiss", err)
+			}
+
+			// Unchanged items are validated without being fetched.
+			clock.Advance(2 * time.Second)
+			it, err := lc.Get("k")
+			if err != nil || string(it.Value) != "1" {
+				t.Fatalf("Get after the window = %v, %v, want 1", it, err)
+			}
+			if validated := it == first; validated != (name == "text") {
+				t.Errorf("item served from the local cache after validation: %v, want %v", validated, name == "text")
+			}
+			if _, err := lc.Get("absent"); err != ErrCacheMiss {
+				t.Fatalf("Get(absent) after the window error = %v, want ErrCacheMiss", err)
+			}
+
+			// Writes of others are seen once the window is over.
+			if err := writer.Set(&Item{Key: "k", Value: []byte("2")}); err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Set(&Item{Key: "absent", Value: []byte("3")}); err != nil {
+				t.Fatal(err)
+			}
+			if it, err := lc.Get("k"); err != nil || string(it.Value) != "1" {
+				t.Errorf("Get within the window = %v, %v, want the cached 1", it, err)
+			}
+			if _, err := lc.Get("absent"); err != ErrCacheMiss {
+				t.Errorf("Get(absent) within the window error = %v, want the cached miss", err)
+			}
+			clock.Advance(2 * time.Second)
+			if it, err := lc.Get("k"); err != nil || string(it.Value) != "2" {
+				t.Errorf("Get after the window = %v, %v, want 2", it, err)
+			}
+			if it, err := lc.Get("absent"); err != nil || string(it.Value) != "3" {
+				t.Errorf("Get(absent) after the window = %v, %v, want 3", it, err)
+			}
+
+			if err := lc.Delete("k"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := lc.Get("k"); err != ErrCacheMiss {
+				t.Errorf("Get after Delete error = %v, want ErrCacheMiss", err)
+			}
+			writer.Delete("absent")
+		})
+	}
+}
+
+func TestMetaCAS(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	it, err := c.Get("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cas, ok, err := c.casOf("k"); !ok || err != nil || cas != it.Casid {
+		t.Errorf("casOf(k) = %d, %v, %v, want %d", cas, ok, err, it.Casid)
+	}
+	if _, ok, err := c.casOf("absent"); !ok || err != ErrCacheMiss {
+		t.Errorf("casOf(absent) = %v, %v, want ErrCacheMiss", ok, err)
+	}
+	if _, ok, _ := NewBinary(s.Addr()).casOf("k"); ok {
+		t.Error("casOf over the binary protocol used a meta get")
+	}
+}
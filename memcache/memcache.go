@@ -19,6 +19,8 @@ package memcache
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
 	"net"
 	"strings"
@@ -72,6 +74,10 @@ var (
 	ErrUnknownCommand = types.ErrUnknownCommand
 	ErrOutOfMemory    = types.ErrOutOfMemory
 	ErrUnknownError   = types.ErrUnknownError
+
+	// ErrMalformedList is returned when a value read as a capped list
+	// can't be decoded.
+	ErrMalformedList = types.ErrMalformedList
 )
 
 const (
@@ -126,7 +132,10 @@ func NewFromSelectorBinary(ss ServerSelector) *Client {
 // It is safe for unlocked use by multiple concurrent goroutines.
 type Client struct {
 	// Timeout specifies the socket read/write timeout.
-	// If zero, DefaultTimeout is used.
+	// If zero or negative, DefaultTimeout is used.
+	//
+	// Timeout, AuthTimeout and MaxIdleConns must not be assigned once the
+	// client is in use; use ApplyConfig to change them on a live client.
 	Timeout     time.Duration
 	AuthTimeout time.Duration
 
@@ -144,10 +153,79 @@ type Client struct {
 
 	selector ServerSelector
 
-	lk       sync.Mutex
-	freeconn map[string][]*conn
+	lk        sync.Mutex
+	freeconn  map[string][]*conn
+	pools     map[string]*poolCounters
+	authMechs map[string]string // SASL mechanisms by server address
+	caps      map[string]Capabilities
+	limiters  map[string]*tokenBucket
+	closed    bool
 
+	maxOpenConns int
+	idleCheck    time.Duration
+
+	// cfgMu guards the settings ApplyConfig may change on a live client.
+	cfgMu                   sync.RWMutex
 	checkReconnectibleError func(error) bool
+	codec                   Codec
+	phaseBudgets            PhaseBudgets
+	hooks                   []Hook
+	overrides               Overrides
+	overridePrefixes        prefixList
+	pressureHooks           []PressureHook
+	mirror                  *mirror
+
+	tlsConfig   *tls.Config
+	flight      flightGroup // the loads of Memoize
+	touchFlight flightGroup // the loads of TouchOrSet, which stores differently
+	clock       Clock
+	profiles    map[string]Profile
+	tenants     map[string]*tenant // by profile prefix, fixed once built
+	named       map[string]*Client // the pools of Config.Pools
+
+	profilePrefixes prefixList // the prefixes of profiles
+
+	standby    *Client // the pool of Config.StandbyPool
+	standbyTTL time.Duration
+	// standbyWrites copies writes to the standby, and backfills copies
+	// its hits to the client's servers.
+	standbyWrites, backfills *WriteBehind
+
+	policy Policy
+
+	validateKey func(key string) error
+	clientName  string
+	keyEncoding KeyEncoding
+	dialContext func(ctx context.Context, network, address string) (net.Conn, error)
+
+	waitForServers time.Duration
+	writeLimit     RateLimit
+	maxBatchKeys   int
+	maxBatchBytes  int64
+
+	pressureRetryTTL time.Duration
+	checkFlags       bool
+	validateValue    func(value []byte) error
+	deleteInvalid    bool
+	reencoder        Reencoder
+
+	copyOnSet   bool
+	proxyCompat bool
+	allowFlush  bool
+	buffers     *bufferPool // nil unless values are borrowed
+	coalescer   *coalescer  // nil unless writes are coalesced
+
+	// drainMu guards drained, the servers out of rotation; drainCount
+	// is its size, read atomically to skip the lock when it is zero.
+	drainMu    sync.RWMutex
+	drained    map[string]bool
+	drainCount int32
+
+	failureDetector FailureDetector
+	errorBreaker    *errorBreaker
+
+	// goroutines counts the goroutines started by the client, for Debug.
+	goroutines int32
 }
 
 type CmdRunner interface {
@@ -160,22 +238,43 @@ type CmdRunner interface {
 
 	Get(rw *bufio.ReadWriter, keys []string, cb func(*Item)) error
 	Populate(rw *bufio.ReadWriter, verb types.Verb, item *Item) error
+	// SetMulti stores items in as few round trips as the protocol allows,
+	// returning the error of each item, or an error failing the batch.
+	SetMulti(rw *bufio.ReadWriter, items []*Item) ([]error, error)
 	Delete(rw *bufio.ReadWriter, key string) error
+	// DeleteMulti deletes keys in as few round trips as the protocol
+	// allows, returning the error of each key, or an error failing the
+	// batch.
+	DeleteMulti(rw *bufio.ReadWriter, keys []string) ([]error, error)
 	DeleteAll(rw *bufio.ReadWriter) error
 	FlushAll(rw *bufio.ReadWriter) error
 	Touch(rw *bufio.ReadWriter, keys []string, expiration int32) error
+	GetAndTouch(rw *bufio.ReadWriter, keys []string, expiration int32, cb func(*Item)) error
 	IncrDecr(rw *bufio.ReadWriter, verb types.Verb, key string, delta uint64) (uint64, error)
 	Ping(rw *bufio.ReadWriter) error
+	// Version returns the version the server reports.
+	Version(rw *bufio.ReadWriter) (string, error)
+	// Barrier returns once the server has answered every request sent
+	// before it on rw, as cheaply as the protocol allows.
+	Barrier(rw *bufio.ReadWriter) error
+	// Quit asks the server to close the connection.
+	Quit(rw *bufio.ReadWriter) error
+	Stats(rw *bufio.ReadWriter, group string, cb func(name, value string)) error
 }
 
 type Item = types.Item
 
+// ItemMeta is the metadata recorded about a retrieved Item.
+type ItemMeta = types.ItemMeta
+
 // conn is a connection to a server.
 type conn struct {
 	nc   net.Conn
 	rw   *bufio.ReadWriter
 	addr net.Addr
 	c    *Client
+
+	idleSince time.Time // when it was last put in the idle pool
 }
 
 // release returns this connection back to the client's free pool
@@ -199,22 +298,95 @@ func (cn *conn) condRelease(err *error) {
 	if *err == nil || resumableError(*err) {
 		cn.release()
 	} else {
-		cn.nc.Close()
+		cn.discard()
 	}
 }
 
+// discard closes this connection without telling the server, for
+// connections in an unknown state.
+func (cn *conn) discard() {
+	cn.nc.Close()
+	cn.c.lk.Lock()
+	cn.c.releaseSlot(cn.addr)
+	cn.c.lk.Unlock()
+}
+
+// close tells the server this healthy connection is going away before
+// closing it, so that it is torn down cleanly instead of being reset.
+func (cn *conn) close() {
+	cn.extendDeadline()
+	cn.c.cmdRunner.Quit(cn.rw)
+	cn.discard()
+}
+
+// closeLater closes cn in a goroutine of its own, so that neither the
+// operation releasing it nor a Close or Drain of many connections waits
+// on the servers, however slow.
+func (cn *conn) closeLater() {
+	cn.c.goFunc(cn.close)
+}
+
 func (c *Client) putFreeConn(addr net.Addr, cn *conn) {
+	max := c.maxIdleConns()
 	c.lk.Lock()
-	defer c.lk.Unlock()
 	if c.freeconn == nil {
 		c.freeconn = make(map[string][]*conn)
 	}
+	cn.idleSince = time.Now()
+	if !c.closed && c.handOff(addr, cn) {
+		c.lk.Unlock()
+		return
+	}
 	freelist := c.freeconn[addr.String()]
-	if len(freelist) >= c.maxIdleConns() {
-		cn.nc.Close()
+	if c.closed || len(freelist) >= max || c.isDrained(addr.String()) {
+		c.lk.Unlock()
+		cn.closeLater()
 		return
 	}
 	c.freeconn[addr.String()] = append(freelist, cn)
+	c.pool(addr).recycled++
+	c.lk.Unlock()
+}
+
+// trimFreeConns closes idle connections beyond the current idle limit.
+func (c *Client) trimFreeConns() {
+	max := c.maxIdleConns()
+	var extra []*conn
+	c.lk.Lock()
+	for addr, freelist := range c.freeconn {
+		if len(freelist) <= max {
+			continue
+		}
+		extra = append(extra, freelist[max:]...)
+		c.freeconn[addr] = freelist[:max]
+	}
+	c.lk.Unlock()
+	for _, cn := range extra {
+		cn.closeLater()
+	}
+}
+
+// Close closes the idle connections of the client and of its pools,
+// sending each server a quit first. Connections still in use are closed
+// when they are released. The connections are closed in the background,
+// all at once; Debug reports when they are. Close should only be called once the client is
+// no longer needed: connections it opens afterwards are not pooled.
+func (c *Client) Close() error {
+	c.closeStandby()
+	for _, pc := range c.named {
+		pc.Close()
+	}
+	c.lk.Lock()
+	c.closed = true
+	freeconn := c.freeconn
+	c.freeconn = nil
+	c.lk.Unlock()
+	for _, freelist := range freeconn {
+		for _, cn := range freelist {
+			cn.closeLater()
+		}
+	}
+	return nil
 }
 
 func (c *Client) getFreeConn(addr net.Addr) (cn *conn, ok bool) {
@@ -233,29 +405,40 @@ func (c *Client) getFreeConn(addr net.Addr) (cn *conn, ok bool) {
 }
 
 func (c *Client) netTimeout() time.Duration {
-	if c.Timeout != 0 {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
+	if c.Timeout > 0 {
 		return c.Timeout
 	}
 	return DefaultTimeout
 }
 
 func (c *Client) authTimeout() time.Duration {
-	if c.AuthTimeout != 0 {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
+	if c.AuthTimeout > 0 {
 		return c.AuthTimeout
 	}
 	return DefaultAuthTimeout
 }
 
 func (c *Client) maxIdleConns() int {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
 	if c.MaxIdleConns > 0 {
 		return c.MaxIdleConns
 	}
 	return DefaultMaxIdleConns
 }
 
+// ResponseTooLargeError is returned when a server response is larger than
+// Config.MaxResponseSize.
+type ResponseTooLargeError = types.ResponseTooLargeError
+
 // ConnectTimeoutError is the error type used when it takes
 // too long to connect to the desired host. This level of
 // detail can generally be ignored.
+// Operations report it wrapped in a *TimeoutError with PhaseDial.
 type ConnectTimeoutError struct {
 	Addr net.Addr
 }
@@ -268,13 +451,22 @@ func (c *Client) ProtoType() string {
 	return c.cmdRunner.ProtoType()
 }
 
-func (c *Client) dial(addr net.Addr) (net.Conn, error) {
+func (c *Client) dial(addr net.Addr, timeout time.Duration) (net.Conn, error) {
 	type connError struct {
 		cn  net.Conn
 		err error
 	}
 
-	nc, err := net.DialTimeout(addr.Network(), addr.String(), c.netTimeout())
+	var nc net.Conn
+	var err error
+	if c.dialContext != nil {
+		nc, err = c.dialThrough(addr, timeout)
+	} else if c.tlsConfig != nil {
+		d := &net.Dialer{Timeout: timeout}
+		nc, err = tls.DialWithDialer(d, addr.Network(), addr.String(), c.tlsConfig)
+	} else {
+		nc, err = net.DialTimeout(addr.Network(), addr.String(), timeout)
+	}
 	if err == nil {
 		return nc, nil
 	}
@@ -286,20 +478,70 @@ func (c *Client) dial(addr net.Addr) (net.Conn, error) {
 	return nil, err
 }
 
+// dialThrough connects to addr with the client's dial function, starting
+// TLS on top if configured.
+func (c *Client) dialThrough(addr net.Addr, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	nc, err := c.dialContext(ctx, addr.Network(), addr.String())
+	if err != nil || c.tlsConfig == nil {
+		return nc, err
+	}
+	cfg := c.tlsConfig
+	if cfg.ServerName == "" {
+		cfg = cfg.Clone()
+		cfg.ServerName, _, _ = net.SplitHostPort(addr.String())
+	}
+	tc := tls.Client(nc, cfg)
+	tc.SetDeadline(time.Now().Add(timeout))
+	if err := tc.Handshake(); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
 func (c *Client) getConn(addr net.Addr, needNew bool) (*conn, error) {
-	var cn *conn
+	return c.getConnContext(context.Background(), addr, needNew)
+}
+
+// getConnContext is like getConn, but gives up waiting for a connection
+// under MaxOpenConns when ctx is done.
+func (c *Client) getConnContext(ctx context.Context, addr net.Addr, needNew bool) (*conn, error) {
+	start := time.Now()
+	cp, budgeted := c.checkpoints()
 	if !needNew {
-		var ok bool
-		cn, ok = c.getFreeConn(addr)
-		if ok {
-			cn.extendDeadline()
-			return cn, nil
+		for {
+			cn, ok := c.getFreeConn(addr)
+			if !ok {
+				break
+			}
+			if c.checkIdle(cn) {
+				cn.startOp(start, cp, budgeted)
+				return cn, nil
+			}
 		}
 	}
-	nc, err := c.dial(addr)
+	cn, err := c.reserveConn(ctx, addr, start)
 	if err != nil {
 		return nil, err
 	}
+	if cn != nil {
+		cn.startOp(start, cp, budgeted)
+		return cn, nil
+	}
+	dialTimeout := c.netTimeout()
+	if budgeted {
+		dialTimeout = cp.dial
+	}
+	nc, err := c.dial(addr, dialTimeout)
+	if err != nil {
+		c.lk.Lock()
+		c.pool(addr).dialFailures++
+		c.releaseSlot(addr)
+		c.lk.Unlock()
+		return nil, c.timeoutError(addr, PhaseDial, err)
+	}
 	cn = &conn{
 		nc:   nc,
 		addr: addr,
@@ -307,51 +549,211 @@ func (c *Client) getConn(addr net.Addr, needNew bool) (*conn, error) {
 		c:    c,
 	}
 
+	if budgeted {
+		cn.nc.SetDeadline(start.Add(cp.auth))
+	}
 	if c.Username != "" && c.Password != "" && c.cmdRunner.IsAuthSupported() {
-		cn.extendAuthDeadline()
-		return cn, c.cmdRunner.Auth(cn.rw, c.Username, c.Password)
+		if !budgeted {
+			cn.extendAuthDeadline()
+		}
+		if err := c.auth(cn); err != nil {
+			cn.discard()
+			return nil, c.timeoutError(addr, PhaseAuth, err)
+		}
 	}
 
-	cn.extendDeadline()
+	if !budgeted {
+		cn.extendDeadline()
+	}
+	if c.clientName != "" {
+		if err := c.label(cn); err != nil {
+			cn.discard()
+			return nil, c.timeoutError(addr, PhaseAuth, err)
+		}
+	}
+	cn.startOp(start, cp, budgeted)
 	return cn, nil
 }
 
-func (c *Client) onItem(item *Item, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
-	addr, err := c.selector.PickServer(item.Key)
+// mechAuther is implemented by commanders that can skip asking a server
+// for its SASL mechanisms when the client remembers them, such as the
+// binary protocol's.
+type mechAuther interface {
+	AuthMechs(rw *bufio.ReadWriter, mechs, username, password string) (string, error)
+}
+
+// auth authenticates the new connection cn, reusing the mechanisms its
+// server listed for a previous connection.
+func (c *Client) auth(cn *conn) error {
+	ma, ok := c.cmdRunner.(mechAuther)
+	if !ok {
+		return c.cmdRunner.Auth(cn.rw, c.Username, c.Password)
+	}
+	addr := cn.addr.String()
+	c.lk.Lock()
+	mechs := c.authMechs[addr]
+	c.lk.Unlock()
+	mechs, err := ma.AuthMechs(cn.rw, mechs, c.Username, c.Password)
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if err != nil {
+		// The server may have been reconfigured: ask again next time.
+		delete(c.authMechs, addr)
+		return err
+	}
+	if c.authMechs == nil {
+		c.authMechs = make(map[string]string)
+	}
+	c.authMechs[addr] = mechs
+	return nil
+}
+
+// LabelKeyPrefix starts the key that connections of a client with a
+// Config.ClientName fetch once connected, followed by the name.
+const LabelKeyPrefix = "_client:"
+
+// label identifies the new connection cn to its server by fetching the
+// key naming the client. The fetch is a harmless miss, but shows in the
+// server's command logs, such as those of "watch fetchers", so that
+// operators can attribute connections to services.
+func (c *Client) label(cn *conn) error {
+	return c.cmdRunner.Get(cn.rw, []string{LabelKeyPrefix + c.clientName}, func(*Item) {})
+}
+
+// onItem stores item with fn on its server, which it reports in *server
+// if server is not nil, and on the replicas of its profile.
+func (c *Client) onItem(op string, item *Item, server *net.Addr, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
+	if c.checkFlags {
+		if err := checkFlags(item); err != nil {
+			return err
+		}
+	}
+	orig := item.Key
+	key, err := c.prepareKey(op, item.Key)
 	if err != nil {
 		return err
 	}
+	addr, err := c.pickServer(op, item.Key, key)
+	if err != nil {
+		return err
+	}
+	if server != nil {
+		*server = addr
+	}
+	replicas := c.replicas(item.Key, addr)
+	if err := c.chargeQuota(orig, 1, len(item.Value)); err != nil {
+		return err
+	}
+	item = c.itemToSend(item, key)
+	if err := c.throttle(addr, 1); err != nil {
+		return err
+	}
+	if op == "set" && c.coalescer != nil {
+		err = c.coalescer.set(addr, item)
+	} else {
+		err = c.onItemAt(addr, item, fn)
+	}
+	if isMemoryPressure(op, err) {
+		err = c.onPressure(op, orig, addr, item, err, fn)
+	}
+	if err != nil {
+		return err
+	}
+	if len(replicas) > 0 {
+		// The primary decided the outcome, which the replicas copy.
+		if op != "append" && op != "prepend" {
+			fn = (*Client).set
+		}
+		for _, addr := range replicas {
+			if c.throttle(addr, 1) == nil {
+				c.onItemAt(addr, item, fn)
+			}
+		}
+	}
+	return nil
+}
+
+// itemToSend returns the item to send to store item under skey, the key
+// prepared for sending, with the TTL forced by its override or else the
+// default TTL of its profile and, if the client copies on set, a copy of
+// its value.
+func (c *Client) itemToSend(item *Item, skey string) *Item {
+	ttl := c.profile(item.Key).TTL
+	forced := c.override(item.Key).TTL
+	if skey == item.Key && (item.Expiration != 0 || ttl == 0) && forced <= 0 && !c.copyOnSet {
+		return item
+	}
+	it := c.ownItem(item)
+	it.Key = skey
+	if forced > 0 {
+		it.Expiration = Expiration(forced, c.now())
+	} else if it.Expiration == 0 && ttl > 0 {
+		it.Expiration = Expiration(ttl, c.now())
+	}
+	return it
+}
+
+func (c *Client) onItemAt(addr net.Addr, item *Item, fn func(*Client, *bufio.ReadWriter, *Item) error) (err error) {
+	defer func() {
+		err = c.timeoutError(addr, "", err)
+		c.reportOutcome(addr, err)
+	}()
 	cn, err := c.getConn(addr, false)
 	if err != nil {
 		return err
 	}
-	defer cn.condRelease(&err)
 	if err = fn(c, cn.rw, item); err == nil || !c.isReconectibleError(err) {
+		cn.condRelease(&err)
 		return err
 	}
 
-	cn, errRetry := c.getConn(addr, true)
-	if errRetry != nil {
-		return errRetry
+	// Give up the broken connection first, for it may hold the last
+	// slot under MaxOpenConns.
+	cn.discard()
+	if cn, err = c.getConn(addr, true); err != nil {
+		return err
 	}
-	defer cn.condRelease(&errRetry)
+	defer cn.condRelease(&err)
 	return fn(c, cn.rw, item)
 }
 
-func (c *Client) FlushAll() error {
-	return c.selector.Each(c.flushAllFromAddr)
+// FlushAll removes all the items in the cache. It fails with
+// ErrOperationNotPermitted unless the client allows flushes and confirm is
+// ConfirmFlush.
+func (c *Client) FlushAll(confirm string) (err error) {
+	defer c.trace("flush_all", "", time.Now(), &err)
+	if err := c.checkFlush(confirm); err != nil {
+		return err
+	}
+	if _, err := c.prepareKey("flush_all", ""); err != nil {
+		return err
+	}
+	return c.eachServer(c.flushAllFromAddr)
 }
 
 // Get gets the item for the given key. ErrCacheMiss is returned for a
 // memcache cache miss. The key must be at most 250 bytes in length.
 func (c *Client) Get(key string) (item *Item, err error) {
-	err = c.withKeyAddr(key, func(addr net.Addr) error {
-		return c.getFromAddr(addr, []string{key}, func(it *Item) { item = it })
+	var server net.Addr
+	defer c.traceServer("get", key, time.Now(), &server, &err)
+	if c.override(key).Miss {
+		return nil, ErrCacheMiss
+	}
+	c.mirrorGets([]string{key})
+	err = c.withKeyAddr("get", key, func(addr net.Addr, skey string) error {
+		server = addr
+		return c.getFromAddr(addr, []string{skey}, func(it *Item) {
+			it.Key = key
+			item = it
+		})
 	})
 
 	if err == nil && item == nil {
 		err = ErrCacheMiss
 	}
+	if err != nil {
+		item, err = c.standbyGet(key, err)
+	}
 	return
 }
 
@@ -361,53 +763,79 @@ func (c *Client) Get(key string) (item *Item, err error) {
 // no expiration time. ErrCacheMiss is returned if the key is not in the cache.
 // The key must be at most 250 bytes in length.
 func (c *Client) Touch(key string, seconds int32) (err error) {
-	return c.withKeyAddr(key, func(addr net.Addr) error {
-		return c.touchFromAddr(addr, []string{key}, seconds)
+	var server net.Addr
+	defer c.traceWrite("touch", key, 0, seconds, time.Now(), &server, &err)
+	return c.withKeyAddr("touch", key, func(addr net.Addr, skey string) error {
+		server = addr
+		return c.touchFromAddr(addr, []string{skey}, seconds)
 	})
 }
 
-func (c *Client) withKeyAddr(key string, fn func(net.Addr) error) (err error) {
-	if !legalKey(key) {
-		return ErrMalformedKey
+// withKeyAddr calls fn with the server of key and the key to send for op,
+// as prepared by prepareKey.
+func (c *Client) withKeyAddr(op, key string, fn func(addr net.Addr, key string) error) (err error) {
+	orig := key
+	key, err = c.prepareKey(op, key)
+	if err != nil {
+		return err
 	}
-	addr, err := c.selector.PickServer(key)
+	addr, err := c.pickServer(op, orig, key)
 	if err != nil {
 		return err
 	}
-	return fn(addr)
+	return fn(addr, key)
 }
 
 func (c *Client) withAddrRw(addr net.Addr, fn func(*bufio.ReadWriter) error) (err error) {
+	defer func() {
+		err = c.timeoutError(addr, "", err)
+		c.reportOutcome(addr, err)
+	}()
 	cn, err := c.getConn(addr, false)
 	if err != nil {
 		return err
 	}
-	defer cn.condRelease(&err)
-	err = fn(cn.rw)
-	if err == nil || !c.isReconectibleError(err) {
+	if err = fn(cn.rw); err == nil || !c.isReconectibleError(err) {
+		cn.condRelease(&err)
 		return err
 	}
 
-	cn, errRetry := c.getConn(addr, true)
-	if errRetry != nil {
-		return errRetry
+	cn.discard()
+	if cn, err = c.getConn(addr, true); err != nil {
+		return err
 	}
-	defer cn.condRelease(&errRetry)
+	defer cn.condRelease(&err)
 	return fn(cn.rw)
 }
 
-func (c *Client) withKeyRw(key string, fn func(*bufio.ReadWriter) error) error {
-	return c.withKeyAddr(key, func(addr net.Addr) error {
-		return c.withAddrRw(addr, fn)
+func (c *Client) withKeyRw(op, key string, fn func(rw *bufio.ReadWriter, key string) error) error {
+	return c.withKeyAddr(op, key, func(addr net.Addr, key string) error {
+		return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+			return fn(rw, key)
+		})
 	})
 }
 
 func (c *Client) getFromAddr(addr net.Addr, keys []string, cb func(*Item)) error {
 	return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
-		return c.cmdRunner.Get(rw, keys, cb)
+		return c.cmdRunner.Get(rw, keys, c.withMeta(addr, cb))
 	})
 }
 
+// withMeta wraps cb to record the metadata of the items fetched from addr.
+// Items whose value Config.ValidateValue rejects are dropped, as misses,
+// and stale ones are handed to Config.Reencoder.
+func (c *Client) withMeta(addr net.Addr, cb func(*Item)) func(*Item) {
+	return func(it *Item) {
+		if !c.checkValue(addr, it) {
+			return
+		}
+		c.reencode(addr, it)
+		it.Meta = ItemMeta{Addr: addr, Fetched: c.now()}
+		cb(it)
+	}
+}
+
 // flushAllFromAddr send the flush_all command to the given addr
 func (c *Client) flushAllFromAddr(addr net.Addr) error {
 	return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
@@ -423,7 +851,7 @@ func (c *Client) ping(addr net.Addr) error {
 }
 
 func (c *Client) touchFromAddr(addr net.Addr, keys []string, expiration int32) error {
-	return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+	return c.withCapableRw(addr, "touch", func(rw *bufio.ReadWriter) error {
 		return c.cmdRunner.Touch(rw, keys, expiration)
 	})
 }
@@ -433,45 +861,254 @@ func (c *Client) touchFromAddr(addr net.Addr, keys []string, expiration int32) e
 // cache misses. Each key must be at most 250 bytes in length.
 // If no error is returned, the returned map will also be non-nil.
 func (c *Client) GetMulti(keys []string) (map[string]*Item, error) {
-	var lk sync.Mutex
-	m := make(map[string]*Item)
-	addItemToMap := func(it *Item) {
-		lk.Lock()
-		defer lk.Unlock()
+	c.mirrorGets(keys)
+	return c.getMulti(context.Background(), "get", keys, c.getFromAddr)
+}
+
+// GetMultiFunc is like GetMulti, but passes the items found to fn as they
+// arrive from the servers instead of collecting them in a map, so that
+// they can be processed while the others are still being read, without
+// holding them all. Calls to fn don't overlap. If fn returns an error, it
+// isn't called again and GetMultiFunc returns that error once the
+// servers have answered.
+func (c *Client) GetMultiFunc(keys []string, fn func(*Item) error) error {
+	keyMap, orig, err := c.prepareMulti("get", keys)
+	if err != nil {
+		return err
+	}
+	c.mirrorGets(keys)
+	return c.fetchMulti(context.Background(), "get", keyMap, orig, c.getFromAddr, fn)
+}
+
+// GetMultiContext is like GetMulti, but stops waiting for the servers when
+// ctx is done. It then returns the items received so far along with a
+// *PartialError naming the servers that did not answer in time, so that
+// callers can make do with partial hits. Under WithRequestMemo, only the
+// keys not already fetched within the request are requested, and under
+// WithKeyRewrite, the rewritten keys are fetched.
+func (c *Client) GetMultiContext(ctx context.Context, keys []string) (map[string]*Item, error) {
+	if rewrite := keyRewriteFrom(ctx); rewrite != nil {
+		return c.getMultiRewritten(ctx, keys, rewrite)
+	}
+	memo := requestMemoFrom(ctx)
+	if memo == nil {
+		c.mirrorGets(keys)
+		return c.getMulti(ctx, "get", keys, c.getFromAddr)
+	}
+
+	known := make(map[string]*Item)
+	var rest []string
+	for _, key := range keys {
+		if it, ok := memo.get(c, key); ok {
+			known[key] = it
+		} else {
+			rest = append(rest, key)
+		}
+	}
+	m := make(map[string]*Item, len(keys))
+	var err error
+	if len(rest) > 0 {
+		c.mirrorGets(rest)
+		if m, err = c.getMulti(ctx, "get", rest, c.getFromAddr); m == nil {
+			return nil, err
+		}
+		for _, key := range rest {
+			if it, ok := m[key]; ok {
+				memo.put(c, key, it)
+			} else if err == nil {
+				memo.put(c, key, nil)
+			}
+		}
+	}
+	for key, it := range known {
+		if it != nil {
+			m[key] = it
+		}
+	}
+	return m, err
+}
+
+// getMulti fetches keys with fetch, called concurrently once per server,
+// and reports each key to the hooks as a separate op. It stops waiting
+// once ctx is done; the items that arrive afterwards are dropped.
+func (c *Client) getMulti(ctx context.Context, op string, keys []string, fetch func(net.Addr, []string, func(*Item)) error) (map[string]*Item, error) {
+	keyMap, orig, err := c.prepareMulti(op, keys)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]*Item, len(keys))
+	err = c.fetchMulti(ctx, op, keyMap, orig, fetch, func(it *Item) error {
 		m[it.Key] = it
+		return nil
+	})
+	if op == "get" {
+		return c.standbyMulti(keys, m, err)
 	}
+	return m, err
+}
 
-	keyMap := make(map[net.Addr][]string)
+// prepareMulti groups keys by server, as prepared for op by prepareKey,
+// leaving out the keys forced to miss. orig maps the keys prepareKey
+// changed back to the caller's keys. The groups come from keyGroups, and
+// fetchMulti returns them there.
+func (c *Client) prepareMulti(op string, keys []string) (keyMap map[net.Addr][]string, orig map[string]string, err error) {
+	if err := c.checkBatchKeys(op, len(keys)); err != nil {
+		return nil, nil, err
+	}
+	keyMap = make(map[net.Addr][]string)
+	if c.shortCircuit() {
+		return keyMap, nil, nil
+	}
 	for _, key := range keys {
-		if !legalKey(key) {
-			return nil, ErrMalformedKey
+		if c.override(key).Miss {
+			continue
 		}
-		addr, err := c.selector.PickServer(key)
+		skey, err := c.prepareKey(op, key)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if skey != key {
+			if orig == nil {
+				orig = make(map[string]string)
+			}
+			orig[skey] = key
+		}
+		addr, err := c.pickServer(op, key, skey)
+		if err != nil {
+			return nil, nil, err
+		}
+		group, ok := keyMap[addr]
+		if !ok {
+			group = keyGroups.Get().(*keyGroup).keys
 		}
-		keyMap[addr] = append(keyMap[addr], key)
+		keyMap[addr] = append(group, skey)
 	}
+	return keyMap, orig, nil
+}
+
+// keyGroup holds a server's share of the keys of a multi-key op, pooled in
+// keyGroups so that large batches don't regrow them every time.
+type keyGroup struct {
+	keys []string
+}
+
+var keyGroups = sync.Pool{New: func() interface{} { return new(keyGroup) }}
 
-	ch := make(chan error, buffered)
+// releaseKeyGroups returns the groups of keyMap to keyGroups.
+func releaseKeyGroups(keyMap map[net.Addr][]string) {
+	for _, keys := range keyMap {
+		for i := range keys {
+			keys[i] = ""
+		}
+		keyGroups.Put(&keyGroup{keys: keys[:0]})
+	}
+}
+
+// fetchMulti fetches the keys of keyMap, grouped by server, with fetch,
+// called concurrently once per server, and passes the items, under the
+// caller's keys, to fn one at a time as they arrive. Once fn fails, the
+// items that arrive afterwards are dropped and its error is returned. It
+// stops waiting once ctx is done, and reports each key to the hooks as a
+// separate op. The key groups of keyMap are recycled once every fetch has
+// returned, so fetch must not retain its keys.
+func (c *Client) fetchMulti(ctx context.Context, op string, keyMap map[net.Addr][]string, orig map[string]string, fetch func(net.Addr, []string, func(*Item)) error, fn func(*Item) error) error {
+	start := time.Now()
+	var lk sync.Mutex
+	var done bool
+	var fnErr error
+	n := 0
+	for _, keys := range keyMap {
+		n += len(keys)
+	}
+	found := make(map[string]bool, n)
+	deliver := func(it *Item) {
+		lk.Lock()
+		defer lk.Unlock()
+		if done {
+			return
+		}
+		if key, ok := orig[it.Key]; ok {
+			it.Key = key
+		}
+		found[it.Key] = true
+		if fnErr = fn(it); fnErr != nil {
+			done = true
+		}
+	}
+
+	type addrErr struct {
+		addr net.Addr
+		err  error
+	}
+	// Fetches outliving ctx must not block on sending their result.
+	ch := make(chan addrErr, len(keyMap))
 	for addr, keys := range keyMap {
-		go func(addr net.Addr, keys []string) {
-			ch <- c.getFromAddr(addr, keys, addItemToMap)
-		}(addr, keys)
+		addr, keys := addr, keys
+		c.goFunc(func() {
+			ch <- addrErr{addr, fetch(addr, keys, deliver)}
+		})
 	}
 
 	var err error
-	for range keyMap {
-		if ge := <-ch; ge != nil {
-			err = ge
+	errs := make(map[net.Addr]error)
+	pending := len(keyMap)
+	answered := make(map[net.Addr]bool)
+wait:
+	for ; pending > 0; pending-- {
+		select {
+		case ge := <-ch:
+			answered[ge.addr] = true
+			if ge.err != nil {
+				err = ge.err
+				errs[ge.addr] = ge.err
+			}
+		case <-ctx.Done():
+			break wait
 		}
 	}
-	return m, err
+	lk.Lock()
+	done = true
+	lk.Unlock()
+	if pending > 0 {
+		perr := &PartialError{Err: ctx.Err()}
+		for addr := range keyMap {
+			if !answered[addr] {
+				perr.Servers = append(perr.Servers, addr)
+				errs[addr] = perr.Err
+			}
+		}
+		err = perr
+	}
+
+	d := time.Since(start)
+	caller := CallerFrom(ctx)
+	for addr, keys := range keyMap {
+		for _, key := range keys {
+			if o, ok := orig[key]; ok {
+				key = o
+			}
+			kerr := errs[addr]
+			if kerr == nil && !found[key] {
+				kerr = ErrCacheMiss
+			}
+			c.emit(OpEvent{Op: op, Key: key, Start: start, Duration: d, Err: kerr, Caller: caller, Server: addr})
+		}
+	}
+	if pending == 0 {
+		releaseKeyGroups(keyMap)
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
 }
 
 // Set writes the given item, unconditionally.
-func (c *Client) Set(item *Item) error {
-	return c.onItem(item, (*Client).set)
+func (c *Client) Set(item *Item) (err error) {
+	var server net.Addr
+	defer c.traceWrite("set", item.Key, len(item.Value), item.Expiration, time.Now(), &server, &err)
+	defer c.standbySet(item, &err)
+	return c.onItem("set", item, &server, (*Client).set)
 }
 
 func (c *Client) set(rw *bufio.ReadWriter, item *Item) error {
@@ -480,8 +1117,11 @@ func (c *Client) set(rw *bufio.ReadWriter, item *Item) error {
 
 // Add writes the given item, if no value already exists for its
 // key. ErrNotStored is returned if that condition is not met.
-func (c *Client) Add(item *Item) error {
-	return c.onItem(item, (*Client).add)
+func (c *Client) Add(item *Item) (err error) {
+	var server net.Addr
+	defer c.traceWrite("add", item.Key, len(item.Value), item.Expiration, time.Now(), &server, &err)
+	defer c.standbySet(item, &err)
+	return c.onItem("add", item, &server, (*Client).add)
 }
 
 func (c *Client) add(rw *bufio.ReadWriter, item *Item) error {
@@ -490,14 +1130,72 @@ func (c *Client) add(rw *bufio.ReadWriter, item *Item) error {
 
 // Replace writes the given item, but only if the server *does*
 // already hold data for this key
-func (c *Client) Replace(item *Item) error {
-	return c.onItem(item, (*Client).replace)
+func (c *Client) Replace(item *Item) (err error) {
+	var server net.Addr
+	defer c.traceWrite("replace", item.Key, len(item.Value), item.Expiration, time.Now(), &server, &err)
+	defer c.standbySet(item, &err)
+	return c.onItem("replace", item, &server, (*Client).replace)
 }
 
 func (c *Client) replace(rw *bufio.ReadWriter, item *Item) error {
 	return c.cmdRunner.Populate(rw, "replace", item)
 }
 
+// Append appends the given item's value to the data the server already
+// holds for its key. The item's Flags and Expiration are ignored, except
+// with Config.ProxyCompat, where the value is rewritten with the item's
+// Expiration. ErrNotStored is returned if the key does not exist.
+func (c *Client) Append(item *Item) (err error) {
+	var server net.Addr
+	defer c.traceWrite("append", item.Key, len(item.Value), item.Expiration, time.Now(), &server, &err)
+	defer c.standbyDelete(item.Key, &err)
+	return c.onItem("append", item, &server, (*Client).appendItem)
+}
+
+func (c *Client) appendItem(rw *bufio.ReadWriter, item *Item) error {
+	if c.proxyCompat {
+		return c.concatCAS(rw, types.Append, item)
+	}
+	return c.cmdRunner.Populate(rw, types.Append, item)
+}
+
+// Prepend prepends the given item's value to the data the server already
+// holds for its key, like Append.
+func (c *Client) Prepend(item *Item) (err error) {
+	var server net.Addr
+	defer c.traceWrite("prepend", item.Key, len(item.Value), item.Expiration, time.Now(), &server, &err)
+	defer c.standbyDelete(item.Key, &err)
+	return c.onItem("prepend", item, &server, (*Client).prependItem)
+}
+
+func (c *Client) prependItem(rw *bufio.ReadWriter, item *Item) error {
+	if c.proxyCompat {
+		return c.concatCAS(rw, types.Prepend, item)
+	}
+	return c.cmdRunner.Populate(rw, types.Prepend, item)
+}
+
+// maxCASRetries bounds how many times read-modify-write helpers go around
+// their cycle when they keep racing with other writers.
+const maxCASRetries = 10
+
+// AppendOrSet appends the given item's value to the existing data for its
+// key, or stores the item as a new value if the key is absent. When the
+// key is created or removed concurrently between the two steps the cycle
+// is retried, so callers building log-like values don't lose writes.
+// ErrNotStored is returned if the race persists after several attempts.
+func (c *Client) AppendOrSet(item *Item) error {
+	for i := 0; i < maxCASRetries; i++ {
+		if err := c.Append(item); err != ErrNotStored {
+			return err
+		}
+		if err := c.Add(item); err != ErrNotStored {
+			return err
+		}
+	}
+	return ErrNotStored
+}
+
 // CompareAndSwap writes the given item that was previously returned
 // by Get, if the value was neither modified or evicted between the
 // Get and the CompareAndSwap calls. The item's Key should not change
@@ -505,8 +1203,11 @@ func (c *Client) replace(rw *bufio.ReadWriter, item *Item) error {
 // is returned if the value was modified in between the
 // calls. ErrNotStored is returned if the value was evicted in between
 // the calls.
-func (c *Client) CompareAndSwap(item *Item) error {
-	return c.onItem(item, (*Client).cas)
+func (c *Client) CompareAndSwap(item *Item) (err error) {
+	var server net.Addr
+	defer c.traceWrite("cas", item.Key, len(item.Value), item.Expiration, time.Now(), &server, &err)
+	defer c.standbySet(item, &err)
+	return c.onItem("cas", item, &server, (*Client).cas)
 }
 
 func (c *Client) cas(rw *bufio.ReadWriter, item *Item) error {
@@ -515,15 +1216,39 @@ func (c *Client) cas(rw *bufio.ReadWriter, item *Item) error {
 
 // Delete deletes the item with the provided key. The error ErrCacheMiss is
 // returned if the item didn't already exist in the cache.
-func (c *Client) Delete(key string) error {
-	return c.withKeyRw(key, func(rw *bufio.ReadWriter) error {
-		return c.cmdRunner.Delete(rw, key)
+func (c *Client) Delete(key string) (err error) {
+	var server net.Addr
+	defer c.traceServer("delete", key, time.Now(), &server, &err)
+	defer c.standbyDelete(key, &err)
+	return c.withKeyAddr("delete", key, func(addr net.Addr, skey string) error {
+		server = addr
+		del := func(rw *bufio.ReadWriter) error { return c.cmdRunner.Delete(rw, skey) }
+		if err := c.chargeQuota(key, 1, 0); err != nil {
+			return err
+		}
+		if err := c.throttle(addr, 1); err != nil {
+			return err
+		}
+		if err := c.withAddrRw(addr, del); err != nil {
+			return err
+		}
+		for _, addr := range c.replicas(key, addr) {
+			if c.throttle(addr, 1) == nil {
+				c.withAddrRw(addr, del)
+			}
+		}
+		return nil
 	})
 }
 
-// DeleteAll deletes all items in the cache.
-func (c *Client) DeleteAll() error {
-	return c.withKeyRw("", func(rw *bufio.ReadWriter) error {
+// DeleteAll deletes all items in the cache. Like FlushAll, it requires
+// the client to allow flushes and confirm to be ConfirmFlush.
+func (c *Client) DeleteAll(confirm string) (err error) {
+	defer c.trace("flush_all", "", time.Now(), &err)
+	if err := c.checkFlush(confirm); err != nil {
+		return err
+	}
+	return c.withKeyRw("flush_all", "", func(rw *bufio.ReadWriter, _ string) error {
 		return c.cmdRunner.DeleteAll(rw)
 	})
 }
@@ -531,7 +1256,7 @@ func (c *Client) DeleteAll() error {
 // Ping checks all instances if they are alive. Returns error if any
 // of them is down.
 func (c *Client) Ping() error {
-	return c.selector.Each(c.ping)
+	return c.eachServer(c.ping)
 }
 
 // Increment atomically increments key by delta. The return value is
@@ -555,10 +1280,23 @@ func (c *Client) Decrement(key string, delta uint64) (newValue uint64, err error
 
 func (c *Client) incrDecr(verb types.Verb, key string, delta uint64) (uint64, error) {
 	var val uint64
-	err := c.withKeyRw(key, func(rw *bufio.ReadWriter) error {
-		var errIncDec error
-		val, errIncDec = c.cmdRunner.IncrDecr(rw, verb, key, delta)
-		return errIncDec
+	var err error
+	var server net.Addr
+	defer c.traceServer(string(verb), key, time.Now(), &server, &err)
+	defer c.standbyDelete(key, &err)
+	err = c.withKeyAddr(string(verb), key, func(addr net.Addr, skey string) error {
+		server = addr
+		if err := c.chargeQuota(key, 1, 0); err != nil {
+			return err
+		}
+		if err := c.throttle(addr, 1); err != nil {
+			return err
+		}
+		return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+			var errIncDec error
+			val, errIncDec = c.cmdRunner.IncrDecr(rw, verb, skey, delta)
+			return errIncDec
+		})
 	})
 	return val, err
 }
@@ -568,8 +1306,11 @@ func (c *Client) isReconectibleError(err error) bool {
 		return false
 	}
 
-	if c.checkReconnectibleError != nil {
-		return c.checkReconnectibleError(err)
+	c.cfgMu.RLock()
+	check := c.checkReconnectibleError
+	c.cfgMu.RUnlock()
+	if check != nil {
+		return check(err)
 	}
 
 	if errors.Is(err, syscall.EPIPE) {
@@ -587,6 +1328,8 @@ func (c *Client) isReconectibleError(err error) bool {
 }
 
 func (c *Client) SetCheckReconnectibleError(f func(error) bool) {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
 	c.checkReconnectibleError = f
 }
 
@@ -601,3 +1344,22 @@ func legalKey(key string) bool {
 	}
 	return true
 }
+
+// maxRelativeExpiration is the longest expiration memcached interprets as
+// relative to now; longer durations must be sent as Unix timestamps.
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
+// Expiration converts ttl to an Item.Expiration value, as of now: whole
+// seconds, at least one, or a Unix time for ttls too long for memcached
+// to take as relative. Zero or negative ttls mean no expiration.
+func Expiration(ttl time.Duration, now time.Time) int32 {
+	switch {
+	case ttl <= 0:
+		return 0
+	case ttl < time.Second:
+		return 1
+	case ttl > maxRelativeExpiration:
+		return int32(now.Add(ttl).Unix())
+	}
+	return int32(ttl / time.Second)
+}
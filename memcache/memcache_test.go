@@ -33,16 +33,6 @@ import (
 	"github.com/skinass/gomemcache/memcache/proto/text"
 )
 
-var SupportedCfg = map[string]struct {
-	Touch    bool
-	GetMulti bool
-}{
-	text.ProtoType: {
-		Touch: false, GetMulti: true},
-	bin.ProtoType: {
-		Touch: true, GetMulti: false},
-}
-
 const (
 	doLocalhostTextProtoTest = true
 	testTextServer           = "127.0.0.1:11211"
@@ -89,7 +79,8 @@ func TestLocalhostBinaryProto(t *testing.T) {
 	c.Username, c.Password = testBinaryServerUsername, testBinaryServerPassword
 	c.Timeout = time.Second
 	c.AuthTimeout = time.Second
-	err := c.FlushAll()
+	c.allowFlush = true
+	err := c.FlushAll(ConfirmFlush)
 	if err != nil {
 		t.Errorf("error flush all: %v", err)
 		return
@@ -131,6 +122,7 @@ func mustSetF(t *testing.T, c *Client) func(*Item) {
 }
 
 func testWithClient(t *testing.T, c *Client) {
+	c.allowFlush = true
 	checkErr := func(err error, format string, args ...interface{}) {
 		if err != nil {
 			t.Fatalf(format, args...)
@@ -180,7 +172,7 @@ func testWithClient(t *testing.T, c *Client) {
 	} else if c.ProtoType() == bin.ProtoType && err != nil {
 		t.Errorf("set(foo bar) should return nil instead of %v", err)
 	}
-	malFormed = &Item{Key: "foo" + string(0x7f), Value: []byte("foobarval")}
+	malFormed = &Item{Key: "foo" + string(rune(0x7f)), Value: []byte("foobarval")}
 	err = c.Set(malFormed)
 	if c.ProtoType() == text.ProtoType && err != ErrMalformedKey {
 		t.Errorf("set(foo<0x7f>) should return ErrMalformedKey instead of %v", err)
@@ -205,24 +197,22 @@ func testWithClient(t *testing.T, c *Client) {
 	// checkErr(err, "replaced(foo): %v", err)
 
 	// GetMulti
-	if SupportedCfg[c.ProtoType()].GetMulti {
-		m, err := c.GetMulti([]string{"foo", "bar"})
-		checkErr(err, "GetMulti: %v", err)
-		if g, e := len(m), 2; g != e {
-			t.Errorf("GetMulti: got len(map) = %d, want = %d", g, e)
-		}
-		if _, ok := m["foo"]; !ok {
-			t.Fatalf("GetMulti: didn't get key 'foo'")
-		}
-		if _, ok := m["bar"]; !ok {
-			t.Fatalf("GetMulti: didn't get key 'bar'")
-		}
-		if g, e := string(m["foo"].Value), "fooval"; g != e {
-			t.Errorf("GetMulti: foo: got %q, want %q", g, e)
-		}
-		if g, e := string(m["bar"].Value), "barval"; g != e {
-			t.Errorf("GetMulti: bar: got %q, want %q", g, e)
-		}
+	m, err := c.GetMulti([]string{"foo", "bar"})
+	checkErr(err, "GetMulti: %v", err)
+	if g, e := len(m), 2; g != e {
+		t.Errorf("GetMulti: got len(map) = %d, want = %d", g, e)
+	}
+	if _, ok := m["foo"]; !ok {
+		t.Fatalf("GetMulti: didn't get key 'foo'")
+	}
+	if _, ok := m["bar"]; !ok {
+		t.Fatalf("GetMulti: didn't get key 'bar'")
+	}
+	if g, e := string(m["foo"].Value), "fooval"; g != e {
+		t.Errorf("GetMulti: foo: got %q, want %q", g, e)
+	}
+	if g, e := string(m["bar"].Value), "barval"; g != e {
+		t.Errorf("GetMulti: bar: got %q, want %q", g, e)
 	}
 
 	// Delete
@@ -259,12 +249,16 @@ func testWithClient(t *testing.T, c *Client) {
 		t.Fatalf("increment non-number: want ErrNonNumeric, got %v", err)
 	}
 
-	if SupportedCfg[c.ProtoType()].Touch {
+	addr, err := c.selector.PickServer("foo")
+	checkErr(err, "PickServer: %v", err)
+	caps, err := c.Capabilities(addr.String())
+	checkErr(err, "Capabilities: %v", err)
+	if caps.Touch {
 		testTouchWithClient(t, c)
 	}
 
 	// Test Delete All
-	err = c.DeleteAll()
+	err = c.DeleteAll(ConfirmFlush)
 	checkErr(err, "DeleteAll: %v", err)
 	it, err = c.Get("bar")
 	if err != ErrCacheMiss {
@@ -388,7 +382,7 @@ func BenchmarkOnItem(b *testing.B) {
 
 	addr := fakeServer.Addr()
 	c := New(addr.String())
-	if _, err := c.getConn(addr); err != nil {
+	if _, err := c.getConn(addr, false); err != nil {
 		b.Fatal("failed to initialize connection to fake server")
 	}
 
@@ -396,6 +390,25 @@ func BenchmarkOnItem(b *testing.B) {
 	dummyFn := func(_ *Client, _ *bufio.ReadWriter, _ *Item) error { return nil }
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		c.onItem(&item, dummyFn)
+		c.onItem("set", &item, nil, dummyFn)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	now := time.Unix(1000000000, 0)
+	tests := []struct {
+		ttl  time.Duration
+		want int32
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Millisecond, 1},
+		{time.Hour, 3600},
+		{60 * 24 * time.Hour, 1000000000 + 60*24*3600},
+	}
+	for _, tt := range tests {
+		if got := Expiration(tt.ttl, now); got != tt.want {
+			t.Errorf("Expiration(%v) = %d, want %d", tt.ttl, got, tt.want)
+		}
 	}
 }
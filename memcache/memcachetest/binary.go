@@ -0,0 +1,358 @@
+package memcachetest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// version is what the server reports to version commands, unless
+// SetVersion changes it.
+const version = "1.6.0-fake"
+
+const (
+	magicRequest  = 0x80
+	magicResponse = 0x81
+	headerLen     = 24
+)
+
+// Binary protocol opcodes.
+const (
+	opGet        = 0x00
+	opSet        = 0x01
+	opAdd        = 0x02
+	opReplace    = 0x03
+	opDelete     = 0x04
+	opIncrement  = 0x05
+	opDecrement  = 0x06
+	opQuit       = 0x07
+	opFlush      = 0x08
+	opGetQ       = 0x09
+	opNoop       = 0x0a
+	opVersion    = 0x0b
+	opGetK       = 0x0c
+	opGetKQ      = 0x0d
+	opAppend     = 0x0e
+	opPrepend    = 0x0f
+	opStat       = 0x10
+	opSetQ       = 0x11
+	opAddQ       = 0x12
+	opReplaceQ   = 0x13
+	opDeleteQ    = 0x14
+	opIncrementQ = 0x15
+	opDecrementQ = 0x16
+	opQuitQ      = 0x17
+	opFlushQ     = 0x18
+	opAppendQ    = 0x19
+	opPrependQ   = 0x1a
+	opTouch      = 0x1c
+	opGAT        = 0x1d
+	opGATQ       = 0x1e
+	opAuthList   = 0x20
+	opAuthStart  = 0x21
+	opGATK       = 0x23
+	opGATKQ      = 0x24
+)
+
+// Binary protocol response statuses.
+const (
+	statusOK             = 0x00
+	statusNotFound       = 0x01
+	statusKeyExists      = 0x02
+	statusNonNumeric     = 0x06
+	statusValueNotStored = 0x05
+	statusUnknownCommand = 0x81
+	statusOutOfMemory    = 0x82
+)
+
+type request struct {
+	op     byte
+	opaque uint32
+	cas    uint64
+	extras []byte
+	key    string
+	value  []byte
+}
+
+type response struct {
+	status uint16
+	cas    uint64
+	extras []byte
+	key    string
+	value  []byte
+}
+
+func (s *Server) handleBinary(rw *bufio.ReadWriter) {
+	for {
+		req, err := readRequest(rw.Reader)
+		if err != nil {
+			return
+		}
+		if req.op == opQuitQ {
+			s.quit()
+			return
+		}
+		if req.op == opStat {
+			if err := s.writeStats(rw.Writer, req); err != nil {
+				return
+			}
+		} else {
+			resp, quiet := s.dispatchBinary(req)
+			if resp != nil && !(quiet && resp.status == statusOK) {
+				if err := writeResponse(rw.Writer, req, resp); err != nil {
+					return
+				}
+			}
+		}
+		// Only flush once the client stops pipelining, like a real server.
+		if rw.Reader.Buffered() == 0 {
+			if err := rw.Flush(); err != nil {
+				return
+			}
+		}
+		if req.op == opQuit {
+			s.quit()
+			return
+		}
+	}
+}
+
+func readRequest(r *bufio.Reader) (*request, error) {
+	var h [headerLen]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, err
+	}
+	if h[0] != magicRequest {
+		return nil, fmt.Errorf("memcachetest: bad magic %#x", h[0])
+	}
+	keyLen := int(binary.BigEndian.Uint16(h[2:]))
+	extLen := int(h[4])
+	bodyLen := int(binary.BigEndian.Uint32(h[8:]))
+	if keyLen+extLen > bodyLen {
+		return nil, fmt.Errorf("memcachetest: bad body length %d", bodyLen)
+	}
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return &request{
+		op:     h[1],
+		opaque: binary.BigEndian.Uint32(h[12:]),
+		cas:    binary.BigEndian.Uint64(h[16:]),
+		extras: body[:extLen],
+		key:    string(body[extLen : extLen+keyLen]),
+		value:  body[extLen+keyLen:],
+	}, nil
+}
+
+func writeResponse(w io.Writer, req *request, resp *response) error {
+	var h [headerLen]byte
+	h[0] = magicResponse
+	h[1] = req.op
+	binary.BigEndian.PutUint16(h[2:], uint16(len(resp.key)))
+	h[4] = byte(len(resp.extras))
+	binary.BigEndian.PutUint16(h[6:], resp.status)
+	binary.BigEndian.PutUint32(h[8:], uint32(len(resp.extras)+len(resp.key)+len(resp.value)))
+	binary.BigEndian.PutUint32(h[12:], req.opaque)
+	binary.BigEndian.PutUint64(h[16:], resp.cas)
+	for _, b := range [][]byte{h[:], resp.extras, []byte(resp.key), resp.value} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatchBinary executes req and returns the response, if any, and
+// whether the request was a quiet one whose success must not be answered.
+func (s *Server) dispatchBinary(req *request) (resp *response, quiet bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if since, ok := binarySince[req.op]; ok && !s.supports(since) {
+		return &response{status: statusUnknownCommand}, false
+	}
+	switch req.op {
+	case opGet, opGetK:
+		return s.binGet(req, false, -1), false
+	case opGetQ, opGetKQ:
+		return s.binGet(req, true, -1), false
+	case opGAT, opGATK, opGATQ, opGATKQ:
+		if len(req.extras) != 4 {
+			return &response{status: statusUnknownCommand}, false
+		}
+		exp := int32(binary.BigEndian.Uint32(req.extras))
+		return s.binGet(req, req.op == opGATQ || req.op == opGATKQ, exp), false
+	case opSet, opAdd, opReplace:
+		return s.binStore(req), false
+	case opSetQ, opAddQ, opReplaceQ:
+		return s.binStore(req), true
+	case opAppend, opPrepend:
+		return s.binConcat(req), false
+	case opAppendQ, opPrependQ:
+		return s.binConcat(req), true
+	case opDelete, opDeleteQ:
+		if s.lookup(req.key) == nil {
+			return &response{status: statusNotFound}, req.op == opDeleteQ
+		}
+		delete(s.items, req.key)
+		return &response{}, req.op == opDeleteQ
+	case opIncrement, opDecrement, opIncrementQ, opDecrementQ:
+		return s.binIncrDecr(req), req.op == opIncrementQ || req.op == opDecrementQ
+	case opTouch:
+		if len(req.extras) != 4 {
+			return &response{status: statusUnknownCommand}, false
+		}
+		it := s.lookup(req.key)
+		if it == nil {
+			return &response{status: statusNotFound}, false
+		}
+		it.exp = s.expiry(int32(binary.BigEndian.Uint32(req.extras)))
+		return &response{cas: it.cas}, false
+	case opFlush, opFlushQ:
+		s.items = make(map[string]*item)
+		return &response{}, req.op == opFlushQ
+	case opNoop, opQuit:
+		return &response{}, false
+	case opVersion:
+		return &response{value: []byte(s.versionLocked())}, false
+	case opAuthList:
+		return &response{value: []byte("PLAIN")}, false
+	case opAuthStart:
+		return &response{value: []byte("Authenticated")}, false
+	}
+	return &response{status: statusUnknownCommand}, false
+}
+
+// writeStats answers a stat request with a packet per statistic of the
+// group named by its key, terminated by a packet with an empty key.
+func (s *Server) writeStats(w io.Writer, req *request) error {
+	s.mu.Lock()
+	stats, ok := s.stats(req.key)
+	s.mu.Unlock()
+	if !ok {
+		return writeResponse(w, req, &response{status: statusNotFound})
+	}
+	for _, st := range stats {
+		if err := writeResponse(w, req, &response{key: st[0], value: []byte(st[1])}); err != nil {
+			return err
+		}
+	}
+	return writeResponse(w, req, &response{})
+}
+
+// binGet looks up req.key, touching it first if exp is not negative. Quiet
+// gets only answer hits, so a quiet miss yields a nil response.
+func (s *Server) binGet(req *request, quiet bool, exp int32) *response {
+	s.noteLabel(req.key)
+	it := s.lookup(req.key)
+	if it == nil {
+		if quiet {
+			return nil
+		}
+		return &response{status: statusNotFound, value: []byte("Not found")}
+	}
+	if exp >= 0 {
+		it.exp = s.expiry(exp)
+	}
+	resp := &response{cas: it.cas, extras: make([]byte, 4), value: it.value}
+	binary.BigEndian.PutUint32(resp.extras, it.flags)
+	switch req.op {
+	case opGetK, opGetKQ, opGATK, opGATKQ:
+		resp.key = req.key
+	}
+	return resp
+}
+
+func (s *Server) binStore(req *request) *response {
+	if len(req.extras) != 8 {
+		return &response{status: statusUnknownCommand}
+	}
+	it := s.lookup(req.key)
+	switch req.op {
+	case opAdd, opAddQ:
+		if it != nil {
+			return &response{status: statusKeyExists}
+		}
+	case opReplace, opReplaceQ:
+		if it == nil {
+			return &response{status: statusNotFound}
+		}
+	}
+	if req.cas != 0 {
+		if it == nil {
+			return &response{status: statusNotFound}
+		}
+		if it.cas != req.cas {
+			return &response{status: statusKeyExists}
+		}
+	}
+	if !s.fits(req.key, len(req.value)) {
+		return &response{status: statusOutOfMemory}
+	}
+	it = &item{
+		value: append([]byte(nil), req.value...),
+		flags: binary.BigEndian.Uint32(req.extras),
+		exp:   s.expiry(int32(binary.BigEndian.Uint32(req.extras[4:]))),
+		cas:   s.nextCas(),
+	}
+	s.items[req.key] = it
+	return &response{cas: it.cas}
+}
+
+func (s *Server) binConcat(req *request) *response {
+	it := s.lookup(req.key)
+	if it == nil {
+		return &response{status: statusValueNotStored}
+	}
+	if !s.fits(req.key, len(it.value)+len(req.value)) {
+		return &response{status: statusOutOfMemory}
+	}
+	if req.op == opAppend || req.op == opAppendQ {
+		it.value = append(append([]byte(nil), it.value...), req.value...)
+	} else {
+		it.value = append(append([]byte(nil), req.value...), it.value...)
+	}
+	it.cas = s.nextCas()
+	return &response{cas: it.cas}
+}
+
+func (s *Server) binIncrDecr(req *request) *response {
+	if len(req.extras) != 20 {
+		return &response{status: statusUnknownCommand}
+	}
+	delta := binary.BigEndian.Uint64(req.extras)
+	initial := binary.BigEndian.Uint64(req.extras[8:])
+	exp := binary.BigEndian.Uint32(req.extras[16:])
+
+	var cur uint64
+	it := s.lookup(req.key)
+	switch {
+	case it == nil && exp == 0xffffffff:
+		return &response{status: statusNotFound}
+	case it == nil:
+		it = &item{exp: s.expiry(int32(exp))}
+		s.items[req.key] = it
+		cur = initial
+	default:
+		n, err := strconv.ParseUint(string(it.value), 10, 64)
+		if err != nil {
+			return &response{status: statusNonNumeric}
+		}
+		switch {
+		case req.op == opIncrement || req.op == opIncrementQ:
+			cur = n + delta
+		case delta > n:
+			cur = 0
+		default:
+			cur = n - delta
+		}
+	}
+	it.value = []byte(strconv.FormatUint(cur, 10))
+	it.cas = s.nextCas()
+	resp := &response{cas: it.cas, value: make([]byte, 8)}
+	binary.BigEndian.PutUint64(resp.value, cur)
+	return resp
+}
@@ -0,0 +1,116 @@
+package memcachetest
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Faults describes the failures a Server injects into its responses, to
+// exercise a client's error handling and recovery. Each rate is the
+// probability, between 0 and 1, that a given write of responses is hit by
+// that fault. At most one fault hits a write.
+type Faults struct {
+	// DisconnectRate is the rate of connections closed instead of
+	// answering.
+	DisconnectRate float64
+
+	// SlowRate is the rate of responses delayed by Delay.
+	SlowRate float64
+	Delay    time.Duration
+
+	// PartialRate is the rate of responses cut short: only the first half
+	// is written before the connection is closed.
+	PartialRate float64
+
+	// GarbageRate is the rate of responses replaced by random bytes of
+	// the same length. The connection is left open.
+	GarbageRate float64
+
+	// Seed seeds the random source deciding which writes are hit, for
+	// reproducible runs.
+	Seed int64
+}
+
+// SetFaults makes the server inject f into the responses it writes from
+// now on, on new and existing connections. The zero Faults turns fault
+// injection off.
+func (s *Server) SetFaults(f Faults) {
+	s.faultMu.Lock()
+	defer s.faultMu.Unlock()
+	s.faults = f
+	s.rand = rand.New(rand.NewSource(f.Seed))
+}
+
+// fault is a fault picked for one write.
+type fault int
+
+const (
+	noFault fault = iota
+	faultDisconnect
+	faultSlow
+	faultPartial
+	faultGarbage
+)
+
+// pickFault decides which fault, if any, hits the next write, and returns
+// the garbage to write instead of n bytes for faultGarbage.
+func (s *Server) pickFault(n int) (fault, time.Duration, []byte) {
+	s.faultMu.Lock()
+	defer s.faultMu.Unlock()
+	f := s.faults
+	if s.rand == nil {
+		return noFault, 0, nil
+	}
+	x := s.rand.Float64()
+	switch {
+	case x < f.DisconnectRate:
+		return faultDisconnect, 0, nil
+	case x < f.DisconnectRate+f.SlowRate:
+		return faultSlow, f.Delay, nil
+	case x < f.DisconnectRate+f.SlowRate+f.PartialRate:
+		return faultPartial, 0, nil
+	case x < f.DisconnectRate+f.SlowRate+f.PartialRate+f.GarbageRate:
+		garbage := make([]byte, n)
+		s.rand.Read(garbage)
+		return faultGarbage, 0, garbage
+	}
+	return noFault, 0, nil
+}
+
+// faultConn is a server connection injecting its server's faults into
+// what it writes.
+type faultConn struct {
+	net.Conn
+	s *Server
+
+	closeOnce sync.Once
+}
+
+func (c *faultConn) Write(p []byte) (int, error) {
+	f, delay, garbage := c.s.pickFault(len(p))
+	switch f {
+	case faultDisconnect:
+		c.Close()
+		return 0, errInjected
+	case faultSlow:
+		time.Sleep(delay)
+	case faultPartial:
+		c.Conn.Write(p[:len(p)/2])
+		c.Close()
+		return len(p) / 2, errInjected
+	case faultGarbage:
+		return c.Conn.Write(garbage)
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *faultConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() { err = c.Conn.Close() })
+	return err
+}
+
+var errInjected = errors.New("memcachetest: injected fault")
@@ -0,0 +1,156 @@
+package memcachetest
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Selector picks the server of each key. It has the method set of
+// memcache.ServerSelector, so the selectors here can be given to a client
+// with memcache.WithSelector.
+type Selector interface {
+	PickServer(key string) (net.Addr, error)
+	Each(func(net.Addr) error) error
+}
+
+// StaticSelector is a Selector routing keys by an explicit table rather
+// than by hashing, so that tests can put keys on the fake servers of
+// their choice. It is safe for concurrent use.
+type StaticSelector struct {
+	mu     sync.RWMutex
+	routes map[string]net.Addr
+	def    net.Addr
+}
+
+// NewStaticSelector returns a StaticSelector sending the keys it has no
+// route for to the server at defaultAddr, or failing them if defaultAddr
+// is empty.
+func NewStaticSelector(defaultAddr string) *StaticSelector {
+	s := &StaticSelector{routes: make(map[string]net.Addr)}
+	if defaultAddr != "" {
+		s.def = addr(defaultAddr)
+	}
+	return s
+}
+
+// Pin routes key to the server at serverAddr, such as a Server's Addr.
+func (s *StaticSelector) Pin(key, serverAddr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[key] = addr(serverAddr)
+}
+
+// PickServer returns the server key is pinned to, or the default server.
+func (s *StaticSelector) PickServer(key string) (net.Addr, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if a, ok := s.routes[key]; ok {
+		return a, nil
+	}
+	if s.def == nil {
+		return nil, fmt.Errorf("memcachetest: no server pinned for key %q", key)
+	}
+	return s.def, nil
+}
+
+// Each calls f once for each server of the selector, in address order.
+func (s *StaticSelector) Each(f func(net.Addr) error) error {
+	s.mu.RLock()
+	seen := make(map[string]net.Addr)
+	for _, a := range s.routes {
+		seen[a.String()] = a
+	}
+	if s.def != nil {
+		seen[s.def.String()] = s.def
+	}
+	s.mu.RUnlock()
+	addrs := make([]string, 0, len(seen))
+	for a := range seen {
+		addrs = append(addrs, a)
+	}
+	sort.Strings(addrs)
+	for _, a := range addrs {
+		if err := f(seen[a]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staticAddr is the address of a server given by its string form.
+type staticAddr string
+
+func addr(s string) net.Addr { return staticAddr(s) }
+
+func (a staticAddr) Network() string {
+	if strings.Contains(string(a), "/") {
+		return "unix"
+	}
+	return "tcp"
+}
+
+func (a staticAddr) String() string { return string(a) }
+
+// Pick is a routing decision recorded by a RecordingSelector.
+type Pick struct {
+	Key    string
+	Server net.Addr // nil if Err is set
+	Err    error
+}
+
+// RecordingSelector wraps a Selector and records its routing decisions,
+// so that tests can assert where a client sent each key. It is safe for
+// concurrent use.
+type RecordingSelector struct {
+	Selector
+
+	mu    sync.Mutex
+	picks []Pick
+}
+
+// NewRecordingSelector returns a RecordingSelector recording the
+// decisions of s.
+func NewRecordingSelector(s Selector) *RecordingSelector {
+	return &RecordingSelector{Selector: s}
+}
+
+// PickServer picks the server of key with the wrapped selector and
+// records the decision.
+func (r *RecordingSelector) PickServer(key string) (net.Addr, error) {
+	a, err := r.Selector.PickServer(key)
+	r.mu.Lock()
+	r.picks = append(r.picks, Pick{Key: key, Server: a, Err: err})
+	r.mu.Unlock()
+	return a, err
+}
+
+// Picks returns the decisions recorded since the last Reset, in the order
+// they were made.
+func (r *RecordingSelector) Picks() []Pick {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Pick(nil), r.picks...)
+}
+
+// Servers returns the address of the server each key was last sent to.
+func (r *RecordingSelector) Servers() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := make(map[string]string)
+	for _, p := range r.picks {
+		if p.Err == nil {
+			m[p.Key] = p.Server.String()
+		}
+	}
+	return m
+}
+
+// Reset forgets the recorded decisions.
+func (r *RecordingSelector) Reset() {
+	r.mu.Lock()
+	r.picks = nil
+	r.mu.Unlock()
+}
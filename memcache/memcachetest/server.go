@@ -0,0 +1,597 @@
+// Package memcachetest provides utilities for testing code that talks to
+// memcached, such as an in-memory server.
+package memcachetest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server is an in-memory memcached speaking the text and binary protocols,
+// listening on a loopback address. It implements just enough of the server to
+// exercise a client without depending on a real memcached being available.
+type Server struct {
+	ln net.Listener
+
+	mu     sync.Mutex
+	items  map[string]*item
+	cas    uint64
+	conns  int
+	quits  int
+	labels []string
+	clock  Clock
+	memory int // the limit of SetMemoryLimit
+
+	noCrawler bool   // lru_crawler is disabled
+	version   string // of SetVersion
+
+	faultMu sync.Mutex
+	faults  Faults
+	rand    *rand.Rand
+}
+
+type item struct {
+	value []byte
+	flags uint32
+	exp   time.Time
+	cas   uint64
+}
+
+// NewServer starts and returns a new Server. The caller should call Close
+// when finished, to shut it down.
+func NewServer(t testing.TB) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("memcachetest: listen: %v", err)
+	}
+	s := &Server{ln: ln, items: make(map[string]*item)}
+	go s.serve()
+	return s
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string { return s.ln.Addr().String() }
+
+// Close stops accepting new connections.
+func (s *Server) Close() { s.ln.Close() }
+
+// SetClock makes the server evaluate expirations against clock instead of
+// the system clock, so that tests can expire items without sleeping.
+func (s *Server) SetClock(clock Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = clock
+}
+
+// SetMemoryLimit makes the server refuse, as out of memory, the writes
+// that would take the total size of the values it holds over n bytes.
+// Zero removes the limit.
+func (s *Server) SetMemoryLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.memory = n
+}
+
+// DisableLRUCrawler makes the server refuse lru_crawler commands, as
+// memcached started with -o no_lru_crawler does, leaving stats cachedump
+// to list its items.
+func (s *Server) DisableLRUCrawler() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noCrawler = true
+}
+
+// SetVersion makes the server report v to version commands and refuse,
+// as unknown, the commands memcached v lacks: touch before 1.4.8, gat and
+// gats before 1.5.3, lru_crawler before 1.4.31 and mg before 1.6.0.
+func (s *Server) SetVersion(v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = v
+}
+
+// textSince and binarySince map the commands SetVersion may refuse to the
+// version of memcached that added them.
+var (
+	textSince = map[string][3]int{
+		"touch":       {1, 4, 8},
+		"gat":         {1, 5, 3},
+		"gats":        {1, 5, 3},
+		"lru_crawler": {1, 4, 31},
+		"mg":          {1, 6, 0},
+	}
+	binarySince = map[byte][3]int{
+		opTouch: {1, 4, 8},
+		opGAT:   {1, 5, 3},
+		opGATQ:  {1, 5, 3},
+		opGATK:  {1, 5, 3},
+		opGATKQ: {1, 5, 3},
+	}
+)
+
+// versionLocked returns the version the server reports. It must be
+// called with s.mu held.
+func (s *Server) versionLocked() string {
+	if s.version == "" {
+		return version
+	}
+	return s.version
+}
+
+// supports reports whether the version the server reports is since or
+// later. It must be called with s.mu held.
+func (s *Server) supports(since [3]int) bool {
+	var v [3]int
+	if n, _ := fmt.Sscanf(s.versionLocked(), "%d.%d.%d", &v[0], &v[1], &v[2]); n != 3 {
+		return true
+	}
+	for i := range v {
+		if v[i] != since[i] {
+			return v[i] > since[i]
+		}
+	}
+	return true
+}
+
+// fits reports whether a value of size bytes stored under key stays
+// within the limit of SetMemoryLimit. It must be called with s.mu held.
+func (s *Server) fits(key string, size int) bool {
+	if s.memory == 0 {
+		return true
+	}
+	used := size
+	for k := range s.items {
+		if it := s.lookup(k); it != nil && k != key {
+			used += len(it.value)
+		}
+	}
+	return used <= s.memory
+}
+
+func (s *Server) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
+// Conns returns the number of open client connections.
+func (s *Server) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// Quits returns the number of connections clients closed with a quit
+// command.
+func (s *Server) Quits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quits
+}
+
+// labelKeyPrefix starts the keys clients fetch to label their
+// connections, as memcache.LabelKeyPrefix.
+const labelKeyPrefix = "_client:"
+
+// Labels returns the names of the clients that labeled their connections,
+// once per labeled connection, in the order they connected.
+func (s *Server) Labels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.labels...)
+}
+
+// noteLabel records a label fetched as key. It must be called with s.mu
+// held.
+func (s *Server) noteLabel(key string) {
+	if strings.HasPrefix(key, labelKeyPrefix) {
+		s.labels = append(s.labels, strings.TrimPrefix(key, labelKeyPrefix))
+	}
+}
+
+func (s *Server) quit() {
+	s.mu.Lock()
+	s.quits++
+	s.mu.Unlock()
+}
+
+func (s *Server) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(&faultConn{Conn: nc, s: s})
+	}
+}
+
+func (s *Server) handle(nc net.Conn) {
+	s.mu.Lock()
+	s.conns++
+	s.mu.Unlock()
+	defer func() {
+		nc.Close()
+		s.mu.Lock()
+		s.conns--
+		s.mu.Unlock()
+	}()
+	rw := bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))
+	if b, err := rw.Peek(1); err == nil && b[0] == magicRequest {
+		s.handleBinary(rw)
+		return
+	}
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if f[0] == "quit" {
+			s.quit()
+			return
+		}
+		if err := s.dispatch(rw, f); err != nil {
+			return
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) dispatch(rw *bufio.ReadWriter, f []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if since, ok := textSince[f[0]]; ok && !s.supports(since) {
+		return writeError(rw)
+	}
+	switch f[0] {
+	case "get", "gets":
+		s.writeValues(rw, f[1:], f[0] == "gets")
+	case "gat", "gats":
+		if len(f) < 3 {
+			return writeError(rw)
+		}
+		exp, _ := strconv.ParseInt(f[1], 10, 32)
+		for _, key := range f[2:] {
+			if it := s.lookup(key); it != nil {
+				it.exp = s.expiry(int32(exp))
+			}
+		}
+		s.writeValues(rw, f[2:], f[0] == "gats")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.store(rw, f)
+	case "delete":
+		if len(f) < 2 {
+			return writeError(rw)
+		}
+		if s.lookup(f[1]) == nil {
+			fmt.Fprint(rw, "NOT_FOUND\r\n")
+			return nil
+		}
+		delete(s.items, f[1])
+		fmt.Fprint(rw, "DELETED\r\n")
+	case "incr", "decr":
+		if len(f) < 3 {
+			return writeError(rw)
+		}
+		it := s.lookup(f[1])
+		if it == nil {
+			fmt.Fprint(rw, "NOT_FOUND\r\n")
+			return nil
+		}
+		delta, err := strconv.ParseUint(f[2], 10, 64)
+		cur, errCur := strconv.ParseUint(string(it.value), 10, 64)
+		if err != nil || errCur != nil {
+			fmt.Fprint(rw, "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return nil
+		}
+		switch {
+		case f[0] == "incr":
+			cur += delta
+		case delta > cur:
+			cur = 0
+		default:
+			cur -= delta
+		}
+		it.value = []byte(strconv.FormatUint(cur, 10))
+		it.cas = s.nextCas()
+		fmt.Fprintf(rw, "%d\r\n", cur)
+	case "touch":
+		if len(f) < 3 {
+			return writeError(rw)
+		}
+		it := s.lookup(f[1])
+		if it == nil {
+			fmt.Fprint(rw, "NOT_FOUND\r\n")
+			return nil
+		}
+		exp, _ := strconv.ParseInt(f[2], 10, 32)
+		it.exp = s.expiry(int32(exp))
+		fmt.Fprint(rw, "TOUCHED\r\n")
+	case "flush_all":
+		s.items = make(map[string]*item)
+		fmt.Fprint(rw, "OK\r\n")
+	case "lru_crawler":
+		if len(f) < 3 || f[1] != "metadump" {
+			return writeError(rw)
+		}
+		if s.noCrawler {
+			fmt.Fprint(rw, "CLIENT_ERROR lru crawler disabled\r\n")
+			return nil
+		}
+		s.metadump(rw)
+	case "mg":
+		if len(f) < 2 {
+			return writeError(rw)
+		}
+		s.metaGet(rw, f[1], f[2:])
+	case "version":
+		fmt.Fprintf(rw, "VERSION %s\r\n", s.versionLocked())
+	case "verbosity":
+		fmt.Fprint(rw, "OK\r\n")
+	case "stats":
+		var group string
+		if len(f) > 1 {
+			group = f[1]
+		}
+		if group == "cachedump" {
+			if len(f) < 4 {
+				return writeError(rw)
+			}
+			slab, _ := strconv.Atoi(f[2])
+			limit, _ := strconv.Atoi(f[3])
+			s.cachedump(rw, slab, limit)
+			return nil
+		}
+		stats, ok := s.stats(group)
+		if !ok {
+			return writeError(rw)
+		}
+		for _, st := range stats {
+			fmt.Fprintf(rw, "STAT %s %s\r\n", st[0], st[1])
+		}
+		fmt.Fprint(rw, "END\r\n")
+	default:
+		return writeError(rw)
+	}
+	return nil
+}
+
+// metadump writes the metadata of every live item, in key order, as
+// memcached's lru_crawler metadump does.
+func (s *Server) metadump(w io.Writer) {
+	keys := make([]string, 0, len(s.items))
+	for key := range s.items {
+		if s.lookup(key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		it := s.items[key]
+		exp := int64(-1)
+		if !it.exp.IsZero() {
+			exp = it.exp.Unix()
+		}
+		fmt.Fprintf(w, "key=%s exp=%d la=%d cas=%d fetch=no cls=1 size=%d\r\n",
+			url.QueryEscape(key), exp, s.now().Unix(), it.cas, len(key)+len(it.value))
+	}
+	fmt.Fprint(w, "END\r\n")
+}
+
+// metaGet answers a meta get of key without its value, supporting the c,
+// k and t flags.
+func (s *Server) metaGet(w io.Writer, key string, flags []string) {
+	it := s.lookup(key)
+	if it == nil {
+		fmt.Fprint(w, "EN\r\n")
+		return
+	}
+	fmt.Fprint(w, "HD")
+	for _, flag := range flags {
+		switch flag {
+		case "c":
+			fmt.Fprintf(w, " c%d", it.cas)
+		case "k":
+			fmt.Fprintf(w, " k%s", key)
+		case "t":
+			ttl := int64(-1)
+			if !it.exp.IsZero() {
+				ttl = int64((it.exp.Sub(s.now()) + time.Second - 1) / time.Second)
+			}
+			fmt.Fprintf(w, " t%d", ttl)
+		}
+	}
+	fmt.Fprint(w, "\r\n")
+}
+
+// cachedump writes up to limit live items of slab, all of them if limit
+// is zero, in key order, as memcached's stats cachedump does. Every item
+// is in slab 1.
+func (s *Server) cachedump(w io.Writer, slab, limit int) {
+	var keys []string
+	if slab == 1 {
+		for key := range s.items {
+			if s.lookup(key) != nil {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	for _, key := range keys {
+		it := s.items[key]
+		var exp int64
+		if !it.exp.IsZero() {
+			exp = it.exp.Unix()
+		}
+		fmt.Fprintf(w, "ITEM %s [%d b; %d s]\r\n", key, len(it.value), exp)
+	}
+	fmt.Fprint(w, "END\r\n")
+}
+
+// stats returns the name and value of the statistics in group, which is
+// empty for the general statistics, and whether the group exists.
+func (s *Server) stats(group string) ([][2]string, bool) {
+	switch group {
+	case "":
+		return [][2]string{
+			{"version", s.versionLocked()},
+			{"curr_items", strconv.Itoa(len(s.items))},
+		}, true
+	case "items":
+		return [][2]string{{"items:1:number", strconv.Itoa(len(s.items))}}, true
+	case "slabs":
+		return [][2]string{{"active_slabs", "1"}}, true
+	case "settings":
+		return [][2]string{{"maxbytes", "67108864"}, {"item_size_max", "1048576"}}, true
+	}
+	return nil, false
+}
+
+func writeError(w io.Writer) error {
+	_, err := fmt.Fprint(w, "ERROR\r\n")
+	return err
+}
+
+func (s *Server) writeValues(w io.Writer, keys []string, withCas bool) {
+	for _, key := range keys {
+		s.noteLabel(key)
+		it := s.lookup(key)
+		if it == nil {
+			continue
+		}
+		if withCas {
+			fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, it.flags, len(it.value), it.cas)
+		} else {
+			fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, it.flags, len(it.value))
+		}
+		w.Write(it.value)
+		io.WriteString(w, "\r\n")
+	}
+	fmt.Fprint(w, "END\r\n")
+}
+
+func (s *Server) store(rw *bufio.ReadWriter, f []string) error {
+	verb := f[0]
+	want := 5
+	if verb == "cas" {
+		want = 6
+	}
+	if len(f) < want {
+		return writeError(rw)
+	}
+	flags, _ := strconv.ParseUint(f[2], 10, 32)
+	exp, _ := strconv.ParseInt(f[3], 10, 32)
+	size, err := strconv.Atoi(f[4])
+	if err != nil || size < 0 {
+		fmt.Fprint(rw, "CLIENT_ERROR bad data chunk\r\n")
+		return nil
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(rw, data); err != nil {
+		return err
+	}
+	data = data[:size]
+
+	key := f[1]
+	it := s.lookup(key)
+	switch verb {
+	case "add":
+		if it != nil {
+			fmt.Fprint(rw, "NOT_STORED\r\n")
+			return nil
+		}
+	case "replace", "append", "prepend":
+		if it == nil {
+			fmt.Fprint(rw, "NOT_STORED\r\n")
+			return nil
+		}
+	case "cas":
+		if it == nil {
+			fmt.Fprint(rw, "NOT_FOUND\r\n")
+			return nil
+		}
+		if cas, _ := strconv.ParseUint(f[5], 10, 64); cas != it.cas {
+			fmt.Fprint(rw, "EXISTS\r\n")
+			return nil
+		}
+	}
+
+	stored := len(data)
+	if verb == "append" || verb == "prepend" {
+		stored += len(it.value)
+	}
+	if !s.fits(key, stored) {
+		fmt.Fprint(rw, "SERVER_ERROR out of memory storing object\r\n")
+		return nil
+	}
+
+	switch verb {
+	case "append":
+		it.value = append(append([]byte(nil), it.value...), data...)
+		it.cas = s.nextCas()
+	case "prepend":
+		it.value = append(append([]byte(nil), data...), it.value...)
+		it.cas = s.nextCas()
+	default:
+		s.items[key] = &item{
+			value: data,
+			flags: uint32(flags),
+			exp:   s.expiry(int32(exp)),
+			cas:   s.nextCas(),
+		}
+	}
+	fmt.Fprint(rw, "STORED\r\n")
+	return nil
+}
+
+// lookup returns the live item stored under key, dropping it if expired.
+func (s *Server) lookup(key string) *item {
+	it, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	if !it.exp.IsZero() && !s.now().Before(it.exp) {
+		delete(s.items, key)
+		return nil
+	}
+	return it
+}
+
+func (s *Server) nextCas() uint64 {
+	s.cas++
+	return s.cas
+}
+
+// expiry converts a protocol expiration to a point in time. It must be
+// called with s.mu held.
+func (s *Server) expiry(exp int32) time.Time {
+	switch {
+	case exp == 0:
+		return time.Time{}
+	case exp < 0:
+		return time.Unix(1, 0)
+	case exp <= 60*60*24*30:
+		return s.now().Add(time.Duration(exp) * time.Second)
+	}
+	return time.Unix(int64(exp), 0)
+}
@@ -0,0 +1,56 @@
+package memcache
+
+import (
+	"context"
+	"time"
+)
+
+// Memoize decodes the value cached under key into dst, which must be a
+// pointer. On a cache miss fn is called to compute the value, which is
+// encoded with the client's codec for key, stored for ttl and then decoded
+// into dst, so hits and misses produce the same result. A zero ttl selects
+// the TTL of the key's profile.
+//
+// Concurrent Memoize calls for the same key on the same Client share one
+// call of fn, so an expensive query runs once per key and process however
+// many requests miss at the same time. fn is called with the context of
+// the caller that started it; other callers stop waiting when their own
+// context is done. If fn fails once that caller's context is done, the
+// callers still waiting call fn again rather than share the failure.
+//
+// Cache errors are not fatal: if memcache can't be read or written, the
+// value computed by fn is still returned.
+func (c *Client) Memoize(ctx context.Context, key string, ttl time.Duration, dst interface{}, fn func(context.Context) (interface{}, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key = rewriteKey(ctx, key)
+	codec := c.valueCodec(key)
+	if it, err := c.Get(key); err == nil {
+		if err := codec.Unmarshal(it.Value, dst); err == nil {
+			return nil
+		}
+	} else if err == ErrMalformedKey {
+		return err
+	}
+
+	data, err := c.flight.doContext(ctx, key, func(ctx context.Context) ([]byte, error) {
+		v, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		data, err := codec.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if ttl == 0 {
+			ttl = c.profile(key).TTL
+		}
+		c.Set(&Item{Key: key, Value: data, Expiration: Expiration(ttl, c.now())})
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(data, dst)
+}
@@ -0,0 +1,121 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+type memoRow struct {
+	ID   int
+	Name string
+}
+
+func TestMemoize(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	ctx := context.Background()
+
+	var calls int32
+	release := make(chan struct{})
+	load := func(context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []memoRow{{1, "a"}, {2, "b"}}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var rows []memoRow
+			if err := c.Memoize(ctx, "rows", time.Minute, &rows, load); err != nil {
+				t.Errorf("Memoize: %v", err)
+				return
+			}
+			if len(rows) != 2 || rows[1].Name != "b" {
+				t.Errorf("rows = %+v", rows)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	var rows []memoRow
+	if err := c.Memoize(ctx, "rows", time.Minute, &rows, load); err != nil {
+		t.Fatalf("Memoize(cached): %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+}
+
+func TestMemoizeError(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	errLoad := errors.New("query failed")
+	var v int
+	err := c.Memoize(context.Background(), "k", time.Minute, &v, func(context.Context) (interface{}, error) {
+		return nil, errLoad
+	})
+	if err != errLoad {
+		t.Fatalf("Memoize: got %v, want %v", err, errLoad)
+	}
+	if _, err := c.Get("k"); err != ErrCacheMiss {
+		t.Errorf("failed load was cached: Get returned %v", err)
+	}
+}
+
+func TestMemoizeStarterCanceled(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+
+	var calls int32
+	started := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return "v", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	starter := make(chan error, 1)
+	go func() {
+		var v string
+		starter <- c.Memoize(ctx, "k", time.Minute, &v, load)
+	}()
+	<-started
+	waiter := make(chan error, 1)
+	var v string
+	go func() {
+		waiter <- c.Memoize(context.Background(), "k", time.Minute, &v, load)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// The waiter outlives the caller whose context the load got.
+	cancel()
+	if err := <-starter; err != context.Canceled {
+		t.Errorf("canceled Memoize = %v, want %v", err, context.Canceled)
+	}
+	if err := <-waiter; err != nil || v != "v" {
+		t.Errorf("Memoize joining a canceled load = %q, %v; want v", v, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("loader called %d times, want 2", n)
+	}
+}
@@ -0,0 +1,37 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestItemMeta(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for name, c := range protoClients(s) {
+		t.Run(name, func(t *testing.T) {
+			if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			before := time.Now()
+			it, err := c.Get("k")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			m, err := c.GetAndTouchMulti([]string{"k"}, 60)
+			if err != nil {
+				t.Fatalf("GetAndTouchMulti: %v", err)
+			}
+			for _, it := range []*Item{it, m["k"]} {
+				if it.Meta.Addr == nil || it.Meta.Addr.String() != s.Addr() {
+					t.Errorf("Meta.Addr = %v, want %s", it.Meta.Addr, s.Addr())
+				}
+				if it.Meta.Fetched.Before(before) || it.Meta.Age(time.Now()) < 0 {
+					t.Errorf("Meta.Fetched = %v, want after %v", it.Meta.Fetched, before)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package memcache
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyMeta describes an item held by a server, as listed by Conn.MetaDump
+// or Conn.CacheDump.
+type KeyMeta struct {
+	// Key is the key as stored, after any rewriting by the client's
+	// policy or key encoding.
+	Key string
+
+	// Server is the server holding the item.
+	Server net.Addr
+
+	// Expiration is when the item expires, or the zero Time if it
+	// doesn't.
+	Expiration time.Time
+
+	// Size is the size of the item on the server, its key included, as
+	// listed by MetaDump, or the size of its value, as listed by
+	// CacheDump.
+	Size int
+}
+
+// MetaDump calls fn with the metadata of every item of the server, as
+// listed by lru_crawler metadump, until fn returns an error, which it
+// then returns; the connection is closed, since the rest of the listing
+// is left unread. Listing the items of a large server takes a while, and
+// the server may list items that expire meanwhile. It is only supported
+// by the text protocol.
+func (c *Conn) MetaDump(fn func(KeyMeta) error) error {
+	if err := c.cn.c.checkProto("metadump"); err != nil {
+		return err
+	}
+	rw := c.rw()
+	if _, err := rw.WriteString("lru_crawler metadump all\r\n"); err != nil {
+		return err
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	for {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		// Each line extends the deadline, since the listing may be long.
+		line, err := c.rw().ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case string(line) == "END":
+			return nil
+		case isErrorLine(line):
+			return &refusedError{cmd: "metadump", line: string(line)}
+		}
+		km, err := parseMetaDump(string(line))
+		if err != nil {
+			return err
+		}
+		km.Server = c.cn.addr
+		if err := fn(km); err != nil {
+			return err
+		}
+	}
+}
+
+// parseMetaDump parses a line of lru_crawler metadump, such as
+// "key=foo exp=-1 la=1700000000 cas=1 fetch=no cls=1 size=63".
+func parseMetaDump(line string) (KeyMeta, error) {
+	var km KeyMeta
+	var hasKey bool
+	for _, field := range strings.Fields(line) {
+		eq := strings.IndexByte(field, '=')
+		if eq < 0 {
+			continue
+		}
+		name, value := field[:eq], field[eq+1:]
+		var err error
+		switch name {
+		case "key":
+			km.Key, err = url.QueryUnescape(value)
+			hasKey = true
+		case "exp":
+			var exp int64
+			if exp, err = strconv.ParseInt(value, 10, 64); err == nil && exp >= 0 {
+				km.Expiration = time.Unix(exp, 0)
+			}
+		case "size":
+			km.Size, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return KeyMeta{}, fmt.Errorf("memcache: malformed metadump line %q: %v", line, err)
+		}
+	}
+	if !hasKey {
+		return KeyMeta{}, fmt.Errorf("memcache: malformed metadump line %q", line)
+	}
+	return km, nil
+}
+
+// refusedError is returned when a server answers a listing command with
+// an error line, such as "CLIENT_ERROR lru crawler disabled".
+type refusedError struct {
+	cmd, line string
+}
+
+func (e *refusedError) Error() string {
+	return fmt.Sprintf("memcache: %s: %s", e.cmd, e.line)
+}
+
+func isErrorLine(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("ERROR")) || bytes.HasPrefix(line, []byte("CLIENT_ERROR")) || bytes.HasPrefix(line, []byte("SERVER_ERROR"))
+}
+
+// CacheDump calls fn with the metadata of up to limit items of the slab
+// class slab, all that fit in the server's answer if limit is zero, as
+// listed by stats cachedump, until fn returns an error, which it then
+// returns. It is the way to list keys on the servers that refuse
+// lru_crawler, but memcached caps its answer at 2MB per slab class and
+// may not support it at all in recent versions. Servers that report
+// their start time as the expiration of the items that don't expire
+// can't be told apart. It is only supported by the text protocol.
+func (c *Conn) CacheDump(slab, limit int, fn func(KeyMeta) error) error {
+	if err := c.cn.c.checkProto("cachedump"); err != nil {
+		return err
+	}
+	rw := c.rw()
+	if _, err := fmt.Fprintf(rw, "stats cachedump %d %d\r\n", slab, limit); err != nil {
+		return err
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	var fnErr error
+	for {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		line, err := c.rw().ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case string(line) == "END":
+			return fnErr
+		case isErrorLine(line):
+			return &refusedError{cmd: "cachedump", line: string(line)}
+		case fnErr != nil:
+			continue // the answer is short; read it to keep the connection
+		}
+		km, err := parseCacheDump(string(line))
+		if err != nil {
+			return err
+		}
+		km.Server = c.cn.addr
+		fnErr = fn(km)
+	}
+}
+
+// parseCacheDump parses a line of stats cachedump, such as
+// "ITEM foo [5 b; 1700000000 s]".
+func parseCacheDump(line string) (KeyMeta, error) {
+	var km KeyMeta
+	var size, exp int64
+	if n, _ := fmt.Sscanf(line, "ITEM %s [%d b; %d s]", &km.Key, &size, &exp); n != 3 {
+		return KeyMeta{}, fmt.Errorf("memcache: malformed cachedump line %q", line)
+	}
+	km.Size = int(size)
+	if exp > 0 {
+		km.Expiration = time.Unix(exp, 0)
+	}
+	return km, nil
+}
+
+// listKeys calls fn with the metadata of every item of the server, with
+// MetaDump or, if the server refuses it, with CacheDump on each of its
+// slab classes.
+func (c *Conn) listKeys(fn func(KeyMeta) error) error {
+	caps, err := c.cn.c.capabilities(c.cn.addr, c.rw())
+	if err != nil {
+		return err
+	}
+	if caps.MetaDump {
+		err := c.MetaDump(fn)
+		if _, ok := err.(*refusedError); !ok {
+			return err
+		}
+	}
+	st, err := c.Stats("items")
+	if err != nil {
+		return err
+	}
+	var slabs []int
+	seen := make(map[int]bool)
+	for name := range st {
+		// items:<slab>:<stat>
+		f := strings.Split(name, ":")
+		if len(f) != 3 || f[0] != "items" {
+			continue
+		}
+		if slab, err := strconv.Atoi(f[1]); err == nil && !seen[slab] {
+			seen[slab] = true
+			slabs = append(slabs, slab)
+		}
+	}
+	sort.Ints(slabs)
+	for _, slab := range slabs {
+		if err := c.CacheDump(slab, 0, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
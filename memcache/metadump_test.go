@@ -0,0 +1,110 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestMetaDump(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+	if err := c.Set(&Item{Key: "a", Value: []byte("xyz")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(&Item{Key: "b", Value: []byte("v"), Expiration: 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	var metas []KeyMeta
+	err := c.ForEachServer(context.Background(), func(_ net.Addr, cn *Conn) error {
+		return cn.MetaDump(func(km KeyMeta) error {
+			metas = append(metas, km)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metas) != 2 {
+		t.Fatalf("MetaDump listed %+v, want a and b", metas)
+	}
+	if m := metas[0]; m.Key != "a" || !m.Expiration.IsZero() || m.Size != 4 || m.Server.String() != s.Addr() {
+		t.Errorf("metadata of a = %+v", m)
+	}
+	if m := metas[1]; m.Key != "b" || m.Expiration.IsZero() {
+		t.Errorf("metadata of b = %+v, want an expiration", m)
+	}
+}
+
+func TestParseMetaDump(t *testing.T) {
+	km, err := parseMetaDump("key=a%20b exp=1700000000 la=1 cas=2 fetch=no cls=1 size=63")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if km.Key != "a b" || km.Expiration.Unix() != 1700000000 || km.Size != 63 {
+		t.Errorf("parseMetaDump = %+v", km)
+	}
+	if _, err := parseMetaDump("exp=-1 size=1"); err == nil {
+		t.Error("parseMetaDump accepted a line without a key")
+	}
+}
+
+func TestCacheDump(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	s.DisableLRUCrawler()
+	c := New(s.Addr())
+	defer c.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		if err := c.Set(&Item{Key: key, Value: []byte("xyz")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var metas, listed []KeyMeta
+	err := c.ForEachServer(context.Background(), func(_ net.Addr, cn *Conn) error {
+		if err := cn.MetaDump(func(KeyMeta) error { return nil }); err == nil {
+			t.Error("MetaDump succeeded with the LRU crawler disabled")
+		}
+		if err := cn.CacheDump(1, 2, func(km KeyMeta) error {
+			metas = append(metas, km)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return cn.listKeys(func(km KeyMeta) error {
+			listed = append(listed, km)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metas) != 2 {
+		t.Fatalf("CacheDump with a limit of 2 listed %+v", metas)
+	}
+	if m := metas[0]; m.Key != "a" || m.Size != 3 || !m.Expiration.IsZero() || m.Server.String() != s.Addr() {
+		t.Errorf("metadata of a = %+v", m)
+	}
+	if len(listed) != 3 {
+		t.Errorf("listing keys without the LRU crawler found %+v, want a, b and c", listed)
+	}
+}
+
+func TestParseCacheDump(t *testing.T) {
+	km, err := parseCacheDump("ITEM foo [5 b; 1700000000 s]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if km.Key != "foo" || km.Size != 5 || km.Expiration.Unix() != 1700000000 {
+		t.Errorf("parseCacheDump = %+v", km)
+	}
+	if _, err := parseCacheDump("STAT foo 1"); err == nil {
+		t.Error("parseCacheDump accepted a stats line")
+	}
+}
@@ -0,0 +1,111 @@
+package memcache
+
+import (
+	"hash/crc32"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// maxMirrorsInFlight bounds the mirrored reads running at once; reads
+// beyond it are not mirrored.
+const maxMirrorsInFlight = 64
+
+// MirrorStats describes the reads a client mirrored to its shadow.
+type MirrorStats struct {
+	Reads   uint64        // mirrored reads, a GetMulti counting once
+	Errors  uint64        // mirrored reads that failed, misses aside
+	Dropped uint64        // sampled reads not mirrored, with too many in flight
+	Latency time.Duration // the total latency of the mirrored reads
+}
+
+// mirror is the shadow configured with SetMirror.
+type mirror struct {
+	stats     MirrorStats // first, to be 64-bit aligned for atomic access
+	shadow    *Client
+	threshold uint32 // keys hashing below it are mirrored
+	all       bool
+	inFlight  chan struct{}
+}
+
+// SetMirror makes the client mirror a sample of its reads to shadow, such
+// as a cluster of new hardware or one using a new hashing scheme, to test
+// its capacity before cutting over. The results of the mirrored reads are
+// discarded; only their outcome and latency are recorded, in MirrorStats.
+//
+// The sample is the fraction rate, between 0 and 1, of the keys, picked
+// by a hash of the key so that the same keys are always mirrored and the
+// shadow sees a realistic hit ratio. A nil shadow or a zero rate turns
+// mirroring off.
+func (c *Client) SetMirror(shadow *Client, rate float64) {
+	var m *mirror
+	if shadow != nil && rate > 0 {
+		m = &mirror{
+			shadow:    shadow,
+			threshold: uint32(math.Min(rate, 1) * math.MaxUint32),
+			all:       rate >= 1,
+			inFlight:  make(chan struct{}, maxMirrorsInFlight),
+		}
+	}
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+	c.mirror = m
+}
+
+// MirrorStats returns the statistics of the mirroring set up with
+// SetMirror, since it was last called.
+func (c *Client) MirrorStats() MirrorStats {
+	c.cfgMu.RLock()
+	m := c.mirror
+	c.cfgMu.RUnlock()
+	if m == nil {
+		return MirrorStats{}
+	}
+	return MirrorStats{
+		Reads:   atomic.LoadUint64(&m.stats.Reads),
+		Errors:  atomic.LoadUint64(&m.stats.Errors),
+		Dropped: atomic.LoadUint64(&m.stats.Dropped),
+		Latency: time.Duration(atomic.LoadInt64((*int64)(&m.stats.Latency))),
+	}
+}
+
+// mirrorGets mirrors the sampled keys of a read of keys to the shadow, in
+// the background.
+func (c *Client) mirrorGets(keys []string) {
+	c.cfgMu.RLock()
+	m := c.mirror
+	c.cfgMu.RUnlock()
+	if m == nil {
+		return
+	}
+	var sample []string
+	for _, key := range keys {
+		if m.all || crc32.ChecksumIEEE([]byte(key)) < m.threshold {
+			sample = append(sample, key)
+		}
+	}
+	if len(sample) == 0 {
+		return
+	}
+	select {
+	case m.inFlight <- struct{}{}:
+	default:
+		atomic.AddUint64(&m.stats.Dropped, 1)
+		return
+	}
+	c.goFunc(func() {
+		defer func() { <-m.inFlight }()
+		start := time.Now()
+		var err error
+		if len(sample) == 1 {
+			_, err = m.shadow.Get(sample[0])
+		} else {
+			_, err = m.shadow.GetMulti(sample)
+		}
+		atomic.AddInt64((*int64)(&m.stats.Latency), int64(time.Since(start)))
+		atomic.AddUint64(&m.stats.Reads, 1)
+		if err != nil && err != ErrCacheMiss {
+			atomic.AddUint64(&m.stats.Errors, 1)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package memcache
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestMirror(t *testing.T) {
+	prod, shadow := memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer prod.Close()
+	defer shadow.Close()
+	c, sc := New(prod.Addr()), New(shadow.Addr())
+	defer c.Close()
+	defer sc.Close()
+
+	c.SetMirror(sc, 1)
+	c.Get("a")
+	c.GetMulti([]string{"b", "c"})
+	waitFor(t, "the mirrored reads", func() bool { return c.MirrorStats().Reads == 2 })
+	if st := c.MirrorStats(); st.Errors != 0 || st.Latency <= 0 {
+		t.Errorf("stats = %+v, want no errors and some latency", st)
+	}
+
+	// A partial rate mirrors the keys whose hash falls in the sample.
+	c.SetMirror(sc, 0.5)
+	sampled := 0
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprint("k", i)
+		if crc32.ChecksumIEEE([]byte(key)) < math.MaxUint32/2 {
+			sampled++
+		}
+		c.Get(key)
+	}
+	if sampled < 30 || sampled > 70 {
+		t.Errorf("%d of 100 keys sampled at rate 0.5", sampled)
+	}
+	waitFor(t, "the sampled reads", func() bool {
+		st := c.MirrorStats()
+		return st.Reads+st.Dropped == uint64(sampled)
+	})
+
+	shadow.Close()
+	sc.Close()
+	c.SetMirror(sc, 1)
+	c.Get("a")
+	waitFor(t, "the failed mirrored read", func() bool { return c.MirrorStats().Errors == 1 })
+	if _, err := c.Get("a"); err != ErrCacheMiss {
+		t.Errorf("Get with the shadow down error = %v, want ErrCacheMiss", err)
+	}
+
+	c.SetMirror(nil, 0)
+	c.Get("a")
+	if st := c.MirrorStats(); st != (MirrorStats{}) {
+		t.Errorf("stats with mirroring off = %+v", st)
+	}
+}
@@ -0,0 +1,253 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyErrors is returned by batch operations when some of their keys fail.
+// It maps each failed key to its error.
+type KeyErrors map[string]error
+
+func (e KeyErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) == 1 {
+		return fmt.Sprintf("memcache: key %q: %v", keys[0], e[keys[0]])
+	}
+	return fmt.Sprintf("memcache: %d keys failed, first %q: %v", len(keys), keys[0], e[keys[0]])
+}
+
+// PartialError is returned by GetMultiContext when its context is done
+// before every server answered. The items of the servers that did answer
+// are returned along with it.
+type PartialError struct {
+	Servers []net.Addr // the servers that did not answer
+	Err     error      // the error of the context
+}
+
+func (e *PartialError) Error() string {
+	if len(e.Servers) == 1 {
+		return fmt.Sprintf("memcache: server %s did not answer: %v", e.Servers[0], e.Err)
+	}
+	return fmt.Sprintf("memcache: %d servers did not answer: %v", len(e.Servers), e.Err)
+}
+
+// SetMulti is a batch version of Set. Items are grouped by server and
+// each server's share is written in a single round trip; on the binary
+// protocol as quiet sets, so that only failures are answered.
+// If some items could not be written, the returned error is a KeyErrors.
+func (c *Client) SetMulti(items []*Item) error {
+	if err := c.checkBatchBytes("set", items); err != nil {
+		return err
+	}
+	keys := make([]string, len(items))
+	for i, item := range items {
+		if c.checkFlags {
+			if err := checkFlags(item); err != nil {
+				return err
+			}
+		}
+		keys[i] = item.Key
+	}
+	return c.batch("set", keys, items, func(rw *bufio.ReadWriter, idx []int, keys []string) ([]error, error) {
+		batch := make([]*Item, len(idx))
+		for i, j := range idx {
+			batch[i] = c.itemToSend(items[j], keys[i])
+		}
+		return c.cmdRunner.SetMulti(rw, batch)
+	})
+}
+
+// DeleteMulti is a batch version of Delete. Keys are grouped by server and
+// each server's share is deleted in a single round trip.
+// If some keys could not be deleted, the returned error is a KeyErrors;
+// keys that did not exist map to ErrCacheMiss.
+func (c *Client) DeleteMulti(keys []string) error {
+	return c.batch("delete", keys, nil, func(rw *bufio.ReadWriter, _ []int, keys []string) ([]error, error) {
+		return c.cmdRunner.DeleteMulti(rw, keys)
+	})
+}
+
+// GetAndTouchMulti is a batch version of Get that also updates the expiry
+// of the items it finds, as Touch does with seconds. Each server's share
+// of keys is fetched in a single round trip. Like GetMulti, the returned
+// map has no entry for the keys that were not found.
+func (c *Client) GetAndTouchMulti(keys []string, seconds int32) (map[string]*Item, error) {
+	return c.getMulti(context.Background(), "gat", keys, func(addr net.Addr, keys []string, cb func(*Item)) error {
+		return c.withCapableRw(addr, "gat", func(rw *bufio.ReadWriter) error {
+			caps, err := c.capabilities(addr, rw)
+			if err != nil {
+				return err
+			}
+			cb := c.withMeta(addr, cb)
+			if caps.GetAndTouch {
+				return c.cmdRunner.GetAndTouch(rw, keys, seconds, cb)
+			}
+			// Servers without gat get a get, then a touch of the keys found.
+			var found []string
+			err = c.cmdRunner.Get(rw, keys, func(it *Item) {
+				found = append(found, it.Key)
+				cb(it)
+			})
+			if err != nil || len(found) == 0 {
+				return err
+			}
+			if err := c.cmdRunner.Touch(rw, found, seconds); err != ErrCacheMiss {
+				return err
+			}
+			return nil
+		})
+	})
+}
+
+// GetMultiOrdered is like GetMulti, but returns the items in the order of
+// keys, with nil for the keys that were not found, so that callers can
+// walk the results alongside their keys. A key given several times gets
+// the same item at each of its positions. On a partial failure, the items
+// received are returned along with the error.
+func (c *Client) GetMultiOrdered(keys []string) ([]*Item, error) {
+	c.mirrorGets(keys)
+	m, err := c.getMulti(context.Background(), "get", keys, c.getFromAddr)
+	if m == nil {
+		return nil, err
+	}
+	items := make([]*Item, len(keys))
+	for i, key := range keys {
+		items[i] = m[key]
+	}
+	return items, err
+}
+
+// replicateBatch repeats fn, as batch ran it, on the replicas of the keys
+// that succeeded on their servers, best effort, as onItem and Delete do
+// for single keys.
+func (c *Client) replicateBatch(keys, skeys []string, servers []net.Addr, kerrs KeyErrors, fn func(rw *bufio.ReadWriter, idx []int, keys []string) ([]error, error)) {
+	idxMap := make(map[net.Addr][]int)
+	for i, key := range keys {
+		if servers[i] == nil || kerrs[key] != nil {
+			continue
+		}
+		for _, addr := range c.replicas(key, servers[i]) {
+			idxMap[addr] = append(idxMap[addr], i)
+		}
+	}
+	var wg sync.WaitGroup
+	for addr, idx := range idxMap {
+		addr, idx := addr, idx
+		wg.Add(1)
+		c.goFunc(func() {
+			defer wg.Done()
+			if c.throttle(addr, len(idx)) != nil {
+				return
+			}
+			batch := make([]string, len(idx))
+			for i, j := range idx {
+				batch[i] = skeys[j]
+			}
+			c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+				_, err := fn(rw, idx, batch)
+				return err
+			})
+		})
+	}
+	wg.Wait()
+}
+
+// batch runs the batch operation op over keys. fn is called concurrently
+// once per server, with the indexes in keys of the keys the server owns
+// and those keys as prepared by prepareKey, and returns their errors in
+// that order or an error failing them all. Every key is reported to the
+// hooks as a separate op, along with its item if op stores items.
+func (c *Client) batch(op string, keys []string, items []*Item, fn func(rw *bufio.ReadWriter, idx []int, keys []string) ([]error, error)) error {
+	if err := c.checkBatchKeys(op, len(keys)); err != nil {
+		return err
+	}
+	start := time.Now()
+	idxMap := make(map[net.Addr][]int)
+	skeys := make([]string, len(keys))
+	servers := make([]net.Addr, len(keys))
+	kerrs := make(KeyErrors)
+	for i, key := range keys {
+		skey, err := c.prepareKey(op, key)
+		if err != nil {
+			return err
+		}
+		skeys[i] = skey
+		addr, err := c.pickServer(op, key, skey)
+		if err != nil {
+			return err
+		}
+		servers[i] = addr
+		size := 0
+		if items != nil {
+			size = len(items[i].Value)
+		}
+		if err := c.chargeQuota(key, 1, size); err != nil {
+			kerrs[key] = err
+			continue
+		}
+		idxMap[addr] = append(idxMap[addr], i)
+	}
+
+	type addrErrs struct {
+		idx  []int
+		errs []error
+		err  error
+	}
+	ch := make(chan addrErrs, buffered)
+	for addr, idx := range idxMap {
+		addr, idx := addr, idx
+		c.goFunc(func() {
+			batch := make([]string, len(idx))
+			for i, j := range idx {
+				batch[i] = skeys[j]
+			}
+			var errs []error
+			err := c.throttle(addr, len(idx))
+			if err == nil {
+				err = c.withAddrRw(addr, func(rw *bufio.ReadWriter) (err error) {
+					errs, err = fn(rw, idx, batch)
+					return err
+				})
+			}
+			ch <- addrErrs{idx, errs, err}
+		})
+	}
+
+	for range idxMap {
+		ae := <-ch
+		for i, j := range ae.idx {
+			err := ae.err
+			if err == nil {
+				err = ae.errs[i]
+			}
+			if err != nil {
+				kerrs[keys[j]] = err
+			}
+		}
+	}
+
+	c.replicateBatch(keys, skeys, servers, kerrs, fn)
+	c.standbyBatch(keys, items, kerrs)
+	d := time.Since(start)
+	for i, key := range keys {
+		ev := OpEvent{Op: op, Key: key, Start: start, Duration: d, Err: kerrs[key], Server: servers[i]}
+		if items != nil {
+			ev.Size, ev.Expiration = len(items[i].Value), items[i].Expiration
+		}
+		c.emit(ev)
+	}
+	if len(kerrs) > 0 {
+		return kerrs
+	}
+	return nil
+}
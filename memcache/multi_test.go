@@ -0,0 +1,224 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+// protoClients returns a text and a binary client of s.
+func protoClients(s *memcachetest.Server) map[string]*Client {
+	return map[string]*Client{
+		"text":   New(s.Addr()),
+		"binary": NewBinary(s.Addr()),
+	}
+}
+
+func TestSetMulti(t *testing.T) {
+	for name, c := range protoClients(memcachetest.NewServer(t)) {
+		t.Run(name, func(t *testing.T) {
+			var items []*Item
+			for i := 0; i < 20; i++ {
+				items = append(items, &Item{
+					Key:   fmt.Sprintf("%s-%d", name, i),
+					Value: []byte(fmt.Sprint(i)),
+					Flags: uint32(i),
+				})
+			}
+			var sets int
+			c.AddHook(func(ev OpEvent) {
+				if ev.Op == "set" && ev.Err == nil {
+					sets++
+				}
+			})
+			if err := c.SetMulti(items); err != nil {
+				t.Fatalf("SetMulti: %v", err)
+			}
+			if sets != len(items) {
+				t.Errorf("hooks saw %d sets, want %d", sets, len(items))
+			}
+			for _, want := range items {
+				it, err := c.Get(want.Key)
+				if err != nil {
+					t.Fatalf("Get(%q): %v", want.Key, err)
+				}
+				if string(it.Value) != string(want.Value) || it.Flags != want.Flags {
+					t.Errorf("Get(%q) = %q/%d, want %q/%d", want.Key, it.Value, it.Flags, want.Value, want.Flags)
+				}
+			}
+
+			if err := c.SetMulti([]*Item{{Key: "bad key"}}); err != ErrMalformedKey {
+				t.Errorf("SetMulti with a malformed key: got %v, want ErrMalformedKey", err)
+			}
+		})
+	}
+}
+
+func TestKeyErrors(t *testing.T) {
+	err := KeyErrors{"b": ErrNotStored, "a": ErrCacheMiss}
+	if got, want := err.Error(), `memcache: 2 keys failed, first "a": memcache: cache miss`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestDeleteMulti(t *testing.T) {
+	for name, c := range protoClients(memcachetest.NewServer(t)) {
+		t.Run(name, func(t *testing.T) {
+			keys := []string{name + "-a", name + "-b", name + "-c"}
+			for _, key := range keys[:2] {
+				if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+					t.Fatalf("Set(%q): %v", key, err)
+				}
+			}
+			err := c.DeleteMulti(keys)
+			kerrs, ok := err.(KeyErrors)
+			if !ok || len(kerrs) != 1 || kerrs[keys[2]] != ErrCacheMiss {
+				t.Fatalf("DeleteMulti = %v, want a cache miss on %q only", err, keys[2])
+			}
+			for _, key := range keys[:2] {
+				if _, err := c.Get(key); err != ErrCacheMiss {
+					t.Errorf("Get(%q) after DeleteMulti: got %v, want ErrCacheMiss", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGetAndTouchMulti(t *testing.T) {
+	for name, c := range protoClients(memcachetest.NewServer(t)) {
+		t.Run(name, func(t *testing.T) {
+			keys := []string{name + "-a", name + "-b", name + "-missing"}
+			for i, key := range keys[:2] {
+				if err := c.Set(&Item{Key: key, Value: []byte(key), Flags: uint32(i), Expiration: 1}); err != nil {
+					t.Fatalf("Set(%q): %v", key, err)
+				}
+			}
+			m, err := c.GetAndTouchMulti(keys, 3600)
+			if err != nil {
+				t.Fatalf("GetAndTouchMulti: %v", err)
+			}
+			if len(m) != 2 {
+				t.Fatalf("GetAndTouchMulti returned %d items, want 2", len(m))
+			}
+			for i, key := range keys[:2] {
+				it := m[key]
+				if it == nil || string(it.Value) != key || it.Flags != uint32(i) {
+					t.Errorf("item %q = %+v", key, it)
+				}
+			}
+		})
+	}
+}
+
+func TestGetMultiOrdered(t *testing.T) {
+	for name, c := range protoClients(memcachetest.NewServer(t)) {
+		t.Run(name, func(t *testing.T) {
+			keys := []string{"c", "missing", "a", "b", "a"}
+			for _, key := range []string{"a", "b", "c"} {
+				if err := c.Set(&Item{Key: key, Value: []byte(key)}); err != nil {
+					t.Fatalf("Set(%q): %v", key, err)
+				}
+			}
+			items, err := c.GetMultiOrdered(keys)
+			if err != nil {
+				t.Fatalf("GetMultiOrdered: %v", err)
+			}
+			if len(items) != len(keys) {
+				t.Fatalf("got %d items for %d keys", len(items), len(keys))
+			}
+			for i, key := range keys {
+				it := items[i]
+				if key == "missing" {
+					if it != nil {
+						t.Errorf("items[%d] = %+v, want nil", i, it)
+					}
+					continue
+				}
+				if it == nil || it.Key != key || string(it.Value) != key {
+					t.Errorf("items[%d] = %+v, want %q", i, it, key)
+				}
+			}
+
+			if _, err := c.GetMultiOrdered([]string{"bad key"}); err != ErrMalformedKey {
+				t.Errorf("GetMultiOrdered with a malformed key: got %v, want ErrMalformedKey", err)
+			}
+		})
+	}
+}
+
+func TestGetMultiFunc(t *testing.T) {
+	for name, c := range protoClients(memcachetest.NewServer(t)) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"a", "b", "c"} {
+				if err := c.Set(&Item{Key: key, Value: []byte(key)}); err != nil {
+					t.Fatalf("Set(%q): %v", key, err)
+				}
+			}
+			got := make(map[string]string)
+			err := c.GetMultiFunc([]string{"a", "b", "c", "missing"}, func(it *Item) error {
+				got[it.Key] = string(it.Value)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("GetMultiFunc: %v", err)
+			}
+			if len(got) != 3 || got["a"] != "a" || got["b"] != "b" || got["c"] != "c" {
+				t.Errorf("GetMultiFunc passed %v, want a, b and c", got)
+			}
+
+			stop := errors.New("stop")
+			calls := 0
+			err = c.GetMultiFunc([]string{"a", "b", "c"}, func(*Item) error {
+				calls++
+				return stop
+			})
+			if err != stop || calls != 1 {
+				t.Errorf("GetMultiFunc with a failing callback = %v after %d calls, want the callback's error after 1", err, calls)
+			}
+		})
+	}
+}
+
+func TestGetMultiContextPartial(t *testing.T) {
+	fast, slow := memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer fast.Close()
+	defer slow.Close()
+	c := New(fast.Addr(), slow.Addr())
+	defer c.Close()
+
+	var keys []string
+	owner := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprint("key-", i)
+		if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+		addr, err := c.selector.PickServer(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+		owner[key] = addr.String()
+	}
+
+	slow.SetFaults(memcachetest.Faults{SlowRate: 1, Delay: 200 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	m, err := c.GetMultiContext(ctx, keys)
+	perr, ok := err.(*PartialError)
+	if !ok {
+		t.Fatalf("GetMultiContext error = %v, want a *PartialError", err)
+	}
+	if len(perr.Servers) != 1 || perr.Servers[0].String() != slow.Addr() || perr.Err != context.DeadlineExceeded {
+		t.Errorf("PartialError = %+v, want the slow server and context.DeadlineExceeded", perr)
+	}
+	for _, key := range keys {
+		if _, got := m[key]; got != (owner[key] == fast.Addr()) {
+			t.Errorf("key %q from %s returned = %v", key, owner[key], got)
+		}
+	}
+}
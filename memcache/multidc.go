@@ -0,0 +1,159 @@
+package memcache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DCStats counts the writes a MultiDC sent to a datacenter.
+type DCStats struct {
+	Writes  uint64 // writes answered, such as stored or not found
+	Failed  uint64 // writes that failed, such as on network errors
+	Dropped uint64 // writes to a remote datacenter dropped with its queue full
+}
+
+// MultiDC is a cache replicated over several datacenters, each with its
+// own Client. Writes go to the local datacenter synchronously and are
+// queued for the remote ones, which are written in the background so that
+// cross-datacenter latency stays off the request path. Reads are served
+// locally and can fall back to the remote datacenters.
+//
+// Remote writes are best effort: they are dropped when a datacenter's
+// queue is full, and their failures only show in the stats.
+type MultiDC struct {
+	local    *dc
+	remotes  []*dc // sorted by name
+	fallback bool
+	wg       sync.WaitGroup
+
+	mu     sync.RWMutex // held for reading while enqueueing
+	closed bool
+}
+
+type dc struct {
+	name   string
+	client *Client
+	queue  chan func(*Client) error
+	stats  DCStats
+}
+
+func (d *dc) record(err error) {
+	if err != nil && !resumableError(err) {
+		atomic.AddUint64(&d.stats.Failed, 1)
+	} else {
+		atomic.AddUint64(&d.stats.Writes, 1)
+	}
+}
+
+// NewMultiDC returns a MultiDC writing to the client of the local
+// datacenter, named localName, and, through queues of up to queueSize
+// writes each, to the clients of the remote datacenters, keyed by name.
+// If remoteFallback is set, Get tries the remote datacenters, in name
+// order, when the local one misses or fails. Close must be called to stop
+// the background writers.
+func NewMultiDC(localName string, local *Client, remotes map[string]*Client, queueSize int, remoteFallback bool) *MultiDC {
+	m := &MultiDC{local: &dc{name: localName, client: local}, fallback: remoteFallback}
+	names := make([]string, 0, len(remotes))
+	for name := range remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d := &dc{name: name, client: remotes[name], queue: make(chan func(*Client) error, queueSize)}
+		m.remotes = append(m.remotes, d)
+		m.wg.Add(1)
+		d.client.goFunc(func() {
+			defer m.wg.Done()
+			for write := range d.queue {
+				d.record(write(d.client))
+			}
+		})
+	}
+	return m
+}
+
+// write applies fn to the local datacenter and queues it for the remote
+// ones, returning the local result.
+func (m *MultiDC) write(fn func(*Client) error) error {
+	err := fn(m.local.client)
+	m.local.record(err)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return err
+	}
+	for _, d := range m.remotes {
+		select {
+		case d.queue <- fn:
+		default:
+			atomic.AddUint64(&d.stats.Dropped, 1)
+		}
+	}
+	return err
+}
+
+// Set writes item to every datacenter. The item is copied, but its Value
+// is only copied if the local client was built with CopyOnSet, and must
+// not be modified afterwards otherwise. The error is the local
+// datacenter's.
+func (m *MultiDC) Set(item *Item) error {
+	it := m.local.client.ownItem(item)
+	return m.write(func(c *Client) error { return c.Set(it) })
+}
+
+// Delete deletes key in every datacenter. The error is the local
+// datacenter's.
+func (m *MultiDC) Delete(key string) error {
+	return m.write(func(c *Client) error { return c.Delete(key) })
+}
+
+// Touch updates the expiry of key in every datacenter. The error is the
+// local datacenter's.
+func (m *MultiDC) Touch(key string, seconds int32) error {
+	return m.write(func(c *Client) error { return c.Touch(key, seconds) })
+}
+
+// Get gets key from the local datacenter, falling back to the remote ones
+// if configured to. The error is the local datacenter's if no datacenter
+// has the item.
+func (m *MultiDC) Get(key string) (*Item, error) {
+	it, err := m.local.client.Get(key)
+	if err == nil || !m.fallback || err == ErrMalformedKey {
+		return it, err
+	}
+	for _, d := range m.remotes {
+		if it, errRemote := d.client.Get(key); errRemote == nil {
+			return it, nil
+		}
+	}
+	return nil, err
+}
+
+// Stats returns the write statistics of each datacenter, keyed by name.
+func (m *MultiDC) Stats() map[string]DCStats {
+	stats := make(map[string]DCStats, len(m.remotes)+1)
+	for _, d := range append([]*dc{m.local}, m.remotes...) {
+		stats[d.name] = DCStats{
+			Writes:  atomic.LoadUint64(&d.stats.Writes),
+			Failed:  atomic.LoadUint64(&d.stats.Failed),
+			Dropped: atomic.LoadUint64(&d.stats.Dropped),
+		}
+	}
+	return stats
+}
+
+// Close stops accepting remote writes and waits for the queued ones to
+// finish. It does not close the clients.
+func (m *MultiDC) Close() error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		for _, d := range m.remotes {
+			close(d.queue)
+		}
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+	return nil
+}
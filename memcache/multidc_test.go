@@ -0,0 +1,74 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestMultiDC(t *testing.T) {
+	local, east, west := memcachetest.NewServer(t), memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer local.Close()
+	defer east.Close()
+	defer west.Close()
+	lc, ec, wc := New(local.Addr()), New(east.Addr()), New(west.Addr())
+	defer lc.Close()
+	defer ec.Close()
+	defer wc.Close()
+
+	m := NewMultiDC("local", lc, map[string]*Client{"east": ec, "west": wc}, 10, true)
+	if err := m.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lc.Get("k"); err != nil {
+		t.Errorf("local write was not synchronous: %v", err)
+	}
+	waitFor(t, "the remote writes", func() bool {
+		st := m.Stats()
+		return st["east"].Writes == 1 && st["west"].Writes == 1
+	})
+	for name, c := range map[string]*Client{"east": ec, "west": wc} {
+		if _, err := c.Get("k"); err != nil {
+			t.Errorf("item was not replicated to %s: %v", name, err)
+		}
+	}
+
+	// Reads fall back to a remote datacenter on a local miss.
+	if err := ec.Set(&Item{Key: "remote", Value: []byte("r")}); err != nil {
+		t.Fatal(err)
+	}
+	if it, err := m.Get("remote"); err != nil || string(it.Value) != "r" {
+		t.Errorf("Get with remote fallback = %v, %v", it, err)
+	}
+	if _, err := m.Get("nowhere"); err != ErrCacheMiss {
+		t.Errorf("Get of a missing key error = %v, want ErrCacheMiss", err)
+	}
+
+	if err := m.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+	if _, err := wc.Get("k"); err != ErrCacheMiss {
+		t.Errorf("queued delete was not applied by Close: %v", err)
+	}
+	if st := m.Stats()["local"]; st.Writes != 2 || st.Failed != 0 {
+		t.Errorf("local stats = %+v, want 2 writes", st)
+	}
+}
+
+func TestMultiDCRemoteFailures(t *testing.T) {
+	local, remote := memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer local.Close()
+	lc, rc := New(local.Addr()), New(remote.Addr())
+	defer lc.Close()
+	remote.Close()
+
+	m := NewMultiDC("local", lc, map[string]*Client{"remote": rc}, 1, false)
+	if err := m.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Errorf("Set failed for a remote datacenter being down: %v", err)
+	}
+	m.Close()
+	if st := m.Stats()["remote"]; st.Failed != 1 {
+		t.Errorf("remote stats = %+v, want 1 failed", st)
+	}
+}
@@ -0,0 +1,94 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NoServersError is returned when a key maps to no server, because its
+// selector has none configured or has taken them all out of rotation. It
+// matches ErrNoServers with errors.Is.
+//
+// Selectors that eject failing servers may return a NoServersError
+// themselves to explain why; otherwise the client fills in Servers from
+// the selector's Each.
+type NoServersError struct {
+	Servers []string         // the servers listed by the selector
+	Reasons map[string]error // why each ejected server is out, by address
+}
+
+func (e *NoServersError) Error() string {
+	if len(e.Servers) == 0 {
+		return "memcache: no servers configured"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "memcache: none of the servers %s is available", strings.Join(e.Servers, ", "))
+	addrs := make([]string, 0, len(e.Reasons))
+	for addr := range e.Reasons {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		fmt.Fprintf(&b, "; %s: %v", addr, e.Reasons[addr])
+	}
+	return b.String()
+}
+
+// Is reports whether target is ErrNoServers.
+func (e *NoServersError) Is(target error) bool {
+	return target == ErrNoServers
+}
+
+// noServersPoll is how often an operation waiting for servers asks its
+// selector again.
+const noServersPoll = 20 * time.Millisecond
+
+// pickServer returns the server of the caller's key, to which skey, the
+// key as sent, is hashed. If the key's selector has no server, it waits
+// up to Config.WaitForServers for one to come back, then fails with a
+// *NoServersError. Keys of drained servers go to the undrained ones.
+// While the cache is disabled by Config.ErrorBudget, it fails with
+// ErrCacheMiss if op reads, and with ErrCacheDisabled otherwise.
+func (c *Client) pickServer(op, key, skey string) (net.Addr, error) {
+	if c.shortCircuit() {
+		return nil, shortCircuitError(op)
+	}
+	ss := c.selectorFor(key)
+	addr, err := ss.PickServer(skey)
+	if err == nil {
+		return c.undrained(ss, addr, skey)
+	}
+	if !errors.Is(err, ErrNoServers) {
+		return addr, err
+	}
+	if c.waitForServers > 0 {
+		deadline := time.Now().Add(c.waitForServers)
+		for time.Now().Before(deadline) {
+			wait := noServersPoll
+			if left := time.Until(deadline); left < wait {
+				wait = left
+			}
+			time.Sleep(wait)
+			if addr, err = ss.PickServer(skey); err == nil {
+				return c.undrained(ss, addr, skey)
+			}
+			if !errors.Is(err, ErrNoServers) {
+				return addr, err
+			}
+		}
+	}
+	var nse *NoServersError
+	if errors.As(err, &nse) {
+		return nil, err
+	}
+	nse = new(NoServersError)
+	ss.Each(func(addr net.Addr) error {
+		nse.Servers = append(nse.Servers, addr.String())
+		return nil
+	})
+	return nil, nse
+}
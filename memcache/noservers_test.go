@@ -0,0 +1,90 @@
+package memcache
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+// ejectingSelector lists its servers but has taken them all out.
+type ejectingSelector struct{ addrs []net.Addr }
+
+func (s *ejectingSelector) PickServer(string) (net.Addr, error) {
+	return nil, &NoServersError{
+		Servers: []string{s.addrs[0].String()},
+		Reasons: map[string]error{s.addrs[0].String(): errors.New("too many timeouts")},
+	}
+}
+
+func (s *ejectingSelector) Each(fn func(net.Addr) error) error {
+	for _, addr := range s.addrs {
+		if err := fn(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestNoServersError(t *testing.T) {
+	c, err := NewClient(nil, WithSelector(new(ServerList)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Get("k")
+	var nse *NoServersError
+	if !errors.As(err, &nse) || !errors.Is(err, ErrNoServers) {
+		t.Fatalf("Get without servers error = %#v, want a *NoServersError matching ErrNoServers", err)
+	}
+	if len(nse.Servers) != 0 {
+		t.Errorf("Servers = %v, want none", nse.Servers)
+	}
+
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:11211")
+	c, err = NewClient(nil, WithSelector(&ejectingSelector{[]net.Addr{addr}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Set(&Item{Key: "k"})
+	if !errors.As(err, &nse) {
+		t.Fatalf("Set error = %v, want a *NoServersError", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "127.0.0.1:11211: too many timeouts") {
+		t.Errorf("error %q does not give the ejection reason", msg)
+	}
+}
+
+func TestWaitForServers(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	ss := new(ServerList)
+	c, err := NewClient(nil, WithSelector(ss), WithWaitForServers(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ss.SetServers(s.Addr())
+	}()
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatalf("Set while the servers come back: %v", err)
+	}
+
+	ss.SetServers()
+	c, err = NewClient(nil, WithSelector(ss), WithWaitForServers(30*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := c.Get("k"); !errors.Is(err, ErrNoServers) {
+		t.Errorf("Get error = %v, want ErrNoServers", err)
+	}
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("Get failed after %v, before WaitForServers", d)
+	}
+}
@@ -0,0 +1,741 @@
+package memcache
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/proto/bin"
+	"github.com/skinass/gomemcache/memcache/proto/text"
+)
+
+// Config describes how to build a Client. The zero value of each field
+// selects the same default the Client uses for its zero value.
+type Config struct {
+	// Servers lists the server addresses, as accepted by
+	// ServerList.SetServers. It must be empty if Selector is set.
+	Servers []string
+
+	// Selector picks the server for each key. If nil, a ServerList over
+	// Servers is used.
+	Selector ServerSelector
+
+	// Hash replaces the default CRC-32 key hash of the ServerList built
+	// from Servers. It must be nil if Selector is set.
+	Hash func(key string) uint32
+
+	// FailureDetector, if set, takes the servers it suspects to be down
+	// out of rotation, spreading their keys over the other servers.
+	FailureDetector FailureDetector
+
+	// Protocol is text.ProtoType or bin.ProtoType. If empty, the text
+	// protocol is used.
+	Protocol string
+
+	// Timeout and AuthTimeout are the socket read/write timeouts for
+	// regular operations and for authentication.
+	Timeout     time.Duration
+	AuthTimeout time.Duration
+
+	// MaxIdleConns is the maximum number of idle connections kept per
+	// server.
+	MaxIdleConns int
+
+	// IdleCheck, if positive, makes the client check that a connection
+	// idle for longer still works before reusing it, by waiting for the
+	// answer to a cheap command, so that connections silently dropped by
+	// a firewall or a restarted server fail the check rather than an
+	// operation.
+	IdleCheck time.Duration
+
+	// MaxOpenConns, if positive, limits the number of connections open to
+	// each server. Operations needing another connection wait for one in
+	// line, first come first served, up to Timeout, failing with a
+	// *TimeoutError of PhasePoolWait.
+	MaxOpenConns int
+
+	// MinConns, if positive, is the number of connections opened and
+	// authenticated to each server when the client is built, instead of
+	// on first use, so that misconfigured addresses or credentials are
+	// caught at startup. Building the client then fails if no server can
+	// be reached. It cannot exceed the idle connection limit.
+	MinConns int
+
+	// WaitForServers, if positive, makes operations whose key maps to no
+	// server wait up to that long for the selector to have one again,
+	// such as while a discovery mechanism catches up, instead of failing
+	// at once. They fail with a *NoServersError either way.
+	WaitForServers time.Duration
+
+	// CheckFlags makes writes whose Item.Flags use bits reserved for
+	// client features, as listed by ReservedFlags, fail with a
+	// *FlagConflictError instead of being read back as the feature's
+	// metadata.
+	CheckFlags bool
+
+	// CopyOnSet makes the client copy the values of the items it is
+	// given to store before using them. Callers may then modify a value
+	// while it is being stored, or right after handing it to WriteBehind
+	// or MultiDC. Otherwise the client borrows the value, which must not
+	// change until it has been written.
+	CopyOnSet bool
+
+	// ProxyCompat adapts the client to proxies that don't support every
+	// command: Append and Prepend are emulated with a read and a
+	// compare-and-swap, retried while other writers race with them.
+	ProxyCompat bool
+
+	// BorrowValues makes the client read the values of retrieved items
+	// into pooled buffers, which callers hand back with Client.Release
+	// once done with the items, sparing an allocation per item.
+	// Otherwise each value is allocated for its item and owned by the
+	// caller.
+	BorrowValues bool
+
+	// AllowFlush enables FlushAll and DeleteAll, which empty the whole
+	// cache. Even then, each call must pass ConfirmFlush.
+	AllowFlush bool
+
+	// PressureRetryTTL, if positive, makes writes that a server refuses
+	// for lack of memory be retried once with that TTL, if it is shorter
+	// than theirs, so that they make way for other items sooner. Such
+	// refusals are reported to the PressureHooks either way.
+	PressureRetryTTL time.Duration
+
+	// WriteLimit, if set, limits the rate of the writes and deletes sent
+	// to each server, to protect small cache nodes from bulk backfill
+	// jobs. Batches count as one operation per key.
+	WriteLimit RateLimit
+
+	// MaxBatchKeys and MaxBatchBytes, if positive, are guardrails against
+	// pathological requests, such as multigets of a million keys made by
+	// a bug: batch operations over more keys, and SetMulti calls storing
+	// more value bytes, fail with a *RequestTooLargeError before anything
+	// is sent.
+	MaxBatchKeys  int
+	MaxBatchBytes int64
+
+	// PhaseBudgets, if set, splits Timeout between the dial,
+	// authentication, write and read phases of each operation.
+	PhaseBudgets PhaseBudgets
+
+	// Username and Password enable SASL authentication. Both must be set,
+	// and only the binary protocol supports it.
+	Username, Password string
+
+	// TLSConfig, if set, makes the client connect over TLS.
+	TLSConfig *tls.Config
+
+	// DialContext, if set, opens the connections to the servers instead
+	// of net.Dialer, for example through a proxy or a tunnel. Its context
+	// expires after Timeout. TLS, if configured, runs over the
+	// connections it returns.
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// Codec encodes values for helpers such as Memoize.
+	Codec Codec
+
+	// Profiles configures the handling of the keys starting with each
+	// prefix, the longest matching prefix winning: their default TTL,
+	// codec, servers and replication. This lets values of different
+	// shapes use, for example, compressors with different dictionaries.
+	Profiles map[string]Profile
+
+	// CheckReconnectibleError decides whether an operation that failed
+	// with the given error is retried on a new connection.
+	CheckReconnectibleError func(error) bool
+
+	// MaxResponseSize, if positive, is the largest response body the
+	// client accepts, or with the text protocol the largest value, such
+	// as the servers' item_size_max. Larger responses fail with a
+	// *ResponseTooLargeError, before their size is allocated, and the
+	// connection is closed.
+	MaxResponseSize int64
+
+	// Clock replaces the system clock for the client's time-dependent
+	// logic. It is meant for tests.
+	Clock Clock
+
+	// Policy, if set, may reject or rewrite each operation before it is
+	// sent.
+	Policy Policy
+
+	// ValidateKey, if set, checks every key against the application's
+	// naming convention, such as with KeyPattern. Operations on keys it
+	// rejects fail with a *KeyFormatError.
+	ValidateKey func(key string) error
+
+	// ValidateValue, if set, checks every fetched value, such as with
+	// ValueHeader or ValidJSON. Values it rejects are treated as misses,
+	// so that corrupt or foreign entries don't reach the application.
+	// If DeleteInvalid is also set, they are deleted from the server in
+	// the background; a value stored again in the meantime may be deleted
+	// with them.
+	ValidateValue func(value []byte) error
+	DeleteInvalid bool
+
+	// Reencoder, if set, migrates the fetched values written in an old
+	// format to a new one in the background, as they are read.
+	Reencoder Reencoder
+
+	// ErrorBudget, if its MaxErrorRate is set, disables the cache for a
+	// while when too many operations fail.
+	ErrorBudget ErrorBudget
+
+	// WriteCoalescing, if its Delay is set, batches the concurrent Set
+	// calls to each server into single round trips.
+	WriteCoalescing WriteCoalescing
+
+	// KeyEncoding, if set, encodes keys so that they may contain spaces
+	// and control bytes.
+	KeyEncoding KeyEncoding
+
+	// ClientName, if set, labels the connections of the client: each
+	// fetches LabelKeyPrefix followed by the name once connected, which
+	// the server logs. It must be usable in a key.
+	ClientName string
+
+	// Pools configures named pools, each a client of its own for another
+	// fleet of servers, reached with Client.Pool. A client may have no
+	// servers of its own if it has pools. Pools cannot have pools.
+	Pools map[string]Config
+
+	// StandbyPool, if set, names the pool of Pools kept as a warm
+	// standby of the client's servers, for their rolling restarts: the
+	// client's writes are copied to it in the background, and the
+	// changes it can't copy, such as appends and increments, delete the
+	// key from it. The reads that miss or fail with a network error are
+	// retried on the standby, and its hits are stored back to the
+	// client's servers, expiring after StandbyTTL or, if zero, the TTL
+	// of the key's profile. Touches are not copied. The copies and the
+	// backfills wait in bounded queues, dropping their oldest writes
+	// when full, as Client.StandbyStats reports.
+	StandbyPool string
+	StandbyTTL  time.Duration
+}
+
+// An Option adjusts a Config.
+type Option func(*Config)
+
+// WithProtocol selects the protocol, text.ProtoType or bin.ProtoType.
+func WithProtocol(proto string) Option {
+	return func(cfg *Config) { cfg.Protocol = proto }
+}
+
+// WithTimeout sets the socket read/write timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(cfg *Config) { cfg.Timeout = d }
+}
+
+// WithAuthTimeout sets the socket read/write timeout used while
+// authenticating.
+func WithAuthTimeout(d time.Duration) Option {
+	return func(cfg *Config) { cfg.AuthTimeout = d }
+}
+
+// WithPhaseBudgets splits Timeout between the phases of operations by the
+// weights of b.
+func WithPhaseBudgets(b PhaseBudgets) Option {
+	return func(cfg *Config) { cfg.PhaseBudgets = b }
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections kept per
+// server.
+func WithMaxIdleConns(n int) Option {
+	return func(cfg *Config) { cfg.MaxIdleConns = n }
+}
+
+// WithIdleCheck makes the client check connections idle for longer than
+// d before reusing them.
+func WithIdleCheck(d time.Duration) Option {
+	return func(cfg *Config) { cfg.IdleCheck = d }
+}
+
+// WithMaxOpenConns limits the number of connections open to each server
+// to n.
+func WithMaxOpenConns(n int) Option {
+	return func(cfg *Config) { cfg.MaxOpenConns = n }
+}
+
+// WithMinConns makes the client open n connections to each server when
+// it is built.
+func WithMinConns(n int) Option {
+	return func(cfg *Config) { cfg.MinConns = n }
+}
+
+// WithWaitForServers makes operations wait up to d for a server when
+// their key maps to none.
+func WithWaitForServers(d time.Duration) Option {
+	return func(cfg *Config) { cfg.WaitForServers = d }
+}
+
+// WithCheckFlags makes writes whose flags use reserved bits fail.
+func WithCheckFlags() Option {
+	return func(cfg *Config) { cfg.CheckFlags = true }
+}
+
+// WithCopyOnSet makes the client copy the values it stores.
+func WithCopyOnSet() Option {
+	return func(cfg *Config) { cfg.CopyOnSet = true }
+}
+
+// WithProxyCompat adapts the client to proxies lacking append and prepend.
+func WithProxyCompat() Option {
+	return func(cfg *Config) { cfg.ProxyCompat = true }
+}
+
+// WithBorrowedValues makes retrieved values borrow pooled buffers, to be
+// returned with Client.Release.
+func WithBorrowedValues() Option {
+	return func(cfg *Config) { cfg.BorrowValues = true }
+}
+
+// WithAllowFlush enables FlushAll and DeleteAll.
+func WithAllowFlush() Option {
+	return func(cfg *Config) { cfg.AllowFlush = true }
+}
+
+// WithPressureRetryTTL makes writes refused for lack of memory be retried
+// once with ttl.
+func WithPressureRetryTTL(ttl time.Duration) Option {
+	return func(cfg *Config) { cfg.PressureRetryTTL = ttl }
+}
+
+// WithWriteLimit limits the rate of writes and deletes to each server.
+func WithWriteLimit(l RateLimit) Option {
+	return func(cfg *Config) { cfg.WriteLimit = l }
+}
+
+// WithBatchLimits sets the largest number of keys of batch operations and
+// of value bytes of SetMulti calls.
+func WithBatchLimits(maxKeys int, maxBytes int64) Option {
+	return func(cfg *Config) { cfg.MaxBatchKeys, cfg.MaxBatchBytes = maxKeys, maxBytes }
+}
+
+// WithSelector makes the client pick servers with ss instead of a
+// ServerList over the given addresses.
+func WithSelector(ss ServerSelector) Option {
+	return func(cfg *Config) { cfg.Selector = ss }
+}
+
+// WithFailureDetector makes the client eject the servers fd suspects to
+// be down, such as with NewPhiAccrualDetector(8).
+func WithFailureDetector(fd FailureDetector) Option {
+	return func(cfg *Config) { cfg.FailureDetector = fd }
+}
+
+// WithHash replaces the key hash used to pick a server from the list.
+func WithHash(hash func(key string) uint32) Option {
+	return func(cfg *Config) { cfg.Hash = hash }
+}
+
+// WithTLS makes the client connect over TLS using tlsConfig.
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(cfg *Config) { cfg.TLSConfig = tlsConfig }
+}
+
+// WithDialContext makes the client open connections with dial.
+func WithDialContext(dial func(ctx context.Context, network, address string) (net.Conn, error)) Option {
+	return func(cfg *Config) { cfg.DialContext = dial }
+}
+
+// WithAuth sets the SASL credentials.
+func WithAuth(username, password string) Option {
+	return func(cfg *Config) { cfg.Username, cfg.Password = username, password }
+}
+
+// WithCodec sets the codec used by helpers that store Go values.
+func WithCodec(codec Codec) Option {
+	return func(cfg *Config) { cfg.Codec = codec }
+}
+
+// WithMaxResponseSize sets the largest response body the client accepts.
+func WithMaxResponseSize(n int64) Option {
+	return func(cfg *Config) { cfg.MaxResponseSize = n }
+}
+
+// WithClock makes the client tell the time with clock.
+func WithClock(clock Clock) Option {
+	return func(cfg *Config) { cfg.Clock = clock }
+}
+
+// WithPolicy makes the client consult policy before every operation.
+func WithPolicy(policy Policy) Option {
+	return func(cfg *Config) { cfg.Policy = policy }
+}
+
+// WithKeyValidator makes the client check every key with validate.
+func WithKeyValidator(validate func(key string) error) Option {
+	return func(cfg *Config) { cfg.ValidateKey = validate }
+}
+
+// WithValueValidator makes the client treat the fetched values validate
+// rejects as misses, and delete them if deleteInvalid is set.
+func WithValueValidator(validate func(value []byte) error, deleteInvalid bool) Option {
+	return func(cfg *Config) {
+		cfg.ValidateValue = validate
+		cfg.DeleteInvalid = deleteInvalid
+	}
+}
+
+// WithReencoder makes the client rewrite the stale values it fetches with
+// r.
+func WithReencoder(r Reencoder) Option {
+	return func(cfg *Config) { cfg.Reencoder = r }
+}
+
+// WithErrorBudget makes the client disable the cache for a while when
+// too many operations fail, as described by b.
+func WithErrorBudget(b ErrorBudget) Option {
+	return func(cfg *Config) { cfg.ErrorBudget = b }
+}
+
+// WithWriteCoalescing makes the client batch concurrent Set calls to the
+// same server, as described by w.
+func WithWriteCoalescing(w WriteCoalescing) Option {
+	return func(cfg *Config) { cfg.WriteCoalescing = w }
+}
+
+// WithKeyEncoding makes the client encode keys with enc.
+func WithKeyEncoding(enc KeyEncoding) Option {
+	return func(cfg *Config) { cfg.KeyEncoding = enc }
+}
+
+// WithProfile sets the profile of the keys starting with prefix.
+func WithProfile(prefix string, p Profile) Option {
+	return func(cfg *Config) {
+		if cfg.Profiles == nil {
+			cfg.Profiles = make(map[string]Profile)
+		}
+		cfg.Profiles[prefix] = p
+	}
+}
+
+// WithPrefixCodec makes the client encode the values of the keys starting
+// with prefix with codec, keeping the rest of the prefix's profile.
+func WithPrefixCodec(prefix string, codec Codec) Option {
+	return func(cfg *Config) {
+		p := cfg.Profiles[prefix]
+		p.Codec = codec
+		WithProfile(prefix, p)(cfg)
+	}
+}
+
+// WithClientName labels the client's connections with name.
+func WithClientName(name string) Option {
+	return func(cfg *Config) { cfg.ClientName = name }
+}
+
+// WithPool adds the pool name, configured by cfg.
+func WithPool(name string, cfg Config) Option {
+	return func(c *Config) {
+		if c.Pools == nil {
+			c.Pools = make(map[string]Config)
+		}
+		c.Pools[name] = cfg
+	}
+}
+
+// WithStandbyPool makes the pool name, one of the client's pools, a warm
+// standby of its servers, backfilling them with items expiring after ttl.
+func WithStandbyPool(name string, ttl time.Duration) Option {
+	return func(cfg *Config) { cfg.StandbyPool, cfg.StandbyTTL = name, ttl }
+}
+
+// WithReconnectibleErrorCheck sets the function deciding whether a failed
+// operation is retried on a new connection.
+func WithReconnectibleErrorCheck(f func(error) bool) Option {
+	return func(cfg *Config) { cfg.CheckReconnectibleError = f }
+}
+
+// NewClient returns a client for the given servers configured by opts.
+func NewClient(servers []string, opts ...Option) (*Client, error) {
+	cfg := Config{Servers: servers}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return NewWithOptions(cfg)
+}
+
+// Validate reports the first problem that would make a client built from
+// cfg misbehave, such as negative limits, settings that would be silently
+// ignored, or options that contradict each other.
+func (cfg *Config) Validate() error {
+	if err := cfg.validateTunables(); err != nil {
+		return err
+	}
+	switch {
+	case cfg.Protocol != "" && cfg.Protocol != text.ProtoType && cfg.Protocol != bin.ProtoType:
+		return configError("unknown protocol %q", cfg.Protocol)
+	case cfg.Selector == nil && len(cfg.Servers) == 0 && len(cfg.Pools) == 0:
+		return configError("no servers or selector configured")
+	case cfg.Selector != nil && len(cfg.Servers) > 0:
+		return configError("both Servers and Selector are set")
+	case cfg.Selector != nil && cfg.Hash != nil:
+		return configError("Hash has no effect with a custom Selector")
+	case (cfg.Username == "") != (cfg.Password == ""):
+		return configError("Username and Password must be set together")
+	case cfg.Username != "" && cfg.Protocol != bin.ProtoType:
+		return configError("authentication requires the %s protocol", bin.ProtoType)
+	case cfg.MinConns < 0:
+		return configError("negative MinConns %d", cfg.MinConns)
+	case cfg.IdleCheck < 0:
+		return configError("negative IdleCheck %v", cfg.IdleCheck)
+	case cfg.MaxOpenConns < 0:
+		return configError("negative MaxOpenConns %d", cfg.MaxOpenConns)
+	case cfg.MaxOpenConns > 0 && cfg.MinConns > cfg.MaxOpenConns:
+		return configError("MinConns %d exceeds MaxOpenConns %d", cfg.MinConns, cfg.MaxOpenConns)
+	case cfg.MinConns > cfg.maxIdleConns():
+		return configError("MinConns %d exceeds the idle limit %d", cfg.MinConns, cfg.maxIdleConns())
+	case cfg.DeleteInvalid && cfg.ValidateValue == nil:
+		return configError("DeleteInvalid has no effect without ValidateValue")
+	case cfg.PressureRetryTTL < 0:
+		return configError("negative PressureRetryTTL %v", cfg.PressureRetryTTL)
+	case cfg.WriteLimit.PerSecond < 0:
+		return configError("negative WriteLimit.PerSecond %v", cfg.WriteLimit.PerSecond)
+	case cfg.WriteLimit.Burst < 0:
+		return configError("negative WriteLimit.Burst %d", cfg.WriteLimit.Burst)
+	case cfg.MaxBatchKeys < 0:
+		return configError("negative MaxBatchKeys %d", cfg.MaxBatchKeys)
+	case cfg.MaxBatchBytes < 0:
+		return configError("negative MaxBatchBytes %d", cfg.MaxBatchBytes)
+	case cfg.WaitForServers < 0:
+		return configError("negative WaitForServers %v", cfg.WaitForServers)
+	case cfg.MaxResponseSize < 0:
+		return configError("negative MaxResponseSize %d", cfg.MaxResponseSize)
+	case cfg.ClientName != "" && !legalKey(LabelKeyPrefix+cfg.ClientName):
+		return configError("ClientName %q cannot be used in a key", cfg.ClientName)
+	case cfg.KeyEncoding < KeyEncodingNone || cfg.KeyEncoding > KeyEncodingBase64:
+		return configError("unknown KeyEncoding %d", cfg.KeyEncoding)
+	}
+	if err := cfg.Reencoder.validate(); err != nil {
+		return err
+	}
+	if err := cfg.ErrorBudget.validate(); err != nil {
+		return err
+	}
+	if err := cfg.WriteCoalescing.validate(); err != nil {
+		return err
+	}
+	for prefix, p := range cfg.Profiles {
+		switch {
+		case p.TTL < 0:
+			return configError("negative TTL %v for prefix %q", p.TTL, prefix)
+		case p.Replicas < 0:
+			return configError("negative Replicas %d for prefix %q", p.Replicas, prefix)
+		case p.Quota.Ops.PerSecond < 0 || p.Quota.Bytes.PerSecond < 0:
+			return configError("negative Quota rate for prefix %q", prefix)
+		case p.Quota.Ops.Burst < 0 || p.Quota.Bytes.Burst < 0:
+			return configError("negative Quota burst for prefix %q", prefix)
+		}
+	}
+	for _, server := range cfg.Servers {
+		if strings.Contains(server, "/") && cfg.TLSConfig != nil {
+			return configError("TLS is not supported for unix socket %q", server)
+		}
+	}
+	if _, ok := cfg.Pools[cfg.StandbyPool]; cfg.StandbyPool != "" && !ok {
+		return configError("StandbyPool %q is not one of the pools", cfg.StandbyPool)
+	}
+	if cfg.StandbyTTL < 0 {
+		return configError("negative StandbyTTL %v", cfg.StandbyTTL)
+	}
+	for name, pool := range cfg.Pools {
+		if len(pool.Pools) > 0 {
+			return configError("pool %q has pools", name)
+		}
+		if err := pool.Validate(); err != nil {
+			return fmt.Errorf("%v in pool %q", err, name)
+		}
+	}
+	return nil
+}
+
+// validateTunables checks the settings ApplyConfig can change.
+func (cfg *Config) validateTunables() error {
+	switch {
+	case cfg.Timeout < 0:
+		return configError("negative Timeout %v", cfg.Timeout)
+	case cfg.AuthTimeout < 0:
+		return configError("negative AuthTimeout %v", cfg.AuthTimeout)
+	case cfg.MaxIdleConns < 0:
+		return configError("negative MaxIdleConns %d", cfg.MaxIdleConns)
+	}
+	return cfg.PhaseBudgets.validate()
+}
+
+// maxIdleConns returns the idle connection limit of a client built from
+// cfg.
+func (cfg *Config) maxIdleConns() int {
+	if cfg.MaxIdleConns > 0 {
+		return cfg.MaxIdleConns
+	}
+	return DefaultMaxIdleConns
+}
+
+func configError(format string, args ...interface{}) error {
+	return fmt.Errorf("memcache: invalid config: "+format, args...)
+}
+
+// NewWithOptions returns a client configured by cfg. An error is returned
+// if cfg doesn't pass Validate, a server address can't be resolved, or
+// cfg.MinConns is set and no server can be reached.
+func NewWithOptions(cfg Config) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	c := &Client{
+		Timeout:                 cfg.Timeout,
+		AuthTimeout:             cfg.AuthTimeout,
+		MaxIdleConns:            cfg.MaxIdleConns,
+		maxOpenConns:            cfg.MaxOpenConns,
+		idleCheck:               cfg.IdleCheck,
+		phaseBudgets:            cfg.PhaseBudgets,
+		Username:                cfg.Username,
+		Password:                cfg.Password,
+		selector:                cfg.Selector,
+		failureDetector:         cfg.FailureDetector,
+		codec:                   cfg.Codec,
+		profiles:                cfg.Profiles,
+		tlsConfig:               cfg.TLSConfig,
+		dialContext:             cfg.DialContext,
+		checkReconnectibleError: cfg.CheckReconnectibleError,
+		clock:                   cfg.Clock,
+		policy:                  cfg.Policy,
+		validateKey:             cfg.ValidateKey,
+		validateValue:           cfg.ValidateValue,
+		deleteInvalid:           cfg.DeleteInvalid,
+		reencoder:               cfg.Reencoder,
+		keyEncoding:             cfg.KeyEncoding,
+		clientName:              cfg.ClientName,
+		waitForServers:          cfg.WaitForServers,
+		writeLimit:              cfg.WriteLimit,
+		maxBatchKeys:            cfg.MaxBatchKeys,
+		maxBatchBytes:           cfg.MaxBatchBytes,
+		pressureRetryTTL:        cfg.PressureRetryTTL,
+		checkFlags:              cfg.CheckFlags,
+		copyOnSet:               cfg.CopyOnSet,
+		allowFlush:              cfg.AllowFlush,
+		proxyCompat:             cfg.ProxyCompat,
+	}
+	if cfg.BorrowValues {
+		c.buffers = new(bufferPool)
+	}
+	if cfg.ErrorBudget.MaxErrorRate > 0 {
+		c.errorBreaker = newErrorBreaker(cfg.ErrorBudget)
+	}
+	if cfg.WriteCoalescing.Delay > 0 {
+		c.coalescer = newCoalescer(c, cfg.WriteCoalescing, cfg.MaxBatchKeys)
+	}
+	if len(cfg.Profiles) > 0 {
+		prefixes := make([]string, 0, len(cfg.Profiles))
+		c.tenants = make(map[string]*tenant, len(cfg.Profiles))
+		for prefix, p := range cfg.Profiles {
+			prefixes = append(prefixes, prefix)
+			c.tenants[prefix] = &tenant{quota: p.Quota}
+		}
+		c.profilePrefixes = newPrefixList(prefixes)
+	}
+
+	if cfg.Protocol == bin.ProtoType {
+		r := bin.DefaultBinCommander
+		if cfg.MaxResponseSize > 0 {
+			r = bin.NewCommander(cfg.MaxResponseSize)
+		}
+		if c.buffers != nil {
+			r = r.WithBufferPool(c.buffers)
+		}
+		c.cmdRunner = r
+	} else {
+		r := text.DefaultTextCommander
+		if cfg.MaxResponseSize > 0 {
+			r = text.NewCommander(cfg.MaxResponseSize)
+		}
+		if c.buffers != nil {
+			r = r.WithBufferPool(c.buffers)
+		}
+		c.cmdRunner = r
+	}
+
+	if c.selector == nil {
+		ss := &ServerList{hash: cfg.Hash}
+		if err := ss.SetServers(cfg.Servers...); err != nil {
+			return nil, err
+		}
+		c.selector = ss
+	}
+	for name, pool := range cfg.Pools {
+		pc, err := NewWithOptions(pool)
+		if err != nil {
+			c.Close() // the pools built so far may have connections
+			return nil, err
+		}
+		if c.named == nil {
+			c.named = make(map[string]*Client)
+		}
+		c.named[name] = pc
+	}
+	c.standby, c.standbyTTL = c.named[cfg.StandbyPool], cfg.StandbyTTL
+	c.startStandby()
+	if cfg.MinConns > 0 {
+		if err := c.openMinConns(cfg.MinConns); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Pool returns the client of the pool name, configured by Config.Pools,
+// or nil if the client has no such pool.
+func (c *Client) Pool(name string) *Client {
+	return c.named[name]
+}
+
+// ApplyConfig changes the tunable settings of a live client to those in
+// cfg: Timeout, AuthTimeout, MaxIdleConns, PhaseBudgets, Codec and
+// CheckReconnectibleError. All of them change at once, and operations in
+// flight keep the settings they started with. Established connections are
+// kept, except that idle connections beyond a lowered MaxIdleConns are
+// closed.
+//
+// The settings that define which servers the client talks to and how
+// (Servers, Selector, Hash, FailureDetector, Protocol, credentials,
+// TLSConfig, DialContext, IdleCheck, MaxOpenConns, Profiles, Policy,
+// ValidateKey, ValidateValue, DeleteInvalid, Reencoder, ErrorBudget,
+// WriteCoalescing, KeyEncoding, ClientName, WaitForServers, WriteLimit,
+// MaxBatchKeys, MaxBatchBytes, PressureRetryTTL, CheckFlags, CopyOnSet,
+// ProxyCompat, BorrowValues, AllowFlush, Pools, StandbyPool and
+// StandbyTTL) are fixed when the client is built and are ignored by
+// ApplyConfig, as are Clock and MinConns.
+func (c *Client) ApplyConfig(cfg Config) error {
+	if err := cfg.validateTunables(); err != nil {
+		return err
+	}
+	c.cfgMu.Lock()
+	c.Timeout = cfg.Timeout
+	c.AuthTimeout = cfg.AuthTimeout
+	c.MaxIdleConns = cfg.MaxIdleConns
+	c.phaseBudgets = cfg.PhaseBudgets
+	c.codec = cfg.Codec
+	c.checkReconnectibleError = cfg.CheckReconnectibleError
+	c.cfgMu.Unlock()
+
+	c.trimFreeConns()
+	return nil
+}
+
+// WatchConfig applies each Config received from updates with ApplyConfig
+// until updates is closed, passing the error for rejected configs to
+// onError if it is not nil. It is the integration point for config
+// watchers and is meant to run in its own goroutine.
+func (c *Client) WatchConfig(updates <-chan Config, onError func(error)) {
+	for cfg := range updates {
+		if err := c.ApplyConfig(cfg); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
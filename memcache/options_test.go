@@ -0,0 +1,208 @@
+package memcache
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+	"github.com/skinass/gomemcache/memcache/proto/bin"
+	"github.com/skinass/gomemcache/memcache/proto/text"
+)
+
+func TestNewClient(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+
+	c, err := NewClient([]string{s.Addr()},
+		WithTimeout(time.Second),
+		WithMaxIdleConns(4),
+		WithCodec(JSONCodec),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if c.Timeout != time.Second || c.MaxIdleConns != 4 {
+		t.Errorf("Timeout, MaxIdleConns = %v, %d; want 1s, 4", c.Timeout, c.MaxIdleConns)
+	}
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	c, err = NewClient([]string{s.Addr()}, WithProtocol(bin.ProtoType))
+	if err != nil || c.ProtoType() != bin.ProtoType {
+		t.Fatalf("NewClient(binary) = %v, %v", c, err)
+	}
+	if _, err := NewClient(nil, WithProtocol("udp")); err == nil {
+		t.Error("NewClient accepted an unknown protocol")
+	}
+	if _, err := NewClient([]string{"no-such-host.invalid:11211"}); err == nil {
+		t.Error("NewClient accepted an unresolvable server")
+	}
+}
+
+func TestWithHash(t *testing.T) {
+	c, err := NewClient([]string{"127.0.0.1:1", "127.0.0.1:2"},
+		WithHash(func(string) uint32 { return 1 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := c.selector.PickServer("anything")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != "127.0.0.1:2" {
+		t.Errorf("PickServer = %v, want 127.0.0.1:2", addr)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		ok   bool
+	}{
+		{"minimal", Config{Servers: []string{"127.0.0.1:11211"}}, true},
+		{"binary auth", Config{Servers: []string{"127.0.0.1:11211"}, Protocol: bin.ProtoType, Username: "u", Password: "p"}, true},
+		{"no servers", Config{}, false},
+		{"negative timeout", Config{Servers: []string{"127.0.0.1:11211"}, Timeout: -time.Second}, false},
+		{"negative idle", Config{Servers: []string{"127.0.0.1:11211"}, MaxIdleConns: -1}, false},
+		{"servers and selector", Config{Servers: []string{"127.0.0.1:11211"}, Selector: new(ServerList)}, false},
+		{"half credentials", Config{Servers: []string{"127.0.0.1:11211"}, Protocol: bin.ProtoType, Username: "u"}, false},
+		{"text auth", Config{Servers: []string{"127.0.0.1:11211"}, Username: "u", Password: "p"}, false},
+		{"unix tls", Config{Servers: []string{"/tmp/mc.sock"}, TLSConfig: &tls.Config{}}, false},
+		{"binary max response", Config{Servers: []string{"127.0.0.1:11211"}, Protocol: bin.ProtoType, MaxResponseSize: 1 << 20}, true},
+		{"text max response", Config{Servers: []string{"127.0.0.1:11211"}, MaxResponseSize: 1 << 20}, true},
+		{"negative max response", Config{Servers: []string{"127.0.0.1:11211"}, Protocol: bin.ProtoType, MaxResponseSize: -1}, false},
+	}
+	for _, tt := range tests {
+		err := tt.cfg.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	if err := c.ApplyConfig(Config{MaxIdleConns: 5, Timeout: time.Second}); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	addr, _ := c.selector.PickServer("")
+	var conns []*conn
+	for i := 0; i < 3; i++ {
+		cn, err := c.getConn(addr, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, cn)
+	}
+	for _, cn := range conns {
+		cn.release()
+	}
+	if n := len(c.freeconn[addr.String()]); n != 3 {
+		t.Fatalf("%d idle connections, want 3", n)
+	}
+
+	if err := c.ApplyConfig(Config{MaxIdleConns: -1}); err == nil {
+		t.Error("ApplyConfig accepted a negative MaxIdleConns")
+	}
+	if err := c.ApplyConfig(Config{MaxIdleConns: 1, Timeout: 2 * time.Second}); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if n := len(c.freeconn[addr.String()]); n != 1 {
+		t.Errorf("%d idle connections after lowering MaxIdleConns, want 1", n)
+	}
+	if got := c.netTimeout(); got != 2*time.Second {
+		t.Errorf("netTimeout() = %v, want 2s", got)
+	}
+
+	// Operations running concurrently with updates must keep working.
+	updates := make(chan Config)
+	done := make(chan struct{})
+	go func() {
+		c.WatchConfig(updates, nil)
+		close(done)
+	}()
+	for i := 0; i < 20; i++ {
+		updates <- Config{MaxIdleConns: i%3 + 1, Timeout: time.Second}
+		if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	close(updates)
+	<-done
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	for _, proto := range []string{text.ProtoType, bin.ProtoType} {
+		t.Run(proto, func(t *testing.T) {
+			s := memcachetest.NewServer(t)
+			defer s.Close()
+			c, err := NewClient([]string{s.Addr()}, WithProtocol(proto), WithMaxResponseSize(1024))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Set(&Item{Key: "big", Value: make([]byte, 2048)}); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			if err := c.Set(&Item{Key: "small", Value: []byte("v")}); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+
+			_, err = c.Get("big")
+			if tle, ok := err.(*ResponseTooLargeError); !ok || tle.Max != 1024 {
+				t.Fatalf("Get(big): got %v, want a *ResponseTooLargeError", err)
+			}
+			waitFor(t, "the connection to be closed", func() bool { return s.Conns() == 0 })
+			if _, err := c.Get("small"); err != nil {
+				t.Errorf("Get(small) after an oversized response: %v", err)
+			}
+		})
+	}
+}
+
+func TestMinConns(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithMinConns(2), WithClientName("eager"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Conns(); n != 2 {
+		t.Errorf("server has %d connections after NewClient, want 2", n)
+	}
+	if got := len(s.Labels()); got != 2 {
+		t.Errorf("%d connections were labeled, want 2", got)
+	}
+	st := c.PoolStats()[s.Addr()]
+	if st.IdleConns != 2 || st.Recycled != 0 {
+		t.Errorf("pool stats = %+v, want 2 idle and none recycled", st)
+	}
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Conns(); n != 2 {
+		t.Errorf("server has %d connections after Set, want the 2 eager ones", n)
+	}
+	c.Close()
+
+	down := memcachetest.NewServer(t)
+	addr := down.Addr()
+	down.Close()
+	if _, err := NewClient([]string{addr}, WithMinConns(1), WithTimeout(100*time.Millisecond)); err == nil {
+		t.Error("NewClient succeeded with no reachable server")
+	}
+	c, err = NewClient([]string{s.Addr(), addr}, WithMinConns(1), WithTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient failed with one of two servers reachable: %v", err)
+	}
+	c.Close()
+
+	if _, err := NewClient([]string{s.Addr()}, WithMinConns(3)); err == nil {
+		t.Error("NewClient accepted MinConns above the idle limit")
+	}
+}
@@ -0,0 +1,57 @@
+package memcache
+
+import "time"
+
+// An Override forces the handling of some keys, to neutralize poisoned or
+// misbehaving entries without a deploy.
+type Override struct {
+	// TTL, if positive, replaces the expiration of the items stored.
+	TTL time.Duration
+
+	// Miss makes retrievals of the keys miss without asking the server.
+	// Stores still go through, so that the values are fresh once the
+	// override is lifted.
+	Miss bool
+}
+
+// Overrides is a table of Overrides, by exact key and by key prefix. An
+// exact key wins over the prefixes, and the longest matching prefix over
+// the shorter ones.
+type Overrides struct {
+	Keys     map[string]Override
+	Prefixes map[string]Override
+}
+
+// SetOverrides replaces the override table of the client with o, taking
+// effect for the operations starting afterwards. The zero Overrides
+// lifts them all. The client keeps its own copy of the maps.
+func (c *Client) SetOverrides(o Overrides) {
+	cp := Overrides{
+		Keys:     make(map[string]Override, len(o.Keys)),
+		Prefixes: make(map[string]Override, len(o.Prefixes)),
+	}
+	for key, ov := range o.Keys {
+		cp.Keys[key] = ov
+	}
+	for prefix, ov := range o.Prefixes {
+		cp.Prefixes[prefix] = ov
+	}
+	prefixes := make([]string, 0, len(cp.Prefixes))
+	for prefix := range cp.Prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	c.cfgMu.Lock()
+	c.overrides, c.overridePrefixes = cp, newPrefixList(prefixes)
+	c.cfgMu.Unlock()
+}
+
+// override returns the override of key, or the zero Override.
+func (c *Client) override(key string) Override {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
+	if ov, ok := c.overrides.Keys[key]; ok {
+		return ov
+	}
+	prefix, _ := c.overridePrefixes.longest(key)
+	return c.overrides.Prefixes[prefix]
+}
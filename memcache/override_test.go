@@ -0,0 +1,46 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestOverrides(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+	for _, key := range []string{"bad", "ok", "feed:1"} {
+		if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.SetOverrides(Overrides{
+		Keys:     map[string]Override{"bad": {Miss: true}},
+		Prefixes: map[string]Override{"feed:": {TTL: 5 * time.Second}, "fe": {Miss: true}},
+	})
+	if _, err := c.Get("bad"); err != ErrCacheMiss {
+		t.Errorf("Get of a forced miss error = %v, want ErrCacheMiss", err)
+	}
+	m, err := c.GetMulti([]string{"bad", "ok", "feed:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["bad"]; ok || len(m) != 2 {
+		t.Errorf("GetMulti = %v, want all but the forced miss", m)
+	}
+	if it := c.itemToSend(&Item{Key: "feed:1", Expiration: 600}, "feed:1"); it.Expiration != 5 {
+		t.Errorf("expiration under a forced TTL = %d, want 5", it.Expiration)
+	}
+	if it := c.itemToSend(&Item{Key: "ok", Expiration: 600}, "ok"); it.Expiration != 600 {
+		t.Errorf("expiration without override = %d, want 600", it.Expiration)
+	}
+
+	c.SetOverrides(Overrides{})
+	if _, err := c.Get("bad"); err != nil {
+		t.Errorf("Get after lifting the overrides: %v", err)
+	}
+}
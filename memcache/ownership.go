@@ -0,0 +1,76 @@
+package memcache
+
+import "sync"
+
+// Buffers for borrowed values come in power-of-two size classes from
+// minBufferSize to maxBufferSize. Larger values are allocated for each
+// item and left to the garbage collector.
+const (
+	minBufferShift = 6
+	maxBufferShift = 20
+	maxBufferSize  = 1 << maxBufferShift
+)
+
+// bufferPool is the types.BufferPool of the clients built with
+// BorrowValues.
+type bufferPool struct {
+	classes [maxBufferShift - minBufferShift + 1]sync.Pool
+}
+
+// class returns the index of the smallest class holding n bytes, or -1 if
+// n is too large to be pooled.
+func bufferClass(n int) int {
+	for i := 0; i <= maxBufferShift-minBufferShift; i++ {
+		if n <= 1<<uint(i+minBufferShift) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *bufferPool) Get(n int) []byte {
+	i := bufferClass(n)
+	if i < 0 {
+		return make([]byte, n)
+	}
+	if b, ok := p.classes[i].Get().([]byte); ok {
+		return b[:n]
+	}
+	return make([]byte, n, 1<<uint(i+minBufferShift))
+}
+
+func (p *bufferPool) Put(b []byte) {
+	i := bufferClass(cap(b))
+	if i < 0 || cap(b) != 1<<uint(i+minBufferShift) {
+		return // not one of ours
+	}
+	p.classes[i].Put(b[:0])
+}
+
+// Release returns the values of items to the client for reuse. It only
+// has an effect on clients built with BorrowValues, whose retrieved items
+// borrow their Value from a buffer pool: once an item is released, its
+// Value is set to nil, and no copy of the slice may be used anymore.
+// Releasing items is optional; the values of the items that are not
+// released are garbage collected as usual.
+func (c *Client) Release(items ...*Item) {
+	if c.buffers == nil {
+		return
+	}
+	for _, it := range items {
+		if it != nil && it.Value != nil {
+			c.buffers.Put(it.Value)
+			it.Value = nil
+		}
+	}
+}
+
+// ownItem returns a copy of item to keep beyond the call that passed it,
+// with a copy of its value if the client was built with CopyOnSet.
+func (c *Client) ownItem(item *Item) *Item {
+	it := *item
+	if c.copyOnSet {
+		it.Value = append([]byte(nil), item.Value...)
+	}
+	return &it
+}
@@ -0,0 +1,89 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+	"github.com/skinass/gomemcache/memcache/proto/bin"
+)
+
+func TestCopyOnSet(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithCopyOnSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWriteBehind(c, 100, 1, Block)
+	buf := []byte("first")
+	for i := 0; i < 10; i++ {
+		if err := w.Set(&Item{Key: fmt.Sprint("k", i), Value: buf}); err != nil {
+			t.Fatal(err)
+		}
+		copy(buf, "reuse")
+	}
+	w.Close()
+
+	it, err := c.Get("k0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(it.Value) != "first" {
+		t.Errorf("Value = %q, want the value at the time of Set", it.Value)
+	}
+}
+
+func TestBorrowValues(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for _, proto := range []string{"text", bin.ProtoType} {
+		t.Run(proto, func(t *testing.T) {
+			c, err := NewClient([]string{s.Addr()}, WithProtocol(proto), WithBorrowedValues())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			for i := 0; i < 5; i++ {
+				want := fmt.Sprint("value ", i)
+				if err := c.Set(&Item{Key: "k", Value: []byte(want)}); err != nil {
+					t.Fatal(err)
+				}
+				it, err := c.Get("k")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(it.Value) != want {
+					t.Fatalf("Get = %q, want %q", it.Value, want)
+				}
+				c.Release(it)
+				if it.Value != nil {
+					t.Fatal("Release left the value in place")
+				}
+			}
+			m, err := c.GetMulti([]string{"k", "missing"})
+			if err != nil || len(m) != 1 {
+				t.Fatalf("GetMulti = %v, %v", m, err)
+			}
+			c.Release(m["k"], m["missing"])
+		})
+	}
+}
+
+func TestBufferPool(t *testing.T) {
+	var p bufferPool
+	for _, n := range []int{0, 1, 64, 65, 1000, maxBufferSize} {
+		b := p.Get(n)
+		if len(b) != n || cap(b)&(cap(b)-1) != 0 || cap(b) < 1<<minBufferShift {
+			t.Errorf("Get(%d): len %d cap %d, want len %[1]d and a power of two cap", n, len(b), cap(b))
+		}
+		p.Put(b)
+	}
+	if b := p.Get(maxBufferSize + 1); len(b) != maxBufferSize+1 {
+		t.Errorf("Get of a large value returned %d bytes", len(b))
+	}
+	p.Put(make([]byte, 100)) // not from the pool, dropped
+	if b := p.Get(100); cap(b) != 128 {
+		t.Errorf("Get(100) has cap %d, want 128", cap(b))
+	}
+}
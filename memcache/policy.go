@@ -0,0 +1,82 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrDenied is returned by the policies of DenyOps for the operations they
+// reject.
+var ErrDenied = errors.New("memcache: operation denied by policy")
+
+// A Policy is consulted before every operation with the name of the op, as
+// in OpEvent, and its key. It returns the key to send instead, which is
+// key itself unless the policy rewrites it, or an error rejecting the
+// operation. A rejected operation fails with that error without reaching
+// any server. Operations on the whole cache, such as flush_all, are checked
+// with an empty key, as are raw commands, under the "raw" op.
+//
+// Items returned by the client carry the caller's keys, not the rewritten
+// ones. A Policy must be safe for concurrent use.
+type Policy func(op, key string) (string, error)
+
+// DenyOps returns a Policy rejecting the given ops with ErrDenied and
+// allowing all others unchanged.
+func DenyOps(ops ...string) Policy {
+	deny := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		deny[op] = true
+	}
+	return func(op, key string) (string, error) {
+		if deny[op] {
+			return "", ErrDenied
+		}
+		return key, nil
+	}
+}
+
+// KeyFormatError is returned for the keys rejected by Config.ValidateKey.
+type KeyFormatError struct {
+	Key string
+	Err error // the validator's error
+}
+
+func (e *KeyFormatError) Error() string {
+	return fmt.Sprintf("memcache: key %q breaks the naming convention: %v", e.Key, e.Err)
+}
+
+// KeyPattern returns a key validator, for Config.ValidateKey, accepting
+// the keys matched by re.
+func KeyPattern(re *regexp.Regexp) func(key string) error {
+	return func(key string) error {
+		if !re.MatchString(key) {
+			return fmt.Errorf("does not match %s", re)
+		}
+		return nil
+	}
+}
+
+// prepareKey returns the key to send for op on key, as rewritten by the
+// policy and encoded, or the error the operation fails with. The key is
+// validated before the policy sees it.
+func (c *Client) prepareKey(op, key string) (string, error) {
+	if c.validateKey != nil && key != "" {
+		if err := c.validateKey(key); err != nil {
+			return "", &KeyFormatError{Key: key, Err: err}
+		}
+	}
+	if c.policy != nil {
+		var err error
+		if key, err = c.policy(op, key); err != nil {
+			return "", err
+		}
+	}
+	if key != "" {
+		key = c.keyEncoding.encode(key)
+	}
+	if !legalKey(key) {
+		return "", ErrMalformedKey
+	}
+	return key, nil
+}
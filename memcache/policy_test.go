@@ -0,0 +1,98 @@
+package memcache
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestPolicyDeny(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithPolicy(DenyOps("flush_all", "delete")), WithAllowFlush())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := c.FlushAll(ConfirmFlush); err != ErrDenied {
+		t.Errorf("FlushAll error = %v, want ErrDenied", err)
+	}
+	if err := c.DeleteAll(ConfirmFlush); err != ErrDenied {
+		t.Errorf("DeleteAll error = %v, want ErrDenied", err)
+	}
+	if err := c.Delete("k"); err != ErrDenied {
+		t.Errorf("Delete error = %v, want ErrDenied", err)
+	}
+	if err := c.DeleteMulti([]string{"k"}); err != ErrDenied {
+		t.Errorf("DeleteMulti error = %v, want ErrDenied", err)
+	}
+	if _, err := c.Get("k"); err != nil {
+		t.Errorf("Get after denied deletes: %v", err)
+	}
+}
+
+func TestPolicyRewrite(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	prefix := func(op, key string) (string, error) {
+		if key == "" {
+			return key, nil
+		}
+		return "app:" + key, nil
+	}
+	for name, plain := range protoClients(s) {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient([]string{s.Addr()}, WithProtocol(plain.ProtoType()), WithPolicy(prefix))
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := name + "-k"
+			if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := plain.Get("app:" + key); err != nil {
+				t.Errorf("rewritten key not stored: %v", err)
+			}
+			it, err := c.Get(key)
+			if err != nil || it.Key != key {
+				t.Errorf("Get = %+v, %v, want the item under the caller's key", it, err)
+			}
+			if err := c.SetMulti([]*Item{{Key: key + "2", Value: []byte("v2")}}); err != nil {
+				t.Fatal(err)
+			}
+			m, err := c.GetMulti([]string{key, key + "2"})
+			if err != nil || m[key] == nil || m[key+"2"] == nil || m[key].Key != key {
+				t.Errorf("GetMulti = %v, %v, want both items under the caller's keys", m, err)
+			}
+			if _, err := c.Increment(key, 1); err == nil || !strings.Contains(err.Error(), "non-numeric") {
+				t.Errorf("Increment error = %v, want the server's non-numeric error", err)
+			}
+		})
+	}
+}
+
+func TestKeyValidator(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithKeyValidator(KeyPattern(regexp.MustCompile(`^[a-z]+:[a-z]+:[0-9]+$`))), WithAllowFlush())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(&Item{Key: "users:profile:42", Value: []byte("v")}); err != nil {
+		t.Errorf("Set with a conforming key: %v", err)
+	}
+	err = c.Set(&Item{Key: "profile-42", Value: []byte("v")})
+	if kerr, ok := err.(*KeyFormatError); !ok || kerr.Key != "profile-42" {
+		t.Errorf("Set with a nonconforming key error = %v, want a *KeyFormatError", err)
+	}
+	if _, err := c.GetMulti([]string{"users:profile:42", "oops"}); err == nil {
+		t.Error("GetMulti with a nonconforming key succeeded")
+	}
+	if err := c.FlushAll(ConfirmFlush); err != nil {
+		t.Errorf("FlushAll: %v", err)
+	}
+}
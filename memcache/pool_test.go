@@ -0,0 +1,53 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestPools(t *testing.T) {
+	sessions, counters := memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer sessions.Close()
+	defer counters.Close()
+	c, err := NewClient(nil,
+		WithPool("sessions", Config{Servers: []string{sessions.Addr()}}),
+		WithPool("counters", Config{Servers: []string{counters.Addr()}, Protocol: "binary"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Pool("sessions").Set(&Item{Key: "s", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	direct := New(sessions.Addr())
+	if _, err := direct.Get("s"); err != nil {
+		t.Errorf("item of the sessions pool is not on its server: %v", err)
+	}
+	direct.Close()
+	if got := c.Pool("counters").ProtoType(); got != "binary" {
+		t.Errorf("counters pool protocol = %q, want binary", got)
+	}
+	if c.Pool("render") != nil {
+		t.Error("Pool returned a client for an unknown pool")
+	}
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); !errors.Is(err, ErrNoServers) {
+		t.Errorf("Set on a client with only pools error = %v, want ErrNoServers", err)
+	}
+
+	c.Close()
+	waitFor(t, "the pools to close their connections", func() bool { return sessions.Conns() == 0 })
+}
+
+func TestPoolsValidate(t *testing.T) {
+	_, err := NewClient(nil, WithPool("bad", Config{Servers: []string{"127.0.0.1:1"}, Timeout: -1}))
+	if err == nil {
+		t.Error("NewClient accepted a pool with a negative timeout")
+	}
+	nested := Config{Servers: []string{"127.0.0.1:1"}, Pools: map[string]Config{"inner": {Servers: []string{"127.0.0.1:2"}}}}
+	if _, err := NewClient(nil, WithPool("outer", nested)); err == nil {
+		t.Error("NewClient accepted nested pools")
+	}
+}
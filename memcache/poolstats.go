@@ -0,0 +1,83 @@
+package memcache
+
+import (
+	"net"
+	"time"
+)
+
+// PoolStats describes the connection pool of a server, in the manner of
+// database/sql.DBStats.
+type PoolStats struct {
+	OpenConns  int // open connections, idle, in use or being opened
+	IdleConns  int // connections waiting in the idle pool
+	InUseConns int // connections running an operation
+
+	// WaitCount and WaitDuration are the number of times an operation
+	// waited for a connection under Config.MaxOpenConns and the total
+	// time it waited.
+	WaitCount    int64
+	WaitDuration time.Duration
+
+	DialFailures int64 // connections that could not be established
+	Recycled     int64 // connections returned to the idle pool after use
+
+	// StaleConns counts the idle connections found broken by the check of
+	// Config.IdleCheck and discarded.
+	StaleConns int64
+
+	// Timeouts counts the operations that timed out, by phase.
+	Timeouts TimeoutCounts
+}
+
+// poolCounters keeps the counts behind the PoolStats of a server. It is
+// guarded by Client.lk.
+type poolCounters struct {
+	open         int
+	waitCount    int64
+	waitDuration time.Duration
+	dialFailures int64
+	recycled     int64
+	staleConns   int64
+	timeouts     TimeoutCounts
+
+	// waiters are the operations waiting for a connection, first come
+	// first served.
+	waiters []chan *conn
+}
+
+// pool returns the counters of the pool of addr. It must be called with
+// c.lk held.
+func (c *Client) pool(addr net.Addr) *poolCounters {
+	if c.pools == nil {
+		c.pools = make(map[string]*poolCounters)
+	}
+	p := c.pools[addr.String()]
+	if p == nil {
+		p = new(poolCounters)
+		c.pools[addr.String()] = p
+	}
+	return p
+}
+
+// PoolStats returns the statistics of the connection pool of each server
+// the client has connected to, or tried to, keyed by server address.
+func (c *Client) PoolStats() map[string]PoolStats {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	stats := make(map[string]PoolStats, len(c.pools))
+	for addr, p := range c.pools {
+		idle := len(c.freeconn[addr])
+		stats[addr] = PoolStats{
+			OpenConns:    p.open,
+			IdleConns:    idle,
+			InUseConns:   p.open - idle,
+			WaitCount:    p.waitCount,
+			WaitDuration: p.waitDuration,
+			DialFailures: p.dialFailures,
+			Recycled:     p.recycled,
+			StaleConns:   p.staleConns,
+			Timeouts:     p.timeouts,
+		}
+	}
+	return stats
+}
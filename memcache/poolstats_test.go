@@ -0,0 +1,55 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestPoolStats(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	addr, err := c.selector.PickServer("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, err := c.getConn(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn2, err := c.getConn(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PoolStats{OpenConns: 2, InUseConns: 2, Recycled: 1}
+	if got := c.PoolStats()[s.Addr()]; got != want {
+		t.Errorf("PoolStats() with two connections in use = %+v, want %+v", got, want)
+	}
+
+	cn.release()
+	cn2.discard()
+	want = PoolStats{OpenConns: 1, IdleConns: 1, Recycled: 2}
+	if got := c.PoolStats()[s.Addr()]; got != want {
+		t.Errorf("PoolStats() after release = %+v, want %+v", got, want)
+	}
+}
+
+func TestPoolStatsDialFailures(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	addr := s.Addr()
+	s.Close()
+	c := New(addr)
+
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err == nil {
+		t.Fatal("Set to a closed server succeeded")
+	}
+	if got, want := c.PoolStats()[addr], (PoolStats{DialFailures: 1}); got != want {
+		t.Errorf("PoolStats() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// reserveConn reserves one of the MaxOpenConns connections to addr for a
+// new connection, waiting in line while they are all open. It returns the
+// connection a waiter was handed, or nil if it may dial a new one. The
+// wait lasts until ctx is done, and at most the client's timeout past
+// start.
+func (c *Client) reserveConn(ctx context.Context, addr net.Addr, start time.Time) (*conn, error) {
+	c.lk.Lock()
+	p := c.pool(addr)
+	if c.maxOpenConns <= 0 || p.open < c.maxOpenConns {
+		p.open++
+		c.lk.Unlock()
+		return nil, nil
+	}
+	w := make(chan *conn, 1) // handed a connection, or nil for a slot
+	p.waiters = append(p.waiters, w)
+	c.lk.Unlock()
+
+	timer := time.NewTimer(time.Until(start.Add(c.netTimeout())))
+	defer timer.Stop()
+	var err error
+	select {
+	case cn := <-w:
+		c.lk.Lock()
+		p.noteWait(start)
+		c.lk.Unlock()
+		return cn, nil
+	case <-timer.C:
+		err = context.DeadlineExceeded
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c.lk.Lock()
+	p.noteWait(start)
+	served := !p.removeWaiter(w)
+	c.lk.Unlock()
+	if served {
+		// Handed something just as the wait ended.
+		return <-w, nil
+	}
+	return nil, c.timeoutError(addr, PhasePoolWait, err)
+}
+
+// handOff hands cn, released to the pool of addr, to the first waiter
+// for a connection to addr, if any. It must be called with c.lk held.
+func (c *Client) handOff(addr net.Addr, cn *conn) bool {
+	p := c.pool(addr)
+	if len(p.waiters) == 0 {
+		return false
+	}
+	w := p.waiters[0]
+	p.waiters = p.waiters[1:]
+	p.recycled++
+	w <- cn
+	return true
+}
+
+// releaseSlot gives up a connection to addr that was closed or never
+// established, passing its slot to the first waiter if any. It must be
+// called with c.lk held.
+func (c *Client) releaseSlot(addr net.Addr) {
+	p := c.pool(addr)
+	if len(p.waiters) == 0 {
+		p.open--
+		return
+	}
+	w := p.waiters[0]
+	p.waiters = p.waiters[1:]
+	w <- nil
+}
+
+func (p *poolCounters) noteWait(start time.Time) {
+	p.waitCount++
+	p.waitDuration += time.Since(start)
+}
+
+// removeWaiter takes w out of the line, reporting whether it was still
+// waiting.
+func (p *poolCounters) removeWaiter(w chan *conn) bool {
+	for i, x := range p.waiters {
+		if x == w {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
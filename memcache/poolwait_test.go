@@ -0,0 +1,103 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+// waiters returns the number of operations waiting for a connection to
+// addr.
+func waiters(c *Client, addr string) int {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if p := c.pools[addr]; p != nil {
+		return len(p.waiters)
+	}
+	return 0
+}
+
+func TestMaxOpenConnsFIFO(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithMaxOpenConns(1), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	addr, err := c.selector.PickServer("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, err := c.getConn(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 2)
+	for i, name := range []string{"first", "second"} {
+		name := name
+		go func() {
+			cn, err := c.getConn(addr, false)
+			if err != nil {
+				order <- err.Error()
+				return
+			}
+			order <- name
+			cn.release()
+		}()
+		waitFor(t, "the waiter to queue", func() bool { return waiters(c, s.Addr()) == i+1 })
+	}
+	held.release()
+	if a, b := <-order, <-order; a != "first" || b != "second" {
+		t.Errorf("waiters served in order %s, %s, want first, second", a, b)
+	}
+	st := c.PoolStats()[s.Addr()]
+	if st.OpenConns != 1 || st.WaitCount != 2 || st.WaitDuration <= 0 {
+		t.Errorf("PoolStats() = %+v, want one open connection and two waits", st)
+	}
+}
+
+func TestMaxOpenConnsTimeout(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithMaxOpenConns(1), WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	addr, err := c.selector.PickServer("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, err := c.getConn(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.release()
+
+	_, err = c.Get("k")
+	var te *TimeoutError
+	if !errors.As(err, &te) || te.Phase != PhasePoolWait {
+		t.Errorf("Get on a saturated pool error = %v, want a pool wait *TimeoutError", err)
+	}
+	if got := c.PoolStats()[s.Addr()].Timeouts.PoolWait; got != 1 {
+		t.Errorf("pool wait timeouts = %d, want 1", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.getConnContext(ctx, addr, false); err != context.Canceled {
+		t.Errorf("getConnContext with a canceled context error = %v, want context.Canceled", err)
+	}
+	if n := waiters(c, s.Addr()); n != 0 {
+		t.Errorf("%d waiters left in line", n)
+	}
+
+	if _, err := NewClient([]string{s.Addr()}, WithMaxOpenConns(1), WithMinConns(2), WithMaxIdleConns(2)); err == nil {
+		t.Error("NewClient accepted MinConns above MaxOpenConns")
+	}
+}
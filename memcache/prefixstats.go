@@ -0,0 +1,122 @@
+package memcache
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrefixStats holds the counters PrefixStatsCollector keeps for one key
+// prefix.
+type PrefixStats struct {
+	// Ops counts all operations; Gets counts the retrievals among them,
+	// split into Hits and Misses.
+	Ops, Gets, Hits, Misses uint64
+
+	// Errors counts operations that failed for reasons other than a
+	// miss or an unmet condition such as ErrNotStored.
+	Errors uint64
+
+	// TotalLatency and MaxLatency summarize the durations of all Ops.
+	TotalLatency, MaxLatency time.Duration
+}
+
+// HitRatio returns Hits/Gets, or zero if there were no retrievals.
+func (s PrefixStats) HitRatio() float64 {
+	if s.Gets == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Gets)
+}
+
+// AvgLatency returns the mean duration of an operation.
+func (s PrefixStats) AvgLatency() time.Duration {
+	if s.Ops == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Ops)
+}
+
+// PrefixStatsCollector attributes traffic to configured key prefixes, so
+// features sharing a cluster can see their own hit rate and latency.
+// Register its Observe method with Client.AddHook.
+//
+// Each key is counted under the longest configured prefix it starts with;
+// keys matching none are counted under the empty prefix.
+type PrefixStatsCollector struct {
+	prefixes []string // longest first
+
+	mu    sync.Mutex
+	stats map[string]*PrefixStats
+}
+
+// NewPrefixStatsCollector returns a collector for the given prefixes.
+func NewPrefixStatsCollector(prefixes ...string) *PrefixStatsCollector {
+	p := &PrefixStatsCollector{
+		prefixes: append([]string(nil), prefixes...),
+		stats:    make(map[string]*PrefixStats),
+	}
+	sort.Slice(p.prefixes, func(i, j int) bool {
+		return len(p.prefixes[i]) > len(p.prefixes[j])
+	})
+	return p
+}
+
+// Observe counts ev. It is a Hook.
+func (p *PrefixStatsCollector) Observe(ev OpEvent) {
+	prefix := p.match(ev.Key)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats[prefix]
+	if s == nil {
+		s = new(PrefixStats)
+		p.stats[prefix] = s
+	}
+	s.Ops++
+	s.TotalLatency += ev.Duration
+	if ev.Duration > s.MaxLatency {
+		s.MaxLatency = ev.Duration
+	}
+	if ev.Op == "get" {
+		s.Gets++
+		switch ev.Err {
+		case nil:
+			s.Hits++
+		case ErrCacheMiss:
+			s.Misses++
+		}
+	}
+	if ev.Err != nil && !resumableError(ev.Err) {
+		s.Errors++
+	}
+}
+
+// Snapshot returns a copy of the counters, keyed by prefix. Prefixes that
+// saw no traffic are absent.
+func (p *PrefixStatsCollector) Snapshot() map[string]PrefixStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m := make(map[string]PrefixStats, len(p.stats))
+	for prefix, s := range p.stats {
+		m[prefix] = *s
+	}
+	return m
+}
+
+// Reset zeroes all counters.
+func (p *PrefixStatsCollector) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats = make(map[string]*PrefixStats)
+}
+
+func (p *PrefixStatsCollector) match(key string) string {
+	for _, prefix := range p.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
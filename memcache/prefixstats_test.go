@@ -0,0 +1,46 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestPrefixStats(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	ps := NewPrefixStatsCollector("user:", "user:session:", "feed:")
+	c.AddHook(ps.Observe)
+
+	c.Set(&Item{Key: "user:1", Value: []byte("a")})
+	c.Get("user:1")
+	c.Get("user:2")
+	c.Get("user:session:9")
+	c.GetMulti([]string{"feed:1", "feed:2", "user:1"})
+	c.Delete("other")
+
+	got := ps.Snapshot()
+	want := map[string]struct{ ops, gets, hits, misses uint64 }{
+		"user:":         {4, 3, 2, 1},
+		"user:session:": {1, 1, 0, 1},
+		"feed:":         {2, 2, 0, 2},
+		"":              {1, 0, 0, 0},
+	}
+	if len(got) != len(want) {
+		t.Errorf("Snapshot has %d prefixes, want %d: %+v", len(got), len(want), got)
+	}
+	for prefix, w := range want {
+		g := got[prefix]
+		if g.Ops != w.ops || g.Gets != w.gets || g.Hits != w.hits || g.Misses != w.misses {
+			t.Errorf("stats[%q] = %+v, want ops=%d gets=%d hits=%d misses=%d",
+				prefix, g, w.ops, w.gets, w.hits, w.misses)
+		}
+		if g.Errors != 0 {
+			t.Errorf("stats[%q].Errors = %d, want 0", prefix, g.Errors)
+		}
+	}
+	if r := got["user:"].HitRatio(); r < 0.66 || r > 0.67 {
+		t.Errorf("user: hit ratio = %v, want 2/3", r)
+	}
+}
@@ -0,0 +1,78 @@
+package memcache
+
+import (
+	"bufio"
+	"net"
+	"time"
+)
+
+// PressureEvent describes a write a server refused for lack of memory: it
+// answered ErrOutOfMemory, or ErrValueNotStored to a set, which can only
+// fail that way.
+type PressureEvent struct {
+	Op     string // as in OpEvent
+	Key    string
+	Server net.Addr
+	Size   int   // the size of the value
+	Err    error // the server's answer
+
+	// Retried is whether the write is retried with the expiration of
+	// Config.PressureRetryTTL.
+	Retried bool
+}
+
+// A PressureHook is called for every write refused for lack of memory,
+// so that applications can degrade gracefully, for example by caching
+// less, rather than log opaque errors. Like Hooks, PressureHooks run
+// synchronously and must be fast and safe for concurrent use.
+type PressureHook func(PressureEvent)
+
+// AddPressureHook registers h to observe the writes refused for lack of
+// memory.
+func (c *Client) AddPressureHook(h PressureHook) {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+	hooks := make([]PressureHook, len(c.pressureHooks), len(c.pressureHooks)+1)
+	copy(hooks, c.pressureHooks)
+	c.pressureHooks = append(hooks, h)
+}
+
+// isMemoryPressure reports whether op failed with err for lack of memory.
+func isMemoryPressure(op string, err error) bool {
+	return err == ErrOutOfMemory || op == "set" && err == ErrValueNotStored
+}
+
+// onPressure reports that the write op of item, the caller's key being
+// key, failed at addr with err for lack of memory, and retries it with
+// Config.PressureRetryTTL if that shortens its expiration. It returns
+// the result of the retry, or err.
+func (c *Client) onPressure(op, key string, addr net.Addr, item *Item, err error, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
+	retry := c.pressureRetryTTL > 0 && expiresAfter(item.Expiration, c.pressureRetryTTL, c.now())
+	c.cfgMu.RLock()
+	hooks := c.pressureHooks
+	c.cfgMu.RUnlock()
+	ev := PressureEvent{Op: op, Key: key, Server: addr, Size: len(item.Value), Err: err, Retried: retry}
+	for _, h := range hooks {
+		h(ev)
+	}
+	if !retry {
+		return err
+	}
+	it := *item
+	it.Expiration = Expiration(c.pressureRetryTTL, c.now())
+	return c.onItemAt(addr, &it, fn)
+}
+
+// expiresAfter reports whether the expiration exp, as sent to memcached,
+// is further than ttl from now.
+func expiresAfter(exp int32, ttl time.Duration, now time.Time) bool {
+	switch {
+	case exp == 0:
+		return true
+	case exp < 0:
+		return false
+	case time.Duration(exp)*time.Second <= maxRelativeExpiration:
+		return time.Duration(exp)*time.Second > ttl
+	}
+	return time.Unix(int64(exp), 0).Sub(now) > ttl
+}
@@ -0,0 +1,76 @@
+package memcache
+
+import (
+	"bufio"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestMemoryPressure(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	s.SetMemoryLimit(10)
+	for name, c := range protoClients(s) {
+		t.Run(name, func(t *testing.T) {
+			defer c.Close()
+			var events []PressureEvent
+			c.AddPressureHook(func(ev PressureEvent) { events = append(events, ev) })
+
+			err := c.Set(&Item{Key: "big", Value: make([]byte, 20)})
+			if err != ErrOutOfMemory {
+				t.Errorf("Set over the memory limit error = %v, want ErrOutOfMemory", err)
+			}
+			if len(events) != 1 || events[0].Key != "big" || events[0].Size != 20 || events[0].Retried {
+				t.Errorf("pressure events = %+v, want one for big", events)
+			}
+			if events[0].Server.String() != s.Addr() {
+				t.Errorf("event server = %v, want %s", events[0].Server, s.Addr())
+			}
+			if err := c.Set(&Item{Key: "small", Value: []byte("v")}); err != nil {
+				t.Errorf("Set within the memory limit: %v", err)
+			}
+		})
+	}
+}
+
+func TestPressureRetryTTL(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithPressureRetryTTL(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var mu sync.Mutex
+	var retried bool
+	c.AddPressureHook(func(ev PressureEvent) {
+		mu.Lock()
+		retried = ev.Retried
+		mu.Unlock()
+	})
+
+	// A server that runs out of memory only for the first attempt.
+	var exps []int32
+	set := func(c *Client, rw *bufio.ReadWriter, it *Item) error {
+		exps = append(exps, it.Expiration)
+		if len(exps) == 1 {
+			return ErrOutOfMemory
+		}
+		return c.set(rw, it)
+	}
+	if err := c.onItem("set", &Item{Key: "k", Value: []byte("v"), Expiration: 3600}, nil, set); err != nil {
+		t.Fatalf("retried write: %v", err)
+	}
+	if len(exps) != 2 || exps[1] != 60 || !retried {
+		t.Errorf("expirations sent = %v, retried %v; want a retry with 60", exps, retried)
+	}
+
+	exps = nil
+	err = c.onItem("set", &Item{Key: "k", Value: []byte("v"), Expiration: 30}, nil, set)
+	if err != ErrOutOfMemory || len(exps) != 1 {
+		t.Errorf("write with a TTL already shorter was retried: %v, expirations %v", err, exps)
+	}
+}
@@ -0,0 +1,132 @@
+package memcache
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Profile configures how the client handles the keys starting with a
+// prefix, so that one client can serve several caching profiles.
+type Profile struct {
+	// TTL is the expiration of the items stored without one, and the ttl
+	// of Memoize calls passing zero.
+	TTL time.Duration
+
+	// Codec, if set, replaces the client's Codec for these keys.
+	Codec Codec
+
+	// Selector, if set, picks the servers of these keys instead of the
+	// client's selector, keeping them in a pool of their own.
+	Selector ServerSelector
+
+	// Replicas is the number of servers each item is stored on. Stores
+	// and deletes succeeding on the key's server are repeated, best
+	// effort, on the servers following it in the selector, where a Get
+	// with FallbackOnError looks for them. SetMulti and DeleteMulti are
+	// replicated the same way; Touch, Increment and Decrement only reach
+	// the key's server.
+	Replicas int
+
+	// Quota limits the rate of the writes of these keys. The usage of
+	// the keys of every profile is reported by Client.QuotaUsage.
+	Quota Quota
+}
+
+// profile returns the profile of the longest prefix of key in the
+// client's Profiles, or the zero Profile.
+func (c *Client) profile(key string) Profile {
+	prefix, _ := c.profilePrefixes.longest(key)
+	return c.profiles[prefix]
+}
+
+// prefixList lists key prefixes longest first, so that the first one a
+// key starts with is the longest.
+type prefixList []string
+
+// newPrefixList sorts prefixes into a prefixList.
+func newPrefixList(prefixes []string) prefixList {
+	l := prefixList(prefixes)
+	sort.Slice(l, func(i, j int) bool {
+		if len(l[i]) != len(l[j]) {
+			return len(l[i]) > len(l[j])
+		}
+		return l[i] < l[j]
+	})
+	return l
+}
+
+// longest returns the longest prefix of l that key starts with, and
+// false if there is none.
+func (l prefixList) longest(key string) (string, bool) {
+	for _, prefix := range l {
+		if strings.HasPrefix(key, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// selectorFor returns the selector picking the servers of key.
+func (c *Client) selectorFor(key string) ServerSelector {
+	if ss := c.profile(key).Selector; ss != nil {
+		return ss
+	}
+	return c.selector
+}
+
+// replicas returns the servers holding the replicas of key, whose own
+// server is addr.
+func (c *Client) replicas(key string, addr net.Addr) []net.Addr {
+	p := c.profile(key)
+	if p.Replicas <= 1 {
+		return nil
+	}
+	ss := c.selectorFor(key)
+	var addrs []net.Addr
+	for next, ok := nextServer(ss, addr); ok && len(addrs) < p.Replicas-1; next, ok = nextServer(ss, next) {
+		if next.String() == addr.String() {
+			break
+		}
+		addrs = append(addrs, next)
+	}
+	return addrs
+}
+
+// eachServer calls fn for each server of the client's selector and of the
+// selectors of its profiles, once per address, stopping at the first
+// error.
+func (c *Client) eachServer(fn func(net.Addr) error) error {
+	seen := make(map[string]bool)
+	visit := func(addr net.Addr) error {
+		if seen[addr.String()] {
+			return nil
+		}
+		seen[addr.String()] = true
+		return fn(addr)
+	}
+	if err := c.selector.Each(visit); err != nil {
+		return err
+	}
+	for _, p := range c.profiles {
+		if p.Selector == nil {
+			continue
+		}
+		if err := p.Selector.Each(visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// servers returns the servers eachServer visits, for the callers that
+// must not hold the selectors' locks while they use them.
+func (c *Client) servers() []net.Addr {
+	var addrs []net.Addr
+	c.eachServer(func(addr net.Addr) error {
+		addrs = append(addrs, addr)
+		return nil
+	})
+	return addrs
+}
@@ -0,0 +1,132 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestProfileTTL(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	clock := memcachetest.NewFakeClock(time.Unix(1e9, 0))
+	s.SetClock(clock)
+	c, err := NewClient([]string{s.Addr()}, WithClock(clock), WithProfile("session:", Profile{TTL: time.Minute}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"session:1", "user:1"} {
+		if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.SetMulti([]*Item{{Key: "session:2", Value: []byte("v")}}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Minute)
+	for key, want := range map[string]error{"session:1": ErrCacheMiss, "session:2": ErrCacheMiss, "user:1": nil} {
+		if _, err := c.Get(key); err != want {
+			t.Errorf("Get(%q) after the profile TTL error = %v, want %v", key, err, want)
+		}
+	}
+}
+
+func TestProfileSelector(t *testing.T) {
+	main, other := memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer main.Close()
+	defer other.Close()
+	ss := new(ServerList)
+	if err := ss.SetServers(other.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient([]string{main.Addr()}, WithProfile("big:", Profile{Selector: ss}), WithAllowFlush())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(&Item{Key: "big:1", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(other.Addr()).Get("big:1"); err != nil {
+		t.Errorf("item of the profile is not on its server: %v", err)
+	}
+	if _, err := New(main.Addr()).Get("big:1"); err != ErrCacheMiss {
+		t.Errorf("item of the profile found on the main server: %v", err)
+	}
+
+	if err := c.FlushAll(ConfirmFlush); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("big:1"); err != ErrCacheMiss {
+		t.Errorf("Get after FlushAll error = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestProfileReplicas(t *testing.T) {
+	var servers []*memcachetest.Server
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		s := memcachetest.NewServer(t)
+		defer s.Close()
+		servers = append(servers, s)
+		addrs = append(addrs, s.Addr())
+	}
+	c, err := NewClient(addrs, WithProfile("hot:", Profile{Replicas: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	holders := func(key string) int {
+		n := 0
+		for _, addr := range addrs {
+			if _, err := New(addr).Get(key); err == nil {
+				n++
+			}
+		}
+		return n
+	}
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprint("hot:", i)
+		if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+		if n := holders(key); n != 2 {
+			t.Errorf("%q stored on %d servers, want 2", key, n)
+		}
+		if err := c.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+		if n := holders(key); n != 0 {
+			t.Errorf("%q still on %d servers after Delete", key, n)
+		}
+	}
+	var keys []string
+	var items []*Item
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprint("hot:multi", i)
+		keys = append(keys, key)
+		items = append(items, &Item{Key: key, Value: []byte("v")})
+	}
+	if err := c.SetMulti(items); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if n := holders(key); n != 2 {
+			t.Errorf("%q stored on %d servers by SetMulti, want 2", key, n)
+		}
+	}
+	if err := c.DeleteMulti(keys); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if n := holders(key); n != 0 {
+			t.Errorf("%q still on %d servers after DeleteMulti", key, n)
+		}
+	}
+	if err := c.Set(&Item{Key: "cold", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if n := holders("cold"); n != 1 {
+		t.Errorf("key outside the profile stored on %d servers, want 1", n)
+	}
+}
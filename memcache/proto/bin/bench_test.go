@@ -0,0 +1,95 @@
+package bin
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+var benchSizes = []struct {
+	name string
+	size int
+}{{"100B", 100}, {"1MB", 1 << 20}}
+
+func BenchmarkWrite(b *testing.B) {
+	for _, bs := range benchSizes {
+		size := bs.size
+		b.Run(bs.name, func(b *testing.B) {
+			w := bufio.NewWriter(ioutil.Discard)
+			m := &msg{
+				header:  header{Op: opSet},
+				iextras: []interface{}{uint32(0), uint32(0)},
+				key:     "key",
+				val:     make([]byte, size),
+			}
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := write(w, m); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	for _, bs := range benchSizes {
+		size := bs.size
+		b.Run(bs.name, func(b *testing.B) {
+			resp := response(opGetK, 0, []byte{0, 0, 0, 7}, "key", make([]byte, size))
+			rd := bytes.NewReader(resp)
+			br := bufio.NewReader(rd)
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rd.Reset(resp)
+				br.Reset(rd)
+				var flags uint32
+				if err := DefaultBinCommander.read(br, &msg{oextras: []interface{}{&flags}}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestReadAllocs guards the allocations of decoding a small get response,
+// so that regressions show up in go test rather than only in benchmarks.
+func TestReadAllocs(t *testing.T) {
+	resp := response(opGetK, 0, []byte{0, 0, 0, 7}, "key", []byte("value"))
+	rd := bytes.NewReader(resp)
+	br := bufio.NewReader(rd)
+	m := &msg{}
+	var flags uint32
+	allocs := testing.AllocsPerRun(100, func() {
+		rd.Reset(resp)
+		br.Reset(rd)
+		*m = msg{oextras: []interface{}{&flags}}
+		if err := DefaultBinCommander.read(br, m); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if max := 5.0; allocs > max {
+		t.Errorf("read allocated %v times, want at most %v", allocs, max)
+	}
+}
+
+// TestWriteAllocs guards that encoding a request doesn't allocate.
+func TestWriteAllocs(t *testing.T) {
+	w := bufio.NewWriter(ioutil.Discard)
+	m := &msg{
+		header:  header{Op: opIncrement},
+		iextras: []interface{}{uint64(1), uint64(0), uint32(0)},
+		key:     "key",
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := write(w, m); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("write allocated %v times, want none", allocs)
+	}
+}
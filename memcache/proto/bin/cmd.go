@@ -2,11 +2,12 @@ package bin
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"strings"
+	"sync"
 
 	"github.com/skinass/gomemcache/memcache/types"
 )
@@ -15,7 +16,27 @@ const ProtoType = "binary"
 
 var DefaultBinCommander = &cmdRunner{}
 
-type cmdRunner struct{}
+// NewCommander returns a binary protocol commander failing responses
+// whose body is larger than maxResponseSize bytes with a
+// *types.ResponseTooLargeError. Zero means no limit, as with
+// DefaultBinCommander.
+func NewCommander(maxResponseSize int64) *cmdRunner {
+	return &cmdRunner{maxResponseSize: maxResponseSize}
+}
+
+type cmdRunner struct {
+	maxResponseSize int64
+	buffers         types.BufferPool
+}
+
+// WithBufferPool returns a copy of r reading the values of retrieved
+// items into buffers from pool, which the caller returns to it once done
+// with the items.
+func (r *cmdRunner) WithBufferPool(pool types.BufferPool) *cmdRunner {
+	cp := *r
+	cp.buffers = pool
+	return &cp
+}
 
 func (r *cmdRunner) ProtoType() string {
 	return ProtoType
@@ -25,22 +46,27 @@ func (r *cmdRunner) IsAuthSupported() bool {
 	return true
 }
 
+// Auth authenticates with SASL PLAIN. The request for the server's
+// mechanisms and the PLAIN attempt are pipelined, so that authentication
+// takes a single round trip.
 func (r *cmdRunner) Auth(rw *bufio.ReadWriter, username, password string) error {
-	s, err := r.authList(rw)
-	if err != nil {
-		return err
-	}
-
-	switch {
-	case strings.Index(s, "PLAIN") != -1:
-		return r.authPlain(rw, username, password)
-	}
-
-	return fmt.Errorf("memcache: unknown auth types %q", s)
+	_, err := r.AuthMechs(rw, "", username, password)
+	return err
 }
 
-func (r *cmdRunner) authPlain(rw *bufio.ReadWriter, username, password string) error {
-	m := &msg{
+// AuthMechs is like Auth, but doesn't ask for the server's mechanisms if
+// mechs, as returned by a previous call for the same server, is not
+// empty. It returns the server's mechanisms.
+func (r *cmdRunner) AuthMechs(rw *bufio.ReadWriter, mechs, username, password string) (string, error) {
+	if mechs != "" && !strings.Contains(mechs, "PLAIN") {
+		return mechs, fmt.Errorf("memcache: unknown auth types %q", mechs)
+	}
+	list := &msg{
+		header: header{
+			Op: opAuthList,
+		},
+	}
+	plain := &msg{
 		header: header{
 			Op: opAuthStart,
 		},
@@ -49,50 +75,100 @@ func (r *cmdRunner) authPlain(rw *bufio.ReadWriter, username, password string) e
 		val: []byte(fmt.Sprintf("\x00%s\x00%s", username, password)),
 	}
 
-	return sendRecv(rw, m)
-}
-
-func (r *cmdRunner) authList(rw *bufio.ReadWriter) (string, error) {
-	m := &msg{
-		header: header{
-			Op: opAuthList,
-		},
+	if mechs == "" {
+		if err := write(rw.Writer, list); err != nil {
+			return "", err
+		}
 	}
-
-	err := sendRecv(rw, m)
-	return string(m.val), err
+	if err := send(rw, plain); err != nil {
+		return "", err
+	}
+	if mechs == "" {
+		if err := r.recv(rw.Reader, list); err != nil {
+			return "", err
+		}
+		mechs = string(list.val)
+	}
+	if err := r.recv(rw.Reader, plain); err != nil {
+		if !strings.Contains(mechs, "PLAIN") {
+			return mechs, fmt.Errorf("memcache: unknown auth types %q", mechs)
+		}
+		return mechs, err
+	}
+	return mechs, nil
 }
 
+// Get fetches keys with getk, or with quiet getkq requests pipelined up to
+// a noop when there are several. Either way the items are keyed by the
+// keys the responses carry, not by the order of the requests, and a
+// response whose key is not the one requested is an error.
 func (r *cmdRunner) Get(rw *bufio.ReadWriter, keys []string, cb func(*types.Item)) error {
+	if len(keys) == 1 {
+		err := r.getOne(rw, keys[0], cb)
+		if err == types.ErrCacheMiss {
+			return nil
+		}
+		return err
+	}
+	m := &msg{}
+	for i, key := range keys {
+		*m = msg{
+			header: header{
+				Op:     opGetKQ,
+				Opaque: uint32(i),
+			},
+			key: key,
+		}
+		if err := write(rw.Writer, m); err != nil {
+			return err
+		}
+	}
 	var err error
-	for _, key := range keys {
-		if eg := r.getOne(rw, key, cb); eg != nil && eg != types.ErrCacheMiss {
+	errq := r.recvQuiet(rw, len(keys), func(i int, m *msg) {
+		if eg := getItem(m, keys[i], cb); eg != nil && eg != types.ErrCacheMiss {
 			err = eg
 		}
+	})
+	if errq != nil {
+		return errq
 	}
-
 	return err
 }
 
+// getOne fetches key with a getk request.
 func (r *cmdRunner) getOne(rw *bufio.ReadWriter, key string, cb func(*types.Item)) error {
-	var flags uint32
 	m := &msg{
 		header: header{
-			Op:  opGet,
-			CAS: uint64(0),
+			Op: opGetK,
 		},
-		oextras: []interface{}{&flags},
-		key:     key,
+		key: key,
 	}
-	err := sendRecv(rw, m)
-	if err != nil {
+	if err := send(rw, m); err != nil {
+		return err
+	}
+	if err := r.read(rw.Reader, m); err != nil {
+		return err
+	}
+	return getItem(m, key, cb)
+}
+
+// getItem passes the item of m, a response to a getk or getkq request for
+// key, to cb.
+func getItem(m *msg, key string, cb func(*types.Item)) error {
+	if err := newError(m.ResvOrStatus); err != nil {
 		return err
 	}
+	if m.key != key {
+		return fmt.Errorf("memcache: response for key %q to the request for %q", m.key, key)
+	}
+	if m.ExtraLen < 4 {
+		return fmt.Errorf("memcache: short extras in response to %q", key)
+	}
 	cb(&types.Item{
-		Key:   key,
+		Key:   m.key,
 		Value: m.val,
 		Casid: m.CAS,
-		Flags: flags,
+		Flags: binary.BigEndian.Uint32(m.extras),
 	})
 	return nil
 }
@@ -109,18 +185,77 @@ func (r *cmdRunner) Populate(rw *bufio.ReadWriter, verb types.Verb, item *types.
 			Op:  op,
 			CAS: ocas,
 		},
-		iextras: []interface{}{item.Flags, uint32(item.Expiration)},
-		key:     item.Key,
-		val:     item.Value,
+		key: item.Key,
+		val: item.Value,
+	}
+	// append and prepend must not carry extras.
+	if verb != types.Append && verb != types.Prepend {
+		m.iextras = []interface{}{item.Flags, uint32(item.Expiration)}
 	}
 
-	err := sendRecv(rw, m)
-	if err == types.ErrCASConflict && verb == "add" || verb == "replace" {
+	err := r.sendRecv(rw, m)
+	switch {
+	case err == types.ErrCASConflict && verb == types.Add,
+		err == types.ErrCacheMiss && verb == types.Replace,
+		err == types.ErrValueNotStored && (verb == types.Append || verb == types.Prepend):
 		return types.ErrNotStored
 	}
 	return err
 }
 
+// SetMulti stores items in a single round trip. Each item is sent as a
+// quiet set tagged with its index as Opaque, so the server only answers the
+// ones that fail and the answers can be attributed in any order.
+func (r *cmdRunner) SetMulti(rw *bufio.ReadWriter, items []*types.Item) ([]error, error) {
+	for i, item := range items {
+		m := &msg{
+			header: header{
+				Op:     opSetQ,
+				Opaque: uint32(i),
+			},
+			iextras: []interface{}{item.Flags, uint32(item.Expiration)},
+			key:     item.Key,
+			val:     item.Value,
+		}
+		if err := write(rw.Writer, m); err != nil {
+			return nil, err
+		}
+	}
+	errs := make([]error, len(items))
+	err := r.recvQuiet(rw, len(items), func(i int, m *msg) {
+		errs[i] = newError(m.ResvOrStatus)
+	})
+	return errs, err
+}
+
+// recvQuiet terminates a pipeline of n quiet requests, whose Opaque are
+// their indexes, with a noop and flushes it. It then reads responses up to
+// the noop's, passing each to fn with the index of its request.
+func (r *cmdRunner) recvQuiet(rw *bufio.ReadWriter, n int, fn func(i int, m *msg)) error {
+	noop := &msg{
+		header: header{
+			Op:     opNoop,
+			Opaque: uint32(n),
+		},
+	}
+	if err := send(rw, noop); err != nil {
+		return err
+	}
+	for {
+		m := &msg{}
+		if err := r.read(rw.Reader, m); err != nil {
+			return err
+		}
+		if m.Op == opNoop {
+			return newError(m.ResvOrStatus)
+		}
+		if m.Opaque >= uint32(n) {
+			return fmt.Errorf("memcache: unexpected opaque %d in response to %#x", m.Opaque, m.Op)
+		}
+		fn(int(m.Opaque), m)
+	}
+}
+
 func (r *cmdRunner) Delete(rw *bufio.ReadWriter, key string) error {
 	return r.DeleteCas(rw, key, 0)
 }
@@ -133,7 +268,29 @@ func (r *cmdRunner) DeleteCas(rw *bufio.ReadWriter, key string, cas uint64) erro
 		},
 		key: key,
 	}
-	return sendRecv(rw, m)
+	return r.sendRecv(rw, m)
+}
+
+// DeleteMulti deletes keys in a single round trip, with quiet deletes
+// matched to their key by Opaque like SetMulti.
+func (r *cmdRunner) DeleteMulti(rw *bufio.ReadWriter, keys []string) ([]error, error) {
+	for i, key := range keys {
+		m := &msg{
+			header: header{
+				Op:     opDeleteQ,
+				Opaque: uint32(i),
+			},
+			key: key,
+		}
+		if err := write(rw.Writer, m); err != nil {
+			return nil, err
+		}
+	}
+	errs := make([]error, len(keys))
+	err := r.recvQuiet(rw, len(keys), func(i int, m *msg) {
+		errs[i] = newError(m.ResvOrStatus)
+	})
+	return errs, err
 }
 
 func (r *cmdRunner) DeleteAll(rw *bufio.ReadWriter) error {
@@ -142,7 +299,7 @@ func (r *cmdRunner) DeleteAll(rw *bufio.ReadWriter) error {
 			Op: opFlush,
 		},
 	}
-	return sendRecv(rw, m)
+	return r.sendRecv(rw, m)
 }
 
 func (r *cmdRunner) FlushAll(rw *bufio.ReadWriter) error {
@@ -150,47 +307,73 @@ func (r *cmdRunner) FlushAll(rw *bufio.ReadWriter) error {
 }
 
 func (r *cmdRunner) Ping(rw *bufio.ReadWriter) error {
+	_, err := r.Version(rw)
+	return err
+}
+
+// Version returns the version the server reports.
+func (r *cmdRunner) Version(rw *bufio.ReadWriter) (string, error) {
 	m := &msg{
 		header: header{
 			Op: opVersion,
 		},
 	}
 
-	return sendRecv(rw, m)
+	if err := r.sendRecv(rw, m); err != nil {
+		return "", err
+	}
+	return string(m.val), nil
 }
 
-func (r *cmdRunner) Stat(rw *bufio.ReadWriter, cb func(k string, v []byte)) error {
+// Barrier sends a noop and waits for its answer, which the server sends
+// once it has answered the requests sent before it, quiet ones included.
+func (r *cmdRunner) Barrier(rw *bufio.ReadWriter) error {
 	m := &msg{
 		header: header{
-			Op: opStat,
+			Op: opNoop,
 		},
 	}
 
-	err := sendRecv(rw, m)
-	if err != nil {
+	return r.sendRecv(rw, m)
+}
+
+// Stats reads the statistics of group, or the general statistics if group
+// is empty. The server answers with a packet per statistic, terminated by
+// one with an empty key.
+func (r *cmdRunner) Stats(rw *bufio.ReadWriter, group string, cb func(name, value string)) error {
+	m := &msg{
+		header: header{
+			Op: opStat,
+		},
+		key: group,
+	}
+	if err := send(rw, m); err != nil {
 		return err
 	}
-
-	cb(m.key, m.val)
-
 	for {
-		m = &msg{
-			header: header{
-				Op: opStat,
-			},
-		}
-		err = recv(rw.Reader, m)
-		if err != nil {
+		m = &msg{}
+		if err := r.recv(rw.Reader, m); err == types.ErrCacheMiss {
+			// Unknown stats group.
+			return types.ErrNoStats
+		} else if err != nil {
 			return err
 		}
 		if m.key == "" {
-			break
+			return nil
 		}
-
-		cb(m.key, m.val)
+		cb(m.key, string(m.val))
 	}
+}
 
-	return nil
+// Quit sends a quiet quit, which the server answers by closing the
+// connection, so that quitting doesn't wait on the server.
+func (r *cmdRunner) Quit(rw *bufio.ReadWriter) error {
+	m := &msg{
+		header: header{
+			Op: opQuitQ,
+		},
+	}
+	return send(rw, m)
 }
 
 func (r *cmdRunner) Touch(rw *bufio.ReadWriter, keys []string, expiration int32) error {
@@ -204,7 +387,7 @@ func (r *cmdRunner) Touch(rw *bufio.ReadWriter, keys []string, expiration int32)
 
 	for _, key := range keys {
 		m.key = key
-		err := sendRecv(rw, m)
+		err := r.sendRecv(rw, m)
 		if err != nil {
 			return err
 		}
@@ -213,6 +396,48 @@ func (r *cmdRunner) Touch(rw *bufio.ReadWriter, keys []string, expiration int32)
 	return nil
 }
 
+// GetAndTouch fetches and touches keys in a single round trip. Each key is
+// sent as a quiet get-and-touch, which is only answered on a hit, tagged
+// with its index as Opaque to match the answer to its key.
+func (r *cmdRunner) GetAndTouch(rw *bufio.ReadWriter, keys []string, expiration int32, cb func(*types.Item)) error {
+	for i, key := range keys {
+		m := &msg{
+			header: header{
+				Op:     opGATKQ,
+				Opaque: uint32(i),
+			},
+			iextras: []interface{}{uint32(expiration)},
+			key:     key,
+		}
+		if err := write(rw.Writer, m); err != nil {
+			return err
+		}
+	}
+	var err error
+	errq := r.recvQuiet(rw, len(keys), func(i int, m *msg) {
+		if eg := newError(m.ResvOrStatus); eg != nil {
+			if eg != types.ErrCacheMiss {
+				err = eg
+			}
+			return
+		}
+		if m.ExtraLen < 4 {
+			err = fmt.Errorf("memcache: short extras in response to %q", keys[i])
+			return
+		}
+		cb(&types.Item{
+			Key:   keys[i],
+			Value: m.val,
+			Casid: m.CAS,
+			Flags: binary.BigEndian.Uint32(m.extras),
+		})
+	})
+	if errq != nil {
+		return errq
+	}
+	return err
+}
+
 func (r *cmdRunner) IncrDecr(rw *bufio.ReadWriter, verb types.Verb, key string, delta uint64) (uint64, error) {
 	op := verbToOp(verb)
 
@@ -227,12 +452,11 @@ func (r *cmdRunner) IncrDecr(rw *bufio.ReadWriter, verb types.Verb, key string,
 		key:     key,
 	}
 
-	err := sendRecv(rw, m)
+	err := r.sendRecv(rw, m)
 	if err != nil {
 		return 0, err
 	}
-	val, err := readInt(string(m.val))
-	return val, nil
+	return readInt(string(m.val))
 }
 
 func (r *cmdRunner) LegalKey(key string) bool {
@@ -250,80 +474,248 @@ func readInt(b string) (uint64, error) {
 }
 
 func send(rw *bufio.ReadWriter, m *msg) error {
+	if err := write(rw.Writer, m); err != nil {
+		return err
+	}
+	return rw.Flush()
+}
+
+// scratchPool holds the buffers request headers and extras are encoded
+// into, so that writing a request doesn't allocate.
+var scratchPool = sync.Pool{New: func() interface{} {
+	b := make([]byte, headerLen+maxExtrasLen)
+	return &b
+}}
+
+// maxExtrasLen is the size of the largest extras of a request, those of
+// incr and decr.
+const maxExtrasLen = 20
+
+// write buffers m in w without flushing it, for pipelining. Every part of
+// the message is written straight to w, so large values are only copied
+// once, into the writer or, if bigger than its buffer, to the connection.
+func write(w *bufio.Writer, m *msg) error {
 	m.Magic = magicSend
 	m.ExtraLen = sizeOfExtras(m.iextras)
 	m.KeyLen = uint16(len(m.key))
 	m.BodyLen = uint32(m.ExtraLen) + uint32(m.KeyLen) + uint32(len(m.val))
-	// m.Opaque = sc.opq
-	// sc.opq++
 
-	b := bytes.NewBuffer(nil)
-	// Request
-	err := binary.Write(b, binary.BigEndian, m.header)
-	if err != nil {
-		return err
+	bp := scratchPool.Get().(*[]byte)
+	defer scratchPool.Put(bp)
+	if n := headerLen + int(m.ExtraLen); len(*bp) < n {
+		*bp = make([]byte, n)
 	}
-
+	b := *bp
+	m.header.encode(b)
+	n := headerLen
 	for _, e := range m.iextras {
-		err = binary.Write(b, binary.BigEndian, e)
-		if err != nil {
-			return err
-		}
+		n += putExtra(b[n:], e)
 	}
+	if _, err := w.Write(b[:n]); err != nil {
+		return err
+	}
+	if _, err := w.WriteString(m.key); err != nil {
+		return err
+	}
+	_, err := w.Write(m.val)
+	return err
+}
 
-	_, err = io.WriteString(b, m.key)
-	if err != nil {
+// putExtra encodes e, one of the types sizeOfExtras accepts, into b and
+// returns its size.
+func putExtra(b []byte, e interface{}) int {
+	switch e := e.(type) {
+	case uint8:
+		b[0] = e
+		return 1
+	case uint16:
+		binary.BigEndian.PutUint16(b, e)
+		return 2
+	case uint32:
+		binary.BigEndian.PutUint32(b, e)
+		return 4
+	case uint64:
+		binary.BigEndian.PutUint64(b, e)
+		return 8
+	}
+	panic(fmt.Sprintf("mc: unknown extra type (%T)", e))
+}
+
+func (r *cmdRunner) recv(br *bufio.Reader, m *msg) error {
+	if err := r.read(br, m); err != nil {
 		return err
 	}
+	return newError(m.ResvOrStatus)
+}
 
-	_, err = b.Write(m.val)
+// read reads a response into m. Unlike recv it only fails on I/O and
+// framing errors, leaving the status in m.ResvOrStatus.
+func (r *cmdRunner) read(br *bufio.Reader, m *msg) error {
+	err := readHeader(br, &m.header)
 	if err != nil {
 		return err
 	}
+	if m.Magic != magicRecv {
+		return fmt.Errorf("memcache: bad magic %#x in response", m.Magic)
+	}
 
-	_, err = rw.Write(b.Bytes())
-	if err != nil {
+	if r.maxResponseSize > 0 && int64(m.BodyLen) > r.maxResponseSize {
+		return &types.ResponseTooLargeError{Size: int64(m.BodyLen), Max: r.maxResponseSize}
+	}
+	if int(m.ExtraLen)+int(m.KeyLen) > int(m.BodyLen) {
+		return fmt.Errorf("memcache: malformed response to %#x", m.Op)
+	}
+
+	// Read each part of the body on its own, so that the value doesn't
+	// pin a buffer shared with the extras and key.
+	m.extras = make([]byte, m.ExtraLen)
+	if _, err := io.ReadFull(br, m.extras); err != nil {
 		return err
 	}
-	return rw.Flush()
+	if m.ResvOrStatus == 0 && m.ExtraLen > 0 {
+		if err := getExtras(m.extras, m.oextras); err != nil {
+			return err
+		}
+	}
+	if m.key, err = readKey(br, int(m.KeyLen)); err != nil {
+		return err
+	}
+	m.val, err = r.readBody(br, m.BodyLen-uint32(m.ExtraLen)-uint32(m.KeyLen))
+	return err
 }
 
-func recv(r *bufio.Reader, m *msg) error {
-	err := binary.Read(r, binary.BigEndian, &m.header)
+// readHeader decodes a header from r, in place in r's buffer when it
+// fits there, as it does with any reader the client creates.
+func readHeader(r *bufio.Reader, h *header) error {
+	if r.Size() < headerLen {
+		var b [headerLen]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return err
+		}
+		h.decode(b[:])
+		return nil
+	}
+	b, err := r.Peek(headerLen)
 	if err != nil {
+		if err == io.EOF && len(b) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
 		return err
 	}
+	h.decode(b)
+	r.Discard(headerLen)
+	return nil
+}
 
-	bd := make([]byte, m.BodyLen)
-	_, err = io.ReadFull(r, bd)
+// getExtras decodes b into the pointers of extras in turn.
+func getExtras(b []byte, extras []interface{}) error {
+	for _, e := range extras {
+		var n int
+		switch e := e.(type) {
+		case *uint8:
+			n = 1
+			if len(b) >= n {
+				*e = b[0]
+			}
+		case *uint16:
+			n = 2
+			if len(b) >= n {
+				*e = binary.BigEndian.Uint16(b)
+			}
+		case *uint32:
+			n = 4
+			if len(b) >= n {
+				*e = binary.BigEndian.Uint32(b)
+			}
+		case *uint64:
+			n = 8
+			if len(b) >= n {
+				*e = binary.BigEndian.Uint64(b)
+			}
+		default:
+			panic(fmt.Sprintf("mc: unknown extra type (%T)", e))
+		}
+		if len(b) < n {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// readKey reads a key of n bytes from r, without an intermediate buffer
+// when it fits in r's.
+func readKey(r *bufio.Reader, n int) (string, error) {
+	if n > r.Size() {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return string(b), err
+	}
+	b, err := r.Peek(n)
 	if err != nil {
-		return err
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
 	}
+	key := string(b)
+	r.Discard(n)
+	return key, nil
+}
 
-	buf := bytes.NewBuffer(bd)
+// preallocLimit is the largest body read into a buffer allocated up front.
+// Larger bodies grow their buffer as the data arrives, so that a corrupt
+// length can't make the client allocate memory the server never sends.
+const preallocLimit = 1 << 20
+
+// readBody reads a response body of n bytes from rd, into a buffer from
+// the runner's pool if it has one.
+func (r *cmdRunner) readBody(rd io.Reader, n uint32) ([]byte, error) {
+	if n > preallocLimit {
+		return readGrowing(rd, int(n))
+	}
+	var b []byte
+	if r.buffers != nil && n > 0 {
+		b = r.buffers.Get(int(n))
+	} else {
+		b = make([]byte, n)
+	}
+	_, err := io.ReadFull(rd, b)
+	return b, err
+}
 
-	if m.ResvOrStatus == 0 && m.ExtraLen > 0 {
-		for _, e := range m.oextras {
-			err := binary.Read(buf, binary.BigEndian, e)
-			if err != nil {
-				return err
+// readGrowing reads size bytes from r into a buffer that starts at
+// preallocLimit and doubles, capped at size, each time it fills up.
+func readGrowing(r io.Reader, size int) ([]byte, error) {
+	b := make([]byte, 0, preallocLimit)
+	for len(b) < size {
+		if len(b) == cap(b) {
+			n := 2 * cap(b)
+			if n > size {
+				n = size
+			}
+			nb := make([]byte, len(b), n)
+			copy(nb, b)
+			b = nb
+		}
+		if _, err := io.ReadFull(r, b[len(b):cap(b)]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
 			}
+			return nil, err
 		}
+		b = b[:cap(b)]
 	}
-
-	m.key = string(buf.Next(int(m.KeyLen)))
-	vlen := int(m.BodyLen) - int(m.ExtraLen) - int(m.KeyLen)
-	m.val = buf.Next(int(vlen))
-	return newError(m.ResvOrStatus)
+	return b, nil
 }
 
-func sendRecv(rw *bufio.ReadWriter, m *msg) error {
+func (r *cmdRunner) sendRecv(rw *bufio.ReadWriter, m *msg) error {
 	err := send(rw, m)
 	if err != nil {
 		return err
 	}
 
-	return recv(rw.Reader, m)
+	return r.recv(rw.Reader, m)
 }
 
 // sizeOfExtras returns the size of the extras field for the memcache request.
@@ -359,7 +751,57 @@ func verbToOp(verb types.Verb) opCode {
 		return opAdd
 	case "replace":
 		return opReplace
+	case "append":
+		return opAppend
+	case "prepend":
+		return opPrepend
 	default:
 		return opVersion
 	}
 }
+
+// RawBinary sends a request for op with the given key, extras and value,
+// followed by a noop, and returns the response packets read before the
+// noop's: none for a quiet op that succeeded, several for one such as
+// stat. Statuses are left to the caller rather than turned into errors.
+func (r *cmdRunner) RawBinary(rw *bufio.ReadWriter, op uint8, key string, extras, value []byte) ([]*types.RawResponse, error) {
+	// The header can't hold the lengths of longer extras and keys.
+	if len(extras) > math.MaxUint8 {
+		return nil, fmt.Errorf("memcache: %d bytes of extras, more than %d", len(extras), math.MaxUint8)
+	}
+	if len(key) > math.MaxUint16 {
+		return nil, fmt.Errorf("memcache: key of %d bytes, more than %d", len(key), math.MaxUint16)
+	}
+	m := &msg{
+		header: header{Op: opCode(op)},
+		key:    key,
+		val:    value,
+	}
+	for _, b := range extras {
+		m.iextras = append(m.iextras, b)
+	}
+	if err := write(rw.Writer, m); err != nil {
+		return nil, err
+	}
+	if err := send(rw, &msg{header: header{Op: opNoop}}); err != nil {
+		return nil, err
+	}
+	var resps []*types.RawResponse
+	for {
+		m := &msg{}
+		if err := r.read(rw.Reader, m); err != nil {
+			return nil, err
+		}
+		if m.Op == opNoop {
+			return resps, nil
+		}
+		resps = append(resps, &types.RawResponse{
+			Op:     uint8(m.Op),
+			Status: m.ResvOrStatus,
+			CAS:    m.CAS,
+			Extras: m.extras,
+			Key:    m.key,
+			Value:  m.val,
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package bin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/types"
+)
+
+// withOpaque sets the opaque of the response packet b.
+func withOpaque(b []byte, opaque uint32) []byte {
+	binary.BigEndian.PutUint32(b[12:16], opaque)
+	return b
+}
+
+func TestGetKeysFromResponses(t *testing.T) {
+	flags := []byte{0, 0, 0, 7}
+	noop := withOpaque(response(opNoop, 0, nil, "", nil), 3)
+	for _, tt := range []struct {
+		name    string
+		keys    []string
+		resps   [][]byte
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "one key",
+			keys:  []string{"a"},
+			resps: [][]byte{response(opGetK, 0, flags, "a", []byte("1"))},
+			want:  map[string]string{"a": "1"},
+		},
+		{
+			name:  "one missing key",
+			keys:  []string{"a"},
+			resps: [][]byte{response(opGetK, StatusNotFound, nil, "a", nil)},
+			want:  map[string]string{},
+		},
+		{
+			name: "out of order",
+			keys: []string{"a", "b", "c"},
+			resps: [][]byte{
+				withOpaque(response(opGetKQ, 0, flags, "c", []byte("3")), 2),
+				response(opGetKQ, 0, flags, "a", []byte("1")),
+				noop,
+			},
+			want: map[string]string{"a": "1", "c": "3"},
+		},
+		{
+			name: "key of another request",
+			keys: []string{"a", "b", "c"},
+			resps: [][]byte{
+				withOpaque(response(opGetKQ, 0, flags, "a", []byte("1")), 1),
+				noop,
+			},
+			want:    map[string]string{},
+			wantErr: true,
+		},
+		{
+			name:    "one key of another request",
+			keys:    []string{"a"},
+			resps:   [][]byte{response(opGetK, 0, flags, "b", []byte("2"))},
+			want:    map[string]string{},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rd := bytes.NewReader(bytes.Join(tt.resps, nil))
+			rw := bufio.NewReadWriter(bufio.NewReader(rd), bufio.NewWriter(ioutil.Discard))
+			got := map[string]string{}
+			err := DefaultBinCommander.Get(rw, tt.keys, func(it *types.Item) {
+				if it.Flags != 7 {
+					t.Errorf("%s: flags = %d, want 7", it.Key, it.Flags)
+				}
+				got[it.Key] = string(it.Value)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Get error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Get = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("Get = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package bin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// response encodes a response packet for the fuzz corpus.
+func response(op opCode, status uint16, extras []byte, key string, val []byte) []byte {
+	h := header{
+		Magic:        magicRecv,
+		Op:           op,
+		KeyLen:       uint16(len(key)),
+		ExtraLen:     uint8(len(extras)),
+		ResvOrStatus: status,
+		BodyLen:      uint32(len(extras) + len(key) + len(val)),
+	}
+	b := make([]byte, headerLen, headerLen+len(extras)+len(key)+len(val))
+	h.encode(b)
+	b = append(b, extras...)
+	b = append(b, key...)
+	return append(b, val...)
+}
+
+func TestHeaderEncoding(t *testing.T) {
+	h := header{
+		Magic:        magicSend,
+		Op:           opSet,
+		KeyLen:       0x0102,
+		ExtraLen:     8,
+		ResvOrStatus: 0x0304,
+		BodyLen:      0x05060708,
+		Opaque:       0x090a0b0c,
+		CAS:          0x0d0e0f1011121314,
+	}
+	var want bytes.Buffer
+	binary.Write(&want, binary.BigEndian, h)
+	got := make([]byte, headerLen)
+	h.encode(got)
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Fatalf("encode = %x, want %x", got, want.Bytes())
+	}
+	var dec header
+	dec.decode(got)
+	if dec != h {
+		t.Errorf("decode = %+v, want %+v", dec, h)
+	}
+}
+
+func FuzzRecv(f *testing.F) {
+	f.Add(response(opGet, 0, []byte{0, 0, 0, 7}, "", []byte("value")))
+	f.Add(response(opGetK, 0, []byte{0, 0, 0, 7}, "key", []byte("value")))
+	f.Add(response(opGet, StatusNotFound, nil, "", []byte("Not found")))
+	huge := response(opGet, 0, nil, "", nil)
+	binary.BigEndian.PutUint32(huge[8:], 0xffffffff)
+	f.Add(huge)
+	short := response(opGet, 0, []byte{0, 0, 0, 7}, "", nil)
+	short[4] = 200
+	f.Add(short)
+	f.Fuzz(func(t *testing.T, resp []byte) {
+		r := bufio.NewReader(bytes.NewReader(resp))
+		for {
+			var flags uint32
+			m := &msg{oextras: []interface{}{&flags}}
+			if err := DefaultBinCommander.read(r, m); err != nil {
+				return
+			}
+			if int(m.ExtraLen)+len(m.key)+len(m.val) != int(m.BodyLen) {
+				t.Fatalf("body of %d bytes split into %d+%d+%d", m.BodyLen, m.ExtraLen, len(m.key), len(m.val))
+			}
+		}
+	})
+}
@@ -1,6 +1,10 @@
 package bin
 
-import "github.com/skinass/gomemcache/memcache/types"
+import (
+	"encoding/binary"
+
+	"github.com/skinass/gomemcache/memcache/types"
+)
 
 // Status Codes that may be returned (usually as part of an Error).
 const (
@@ -120,6 +124,35 @@ type header struct {
 	CAS     uint64 // version really
 }
 
+// headerLen is the size of an encoded header.
+const headerLen = 24
+
+// encode writes h to b, which is at least headerLen bytes long.
+func (h *header) encode(b []byte) {
+	b[0] = byte(h.Magic)
+	b[1] = byte(h.Op)
+	binary.BigEndian.PutUint16(b[2:], h.KeyLen)
+	b[4] = h.ExtraLen
+	b[5] = h.DataType
+	binary.BigEndian.PutUint16(b[6:], h.ResvOrStatus)
+	binary.BigEndian.PutUint32(b[8:], h.BodyLen)
+	binary.BigEndian.PutUint32(b[12:], h.Opaque)
+	binary.BigEndian.PutUint64(b[16:], h.CAS)
+}
+
+// decode reads h from b, which is at least headerLen bytes long.
+func (h *header) decode(b []byte) {
+	h.Magic = MagicCode(b[0])
+	h.Op = opCode(b[1])
+	h.KeyLen = binary.BigEndian.Uint16(b[2:])
+	h.ExtraLen = b[4]
+	h.DataType = b[5]
+	h.ResvOrStatus = binary.BigEndian.Uint16(b[6:])
+	h.BodyLen = binary.BigEndian.Uint32(b[8:])
+	h.Opaque = binary.BigEndian.Uint32(b[12:])
+	h.CAS = binary.BigEndian.Uint64(b[16:])
+}
+
 // Main Memcache message structure
 type msg struct {
 	header                // [0..23]
@@ -128,6 +161,7 @@ type msg struct {
 	// Idea of this is we can pass in pointers to values that should appear in the
 	// response extras in this field and the generic send/recieve code can handle.
 	oextras []interface{} // [24..(m-1)] Command specifc extras (Out)
+	extras  []byte        // [24..(m-1)] Raw extras of a response
 
 	key string // [m..(n-1)] Key (as needed, length in header)
 	val []byte // [n..x] Value (as needed, length in header)
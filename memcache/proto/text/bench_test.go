@@ -0,0 +1,64 @@
+package text
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/types"
+)
+
+var benchSizes = []struct {
+	name string
+	size int
+}{{"100B", 100}, {"1MB", 1 << 20}}
+
+// getResponse returns a gets response holding one item of size bytes.
+func getResponse(size int) []byte {
+	var b bytes.Buffer
+	b.WriteString("VALUE key 7 ")
+	b.WriteString(strconv.Itoa(size))
+	b.WriteString(" 42\r\n")
+	b.Write(make([]byte, size))
+	b.WriteString("\r\nEND\r\n")
+	return b.Bytes()
+}
+
+func BenchmarkParseGetResponse(b *testing.B) {
+	for _, bs := range benchSizes {
+		b.Run(bs.name, func(b *testing.B) {
+			resp := getResponse(bs.size)
+			rd := bytes.NewReader(resp)
+			br := bufio.NewReader(rd)
+			b.SetBytes(int64(bs.size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rd.Reset(resp)
+				br.Reset(rd)
+				if err := DefaultTextCommander.parseGetResponse(br, func(*types.Item) {}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestParseGetResponseAllocs guards the allocations of parsing a small get
+// response, so that regressions show up in go test rather than only in
+// benchmarks.
+func TestParseGetResponseAllocs(t *testing.T) {
+	resp := getResponse(5)
+	rd := bytes.NewReader(resp)
+	br := bufio.NewReader(rd)
+	allocs := testing.AllocsPerRun(100, func() {
+		rd.Reset(resp)
+		br.Reset(rd)
+		if err := DefaultTextCommander.parseGetResponse(br, func(*types.Item) {}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if max := 3.0; allocs > max {
+		t.Errorf("parseGetResponse allocated %v times, want at most %v", allocs, max)
+	}
+}
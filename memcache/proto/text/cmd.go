@@ -16,7 +16,27 @@ const ProtoType = "text"
 
 var DefaultTextCommander = &cmdRunner{}
 
-type cmdRunner struct{}
+// NewCommander returns a text protocol commander failing the values
+// declared larger than maxResponseSize bytes with a
+// *types.ResponseTooLargeError, before allocating them. Zero means no
+// limit, as with DefaultTextCommander.
+func NewCommander(maxResponseSize int64) *cmdRunner {
+	return &cmdRunner{maxResponseSize: maxResponseSize}
+}
+
+type cmdRunner struct {
+	maxResponseSize int64
+	buffers         types.BufferPool
+}
+
+// WithBufferPool returns a copy of r reading the values of retrieved
+// items into buffers from pool, which the caller returns to it once done
+// with the items.
+func (r *cmdRunner) WithBufferPool(pool types.BufferPool) *cmdRunner {
+	cp := *r
+	cp.buffers = pool
+	return &cp
+}
 
 func (r *cmdRunner) ProtoType() string {
 	return ProtoType
@@ -29,17 +49,37 @@ func (r *cmdRunner) Auth(*bufio.ReadWriter, string, string) error {
 	return errors.New("method Auth is not implemented for plain cmd runner")
 }
 func (r *cmdRunner) Get(rw *bufio.ReadWriter, keys []string, cb func(*types.Item)) error {
-	if _, err := fmt.Fprintf(rw, "gets %s\r\n", strings.Join(keys, " ")); err != nil {
+	// Write the keys one by one rather than joining them, which would copy
+	// them all once more for large batches.
+	rw.WriteString("gets")
+	for _, key := range keys {
+		rw.WriteByte(' ')
+		rw.WriteString(key)
+	}
+	if _, err := rw.Write(crlf); err != nil {
 		return err
 	}
 	if err := rw.Flush(); err != nil {
 		return err
 	}
-	if err := parseGetResponse(rw.Reader, cb); err != nil {
+	if err := r.parseGetResponse(rw.Reader, cb); err != nil {
 		return err
 	}
 	return nil
 }
+
+// GetAndTouch fetches keys and updates their expiration with a single gats
+// command.
+func (r *cmdRunner) GetAndTouch(rw *bufio.ReadWriter, keys []string, expiration int32, cb func(*types.Item)) error {
+	if _, err := fmt.Fprintf(rw, "gats %d %s\r\n", expiration, strings.Join(keys, " ")); err != nil {
+		return err
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	return r.parseGetResponse(rw.Reader, cb)
+}
+
 func (r *cmdRunner) Populate(rw *bufio.ReadWriter, verb types.Verb, item *types.Item) error {
 	if !r.LegalKey(item.Key) {
 		return types.ErrMalformedKey
@@ -55,10 +95,7 @@ func (r *cmdRunner) Populate(rw *bufio.ReadWriter, verb types.Verb, item *types.
 	if err != nil {
 		return err
 	}
-	if _, err = rw.Write(item.Value); err != nil {
-		return err
-	}
-	if _, err := rw.Write(crlf); err != nil {
+	if err = writeValue(rw.Writer, item.Value); err != nil {
 		return err
 	}
 	if err := rw.Flush(); err != nil {
@@ -68,6 +105,28 @@ func (r *cmdRunner) Populate(rw *bufio.ReadWriter, verb types.Verb, item *types.
 	if err != nil {
 		return err
 	}
+	return storeResult(verb, line)
+}
+
+// writeValue writes the data block of a storage command, after its
+// command line. A value that doesn't fit in the space left in w is not
+// copied into it: what w holds is flushed first, and the value then goes
+// straight to the connection.
+func writeValue(w *bufio.Writer, value []byte) error {
+	if len(value) > w.Available() && w.Buffered() > 0 {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(value); err != nil {
+		return err
+	}
+	_, err := w.Write(crlf)
+	return err
+}
+
+// storeResult maps the response line of a storage command to an error.
+func storeResult(verb types.Verb, line []byte) error {
 	switch {
 	case bytes.Equal(line, resultStored):
 		return nil
@@ -77,14 +136,84 @@ func (r *cmdRunner) Populate(rw *bufio.ReadWriter, verb types.Verb, item *types.
 		return types.ErrCASConflict
 	case bytes.Equal(line, resultNotFound):
 		return types.ErrCacheMiss
+	case bytes.HasPrefix(line, resultOutOfMemoryPrefix):
+		return types.ErrOutOfMemory
 	}
 	return fmt.Errorf("memcache: unexpected response line from %q: %q", verb, string(line))
 }
 
+// SetMulti stores items in a single round trip by pipelining the set
+// commands and reading their responses in order.
+func (r *cmdRunner) SetMulti(rw *bufio.ReadWriter, items []*types.Item) ([]error, error) {
+	errs := make([]error, len(items))
+	for i, item := range items {
+		if !r.LegalKey(item.Key) {
+			errs[i] = types.ErrMalformedKey
+			continue
+		}
+		if _, err := fmt.Fprintf(rw, "set %s %d %d %d\r\n",
+			item.Key, item.Flags, item.Expiration, len(item.Value)); err != nil {
+			return nil, err
+		}
+		if err := writeValue(rw.Writer, item.Value); err != nil {
+			return nil, err
+		}
+	}
+	if err := rw.Flush(); err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if errs[i] != nil {
+			continue
+		}
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return nil, err
+		}
+		errs[i] = storeResult(types.Set, line)
+	}
+	return errs, nil
+}
+
 func (r *cmdRunner) Delete(rw *bufio.ReadWriter, key string) error {
 	return r.writeExpectf(rw, resultDeleted, "delete %s\r\n", key)
 }
 
+// DeleteMulti deletes keys in a single round trip by pipelining the delete
+// commands and reading their responses in order.
+func (r *cmdRunner) DeleteMulti(rw *bufio.ReadWriter, keys []string) ([]error, error) {
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		if !r.LegalKey(key) {
+			errs[i] = types.ErrMalformedKey
+			continue
+		}
+		if _, err := fmt.Fprintf(rw, "delete %s\r\n", key); err != nil {
+			return nil, err
+		}
+	}
+	if err := rw.Flush(); err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		if errs[i] != nil {
+			continue
+		}
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case bytes.Equal(line, resultDeleted):
+		case bytes.Equal(line, resultNotFound):
+			errs[i] = types.ErrCacheMiss
+		default:
+			errs[i] = fmt.Errorf("memcache: unexpected response line from delete: %q", string(line))
+		}
+	}
+	return errs, nil
+}
+
 func (r *cmdRunner) DeleteAll(rw *bufio.ReadWriter) error {
 	return r.writeExpectf(rw, resultDeleted, "flush_all\r\n")
 }
@@ -114,24 +243,81 @@ func (r *cmdRunner) writeExpectf(rw *bufio.ReadWriter, expect []byte, format str
 }
 
 func (r *cmdRunner) Ping(rw *bufio.ReadWriter) error {
+	_, err := r.Version(rw)
+	return err
+}
+
+// Version returns the version the server reports.
+func (r *cmdRunner) Version(rw *bufio.ReadWriter) (string, error) {
 	if _, err := fmt.Fprintf(rw, "version\r\n"); err != nil {
-		return err
+		return "", err
 	}
 	if err := rw.Flush(); err != nil {
-		return err
+		return "", err
 	}
 	line, err := rw.ReadSlice('\n')
 	if err != nil {
+		return "", err
+	}
+	if !bytes.HasPrefix(line, versionPrefix) {
+		return "", fmt.Errorf("memcache: unexpected response line from ping: %q", string(line))
+	}
+	return string(bytes.TrimSpace(line[len(versionPrefix):])), nil
+}
+
+// Barrier waits for the answer to a version command, which the server
+// sends once it has answered the requests sent before it.
+func (r *cmdRunner) Barrier(rw *bufio.ReadWriter) error {
+	return r.Ping(rw)
+}
+
+// Stats reads the statistics of group, or the general statistics if group
+// is empty, from the STAT lines terminated by END.
+func (r *cmdRunner) Stats(rw *bufio.ReadWriter, group string, cb func(name, value string)) error {
+	cmd := "stats\r\n"
+	if group != "" {
+		if !r.LegalKey(group) {
+			return types.ErrMalformedKey
+		}
+		cmd = "stats " + group + "\r\n"
+	}
+	if _, err := io.WriteString(rw, cmd); err != nil {
 		return err
 	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(line, resultEnd) {
+			return nil
+		}
+		if bytes.Equal(line, resultError) {
+			// Unknown stats group.
+			return types.ErrNoStats
+		}
+		if !bytes.HasPrefix(line, resultStatPrefix) {
+			return fmt.Errorf("memcache: unexpected response line from stats: %q", string(line))
+		}
+		stat := strings.TrimSuffix(string(line[len(resultStatPrefix):]), "\r\n")
+		if i := strings.IndexByte(stat, ' '); i >= 0 {
+			cb(stat[:i], stat[i+1:])
+		} else {
+			cb(stat, "")
+		}
+	}
+}
 
-	switch {
-	case bytes.HasPrefix(line, versionPrefix):
-		break
-	default:
-		return fmt.Errorf("memcache: unexpected response line from ping: %q", string(line))
+// Quit sends the quit command. The server closes the connection without
+// answering, so there is nothing to read.
+func (r *cmdRunner) Quit(rw *bufio.ReadWriter) error {
+	if _, err := io.WriteString(rw, "quit\r\n"); err != nil {
+		return err
 	}
-	return nil
+	return rw.Flush()
 }
 
 func (r *cmdRunner) Touch(rw *bufio.ReadWriter, keys []string, expiration int32) error {
@@ -167,9 +353,12 @@ func (r *cmdRunner) IncrDecr(rw *bufio.ReadWriter, verb types.Verb, key string,
 	case bytes.Equal(line, resultNotFound):
 		return 0, types.ErrCacheMiss
 	case bytes.HasPrefix(line, resultClientErrorPrefix):
-		errMsg := line[len(resultClientErrorPrefix) : len(line)-2]
+		errMsg := bytes.TrimSpace(line[len(resultClientErrorPrefix):])
 		return 0, errors.New("memcache: client error: " + string(errMsg))
 	}
+	if !bytes.HasSuffix(line, crlf) {
+		return 0, fmt.Errorf("memcache: unexpected response line from %s: %q", verb, string(line))
+	}
 	val, err := strconv.ParseUint(string(line[:len(line)-2]), 10, 64)
 	if err != nil {
 		return 0, err
@@ -177,6 +366,33 @@ func (r *cmdRunner) IncrDecr(rw *bufio.ReadWriter, verb types.Verb, key string,
 	return val, nil
 }
 
+// MetaGet sends a meta get of key without its value, with flags, each a
+// flag of the mg command such as "c" or "t", and returns the flags of the
+// response by letter, with their tokens. It fails with
+// types.ErrCacheMiss if there is no such item.
+func (r *cmdRunner) MetaGet(rw *bufio.ReadWriter, key string, flags ...string) (map[byte]string, error) {
+	var args string
+	if len(flags) > 0 {
+		args = " " + strings.Join(flags, " ")
+	}
+	line, err := writeReadLine(rw, "mg %s%s\r\n", key, args)
+	if err != nil {
+		return nil, err
+	}
+	f := bytes.Fields(line)
+	switch {
+	case len(f) == 1 && string(f[0]) == "EN":
+		return nil, types.ErrCacheMiss
+	case len(f) == 0 || string(f[0]) != "HD":
+		return nil, fmt.Errorf("memcache: unexpected response line from mg: %q", string(line))
+	}
+	ret := make(map[byte]string, len(f)-1)
+	for _, flag := range f[1:] {
+		ret[flag[0]] = string(flag[1:])
+	}
+	return ret, nil
+}
+
 func writeReadLine(rw *bufio.ReadWriter, format string, args ...interface{}) ([]byte, error) {
 	_, err := fmt.Fprintf(rw, format, args...)
 	if err != nil {
@@ -192,22 +408,60 @@ func writeReadLine(rw *bufio.ReadWriter, format string, args ...interface{}) ([]
 // scanGetResponseLine populates it and returns the declared size of the item.
 // It does not read the bytes of the item.
 func scanGetResponseLine(line []byte, it *types.Item) (size int, err error) {
-	pattern := "VALUE %s %d %d %d\r\n"
-	dest := []interface{}{&it.Key, &it.Flags, &size, &it.Casid}
-	if bytes.Count(line, space) == 3 {
-		pattern = "VALUE %s %d %d\r\n"
-		dest = dest[:3]
+	var fields [4][]byte
+	n, ok := splitGetResponseLine(line, &fields)
+	if ok && !bytes.ContainsAny(fields[0], "\r\n") {
+		var flags, casid uint64
+		var sz int64
+		flags, err = strconv.ParseUint(string(fields[1]), 10, 32)
+		if err == nil {
+			sz, err = strconv.ParseInt(string(fields[2]), 10, 32)
+		}
+		if err == nil && n == 4 {
+			casid, err = strconv.ParseUint(string(fields[3]), 10, 64)
+		}
+		if err == nil && sz >= 0 {
+			it.Key = string(fields[0])
+			it.Flags = uint32(flags)
+			it.Casid = casid
+			return int(sz), nil
+		}
 	}
-	n, err := fmt.Sscanf(string(line), pattern, dest...)
-	if err != nil || n != len(dest) {
-		return -1, fmt.Errorf("memcache: unexpected line in get response: %q", line)
+	return -1, fmt.Errorf("memcache: unexpected line in get response: %q", line)
+}
+
+// splitGetResponseLine splits a "VALUE <key> <flags> <bytes> [<cas>]\r\n"
+// line into the fields after VALUE, returning how many there are.
+func splitGetResponseLine(line []byte, fields *[4][]byte) (int, bool) {
+	if !bytes.HasPrefix(line, valuePrefix) || !bytes.HasSuffix(line, crlf) {
+		return 0, false
 	}
-	return size, nil
+	line = line[len(valuePrefix) : len(line)-len(crlf)]
+	n := 0
+	for len(line) > 0 && n < len(fields) {
+		i := bytes.IndexByte(line, ' ')
+		if i < 0 {
+			i = len(line)
+		}
+		if i == 0 {
+			return 0, false
+		}
+		fields[n] = line[:i]
+		n++
+		line = line[i:]
+		if len(line) > 0 {
+			line = line[1:]
+			if len(line) == 0 {
+				return 0, false
+			}
+		}
+	}
+	return n, len(line) == 0 && n >= 3
 }
 
 // parseGetResponse reads a GET response from r and calls cb for each
 // read and allocated types.Item
-func parseGetResponse(rd *bufio.Reader, cb func(*types.Item)) error {
+func (r *cmdRunner) parseGetResponse(rd *bufio.Reader, cb func(*types.Item)) error {
 	for {
 		line, err := rd.ReadSlice('\n')
 		if err != nil {
@@ -221,8 +475,10 @@ func parseGetResponse(rd *bufio.Reader, cb func(*types.Item)) error {
 		if err != nil {
 			return err
 		}
-		it.Value = make([]byte, size+2)
-		_, err = io.ReadFull(rd, it.Value)
+		if r.maxResponseSize > 0 && int64(size) > r.maxResponseSize {
+			return &types.ResponseTooLargeError{Size: int64(size), Max: r.maxResponseSize}
+		}
+		it.Value, err = r.readValue(rd, size+2)
 		if err != nil {
 			it.Value = nil
 			return err
@@ -236,6 +492,53 @@ func parseGetResponse(rd *bufio.Reader, cb func(*types.Item)) error {
 	}
 }
 
+// preallocLimit is the largest value read into a buffer allocated up
+// front. Larger values grow their buffer as the data arrives, so that a
+// corrupt size can't make the client allocate memory the server never
+// sends.
+const preallocLimit = 1 << 20
+
+// readValue reads a value of size bytes from rd, into a buffer from the
+// runner's pool if it has one.
+func (r *cmdRunner) readValue(rd io.Reader, size int) ([]byte, error) {
+	if size > preallocLimit {
+		return readGrowing(rd, size)
+	}
+	var b []byte
+	if r.buffers != nil {
+		b = r.buffers.Get(size)
+	} else {
+		b = make([]byte, size)
+	}
+	_, err := io.ReadFull(rd, b)
+	return b, err
+}
+
+// readGrowing reads size bytes from r into a buffer that starts at
+// preallocLimit and doubles, capped at size, each time it fills up.
+func readGrowing(r io.Reader, size int) ([]byte, error) {
+	b := make([]byte, 0, preallocLimit)
+	for len(b) < size {
+		if len(b) == cap(b) {
+			n := 2 * cap(b)
+			if n > size {
+				n = size
+			}
+			nb := make([]byte, len(b), n)
+			copy(nb, b)
+			b = nb
+		}
+		if _, err := io.ReadFull(r, b[len(b):cap(b)]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		b = b[:cap(b)]
+	}
+	return b, nil
+}
+
 func (r *cmdRunner) LegalKey(key string) bool {
 	if len(key) > 250 {
 		return false
@@ -247,3 +550,86 @@ func (r *cmdRunner) LegalKey(key string) bool {
 	}
 	return true
 }
+
+// RawText sends cmd, followed by "\r\n", and returns the response, read
+// up to its end as known from the shape of memcached's responses: the
+// values of VALUE and VA lines are read along with them, lists of STAT,
+// ITEM and metadump lines up to the line ending them, and any other line
+// ends the response. Unlike the other commands, it leaves error lines to
+// the caller.
+func (r *cmdRunner) RawText(rw *bufio.ReadWriter, cmd string) ([]byte, error) {
+	if _, err := rw.WriteString(cmd); err != nil {
+		return nil, err
+	}
+	if _, err := rw.Write(crlf); err != nil {
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		return nil, err
+	}
+	var resp []byte
+	for {
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, line...)
+		size, more := rawValueSize(line)
+		if size < 0 {
+			if more {
+				continue
+			}
+			return resp, nil
+		}
+		if r.maxResponseSize > 0 && int64(size) > r.maxResponseSize {
+			return nil, &types.ResponseTooLargeError{Size: int64(size), Max: r.maxResponseSize}
+		}
+		value, err := readRawValue(rw, size+2)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, value...)
+		if !more {
+			return resp, nil
+		}
+	}
+}
+
+// readRawValue reads a value of size bytes like readValue, but never into
+// a pooled buffer, which RawText would not return.
+func readRawValue(rd io.Reader, size int) ([]byte, error) {
+	if size > preallocLimit {
+		return readGrowing(rd, size)
+	}
+	b := make([]byte, size)
+	_, err := io.ReadFull(rd, b)
+	return b, err
+}
+
+// rawValueSize returns the size of the value following line, or -1 if
+// none does, and whether more lines follow it.
+func rawValueSize(line []byte) (size int, more bool) {
+	switch {
+	case bytes.HasPrefix(line, valuePrefix):
+		var it types.Item
+		size, err := scanGetResponseLine(line, &it)
+		if err != nil {
+			return -1, false
+		}
+		return size, true
+	case bytes.HasPrefix(line, []byte("VA ")):
+		f := bytes.Fields(line)
+		if len(f) < 2 {
+			return -1, false
+		}
+		size, err := strconv.Atoi(string(f[1]))
+		if err != nil || size < 0 {
+			return -1, false
+		}
+		return size, false
+	case bytes.HasPrefix(line, resultStatPrefix), bytes.HasPrefix(line, []byte("ITEM ")),
+		bytes.HasPrefix(line, []byte("key=")):
+		return -1, true
+	}
+	return -1, false
+}
@@ -0,0 +1,110 @@
+package text
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/types"
+)
+
+// countingWriter records the size of each write it receives.
+type countingWriter struct {
+	bytes.Buffer
+	writes []int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestWriteValueLarge(t *testing.T) {
+	var cw countingWriter
+	w := bufio.NewWriterSize(&cw, 64)
+	value := bytes.Repeat([]byte("x"), 1000)
+	w.WriteString("set k 0 0 1000\r\n")
+	if err := writeValue(w, value); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "set k 0 0 1000\r\n" + string(value) + "\r\n"; cw.String() != want {
+		t.Fatalf("wrote %q", cw.String())
+	}
+	// The command line, then the value on its own, then the trailing crlf.
+	if len(cw.writes) != 3 || cw.writes[1] != len(value) {
+		t.Errorf("writes = %v, want the value written in one piece", cw.writes)
+	}
+}
+
+func TestScanGetResponseLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want types.Item
+		size int
+	}{
+		{"VALUE foo 7 3\r\n", types.Item{Key: "foo", Flags: 7}, 3},
+		{"VALUE foo 0 3 12\r\n", types.Item{Key: "foo", Casid: 12}, 3},
+		{"VALUE k 4294967295 0 18446744073709551615\r\n", types.Item{Key: "k", Flags: 1<<32 - 1, Casid: 1<<64 - 1}, 0},
+		{"VALUE foo 0 -1\r\n", types.Item{}, -1},
+		{"VALUE foo 4294967296 3\r\n", types.Item{}, -1},
+		{"VALUE foo 0 2147483648\r\n", types.Item{}, -1},
+		{"VALUE foo 0 3 12 1\r\n", types.Item{}, -1},
+		{"VALUE foo 0\r\n", types.Item{}, -1},
+		{"VALUE foo  0 3\r\n", types.Item{}, -1},
+		{"VALUE foo 0 3 \r\n", types.Item{}, -1},
+		{"VALUE foo 0 3\n", types.Item{}, -1},
+		{"VALUE foo 0 x\r\n", types.Item{}, -1},
+		{"VALUE \r 0 0\r\n", types.Item{}, -1},
+		{"STORED\r\n", types.Item{}, -1},
+	}
+	for _, tt := range tests {
+		var it types.Item
+		size, err := scanGetResponseLine([]byte(tt.line), &it)
+		if tt.size < 0 {
+			if err == nil {
+				t.Errorf("%q: got size %d, want an error", tt.line, size)
+			}
+			continue
+		}
+		if err != nil || size != tt.size || it.Key != tt.want.Key || it.Flags != tt.want.Flags || it.Casid != tt.want.Casid {
+			t.Errorf("%q: got %+v, %d, %v, want %+v, %d", tt.line, it, size, err, tt.want, tt.size)
+		}
+	}
+}
+
+func TestMetaGet(t *testing.T) {
+	for _, tt := range []struct {
+		resp, sent string
+		flags      []string
+		want       map[byte]string
+		err        bool
+	}{
+		{"HD c42 t-1\r\n", "mg k c t\r\n", []string{"c", "t"}, map[byte]string{'c': "42", 't': "-1"}, false},
+		{"HD\r\n", "mg k\r\n", nil, map[byte]string{}, false},
+		{"EN\r\n", "mg k c\r\n", []string{"c"}, nil, true},
+		{"ERROR\r\n", "mg k c\r\n", []string{"c"}, nil, true},
+	} {
+		var sent bytes.Buffer
+		rw := bufio.NewReadWriter(bufio.NewReader(bytes.NewBufferString(tt.resp)), bufio.NewWriter(&sent))
+		got, err := DefaultTextCommander.MetaGet(rw, "k", tt.flags...)
+		if sent.String() != tt.sent {
+			t.Errorf("MetaGet(%q) sent %q, want %q", tt.flags, sent.String(), tt.sent)
+		}
+		if (err != nil) != tt.err || len(got) != len(tt.want) {
+			t.Errorf("MetaGet on %q = %v, %v, want %v", tt.resp, got, err, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("MetaGet on %q = %v, want %v", tt.resp, got, tt.want)
+			}
+		}
+	}
+	rw := bufio.NewReadWriter(bufio.NewReader(bytes.NewBufferString("EN\r\n")), bufio.NewWriter(&bytes.Buffer{}))
+	if _, err := DefaultTextCommander.MetaGet(rw, "k"); err != types.ErrCacheMiss {
+		t.Errorf("MetaGet of a missing key error = %v, want ErrCacheMiss", err)
+	}
+}
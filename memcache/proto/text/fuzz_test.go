@@ -0,0 +1,53 @@
+package text
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/types"
+)
+
+func FuzzScanGetResponseLine(f *testing.F) {
+	f.Add([]byte("VALUE foo 0 3 12\r\n"))
+	f.Add([]byte("VALUE foo 1 3\r\n"))
+	f.Add([]byte("VALUE foo 0 -1\r\n"))
+	f.Add([]byte("VALUE foo  0 3\r\n"))
+	f.Add([]byte("VALUE foo 0 3 12 1\r\n"))
+	f.Add([]byte("VALUE \r 0 0\r\n"))
+	f.Fuzz(func(t *testing.T, line []byte) {
+		var it types.Item
+		size, err := scanGetResponseLine(line, &it)
+		if err != nil {
+			return
+		}
+		if size < 0 {
+			t.Fatalf("negative size %d accepted from %q", size, line)
+		}
+		if it.Key == "" || bytes.ContainsAny([]byte(it.Key), " \r\n") {
+			t.Fatalf("key %q accepted from %q", it.Key, line)
+		}
+	})
+}
+
+func FuzzParseGetResponse(f *testing.F) {
+	f.Add([]byte("VALUE foo 0 3 12\r\nbar\r\nEND\r\n"))
+	f.Add([]byte("VALUE foo 0 3\r\nbar\r\nVALUE baz 5 0\r\n\r\nEND\r\n"))
+	f.Add([]byte("VALUE foo 0 999999999999\r\nbar\r\n"))
+	f.Fuzz(func(t *testing.T, resp []byte) {
+		DefaultTextCommander.parseGetResponse(bufio.NewReader(bytes.NewReader(resp)), func(it *types.Item) {})
+	})
+}
+
+func FuzzIncrDecrResponse(f *testing.F) {
+	f.Add([]byte("42\r\n"))
+	f.Add([]byte("NOT_FOUND\r\n"))
+	f.Add([]byte("CLIENT_ERROR bad\r\n"))
+	f.Add([]byte("\n"))
+	f.Add([]byte("CLIENT_ERROR \n"))
+	f.Fuzz(func(t *testing.T, resp []byte) {
+		var out bytes.Buffer
+		rw := bufio.NewReadWriter(bufio.NewReader(bytes.NewReader(resp)), bufio.NewWriter(&out))
+		DefaultTextCommander.IncrDecr(rw, types.Incr, "k", 1)
+	})
+}
@@ -2,7 +2,6 @@ package text
 
 var (
 	crlf            = []byte("\r\n")
-	space           = []byte(" ")
 	resultOK        = []byte("OK\r\n")
 	resultStored    = []byte("STORED\r\n")
 	resultNotStored = []byte("NOT_STORED\r\n")
@@ -12,7 +11,11 @@ var (
 	resultEnd       = []byte("END\r\n")
 	resultOk        = []byte("OK\r\n")
 	resultTouched   = []byte("TOUCHED\r\n")
+	resultError     = []byte("ERROR\r\n")
 
 	resultClientErrorPrefix = []byte("CLIENT_ERROR ")
+	resultOutOfMemoryPrefix = []byte("SERVER_ERROR out of memory")
+	resultStatPrefix        = []byte("STAT ")
 	versionPrefix           = []byte("VERSION")
+	valuePrefix             = []byte("VALUE ")
 )
@@ -0,0 +1,44 @@
+package memcache
+
+import (
+	"bufio"
+
+	"github.com/skinass/gomemcache/memcache/types"
+)
+
+// concatCAS emulates append and prepend, for Config.ProxyCompat, with a
+// read followed by a compare-and-swap on rw, retried while other writers
+// race with it. The value is stored with the flags it had and the
+// expiration of item, since the server doesn't tell the one it had.
+func (c *Client) concatCAS(rw *bufio.ReadWriter, verb types.Verb, item *Item) error {
+	for i := 0; i < maxCASRetries; i++ {
+		var cur *Item
+		if err := c.cmdRunner.Get(rw, []string{item.Key}, func(it *Item) { cur = it }); err != nil {
+			return err
+		}
+		if cur == nil {
+			return ErrNotStored
+		}
+		value := make([]byte, 0, len(cur.Value)+len(item.Value))
+		if verb == types.Append {
+			value = append(append(value, cur.Value...), item.Value...)
+		} else {
+			value = append(append(value, item.Value...), cur.Value...)
+		}
+		err := c.cmdRunner.Populate(rw, types.Cas, &Item{
+			Key:        item.Key,
+			Value:      value,
+			Flags:      cur.Flags,
+			Expiration: item.Expiration,
+			Casid:      cur.Casid,
+		})
+		switch err {
+		case ErrCASConflict:
+			continue
+		case ErrCacheMiss:
+			return ErrNotStored
+		}
+		return err
+	}
+	return ErrCASConflict
+}
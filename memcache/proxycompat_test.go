@@ -0,0 +1,80 @@
+package memcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestProxyCompatAppend(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithProxyCompat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Append(&Item{Key: "log", Value: []byte("a")}); err != ErrNotStored {
+		t.Fatalf("Append on missing key error = %v, want ErrNotStored", err)
+	}
+	if err := c.Set(&Item{Key: "log", Value: []byte("b"), Flags: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Append(&Item{Key: "log", Value: []byte("c"), Flags: 1}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := c.Prepend(&Item{Key: "log", Value: []byte("a")}); err != nil {
+		t.Fatalf("Prepend: %v", err)
+	}
+	it, err := c.Get("log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(it.Value) != "abc" || it.Flags != 7 {
+		t.Errorf("value = %q with flags %d, want %q with flags 7", it.Value, it.Flags, "abc")
+	}
+}
+
+func TestProxyCompatAppendConcurrent(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithProxyCompat(), WithMaxIdleConns(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Set(&Item{Key: "log", Value: []byte{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- c.Append(&Item{Key: "log", Value: []byte(fmt.Sprint(i))})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	appended := 0
+	for err := range errs {
+		if err == nil {
+			appended++
+		} else if err != ErrCASConflict {
+			t.Errorf("Append: %v", err)
+		}
+	}
+	it, err := c.Get("log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(it.Value) != appended {
+		t.Errorf("value %q holds %d appends, want %d", it.Value, len(it.Value), appended)
+	}
+}
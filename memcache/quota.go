@@ -0,0 +1,123 @@
+package memcache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned for the writes and deletes refused by the
+// Quota of their key's profile.
+var ErrQuotaExceeded = errors.New("memcache: tenant quota exceeded")
+
+// Quota limits the writes of the keys of a profile, so that the teams
+// sharing a cluster through one client, each under its own key prefix,
+// can't crowd each other out. Writes, deletes and increments are charged
+// once per key, whatever the replicas of the profile.
+type Quota struct {
+	// Ops limits the rate of the operations, and Bytes the rate of the
+	// value bytes they store. As with Config.WriteLimit, operations over
+	// either limit wait if it has Block set, and otherwise fail, but with
+	// ErrQuotaExceeded.
+	Ops   RateLimit
+	Bytes RateLimit
+}
+
+// QuotaUsage reports the traffic of the keys of a profile.
+type QuotaUsage struct {
+	// Ops counts all the operations on the keys, and BytesWritten the
+	// value bytes the successful writes among them stored.
+	Ops          uint64
+	BytesWritten uint64
+
+	// Throttled counts the operations the Quota held back.
+	Throttled ThrottleStats
+}
+
+// tenant tracks the usage and quota of the keys of a profile.
+type tenant struct {
+	quota      Quota
+	ops, bytes tokenBucket
+
+	mu    sync.Mutex
+	usage QuotaUsage
+}
+
+// tenant returns the tracking of the profile of key, or nil if key has
+// no profile.
+func (c *Client) tenant(key string) *tenant {
+	prefix, ok := c.profilePrefixes.longest(key)
+	if !ok {
+		return nil
+	}
+	return c.tenants[prefix]
+}
+
+// countUsage accounts for ev in the usage of its key's profile.
+func (c *Client) countUsage(ev OpEvent) {
+	t := c.tenant(ev.Key)
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage.Ops++
+	if ev.Err == nil && ev.Size > 0 {
+		t.usage.BytesWritten += uint64(ev.Size)
+	}
+}
+
+// chargeQuota waits until the Quota of the profile of key, the caller's
+// key, allows ops operations storing size bytes, or returns
+// ErrQuotaExceeded if it doesn't.
+func (c *Client) chargeQuota(key string, ops, size int) error {
+	t := c.tenant(key)
+	if t == nil {
+		return nil
+	}
+	return t.charge(ops, size, c.now(), c.netTimeout())
+}
+
+func (t *tenant) charge(ops, size int, now time.Time, maxWait time.Duration) error {
+	var wait time.Duration
+	if t.quota.Ops.enabled() {
+		w, ok := t.ops.reserve(t.quota.Ops, ops, now, maxWait)
+		if !ok {
+			return ErrQuotaExceeded
+		}
+		wait = w
+	}
+	if t.quota.Bytes.enabled() && size > 0 {
+		w, ok := t.bytes.reserve(t.quota.Bytes, size, now, maxWait)
+		if !ok {
+			return ErrQuotaExceeded
+		}
+		if w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// QuotaUsage returns the usage of the keys of each profile, keyed by
+// prefix.
+func (c *Client) QuotaUsage() map[string]QuotaUsage {
+	m := make(map[string]QuotaUsage, len(c.tenants))
+	for prefix, t := range c.tenants {
+		t.mu.Lock()
+		u := t.usage
+		t.mu.Unlock()
+		for _, b := range []*tokenBucket{&t.ops, &t.bytes} {
+			b.mu.Lock()
+			u.Throttled.Delayed += b.stats.Delayed
+			u.Throttled.Delay += b.stats.Delay
+			u.Throttled.Rejected += b.stats.Rejected
+			b.mu.Unlock()
+		}
+		m[prefix] = u
+	}
+	return m
+}
@@ -0,0 +1,79 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestQuota(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()},
+		WithProfile("team-a:", Profile{Quota: Quota{Ops: RateLimit{PerSecond: 0.001, Burst: 2}}}),
+		WithProfile("team-b:", Profile{Quota: Quota{Bytes: RateLimit{PerSecond: 0.001, Burst: 10}}}),
+		WithProfile("team-c:", Profile{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.Set(&Item{Key: "team-a:k", Value: []byte("v")}); err != nil {
+			t.Fatalf("Set %d within the ops quota: %v", i, err)
+		}
+	}
+	if err := c.Delete("team-a:k"); err != ErrQuotaExceeded {
+		t.Errorf("Delete over the ops quota error = %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := c.Get("team-a:k"); err != nil {
+		t.Errorf("Get is charged to the quota: %v", err)
+	}
+
+	if err := c.Set(&Item{Key: "team-b:1", Value: []byte("12345678")}); err != nil {
+		t.Fatalf("Set within the bytes quota: %v", err)
+	}
+	err = c.SetMulti([]*Item{{Key: "team-b:2", Value: []byte("12345")}, {Key: "team-c:1", Value: []byte("free")}})
+	kerrs, ok := err.(KeyErrors)
+	if !ok || len(kerrs) != 1 || kerrs["team-b:2"] != ErrQuotaExceeded {
+		t.Errorf("SetMulti over the bytes quota error = %v, want ErrQuotaExceeded for team-b:2 only", err)
+	}
+
+	usage := c.QuotaUsage()
+	if u := usage["team-a:"]; u.Ops != 4 || u.BytesWritten != 2 || u.Throttled.Rejected != 1 {
+		t.Errorf("team-a: usage = %+v, want 4 ops, 2 bytes, 1 rejection", u)
+	}
+	if u := usage["team-b:"]; u.Ops != 2 || u.BytesWritten != 8 || u.Throttled.Rejected != 1 {
+		t.Errorf("team-b: usage = %+v, want 2 ops, 8 bytes, 1 rejection", u)
+	}
+	if u := usage["team-c:"]; u.Ops != 1 || u.BytesWritten != 4 {
+		t.Errorf("team-c: usage = %+v, want 1 op, 4 bytes", u)
+	}
+}
+
+func TestQuotaClock(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	clock := memcachetest.NewFakeClock(time.Now())
+	c, err := NewClient([]string{s.Addr()}, WithClock(clock),
+		WithProfile("team-a:", Profile{Quota: Quota{Ops: RateLimit{PerSecond: 1}}}),
+		WithProfile("team-a:batch:", Profile{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	set := func(key string) error { return c.Set(&Item{Key: key, Value: []byte("v")}) }
+	if err := set("team-a:k"); err != nil {
+		t.Fatalf("Set within the quota: %v", err)
+	}
+	if err := set("team-a:k"); err != ErrQuotaExceeded {
+		t.Errorf("Set over the quota error = %v, want ErrQuotaExceeded", err)
+	}
+	if err := set("team-a:batch:k"); err != nil {
+		t.Errorf("Set under a longer prefix without a quota: %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := set("team-a:k"); err != nil {
+		t.Errorf("Set once the client's clock refilled the quota: %v", err)
+	}
+}
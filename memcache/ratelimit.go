@@ -0,0 +1,126 @@
+package memcache
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrThrottled is returned for the writes and deletes Config.WriteLimit
+// refuses.
+var ErrThrottled = errors.New("memcache: write rate limit exceeded")
+
+// RateLimit is a token-bucket limit on the rate of operations.
+type RateLimit struct {
+	PerSecond float64 // sustained operations per second; zero disables the limit
+	Burst     int     // operations allowed at once after a quiet period; zero means 1
+
+	// Block makes operations over the limit wait for it to allow them,
+	// failing with ErrThrottled only if that would take longer than the
+	// client's Timeout. Otherwise they fail with ErrThrottled at once.
+	Block bool
+}
+
+func (l RateLimit) enabled() bool { return l.PerSecond > 0 }
+
+func (l RateLimit) burst() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+// ThrottleStats counts the operations Config.WriteLimit held back for a
+// server.
+type ThrottleStats struct {
+	Delayed  int64         // operations that waited for the limit
+	Delay    time.Duration // the total time they waited
+	Rejected int64         // operations failed with ErrThrottled
+}
+
+// tokenBucket limits the writes to a server.
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	stats  ThrottleStats
+}
+
+// reserve takes n tokens from b at now, going into debt if it has fewer
+// but at least as many as it can hold, and returns how long the caller
+// must wait for them. It takes nothing and returns false if the caller
+// may not wait that long.
+func (b *tokenBucket) reserve(l RateLimit, n int, now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	burst := l.burst()
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else if b.tokens += now.Sub(b.last).Seconds() * l.PerSecond; b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	need := float64(n)
+	if need > burst {
+		// Batches larger than the bucket pass once it is full, and pay
+		// for the excess afterwards.
+		need = burst
+	}
+	var wait time.Duration
+	if b.tokens < need {
+		wait = time.Duration((need - b.tokens) / l.PerSecond * float64(time.Second))
+	}
+	if wait > 0 && (!l.Block || wait > maxWait) {
+		b.stats.Rejected++
+		return 0, false
+	}
+	b.tokens -= float64(n)
+	if wait > 0 {
+		b.stats.Delayed++
+		b.stats.Delay += wait
+	}
+	return wait, true
+}
+
+// throttle waits until Config.WriteLimit allows n writes to addr, or
+// returns ErrThrottled if it doesn't.
+func (c *Client) throttle(addr net.Addr, n int) error {
+	if !c.writeLimit.enabled() {
+		return nil
+	}
+	c.lk.Lock()
+	if c.limiters == nil {
+		c.limiters = make(map[string]*tokenBucket)
+	}
+	b := c.limiters[addr.String()]
+	if b == nil {
+		b = new(tokenBucket)
+		c.limiters[addr.String()] = b
+	}
+	c.lk.Unlock()
+
+	wait, ok := b.reserve(c.writeLimit, n, time.Now(), c.netTimeout())
+	if !ok {
+		return ErrThrottled
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// ThrottleStats returns the statistics of Config.WriteLimit for each
+// server it applied to, keyed by server address.
+func (c *Client) ThrottleStats() map[string]ThrottleStats {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	stats := make(map[string]ThrottleStats, len(c.limiters))
+	for addr, b := range c.limiters {
+		b.mu.Lock()
+		stats[addr] = b.stats
+		b.mu.Unlock()
+	}
+	return stats
+}
@@ -0,0 +1,71 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestWriteLimitFailFast(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithWriteLimit(RateLimit{PerSecond: 1, Burst: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+			t.Fatalf("write %d within the burst: %v", i, err)
+		}
+	}
+	if err := c.Delete("k"); err != ErrThrottled {
+		t.Errorf("write over the limit error = %v, want ErrThrottled", err)
+	}
+	if _, err := c.Get("k"); err != nil {
+		t.Errorf("read was throttled: %v", err)
+	}
+	err = c.SetMulti([]*Item{{Key: "a"}, {Key: "b"}})
+	if kerrs, ok := err.(KeyErrors); !ok || kerrs["a"] != ErrThrottled {
+		t.Errorf("SetMulti over the limit error = %v, want ErrThrottled for each key", err)
+	}
+	if st := c.ThrottleStats()[s.Addr()]; st.Rejected != 2 {
+		t.Errorf("stats = %+v, want 2 rejected", st)
+	}
+}
+
+func TestWriteLimitBlock(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithWriteLimit(RateLimit{PerSecond: 50, Burst: 1, Block: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 35*time.Millisecond {
+		t.Errorf("3 writes at 50/s took %v, want about 40ms", d)
+	}
+	if st := c.ThrottleStats()[s.Addr()]; st.Delayed != 2 || st.Rejected != 0 {
+		t.Errorf("stats = %+v, want 2 delayed", st)
+	}
+
+	slow, err := NewClient([]string{s.Addr()}, WithTimeout(10*time.Millisecond),
+		WithWriteLimit(RateLimit{PerSecond: 1, Block: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	slow.Set(&Item{Key: "k"})
+	if err := slow.Set(&Item{Key: "k"}); err != ErrThrottled {
+		t.Errorf("write that would wait past Timeout error = %v, want ErrThrottled", err)
+	}
+}
@@ -0,0 +1,100 @@
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+
+	"github.com/skinass/gomemcache/memcache/types"
+)
+
+// RawResponse is a response packet of the binary protocol, as returned by
+// RawBinary.
+type RawResponse = types.RawResponse
+
+// rawTexter and rawBinarier are implemented by the commanders of the text
+// and binary protocols, for RawText and RawBinary.
+type rawTexter interface {
+	RawText(rw *bufio.ReadWriter, cmd string) ([]byte, error)
+}
+
+type rawBinarier interface {
+	RawBinary(rw *bufio.ReadWriter, op uint8, key string, extras, value []byte) ([]*types.RawResponse, error)
+}
+
+// RawText sends cmd, a command of the text protocol the client doesn't
+// model, such as one of a newer server or a vendor extension, to the
+// server at addr and returns its response as is, error lines included.
+// A storage command carries its data block after its own "\r\n"; the
+// final "\r\n" is added. The response is read up to its end as guessed
+// from the shapes memcached uses, so commands sent with noreply time out.
+//
+// Raw commands run on a connection of their own, dialed for them and
+// closed afterwards, so that one the client misreads can't desynchronize
+// the connections of other operations. They bypass the key handling of
+// the client, but a Policy may deny the "raw" op. RawText fails with a
+// *NotSupportedError unless the client speaks the text protocol.
+func (c *Client) RawText(addr, cmd string) ([]byte, error) {
+	rt, ok := c.cmdRunner.(rawTexter)
+	if !ok {
+		return nil, c.notSupported("raw", nil)
+	}
+	var resp []byte
+	err := c.withRawConn(addr, func(rw *bufio.ReadWriter) (err error) {
+		resp, err = rt.RawText(rw, cmd)
+		return err
+	})
+	return resp, err
+}
+
+// RawBinary sends a binary protocol request for op, with the given key,
+// extras and value, to the server at addr, and returns the response
+// packets, statuses included: none for a quiet op that succeeded, and
+// several for one such as stat. It runs like RawText, and fails with a
+// *NotSupportedError unless the client speaks the binary protocol.
+func (c *Client) RawBinary(addr string, op uint8, key string, extras, value []byte) ([]*RawResponse, error) {
+	rb, ok := c.cmdRunner.(rawBinarier)
+	if !ok {
+		return nil, c.notSupported("raw", nil)
+	}
+	var resps []*RawResponse
+	err := c.withRawConn(addr, func(rw *bufio.ReadWriter) (err error) {
+		resps, err = rb.RawBinary(rw, op, key, extras, value)
+		return err
+	})
+	return resps, err
+}
+
+// withRawConn runs fn on a new connection to the server at addr, closed
+// once fn returns.
+func (c *Client) withRawConn(addr string, fn func(*bufio.ReadWriter) error) error {
+	if _, err := c.prepareKey("raw", ""); err != nil {
+		return err
+	}
+	server, err := c.serverAddr(addr)
+	if err != nil {
+		return err
+	}
+	cn, err := c.getConn(server, true)
+	if err != nil {
+		return err
+	}
+	defer cn.discard()
+	cn.extendDeadline()
+	return c.timeoutError(server, "", fn(cn.rw))
+}
+
+// serverAddr returns the address of the client's server addr.
+func (c *Client) serverAddr(addr string) (net.Addr, error) {
+	var server net.Addr
+	c.eachServer(func(a net.Addr) error {
+		if a.String() == addr {
+			server = a
+		}
+		return nil
+	})
+	if server == nil {
+		return nil, fmt.Errorf("memcache: %s is not a server of the client", addr)
+	}
+	return server, nil
+}
@@ -0,0 +1,84 @@
+package memcache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestRawText(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	for _, tt := range []struct{ cmd, want string }{
+		{"set k 0 0 1\r\nv", "STORED\r\n"},
+		{"get k", "VALUE k 0 1\r\nv\r\nEND\r\n"},
+		{"get nope", "END\r\n"},
+		{"bogus", "ERROR\r\n"},
+	} {
+		resp, err := c.RawText(s.Addr(), tt.cmd)
+		if err != nil || string(resp) != tt.want {
+			t.Errorf("RawText(%q) = %q, %v, want %q", tt.cmd, resp, err, tt.want)
+		}
+	}
+	resp, err := c.RawText(s.Addr(), "stats")
+	if err != nil || !strings.HasPrefix(string(resp), "STAT ") || !strings.HasSuffix(string(resp), "END\r\n") {
+		t.Errorf("RawText(stats) = %q, %v, want the whole list", resp, err)
+	}
+	waitFor(t, "the raw connections to be closed", func() bool { return s.Conns() == 0 })
+	if st := c.PoolStats()[s.Addr()]; st.OpenConns != 0 {
+		t.Errorf("%d connections open after raw commands, want none", st.OpenConns)
+	}
+
+	if _, err := c.RawText("127.0.0.1:1", "version"); err == nil {
+		t.Error("RawText to a server not of the client succeeded")
+	}
+	if _, err := NewBinary(s.Addr()).RawText(s.Addr(), "version"); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("RawText on the binary protocol error = %v, want ErrUnknownCommand", err)
+	}
+	denied, err := NewClient([]string{s.Addr()}, WithPolicy(DenyOps("raw")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := denied.RawText(s.Addr(), "version"); err != ErrDenied {
+		t.Errorf("RawText under a policy denying it error = %v, want ErrDenied", err)
+	}
+}
+
+func TestRawBinary(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := NewBinary(s.Addr())
+	const opGet, opSet, opSetQ = 0x00, 0x01, 0x11
+	extras := make([]byte, 8) // flags and expiration
+
+	resps, err := c.RawBinary(s.Addr(), opSet, "k", extras, []byte("v"))
+	if err != nil || len(resps) != 1 || resps[0].Status != 0 || resps[0].CAS == 0 {
+		t.Fatalf("RawBinary(set) = %+v, %v, want one successful response", resps, err)
+	}
+	if resps, err := c.RawBinary(s.Addr(), opSetQ, "q", extras, []byte("v")); err != nil || len(resps) != 0 {
+		t.Errorf("RawBinary(setq) = %+v, %v, want no response", resps, err)
+	}
+	resps, err = c.RawBinary(s.Addr(), opGet, "k", nil, nil)
+	if err != nil || len(resps) != 1 || string(resps[0].Value) != "v" || len(resps[0].Extras) != 4 {
+		t.Errorf("RawBinary(get) = %+v, %v, want the value and its flags", resps, err)
+	}
+	resps, err = c.RawBinary(s.Addr(), opGet, "nope", nil, nil)
+	if err != nil || len(resps) != 1 || resps[0].Status != 1 {
+		t.Errorf("RawBinary(get) of a missing key = %+v, %v, want the not found status", resps, err)
+	}
+	if _, err := c.RawBinary(s.Addr(), opSet, "k", make([]byte, 300), []byte("v")); err == nil {
+		t.Error("RawBinary with 300 bytes of extras succeeded")
+	}
+	if _, err := c.RawBinary(s.Addr(), opGet, strings.Repeat("k", 1<<16), nil, nil); err == nil {
+		t.Error("RawBinary with a 64KiB key succeeded")
+	}
+	if _, err := c.Get("q"); err != nil {
+		t.Errorf("Get of the quietly set key: %v", err)
+	}
+	if _, err := New(s.Addr()).RawBinary(s.Addr(), opGet, "k", nil, nil); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("RawBinary on the text protocol error = %v, want ErrUnknownCommand", err)
+	}
+}
@@ -0,0 +1,64 @@
+package memcache
+
+import (
+	"bufio"
+	"net"
+	"time"
+)
+
+// A Reencoder migrates stored values to a new encoding as they are read,
+// such as while rolling out compression or encryption: the values Stale
+// reports as written in an old format are returned as they are, and
+// rewritten in the background with Reencode. The application must
+// therefore keep reading both formats until the migration is over.
+//
+// Rewrites are compare-and-swaps, so that they never clobber a value
+// stored since it was read, and count against Config.WriteLimit. Their
+// failures are ignored: the value is tried again on a later read.
+type Reencoder struct {
+	// Stale reports whether a value stored with flags is in an old
+	// format.
+	Stale func(flags uint32) bool
+
+	// Reencode returns value, stored with flags, in the new format with
+	// its flags.
+	Reencode func(value []byte, flags uint32) ([]byte, uint32, error)
+
+	// TTL is the expiration of the rewritten items, since the server
+	// doesn't tell how long the originals had left. Zero means they
+	// don't expire.
+	TTL time.Duration
+}
+
+func (r Reencoder) enabled() bool { return r.Stale != nil }
+
+func (r Reencoder) validate() error {
+	switch {
+	case (r.Stale == nil) != (r.Reencode == nil):
+		return configError("Reencoder needs both Stale and Reencode")
+	case r.TTL < 0:
+		return configError("negative Reencoder.TTL %v", r.TTL)
+	}
+	return nil
+}
+
+// reencode rewrites it, fetched from addr under the key as sent, in the
+// background if Config.Reencoder reports it as stale.
+func (c *Client) reencode(addr net.Addr, it *Item) {
+	r := c.reencoder
+	if !r.enabled() || !r.Stale(it.Flags) || it.Casid == 0 {
+		return
+	}
+	// The value may be a borrowed buffer, released before the rewrite.
+	old := &Item{Key: it.Key, Value: append([]byte(nil), it.Value...), Flags: it.Flags, Casid: it.Casid}
+	c.goFunc(func() {
+		value, flags, err := r.Reencode(old.Value, old.Flags)
+		if err != nil || c.throttle(addr, 1) != nil {
+			return
+		}
+		item := &Item{Key: old.Key, Value: value, Flags: flags, Casid: old.Casid, Expiration: Expiration(r.TTL, c.now())}
+		c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+			return c.cas(rw, item)
+		})
+	})
+}
@@ -0,0 +1,57 @@
+package memcache
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestReencoder(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	const upper = 1 // the flag of the new format
+	c, err := NewClient([]string{s.Addr()}, WithReencoder(Reencoder{
+		Stale: func(flags uint32) bool { return flags&upper == 0 },
+		Reencode: func(value []byte, flags uint32) ([]byte, uint32, error) {
+			return bytes.ToUpper(value), flags | upper, nil
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Set(&Item{Key: "old", Value: []byte("abc")}); err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := c.Get("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(it.Value) != "abc" || it.Flags != 0 {
+		t.Errorf("Get of a stale value = %q with flags %d, want it as stored", it.Value, it.Flags)
+	}
+	direct := New(s.Addr())
+	defer direct.Close()
+	waitFor(t, "the stale value to be rewritten", func() bool {
+		it, err := direct.Get("old")
+		return err == nil && string(it.Value) == "ABC" && it.Flags == upper
+	})
+
+	// A current value is left alone.
+	if err := c.Set(&Item{Key: "new", Value: []byte("xyz"), Flags: upper}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("new"); err != nil {
+		t.Fatal(err)
+	}
+	if it, err := direct.Get("new"); err != nil || string(it.Value) != "xyz" {
+		t.Errorf("current value after Get = %v, %v, want it unchanged", it, err)
+	}
+
+	stale := func(uint32) bool { return true }
+	if _, err := NewClient([]string{s.Addr()}, WithReencoder(Reencoder{Stale: stale})); err == nil {
+		t.Error("NewClient accepted a Reencoder without Reencode")
+	}
+}
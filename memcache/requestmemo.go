@@ -0,0 +1,107 @@
+package memcache
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+type requestMemoKey struct{}
+
+// requestMemo holds the results of the Gets made within one request, per
+// client since the clients of different clusters may share keys. A nil
+// item records a miss.
+type requestMemo struct {
+	prefixes []string
+
+	mu    sync.Mutex
+	items map[*Client]map[string]*Item
+}
+
+// WithRequestMemo returns a context under which GetContext and
+// GetMultiContext remember their results, so that repeated Gets of the
+// same key within one request, as in template rendering, are answered
+// without another round trip. Hits and misses are both remembered; errors
+// are not. If prefixes are given, only the keys starting with one of them
+// are remembered.
+//
+// The memo lives as long as the context and is not updated by the
+// client's writes, which do not see the context: call ForgetMemoized
+// after changing a key that the same request reads again.
+func WithRequestMemo(ctx context.Context, prefixes ...string) context.Context {
+	return context.WithValue(ctx, requestMemoKey{}, &requestMemo{
+		prefixes: append([]string(nil), prefixes...),
+		items:    make(map[*Client]map[string]*Item),
+	})
+}
+
+// ForgetMemoized drops keys from the request memo of ctx, if any, so that
+// the next Get of them within the request reaches memcache again.
+func ForgetMemoized(ctx context.Context, keys ...string) {
+	m := requestMemoFrom(ctx)
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, items := range m.items {
+		for _, key := range keys {
+			delete(items, key)
+		}
+	}
+}
+
+func requestMemoFrom(ctx context.Context) *requestMemo {
+	m, _ := ctx.Value(requestMemoKey{}).(*requestMemo)
+	return m
+}
+
+func (m *requestMemo) covers(key string) bool {
+	if len(m.prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns a copy of the remembered result for key, nil for a miss,
+// and whether there was one.
+func (m *requestMemo) get(c *Client, key string) (*Item, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[c][key]
+	if ok && it != nil {
+		it = copyItem(it)
+	}
+	return it, ok
+}
+
+// put remembers a copy of it, or a miss if it is nil, as the result for
+// key.
+func (m *requestMemo) put(c *Client, key string, it *Item) {
+	if !m.covers(key) {
+		return
+	}
+	if it != nil {
+		it = copyItem(it)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := m.items[c]
+	if items == nil {
+		items = make(map[string]*Item)
+		m.items[c] = items
+	}
+	items[key] = it
+}
+
+// copyItem returns a copy of it whose value the caller may modify.
+func copyItem(it *Item) *Item {
+	cp := *it
+	cp.Value = append([]byte(nil), it.Value...)
+	return &cp
+}
@@ -0,0 +1,74 @@
+package memcache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestRequestMemo(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	var gets int32
+	c.AddHook(func(ev OpEvent) {
+		if ev.Op == "get" {
+			atomic.AddInt32(&gets, 1)
+		}
+	})
+	c.Set(&Item{Key: "tpl:a", Value: []byte("a")})
+	c.Set(&Item{Key: "tpl:b", Value: []byte("b")})
+	c.Set(&Item{Key: "live:c", Value: []byte("c")})
+
+	ctx := WithRequestMemo(context.Background(), "tpl:")
+	for i := 0; i < 3; i++ {
+		it, err := c.GetContext(ctx, "tpl:a")
+		if err != nil || string(it.Value) != "a" {
+			t.Fatalf("GetContext = %v, %v", it, err)
+		}
+		it.Value[0] = 'x' // must not leak into the memo
+		if _, err := c.GetContext(ctx, "tpl:missing"); err != ErrCacheMiss {
+			t.Fatalf("GetContext(missing) error = %v, want ErrCacheMiss", err)
+		}
+		if _, err := c.GetContext(ctx, "live:c"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&gets); n != 5 {
+		t.Errorf("server saw %d gets, want 5 (one per memoized key, three for live:c)", n)
+	}
+
+	atomic.StoreInt32(&gets, 0)
+	m, err := c.GetMultiContext(ctx, []string{"tpl:a", "tpl:b", "tpl:missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || string(m["tpl:a"].Value) != "a" || string(m["tpl:b"].Value) != "b" {
+		t.Errorf("GetMultiContext = %v", m)
+	}
+	if n := atomic.LoadInt32(&gets); n != 1 {
+		t.Errorf("GetMultiContext fetched %d keys, want only tpl:b", n)
+	}
+	if _, err := c.GetContext(ctx, "tpl:b"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&gets); n != 1 {
+		t.Errorf("tpl:b was fetched again after GetMultiContext")
+	}
+
+	c.Set(&Item{Key: "tpl:a", Value: []byte("new")})
+	if it, _ := c.GetContext(ctx, "tpl:a"); string(it.Value) != "a" {
+		t.Errorf("memo answered %q, want the remembered value", it.Value)
+	}
+	ForgetMemoized(ctx, "tpl:a")
+	if it, _ := c.GetContext(ctx, "tpl:a"); string(it.Value) != "new" {
+		t.Errorf("after ForgetMemoized got %q, want new", it.Value)
+	}
+
+	// Another request starts afresh.
+	if it, _ := c.GetContext(WithRequestMemo(context.Background()), "tpl:a"); string(it.Value) != "new" {
+		t.Errorf("fresh request got %q", it.Value)
+	}
+}
@@ -39,6 +39,9 @@ type ServerSelector interface {
 type ServerList struct {
 	mu    sync.RWMutex
 	addrs []net.Addr
+
+	// hash, if set, replaces the default CRC-32 key hash.
+	hash func(key string) uint32
 }
 
 // staticAddr caches the Network() and String() values from any net.Addr.
@@ -65,6 +68,11 @@ func (s *staticAddr) String() string  { return s.str }
 // SetServers returns an error if any of the server names fail to
 // resolve. No attempt is made to connect to the server. If any error
 // is returned, no changes are made to the ServerList.
+//
+// IPv6 literals must be bracketed, as in "[::1]:11211". Host names are
+// kept, and resolved again on each dial, so that connections to dual-stack
+// hosts try all their addresses with RFC 6555 fallback rather than only
+// the address found here.
 func (ss *ServerList) SetServers(servers ...string) error {
 	naddr := make([]net.Addr, len(servers))
 	for i, server := range servers {
@@ -80,6 +88,9 @@ func (ss *ServerList) SetServers(servers ...string) error {
 				return err
 			}
 			naddr[i] = newStaticAddr(tcpaddr)
+			if host, _, _ := net.SplitHostPort(server); !isIPLiteral(host) {
+				naddr[i] = &staticAddr{ntw: tcpaddr.Network(), str: server}
+			}
 		}
 	}
 
@@ -89,6 +100,15 @@ func (ss *ServerList) SetServers(servers ...string) error {
 	return nil
 }
 
+// isIPLiteral reports whether host is an IP address, possibly with an IPv6
+// zone, rather than a name.
+func isIPLiteral(host string) bool {
+	if i := strings.LastIndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host) != nil
+}
+
 // Each iterates over each server calling the given function
 func (ss *ServerList) Each(f func(net.Addr) error) error {
 	ss.mu.RLock()
@@ -115,15 +135,22 @@ func (ss *ServerList) PickServer(key string) (net.Addr, error) {
 	ss.mu.RLock()
 	defer ss.mu.RUnlock()
 	if len(ss.addrs) == 0 {
-		return nil, ErrNoServers
+		return nil, &NoServersError{}
 	}
 	if len(ss.addrs) == 1 {
 		return ss.addrs[0], nil
 	}
+	return ss.addrs[ss.hashKey(key)%uint32(len(ss.addrs))], nil
+}
+
+// hashKey returns the hash of key that PickServer maps to a server.
+func (ss *ServerList) hashKey(key string) uint32 {
+	if ss.hash != nil {
+		return ss.hash(key)
+	}
 	bufp := keyBufPool.Get().(*[]byte)
 	n := copy(*bufp, key)
 	cs := crc32.ChecksumIEEE((*bufp)[:n])
 	keyBufPool.Put(bufp)
-
-	return ss.addrs[cs%uint32(len(ss.addrs))], nil
+	return cs
 }
@@ -16,7 +16,12 @@ limitations under the License.
 
 package memcache
 
-import "testing"
+import (
+	"net"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
 
 func BenchmarkPickServer(b *testing.B) {
 	// at least two to avoid 0 and 1 special cases:
@@ -37,3 +42,45 @@ func benchPickServer(b *testing.B, servers ...string) {
 		}
 	}
 }
+
+func TestSetServersAddrs(t *testing.T) {
+	tests := []struct{ server, want string }{
+		{"127.0.0.1:11211", "127.0.0.1:11211"},
+		{"[::1]:11211", "[::1]:11211"},
+		{"[0:0::1]:11211", "[::1]:11211"},
+		{"[fe80::1%lo]:11211", "[fe80::1%lo]:11211"},
+		{"localhost:11211", "localhost:11211"},
+	}
+	for _, tt := range tests {
+		var ss ServerList
+		if err := ss.SetServers(tt.server); err != nil {
+			t.Errorf("SetServers(%q): %v", tt.server, err)
+			continue
+		}
+		addr, _ := ss.PickServer("k")
+		if addr.String() != tt.want || addr.Network() != "tcp" {
+			t.Errorf("SetServers(%q) address = %s %q, want tcp %q", tt.server, addr.Network(), addr, tt.want)
+		}
+	}
+	for _, server := range []string{"::1", "::1:11211", "[::1]"} {
+		var ss ServerList
+		if err := ss.SetServers(server); err == nil {
+			t.Errorf("SetServers(%q) succeeded", server)
+		}
+	}
+}
+
+func TestDialHostName(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	_, port, err := net.SplitHostPort(s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// localhost may resolve to ::1 first, where nothing listens.
+	c := New(net.JoinHostPort("localhost", port))
+	defer c.Close()
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Errorf("Set through a host name: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Package session implements a session store backed by memcached.
+//
+// Store offers Get/Save/Destroy for callers managing session state
+// directly, as well as Find/Commit/Delete, which matches the store
+// interface used by scs and similar session managers.
+package session
+
+import (
+	"time"
+
+	"github.com/skinass/gomemcache/memcache"
+)
+
+// Store keeps sessions in memcached under its key prefix followed by the
+// session ID.
+// It is safe for concurrent use by multiple goroutines.
+type Store struct {
+	client *memcache.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewStore returns a Store saving sessions through c with the given key
+// prefix. Sessions saved with Save expire after ttl; zero means they
+// never expire.
+func NewStore(c *memcache.Client, prefix string, ttl time.Duration) *Store {
+	return &Store{client: c, prefix: prefix, ttl: ttl}
+}
+
+// Session is the data of one session. Sessions returned by Get remember
+// the version they were loaded at, so that Save can detect concurrent
+// modification.
+type Session struct {
+	ID   string
+	Data []byte
+
+	casid uint64
+	saved bool
+}
+
+// New returns an empty session with the given ID, not yet saved.
+func (s *Store) New(id string) *Session {
+	return &Session{ID: id}
+}
+
+// Get loads the session with the given ID. memcache.ErrCacheMiss is
+// returned if there is no such session or it has expired.
+func (s *Store) Get(id string) (*Session, error) {
+	it, err := s.client.Get(s.prefix + id)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{ID: id, Data: it.Value, casid: it.Casid}, nil
+}
+
+// Save stores sess and refreshes its expiration. A session obtained from
+// Get is only written if nobody saved or destroyed it in the meantime;
+// otherwise memcache.ErrCASConflict or memcache.ErrNotStored is
+// returned and the caller should reload it. A session from New is only
+// written if the ID is not taken yet, returning memcache.ErrNotStored
+// if it is.
+//
+// Saving changes the session's version on the server, so a session must
+// be reloaded with Get before it can be saved again; saving it twice
+// returns memcache.ErrCASConflict.
+func (s *Store) Save(sess *Session) error {
+	if sess.saved {
+		return memcache.ErrCASConflict
+	}
+	it := &memcache.Item{
+		Key:        s.prefix + sess.ID,
+		Value:      sess.Data,
+		Expiration: memcache.Expiration(s.ttl, time.Now()),
+		Casid:      sess.casid,
+	}
+	var err error
+	if sess.casid == 0 {
+		err = s.client.Add(it)
+	} else {
+		err = s.client.CompareAndSwap(it)
+	}
+	if err == nil {
+		sess.saved = true
+	}
+	return err
+}
+
+// Destroy removes the session with the given ID. Destroying a session
+// that doesn't exist is not an error.
+func (s *Store) Destroy(id string) error {
+	err := s.client.Delete(s.prefix + id)
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
+
+// Find returns the data for the session token. The exists result is
+// false, with no error, if the session doesn't exist or has expired.
+func (s *Store) Find(token string) (b []byte, exists bool, err error) {
+	sess, err := s.Get(token)
+	if err == memcache.ErrCacheMiss {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return sess.Data, true, nil
+}
+
+// Commit unconditionally stores b as the data for the session token,
+// expiring at the given time.
+func (s *Store) Commit(token string, b []byte, expiry time.Time) error {
+	now := time.Now()
+	if !expiry.After(now) {
+		return s.Destroy(token)
+	}
+	return s.client.Set(&memcache.Item{
+		Key:        s.prefix + token,
+		Value:      b,
+		Expiration: memcache.Expiration(expiry.Sub(now), now),
+	})
+}
+
+// Delete removes the session token. It is equivalent to Destroy.
+func (s *Store) Delete(token string) error {
+	return s.Destroy(token)
+}
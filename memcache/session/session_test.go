@@ -0,0 +1,78 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache"
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestStore(t *testing.T) {
+	srv := memcachetest.NewServer(t)
+	defer srv.Close()
+	st := NewStore(memcache.New(srv.Addr()), "sess:", time.Hour)
+
+	sess := st.New("abc")
+	sess.Data = []byte("v1")
+	if err := st.Save(sess); err != nil {
+		t.Fatalf("Save(new): %v", err)
+	}
+	if err := st.Save(st.New("abc")); err != memcache.ErrNotStored {
+		t.Fatalf("Save(new, taken ID): got %v, want ErrNotStored", err)
+	}
+
+	a, err := st.Get("abc")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	b, err := st.Get("abc")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	a.Data = []byte("v2")
+	if err := st.Save(a); err != nil {
+		t.Fatalf("Save(a): %v", err)
+	}
+	b.Data = []byte("v3")
+	if err := st.Save(b); err != memcache.ErrCASConflict {
+		t.Fatalf("Save(stale b): got %v, want ErrCASConflict", err)
+	}
+	if err := st.Save(a); err != memcache.ErrCASConflict {
+		t.Fatalf("Save(a) twice: got %v, want ErrCASConflict", err)
+	}
+
+	data, ok, err := st.Find("abc")
+	if err != nil || !ok || string(data) != "v2" {
+		t.Fatalf("Find = %q, %v, %v; want v2, true, nil", data, ok, err)
+	}
+
+	if err := st.Destroy("abc"); err != nil {
+		t.Fatalf("Destroy: %v", err)
+	}
+	if err := st.Destroy("abc"); err != nil {
+		t.Fatalf("Destroy(missing): %v", err)
+	}
+	if _, ok, err := st.Find("abc"); ok || err != nil {
+		t.Fatalf("Find after Destroy = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	srv := memcachetest.NewServer(t)
+	defer srv.Close()
+	st := NewStore(memcache.New(srv.Addr()), "sess:", 0)
+
+	if err := st.Commit("tok", []byte("data"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if data, ok, _ := st.Find("tok"); !ok || string(data) != "data" {
+		t.Fatalf("Find = %q, %v; want data, true", data, ok)
+	}
+	if err := st.Commit("tok", []byte("data"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Commit(expired): %v", err)
+	}
+	if _, ok, _ := st.Find("tok"); ok {
+		t.Fatal("session committed with a past expiry is still present")
+	}
+}
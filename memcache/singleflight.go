@@ -0,0 +1,87 @@
+package memcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// flightGroup deduplicates concurrent calls that share a key: while a call
+// for a key is in flight, further callers wait for and share its result.
+// Its zero value is usable.
+type flightGroup struct {
+	mu sync.Mutex
+	m  map[string]*flight
+
+	running int32 // calls in flight, read atomically
+}
+
+type flight struct {
+	done chan struct{}
+	val  []byte
+	err  error
+}
+
+// do starts fn for key unless a call for key is already in flight, and
+// returns the in-flight call. Its result is available once done is closed.
+func (g *flightGroup) do(key string, fn func() ([]byte, error)) *flight {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*flight)
+	}
+	if f, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		return f
+	}
+	f := &flight{done: make(chan struct{})}
+	g.m[key] = f
+	g.mu.Unlock()
+
+	atomic.AddInt32(&g.running, 1)
+	go func() {
+		defer atomic.AddInt32(&g.running, -1)
+		f.val, f.err = fn()
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	return f
+}
+
+// abandonedError wraps the error of a call that failed once the context
+// of the caller that started it was done.
+type abandonedError struct {
+	err error
+}
+
+func (e abandonedError) Error() string { return e.err.Error() }
+
+// doContext runs fn as do does, passing it the context of the caller that
+// starts the call, and waits for the result or for ctx to be done. The
+// callers that joined a call which failed after the context of its
+// starter was done don't share that failure: while their own context is
+// live, they start or join another call.
+func (g *flightGroup) doContext(ctx context.Context, key string, fn func(context.Context) ([]byte, error)) ([]byte, error) {
+	for {
+		f := g.do(key, func() ([]byte, error) {
+			val, err := fn(ctx)
+			if err != nil && ctx.Err() != nil {
+				err = abandonedError{err}
+			}
+			return val, err
+		})
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if ae, ok := f.err.(abandonedError); ok {
+			if ctx.Err() == nil {
+				continue
+			}
+			return nil, ae.err
+		}
+		return f.val, f.err
+	}
+}
@@ -0,0 +1,193 @@
+package memcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"time"
+)
+
+// Flags of spymemcached's SerializingTranscoder.
+const (
+	spyFlagSerialized = 1
+	spyFlagCompressed = 2
+	spySpecialMask    = 0xff00
+
+	spyBoolean   = 1 << 8
+	spyInt       = 2 << 8
+	spyLong      = 3 << 8
+	spyDate      = 4 << 8
+	spyByte      = 5 << 8
+	spyFloat     = 6 << 8
+	spyDouble    = 7 << 8
+	spyByteArray = 8 << 8
+)
+
+// DefaultSpyCompressionThreshold is the size above which spymemcached
+// compresses strings and byte arrays.
+const DefaultSpyCompressionThreshold = 16384
+
+// SpymemcachedTranscoder follows the default SerializingTranscoder of
+// spymemcached, the Java client: strings are stored as UTF-8, and
+// booleans, numbers, dates (as time.Time) and byte arrays with the flag
+// of their Java type, numbers in big-endian with their leading zero bytes
+// dropped. Strings and byte arrays larger than
+// DefaultSpyCompressionThreshold are gzipped, as Java does. Values
+// stored with Java serialization are not supported.
+var SpymemcachedTranscoder Transcoder = NewSpymemcachedTranscoder(DefaultSpyCompressionThreshold)
+
+// NewSpymemcachedTranscoder returns a SpymemcachedTranscoder compressing
+// the strings and byte arrays larger than threshold bytes, or none if
+// threshold is zero.
+func NewSpymemcachedTranscoder(threshold int) Transcoder {
+	return spyTranscoder{threshold: threshold}
+}
+
+type spyTranscoder struct {
+	threshold int
+}
+
+func (t spyTranscoder) Encode(v interface{}) ([]byte, uint32, error) {
+	switch x := v.(type) {
+	case string:
+		return t.compress([]byte(x), 0)
+	case []byte:
+		return t.compress(x, spyByteArray)
+	case bool:
+		if x {
+			return []byte{'1'}, spyBoolean, nil
+		}
+		return []byte{'0'}, spyBoolean, nil
+	case int8:
+		return []byte{byte(x)}, spyByte, nil
+	case int32:
+		return spyEncodeNum(uint64(uint32(x)), 4), spyInt, nil
+	case int:
+		return spyEncodeNum(uint64(x), 8), spyLong, nil
+	case int64:
+		return spyEncodeNum(uint64(x), 8), spyLong, nil
+	case float32:
+		return spyEncodeNum(uint64(math.Float32bits(x)), 4), spyFloat, nil
+	case float64:
+		return spyEncodeNum(math.Float64bits(x), 8), spyDouble, nil
+	case time.Time:
+		return spyEncodeNum(uint64(x.UnixNano()/int64(time.Millisecond)), 8), spyDate, nil
+	}
+	return nil, 0, &UnsupportedEncodingError{Scheme: "spymemcached", Encoding: fmt.Sprintf("%T without Java serialization", v)}
+}
+
+// compress gzips data if it is larger than the threshold and shrinks.
+func (t spyTranscoder) compress(data []byte, flags uint32) ([]byte, uint32, error) {
+	if t.threshold <= 0 || len(data) <= t.threshold {
+		return data, flags, nil
+	}
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		return nil, 0, err
+	}
+	if b.Len() >= len(data) {
+		return data, flags, nil
+	}
+	return b.Bytes(), flags | spyFlagCompressed, nil
+}
+
+func (spyTranscoder) Decode(value []byte, flags uint32, v interface{}) error {
+	if flags&spyFlagCompressed != 0 {
+		r, err := gzip.NewReader(bytes.NewReader(value))
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if value, err = ioutil.ReadAll(r); err != nil {
+			return err
+		}
+	}
+	if flags&spyFlagSerialized != 0 {
+		return &UnsupportedEncodingError{Scheme: "spymemcached", Flags: flags, Encoding: "Java serialized"}
+	}
+	switch flags & spySpecialMask {
+	case 0:
+		return assign(v, string(value))
+	case spyByteArray:
+		return assign(v, value)
+	case spyBoolean:
+		return assign(v, len(value) > 0 && value[0] == '1')
+	case spyByte:
+		if len(value) != 1 {
+			return fmt.Errorf("memcache: spymemcached byte of %d bytes", len(value))
+		}
+		return assign(v, int64(int8(value[0])))
+	case spyInt:
+		n, err := spyDecodeNum(value, 4)
+		if err != nil {
+			return err
+		}
+		return assign(v, int64(int32(uint32(n))))
+	case spyLong:
+		n, err := spyDecodeNum(value, 8)
+		if err != nil {
+			return err
+		}
+		return assign(v, int64(n))
+	case spyFloat:
+		n, err := spyDecodeNum(value, 4)
+		if err != nil {
+			return err
+		}
+		return assign(v, float64(math.Float32frombits(uint32(n))))
+	case spyDouble:
+		n, err := spyDecodeNum(value, 8)
+		if err != nil {
+			return err
+		}
+		return assign(v, math.Float64frombits(n))
+	case spyDate:
+		n, err := spyDecodeNum(value, 8)
+		if err != nil {
+			return err
+		}
+		ms := int64(n)
+		tm := time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+		switch p := v.(type) {
+		case *time.Time:
+			*p = tm
+			return nil
+		case *interface{}:
+			*p = tm
+			return nil
+		}
+		return assign(v, ms)
+	}
+	return &UnsupportedEncodingError{Scheme: "spymemcached", Flags: flags, Encoding: "of an unknown type"}
+}
+
+// spyEncodeNum returns the size low bytes of n in big-endian order,
+// without their leading zero bytes.
+func spyEncodeNum(n uint64, size int) []byte {
+	b := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+// spyDecodeNum decodes a number of at most size bytes encoded by
+// spyEncodeNum.
+func spyDecodeNum(b []byte, size int) (uint64, error) {
+	if len(b) > size {
+		return 0, fmt.Errorf("memcache: spymemcached number of %d bytes, want at most %d", len(b), size)
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
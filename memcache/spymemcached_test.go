@@ -0,0 +1,80 @@
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpymemcachedTranscoder(t *testing.T) {
+	date := time.Date(2020, 1, 2, 3, 4, 5, 6e6, time.UTC)
+	tests := []struct {
+		v     interface{}
+		value []byte // as spymemcached stores it
+		flags uint32
+	}{
+		{"héllo", []byte("héllo"), 0},
+		{[]byte{0, 1}, []byte{0, 1}, 8 << 8},
+		{true, []byte("1"), 1 << 8},
+		{false, []byte("0"), 1 << 8},
+		{int8(-2), []byte{0xfe}, 5 << 8},
+		{int32(300), []byte{1, 44}, 2 << 8},
+		{int32(-1), []byte{0xff, 0xff, 0xff, 0xff}, 2 << 8},
+		{int64(0), []byte{}, 3 << 8},
+		{int64(1) << 40, []byte{1, 0, 0, 0, 0, 0}, 3 << 8},
+		{float32(1.5), []byte{0x3f, 0xc0, 0, 0}, 6 << 8},
+		{float64(-2), []byte{0xc0, 0, 0, 0, 0, 0, 0, 0}, 7 << 8},
+		{date, []byte{0x01, 0x6f, 0x64, 0x35, 0xcc, 0x8e}, 4 << 8}, // 1577934245006ms
+	}
+	for _, tt := range tests {
+		value, flags, err := SpymemcachedTranscoder.Encode(tt.v)
+		if err != nil {
+			t.Errorf("Encode(%#v): %v", tt.v, err)
+			continue
+		}
+		if !bytes.Equal(value, tt.value) || flags != tt.flags {
+			t.Errorf("Encode(%#v) = %x/%#x, want %x/%#x", tt.v, value, flags, tt.value, tt.flags)
+		}
+		got := reflect.New(reflect.TypeOf(tt.v))
+		if err := SpymemcachedTranscoder.Decode(tt.value, tt.flags, got.Interface()); err != nil {
+			t.Errorf("Decode(%x, %#x): %v", tt.value, tt.flags, err)
+			continue
+		}
+		if g := got.Elem().Interface(); !reflect.DeepEqual(g, tt.v) && !(tt.v == date && g.(time.Time).Equal(date)) {
+			t.Errorf("Decode(%x, %#x) = %#v, want %#v", tt.value, tt.flags, g, tt.v)
+		}
+	}
+}
+
+func TestSpymemcachedCompression(t *testing.T) {
+	big := strings.Repeat("compressible ", 2000)
+	value, flags, err := SpymemcachedTranscoder.Encode(big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags != 2 || len(value) >= len(big) || value[0] != 0x1f || value[1] != 0x8b {
+		t.Fatalf("Encode of %d bytes = %d bytes with flags %#x, want gzip", len(big), len(value), flags)
+	}
+	var s string
+	if err := SpymemcachedTranscoder.Decode(value, flags, &s); err != nil || s != big {
+		t.Errorf("Decode of the compressed string: %v", err)
+	}
+	if _, flags, _ := NewSpymemcachedTranscoder(0).Encode(big); flags != 0 {
+		t.Errorf("compressed with no threshold")
+	}
+}
+
+func TestSpymemcachedSerialized(t *testing.T) {
+	var v interface{}
+	err := SpymemcachedTranscoder.Decode([]byte{0xac, 0xed, 0, 5}, 1, &v)
+	var ue *UnsupportedEncodingError
+	if !errors.As(err, &ue) {
+		t.Errorf("Decode of a Java serialized value: %v, want an UnsupportedEncodingError", err)
+	}
+	if _, _, err := SpymemcachedTranscoder.Encode(map[string]int{}); !errors.As(err, &ue) {
+		t.Errorf("Encode of a map: %v, want an UnsupportedEncodingError", err)
+	}
+}
@@ -0,0 +1,133 @@
+package memcache
+
+// The warm standby of Config.StandbyPool is written behind the primary
+// servers and read when they miss or fail.
+
+// Sizes of the queues of the writes to the standby and of the backfills
+// of its hits, and the number of their workers. When a queue is full,
+// its oldest write is dropped, which StandbyStats counts.
+const (
+	standbyQueueSize = 1024
+	standbyWorkers   = 4
+)
+
+// StandbyStats returns the counts of the writes copied to the standby of
+// Config.StandbyPool and of the backfills of its hits, or zero stats
+// without a standby.
+func (c *Client) StandbyStats() (writes, backfills WriteBehindStats) {
+	if c.standby == nil {
+		return
+	}
+	return c.standbyWrites.Stats(), c.backfills.Stats()
+}
+
+// startStandby starts the queues of the writes to the standby, if any.
+func (c *Client) startStandby() {
+	if c.standby == nil {
+		return
+	}
+	c.standbyWrites = NewWriteBehind(c.standby, standbyQueueSize, standbyWorkers, DropOldest)
+	c.backfills = NewWriteBehind(c, standbyQueueSize, standbyWorkers, DropOldest)
+	// Backfills don't go through Set, which would copy them back to the
+	// standby.
+	c.backfills.set = func(it *Item) error { return c.onItem("set", it, nil, (*Client).set) }
+}
+
+// closeStandby waits for the queued writes to the standby to be done.
+func (c *Client) closeStandby() {
+	if c.standby == nil {
+		return
+	}
+	c.standbyWrites.Close()
+	c.backfills.Close()
+}
+
+// standbyGet looks key up in the standby after the primary failed with
+// err, and backfills the primary with a hit. It returns err unless the
+// standby has the item.
+func (c *Client) standbyGet(key string, err error) (*Item, error) {
+	if c.standby == nil || (err != ErrCacheMiss && !isNetworkError(err)) {
+		return nil, err
+	}
+	it, serr := c.standby.Get(key)
+	if serr != nil {
+		return nil, err
+	}
+	c.backfill(it)
+	return it, nil
+}
+
+// standbyMulti completes m, the items found in the primary for keys,
+// with those of the standby, and backfills the primary with them. It
+// returns err, the primary's error, unless every key was then found.
+func (c *Client) standbyMulti(keys []string, m map[string]*Item, err error) (map[string]*Item, error) {
+	if c.standby == nil || m == nil {
+		return m, err
+	}
+	var missing []string
+	for _, key := range keys {
+		if _, ok := m[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return m, err
+	}
+	found, _ := c.standby.GetMulti(missing)
+	for key, it := range found {
+		m[key] = it
+		c.backfill(it)
+	}
+	if len(found) == len(missing) {
+		err = nil
+	}
+	return m, err
+}
+
+// backfill queues a copy of it, found in the standby, to be stored in the
+// primary, without writing it back to the standby.
+func (c *Client) backfill(it *Item) {
+	it = copyItem(it)
+	it.Expiration = 0
+	if c.standbyTTL > 0 {
+		it.Expiration = Expiration(c.standbyTTL, c.now())
+	}
+	c.backfills.Set(it)
+}
+
+// standbySet queues a copy of item, just stored in the primary unless
+// *err is set, to be stored in the standby.
+func (c *Client) standbySet(item *Item, err *error) {
+	if c.standby == nil || *err != nil {
+		return
+	}
+	item = copyItem(item)
+	item.Casid = 0
+	c.standbyWrites.Set(item)
+}
+
+// standbyDelete queues the deletion of key, just changed or deleted in
+// the primary unless *err is set, from the standby, so that it doesn't
+// serve the old value.
+func (c *Client) standbyDelete(key string, err *error) {
+	if c.standby == nil || (*err != nil && *err != ErrCacheMiss) {
+		return
+	}
+	c.standbyWrites.Delete(key)
+}
+
+// standbyBatch passes the outcome of a batch operation over keys, with
+// items if it stored them and kerrs the keys that failed, to the standby.
+func (c *Client) standbyBatch(keys []string, items []*Item, kerrs KeyErrors) {
+	if c.standby == nil {
+		return
+	}
+	for i, key := range keys {
+		err := kerrs[key]
+		if items != nil {
+			c.standbySet(items[i], &err)
+		} else {
+			c.standbyDelete(key, &err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestStandbyPool(t *testing.T) {
+	primary, standby := memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer standby.Close()
+	c, err := NewClient([]string{primary.Addr()},
+		WithPool("standby", Config{Servers: []string{standby.Addr()}}),
+		WithStandbyPool("standby", time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, sb := New(primary.Addr()), New(standby.Addr())
+	present := func(c *Client, key string) func() bool {
+		return func() bool {
+			_, err := c.Get(key)
+			return err == nil
+		}
+	}
+
+	if err := c.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the write copied to the standby", present(sb, "k"))
+
+	if err := sb.Set(&Item{Key: "only", Value: []byte("s")}); err != nil {
+		t.Fatal(err)
+	}
+	if it, err := c.Get("only"); err != nil || string(it.Value) != "s" {
+		t.Fatalf("Get of a key only on the standby = %+v, %v", it, err)
+	}
+	waitFor(t, "the standby hit backfilled", present(p, "only"))
+
+	if err := sb.Set(&Item{Key: "multi", Value: []byte("m")}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := c.GetMulti([]string{"k", "multi", "absent"})
+	if err != nil || len(m) != 2 || string(m["multi"].Value) != "m" {
+		t.Errorf("GetMulti = %v, %v, want k and multi", m, err)
+	}
+
+	if err := c.Delete("only"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the delete copied to the standby", func() bool { return !present(sb, "only")() })
+	waitFor(t, "the standby writes counted", func() bool {
+		writes, backfills := c.StandbyStats()
+		return writes.Written == 2 && backfills.Written == 2
+	})
+
+	primary.Close()
+	p.Close()
+	c.lk.Lock()
+	for _, cn := range c.freeconn[primary.Addr()] {
+		cn.nc.Close()
+	}
+	c.lk.Unlock()
+	if it, err := c.Get("k"); err != nil || string(it.Value) != "v" {
+		t.Errorf("Get with the primary down = %+v, %v, want the standby's item", it, err)
+	}
+
+	if _, err := NewClient([]string{primary.Addr()}, WithStandbyPool("nope", 0)); err == nil {
+		t.Error("a StandbyPool naming no pool was accepted")
+	}
+}
@@ -0,0 +1,37 @@
+package memcache
+
+import (
+	"bufio"
+	"net"
+)
+
+// Stats returns the statistics of every server, keyed by server address.
+// group names a statistics group such as "items", "slabs" or "settings",
+// or is empty for the general statistics. ErrNoStats is returned if a
+// server doesn't know the group.
+func (c *Client) Stats(group string) (map[net.Addr]map[string]string, error) {
+	stats := make(map[net.Addr]map[string]string)
+	err := c.eachServer(func(addr net.Addr) error {
+		st, err := c.statsFromAddr(addr, group)
+		if err != nil {
+			return err
+		}
+		stats[addr] = st
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (c *Client) statsFromAddr(addr net.Addr, group string) (map[string]string, error) {
+	var st map[string]string
+	err := c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+		st = make(map[string]string)
+		return c.cmdRunner.Stats(rw, group, func(name, value string) {
+			st[name] = value
+		})
+	})
+	return st, err
+}
@@ -0,0 +1,45 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestStats(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for name, c := range protoClients(s) {
+		t.Run(name, func(t *testing.T) {
+			if err := c.Set(&Item{Key: "k-" + name, Value: []byte("v")}); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			stats, err := c.Stats("")
+			if err != nil {
+				t.Fatalf("Stats: %v", err)
+			}
+			if len(stats) != 1 {
+				t.Fatalf("Stats returned %d servers, want 1", len(stats))
+			}
+			for _, st := range stats {
+				if st["curr_items"] == "" || st["version"] == "" {
+					t.Errorf("general stats = %v", st)
+				}
+			}
+
+			stats, err = c.Stats("settings")
+			if err != nil {
+				t.Fatalf("Stats(settings): %v", err)
+			}
+			for _, st := range stats {
+				if st["maxbytes"] != "67108864" {
+					t.Errorf("settings stats = %v", st)
+				}
+			}
+
+			if _, err := c.Stats("nosuchgroup"); err != ErrNoStats {
+				t.Errorf("Stats(nosuchgroup): got %v, want ErrNoStats", err)
+			}
+		})
+	}
+}
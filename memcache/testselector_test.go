@@ -0,0 +1,57 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestTestSelectors(t *testing.T) {
+	hot, cold := memcachetest.NewServer(t), memcachetest.NewServer(t)
+	defer hot.Close()
+	defer cold.Close()
+	static := memcachetest.NewStaticSelector(cold.Addr())
+	static.Pin("hot", hot.Addr())
+	rec := memcachetest.NewRecordingSelector(static)
+	c, err := NewClient(nil, WithSelector(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, key := range []string{"hot", "other"} {
+		if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.GetMulti([]string{"hot", "other"}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"hot": hot.Addr(), "other": cold.Addr()}
+	for key, addr := range rec.Servers() {
+		if addr != want[key] {
+			t.Errorf("key %q went to %s, want %s", key, addr, want[key])
+		}
+	}
+	if n := len(rec.Picks()); n != 4 {
+		t.Errorf("%d routing decisions recorded, want 4", n)
+	}
+	direct := New(hot.Addr())
+	defer direct.Close()
+	if _, err := direct.Get("hot"); err != nil {
+		t.Errorf("pinned key is not on its server: %v", err)
+	}
+
+	rec.Reset()
+	strict := memcachetest.NewStaticSelector("")
+	c2, err := NewClient(nil, WithSelector(strict))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c2.Get("unpinned"); err == nil {
+		t.Error("Get of a key with no route succeeded")
+	}
+	if n := len(rec.Picks()); n != 0 {
+		t.Errorf("%d decisions recorded after Reset, want 0", n)
+	}
+}
@@ -0,0 +1,96 @@
+package memcache
+
+import (
+	"errors"
+	"net"
+)
+
+// A TimeoutPhase names the phase of an operation that ran out of time.
+type TimeoutPhase string
+
+// The phases of an operation. PhasePoolWait is the wait for a connection
+// under Config.MaxOpenConns. PhaseAuth covers the whole setup of a new
+// connection once dialed: authentication and, with Config.ClientName, its
+// labeling.
+const (
+	PhasePoolWait TimeoutPhase = "pool wait"
+	PhaseDial     TimeoutPhase = "dial"
+	PhaseAuth     TimeoutPhase = "auth"
+	PhaseWrite    TimeoutPhase = "write"
+	PhaseRead     TimeoutPhase = "read"
+)
+
+// TimeoutError is returned when an operation on Addr times out, and tells
+// in which phase, so that a slow or unreachable server can be told apart
+// from a lossy network. Err is the underlying error, a
+// *ConnectTimeoutError for PhaseDial. Its Timeout method reports true, as
+// for the net package's errors.
+type TimeoutError struct {
+	Addr  net.Addr
+	Phase TimeoutPhase
+	Err   error
+}
+
+func (e *TimeoutError) Error() string {
+	return "memcache: " + string(e.Phase) + " timeout on " + e.Addr.String() + ": " + e.Err.Error()
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// Timeout reports true: e is a timeout.
+func (e *TimeoutError) Timeout() bool { return true }
+
+// Temporary reports true, as for the net package's timeouts.
+func (e *TimeoutError) Temporary() bool { return true }
+
+// TimeoutCounts counts the timeouts of the operations on a server by
+// phase. Pool wait timeouts point at a saturated pool, dial timeouts at an
+// unreachable server or a lossy network, auth timeouts at an overloaded
+// server, and write and read timeouts at a slow server or a saturated
+// link.
+type TimeoutCounts struct {
+	PoolWait, Dial, Auth, Write, Read int64
+}
+
+func (t *TimeoutCounts) add(phase TimeoutPhase) {
+	switch phase {
+	case PhasePoolWait:
+		t.PoolWait++
+	case PhaseDial:
+		t.Dial++
+	case PhaseAuth:
+		t.Auth++
+	case PhaseWrite:
+		t.Write++
+	case PhaseRead:
+		t.Read++
+	}
+}
+
+// timeoutError returns err, if it is a timeout of phase on addr, as a
+// *TimeoutError counted in the PoolStats of addr, and err unchanged
+// otherwise. An empty phase is taken from the failed read or write.
+func (c *Client) timeoutError(addr net.Addr, phase TimeoutPhase, err error) error {
+	var te *TimeoutError
+	if err == nil || errors.As(err, &te) {
+		return err
+	}
+	var cte *ConnectTimeoutError
+	var ne net.Error
+	if errors.As(err, &cte) {
+		phase = PhaseDial
+	} else if !errors.As(err, &ne) || !ne.Timeout() {
+		return err
+	}
+	if phase == "" {
+		phase = PhaseRead
+		var oe *net.OpError
+		if errors.As(err, &oe) && oe.Op == "write" {
+			phase = PhaseWrite
+		}
+	}
+	c.lk.Lock()
+	c.pool(addr).timeouts.add(phase)
+	c.lk.Unlock()
+	return &TimeoutError{Addr: addr, Phase: phase, Err: err}
+}
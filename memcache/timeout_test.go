@@ -0,0 +1,73 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestTimeoutPhaseRead(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c, err := NewClient([]string{s.Addr()}, WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s.SetFaults(memcachetest.Faults{SlowRate: 1, Delay: 200 * time.Millisecond})
+
+	_, err = c.Get("k")
+	var te *TimeoutError
+	if !errors.As(err, &te) || te.Phase != PhaseRead || te.Addr.String() != s.Addr() {
+		t.Fatalf("Get from a slow server error = %v, want a read *TimeoutError", err)
+	}
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("TimeoutError is not a net.Error timeout")
+	}
+	if got := c.PoolStats()[s.Addr()].Timeouts; got != (TimeoutCounts{Read: 1}) {
+		t.Errorf("Timeouts = %+v, want one read", got)
+	}
+}
+
+func TestTimeoutPhaseAuth(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	// The label fetch is the first response, and it is slow.
+	s.SetFaults(memcachetest.Faults{SlowRate: 1, Delay: 200 * time.Millisecond})
+	c, err := NewClient([]string{s.Addr()}, WithTimeout(50*time.Millisecond), WithClientName("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	err = c.Set(&Item{Key: "k", Value: []byte("v")})
+	var te *TimeoutError
+	if !errors.As(err, &te) || te.Phase != PhaseAuth {
+		t.Errorf("Set with a slow setup error = %v, want an auth *TimeoutError", err)
+	}
+}
+
+func TestTimeoutPhaseDial(t *testing.T) {
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	c, err := NewClient([]string{"127.0.0.1:1"}, WithTimeout(20*time.Millisecond), WithDialContext(dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Delete("k")
+	var te *TimeoutError
+	var cte *ConnectTimeoutError
+	if !errors.As(err, &te) || te.Phase != PhaseDial || !errors.As(err, &cte) {
+		t.Errorf("Delete through a stuck dialer error = %v, want a dial *TimeoutError", err)
+	}
+	if got := c.PoolStats()["127.0.0.1:1"].Timeouts; got != (TimeoutCounts{Dial: 1}) {
+		t.Errorf("Timeouts = %+v, want one dial", got)
+	}
+}
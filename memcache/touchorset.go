@@ -0,0 +1,41 @@
+package memcache
+
+import (
+	"context"
+	"time"
+)
+
+// TouchOrSet keeps key cached: if the item exists, its expiration is
+// extended to ttl from now; otherwise load is called to compute its value,
+// which is stored for ttl. A zero ttl selects the TTL of the key's
+// profile. This is the usual way to keep the result of an expensive
+// computation cached for as long as it is in use.
+//
+// Like Memoize, concurrent calls for the same key on the same Client
+// share one call of load, which gets the context of the caller that
+// started it; other callers stop waiting when their own context is done,
+// and call load again if it fails once that caller's context is done.
+// The loaded value is stored for the ttl of that caller, counted from
+// when load returns. Calls of TouchOrSet and Memoize don't share loads
+// with each other.
+func (c *Client) TouchOrSet(ctx context.Context, key string, ttl time.Duration, load func(context.Context) ([]byte, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key = rewriteKey(ctx, key)
+	if ttl == 0 {
+		ttl = c.profile(key).TTL
+	}
+	if err := c.Touch(key, Expiration(ttl, c.now())); err != ErrCacheMiss {
+		return err
+	}
+
+	_, err := c.touchFlight.doContext(ctx, key, func(ctx context.Context) ([]byte, error) {
+		data, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return data, c.Set(&Item{Key: key, Value: data, Expiration: Expiration(ttl, c.now())})
+	})
+	return err
+}
@@ -0,0 +1,156 @@
+package memcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestTouchOrSet(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	clock := memcachetest.NewFakeClock(time.Now())
+	s.SetClock(clock)
+	c := New(s.Addr())
+	defer c.Close()
+	ctx := context.Background()
+
+	var loads int32
+	release := make(chan struct{})
+	load := func(context.Context) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []byte("expensive"), nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.TouchOrSet(ctx, "k", time.Minute, load); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	waitFor(t, "the load to start", func() bool { return atomic.LoadInt32(&loads) == 1 })
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("loaded %d times, want 1", n)
+	}
+
+	// Keeping the item alive extends it past its original expiration.
+	for i := 0; i < 3; i++ {
+		clock.Advance(40 * time.Second)
+		if err := c.TouchOrSet(ctx, "k", time.Minute, load); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("loaded %d times while the item was kept alive, want 1", n)
+	}
+	it, err := c.Get("k")
+	if err != nil || string(it.Value) != "expensive" {
+		t.Errorf("Get = %v, %v", it, err)
+	}
+}
+
+func TestTouchOrSetDoesNotJoinMemoize(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+	ctx := context.Background()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	memoized := make(chan error, 1)
+	go func() {
+		var v string
+		memoized <- c.Memoize(ctx, "k", time.Minute, &v, func(context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			return "memo", nil
+		})
+	}()
+	<-started
+	loaded := false
+	err := c.TouchOrSet(ctx, "k", time.Minute, func(context.Context) ([]byte, error) {
+		loaded = true
+		return []byte("touched"), nil
+	})
+	if err != nil || !loaded {
+		t.Errorf("TouchOrSet during a Memoize load = %v, loaded %v; want its own load", err, loaded)
+	}
+	close(release)
+	if err := <-memoized; err != nil {
+		t.Errorf("Memoize: %v", err)
+	}
+}
+
+func TestTouchOrSetStarterCanceled(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+
+	var loads int32
+	started := make(chan struct{})
+	load := func(ctx context.Context) ([]byte, error) {
+		if atomic.AddInt32(&loads, 1) == 1 {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return []byte("v"), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	starter := make(chan error, 1)
+	go func() { starter <- c.TouchOrSet(ctx, "k", time.Minute, load) }()
+	<-started
+	waiter := make(chan error, 1)
+	go func() { waiter <- c.TouchOrSet(context.Background(), "k", time.Minute, load) }()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-starter; err != context.Canceled {
+		t.Errorf("canceled TouchOrSet = %v, want %v", err, context.Canceled)
+	}
+	if err := <-waiter; err != nil {
+		t.Errorf("TouchOrSet joining a canceled load = %v", err)
+	}
+	if it, err := c.Get("k"); err != nil || string(it.Value) != "v" {
+		t.Errorf("Get = %v, %v; want v", it, err)
+	}
+}
+
+func TestTouchOrSetExpiresFromStore(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	clock := memcachetest.NewFakeClock(time.Now())
+	s.SetClock(clock)
+	c, err := NewWithOptions(Config{Servers: []string{s.Addr()}, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// A TTL over 30 days is sent as a point in time, which a slow load
+	// must not bring forward.
+	const ttl = 31 * 24 * time.Hour
+	err = c.TouchOrSet(context.Background(), "k", ttl, func(context.Context) ([]byte, error) {
+		clock.Advance(10 * 24 * time.Hour)
+		return []byte("v"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(25 * 24 * time.Hour)
+	if _, err := c.Get("k"); err != nil {
+		t.Errorf("Get %v after the store of a %v TTL: %v", 25*24*time.Hour, ttl, err)
+	}
+}
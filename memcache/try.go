@@ -0,0 +1,48 @@
+package memcache
+
+import "time"
+
+// DefaultTryBudget is a typical budget for TryGet and TrySet on a
+// latency-critical path.
+const DefaultTryBudget = 5 * time.Millisecond
+
+// TryGet gets the item for key as Get does, but gives up after budget
+// and never fails: it returns the item and true on a hit, and nil and
+// false on a miss, an error or running out of time. It is meant for the
+// paths where the cache is purely opportunistic and a slow answer is
+// worse than none. An operation out of time finishes in the background
+// within the client's timeouts.
+func (c *Client) TryGet(key string, budget time.Duration) (*Item, bool) {
+	var item *Item
+	ok := c.tryWithin(budget, func() (err error) {
+		item, err = c.Get(key)
+		return err
+	})
+	if !ok {
+		return nil, false
+	}
+	return item, true
+}
+
+// TrySet stores item as Set does, but gives up after budget and never
+// fails, reporting whether the item was stored in time. Item is copied,
+// so the caller may reuse it once TrySet returns.
+func (c *Client) TrySet(item *Item, budget time.Duration) bool {
+	item = copyItem(item)
+	return c.tryWithin(budget, func() error { return c.Set(item) })
+}
+
+// tryWithin runs fn in the background and reports whether it succeeded
+// within budget.
+func (c *Client) tryWithin(budget time.Duration, fn func() error) bool {
+	done := make(chan error, 1)
+	c.goFunc(func() { done <- fn() })
+	t := time.NewTimer(budget)
+	defer t.Stop()
+	select {
+	case err := <-done:
+		return err == nil
+	case <-t.C:
+		return false
+	}
+}
@@ -0,0 +1,40 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestTryGetSet(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	item := &Item{Key: "k", Value: []byte("v")}
+	if !c.TrySet(item, time.Second) {
+		t.Fatal("TrySet on a healthy server failed")
+	}
+	item.Value[0] = 'x'
+	if it, ok := c.TryGet("k", time.Second); !ok || string(it.Value) != "v" {
+		t.Errorf("TryGet = %+v, %v, want the value stored by TrySet", it, ok)
+	}
+	if it, ok := c.TryGet("absent", time.Second); ok || it != nil {
+		t.Errorf("TryGet of an absent key = %+v, %v, want a miss", it, ok)
+	}
+	if _, ok := c.TryGet("bad key", time.Second); ok {
+		t.Error("TryGet of a malformed key succeeded")
+	}
+
+	s.SetFaults(memcachetest.Faults{SlowRate: 1, Delay: 200 * time.Millisecond})
+	start := time.Now()
+	if _, ok := c.TryGet("k", 10*time.Millisecond); ok {
+		t.Error("TryGet on a slow server succeeded")
+	}
+	if c.TrySet(item, 10*time.Millisecond) {
+		t.Error("TrySet on a slow server succeeded")
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("TryGet and TrySet took %v, want them within their budgets", d)
+	}
+}
@@ -0,0 +1,231 @@
+package memcache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A TTLSuggestion is TTLAdvisor's advice for one key prefix.
+type TTLSuggestion struct {
+	Prefix string
+
+	// Current is the TTL the advisor was told the prefix uses, and
+	// Suggested the one it recommends instead.
+	Current, Suggested time.Duration
+
+	// Reason explains the suggestion in a sentence.
+	Reason string
+
+	// Hits counts the retrievals of tracked items, LateHits those made
+	// in the last quarter of their TTL. Evicted counts the misses on
+	// tracked items read before their TTL elapsed, Expired the misses
+	// after it.
+	Hits, LateHits, Evicted, Expired uint64
+}
+
+// TTLAdvisor watches the lifetime of items under configured key prefixes
+// and suggests TTL adjustments, so that teams can tune their memory use
+// without external analytics. Register its Observe method with
+// Client.AddHook.
+//
+// The advisor remembers when each key was last written and classifies
+// later retrievals of it. A miss before the key's TTL elapsed means the
+// server evicted it: when that is the common fate of a prefix's items,
+// the advisor suggests shortening the TTL to their typical lifetime so
+// that memory is not reserved for items the server cannot keep. A miss
+// after the TTL, for items still being read close to their expiration,
+// suggests the TTL is cutting them short, and the advisor suggests
+// doubling it.
+//
+// The advice assumes the prefix's items are written with the configured
+// TTL and that this client sees the deletes of them; items removed by
+// other clients count as evicted.
+type TTLAdvisor struct {
+	prefixes []string // longest first
+	ttls     map[string]time.Duration
+
+	// MaxTracked bounds the keys remembered per prefix; writes of new
+	// keys beyond it are ignored. It must be set before use.
+	MaxTracked int
+
+	// MinSamples is the number of tracked retrievals a prefix needs
+	// before it gets a suggestion. It must be set before use.
+	MinSamples uint64
+
+	mu     sync.Mutex
+	tracks map[string]*ttlTrack
+}
+
+type ttlTrack struct {
+	written map[string]time.Time
+
+	hits, lateHits, evicted, expired uint64
+
+	ages []time.Duration // of the last evictions, a ring
+	next int
+}
+
+const maxEvictionAges = 256
+
+// Defaults for TTLAdvisor.MaxTracked and MinSamples.
+const (
+	DefaultMaxTracked = 10000
+	DefaultMinSamples = 100
+)
+
+// NewTTLAdvisor returns an advisor for the key prefixes in ttls, mapped
+// to the TTL their items are stored with. Keys matching none are ignored.
+func NewTTLAdvisor(ttls map[string]time.Duration) *TTLAdvisor {
+	a := &TTLAdvisor{
+		ttls:       make(map[string]time.Duration, len(ttls)),
+		MaxTracked: DefaultMaxTracked,
+		MinSamples: DefaultMinSamples,
+		tracks:     make(map[string]*ttlTrack),
+	}
+	for prefix, ttl := range ttls {
+		a.prefixes = append(a.prefixes, prefix)
+		a.ttls[prefix] = ttl
+	}
+	sort.Slice(a.prefixes, func(i, j int) bool {
+		return len(a.prefixes[i]) > len(a.prefixes[j])
+	})
+	return a
+}
+
+// Observe records ev. It is a Hook.
+func (a *TTLAdvisor) Observe(ev OpEvent) {
+	end := ev.Start.Add(ev.Duration)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if ev.Op == "flush_all" {
+		for _, t := range a.tracks {
+			t.written = make(map[string]time.Time)
+		}
+		return
+	}
+	prefix, ok := a.match(ev.Key)
+	if !ok {
+		return
+	}
+	t := a.tracks[prefix]
+	if t == nil {
+		t = &ttlTrack{written: make(map[string]time.Time)}
+		a.tracks[prefix] = t
+	}
+
+	switch ev.Op {
+	case "get":
+		a.retrieved(t, prefix, ev, end)
+	case "gat":
+		a.retrieved(t, prefix, ev, end)
+		a.written(t, ev, end)
+	case "set", "add", "replace", "cas", "touch":
+		a.written(t, ev, end)
+	case "delete":
+		delete(t.written, ev.Key)
+	}
+}
+
+func (a *TTLAdvisor) written(t *ttlTrack, ev OpEvent, end time.Time) {
+	if ev.Err != nil {
+		return
+	}
+	if _, ok := t.written[ev.Key]; ok || len(t.written) < a.MaxTracked {
+		t.written[ev.Key] = end
+	}
+}
+
+func (a *TTLAdvisor) retrieved(t *ttlTrack, prefix string, ev OpEvent, end time.Time) {
+	written, ok := t.written[ev.Key]
+	if !ok {
+		return
+	}
+	ttl := a.ttls[prefix]
+	age := end.Sub(written)
+	switch ev.Err {
+	case nil:
+		t.hits++
+		if age >= ttl-ttl/4 {
+			t.lateHits++
+		}
+	case ErrCacheMiss:
+		delete(t.written, ev.Key)
+		if age >= ttl {
+			t.expired++
+			return
+		}
+		t.evicted++
+		if len(t.ages) < maxEvictionAges {
+			t.ages = append(t.ages, age)
+		} else {
+			t.ages[t.next] = age
+			t.next = (t.next + 1) % maxEvictionAges
+		}
+	}
+}
+
+// Suggestions returns the advice for the prefixes whose TTL looks
+// mistuned, sorted by prefix. Prefixes with too little traffic, or
+// whose items live about as long as their TTL, are absent.
+func (a *TTLAdvisor) Suggestions() []TTLSuggestion {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var out []TTLSuggestion
+	for prefix, t := range a.tracks {
+		if t.hits+t.evicted+t.expired < a.MinSamples {
+			continue
+		}
+		s := TTLSuggestion{
+			Prefix:   prefix,
+			Current:  a.ttls[prefix],
+			Hits:     t.hits,
+			LateHits: t.lateHits,
+			Evicted:  t.evicted,
+			Expired:  t.expired,
+		}
+		switch {
+		case t.evicted > 0 && t.evicted >= t.expired:
+			ages := append([]time.Duration(nil), t.ages...)
+			sort.Slice(ages, func(i, j int) bool { return ages[i] < ages[j] })
+			median := ages[len(ages)/2]
+			s.Suggested = median.Round(time.Second)
+			if s.Suggested < time.Second {
+				s.Suggested = time.Second
+			}
+			if s.Suggested >= s.Current {
+				continue
+			}
+			s.Reason = fmt.Sprintf("%d of %d misses were evictions before expiry; items lived a median %v of their %v TTL",
+				t.evicted, t.evicted+t.expired, median.Round(time.Millisecond), s.Current)
+		case t.expired > 0 && t.lateHits*4 >= t.hits:
+			s.Suggested = 2 * s.Current
+			s.Reason = fmt.Sprintf("%d of %d hits came in the last quarter of the TTL and %d items were missed after expiring",
+				t.lateHits, t.hits, t.expired)
+		default:
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Prefix < out[j].Prefix })
+	return out
+}
+
+// Reset forgets all tracked keys and counters.
+func (a *TTLAdvisor) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tracks = make(map[string]*ttlTrack)
+}
+
+func (a *TTLAdvisor) match(key string) (string, bool) {
+	for _, prefix := range a.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
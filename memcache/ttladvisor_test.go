@@ -0,0 +1,79 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTTLAdvisor(t *testing.T) {
+	a := NewTTLAdvisor(map[string]time.Duration{
+		"evicted:": time.Hour,
+		"short:":   time.Minute,
+		"fine:":    time.Hour,
+	})
+	a.MinSamples = 10
+	t0 := time.Unix(1e9, 0)
+	at := func(op, key string, d time.Duration, err error) {
+		a.Observe(OpEvent{Op: op, Key: key, Start: t0.Add(d), Err: err})
+	}
+	for i := 0; i < 20; i++ {
+		// Evicted after about five minutes of an hour.
+		k := fmt.Sprintf("evicted:%d", i)
+		at("set", k, 0, nil)
+		at("get", k, time.Minute, nil)
+		at("get", k, 5*time.Minute, ErrCacheMiss)
+
+		// Read until the end of their TTL, then missed.
+		k = fmt.Sprintf("short:%d", i)
+		at("set", k, 0, nil)
+		at("get", k, 55*time.Second, nil)
+		at("get", k, 2*time.Minute, ErrCacheMiss)
+
+		// Living their TTL.
+		k = fmt.Sprintf("fine:%d", i)
+		at("set", k, 0, nil)
+		at("get", k, time.Minute, nil)
+		at("get", k, 2*time.Hour, ErrCacheMiss)
+
+		// Deleted keys are not evicted.
+		k = fmt.Sprintf("fine:deleted:%d", i)
+		at("set", k, 0, nil)
+		at("delete", k, time.Second, nil)
+		at("get", k, 2*time.Second, ErrCacheMiss)
+	}
+	at("get", "other", 0, ErrCacheMiss)
+
+	got := a.Suggestions()
+	if len(got) != 2 {
+		t.Fatalf("Suggestions = %+v, want 2", got)
+	}
+	if s := got[0]; s.Prefix != "evicted:" || s.Suggested != 5*time.Minute || s.Evicted != 20 || s.Hits != 20 {
+		t.Errorf("got %+v, want evicted: shortened to 5m", s)
+	}
+	if s := got[1]; s.Prefix != "short:" || s.Suggested != 2*time.Minute || s.Expired != 20 || s.LateHits != 20 {
+		t.Errorf("got %+v, want short: doubled to 2m", s)
+	}
+	for _, s := range got {
+		if s.Reason == "" {
+			t.Errorf("%s: empty Reason", s.Prefix)
+		}
+	}
+
+	a.Reset()
+	if got := a.Suggestions(); len(got) != 0 {
+		t.Errorf("after Reset, Suggestions = %+v", got)
+	}
+}
+
+func TestTTLAdvisorFlush(t *testing.T) {
+	a := NewTTLAdvisor(map[string]time.Duration{"k:": time.Hour})
+	a.MinSamples = 1
+	t0 := time.Now()
+	a.Observe(OpEvent{Op: "set", Key: "k:1", Start: t0})
+	a.Observe(OpEvent{Op: "flush_all", Start: t0})
+	a.Observe(OpEvent{Op: "get", Key: "k:1", Start: t0, Err: ErrCacheMiss})
+	if got := a.Suggestions(); len(got) != 0 {
+		t.Errorf("a flush was taken for evictions: %+v", got)
+	}
+}
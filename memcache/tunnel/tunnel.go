@@ -0,0 +1,181 @@
+// Package tunnel provides dial functions for memcache.Config.DialContext
+// that reach the servers through a SOCKS5 proxy or over SSH, such as from
+// behind a bastion host.
+package tunnel
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// A DialFunc opens a connection to address, as memcache.Config.DialContext.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// SOCKS5 returns a DialFunc connecting through the SOCKS5 proxy at
+// proxyAddr (RFC 1928). If username is not empty, the proxy is offered
+// username and password authentication (RFC 1929). Host names are resolved
+// by the proxy.
+func SOCKS5(proxyAddr, username, password string) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if network != "tcp" && network != "tcp4" && network != "tcp6" {
+			return nil, fmt.Errorf("tunnel: SOCKS5 does not support network %q", network)
+		}
+		var d net.Dialer
+		nc, err := d.DialContext(ctx, "tcp", proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			nc.SetDeadline(deadline)
+		}
+		if err := socks5Connect(nc, address, username, password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		nc.SetDeadline(time.Time{})
+		return nc, nil
+	}
+}
+
+const (
+	socksVersion      = 5
+	socksNoAuth       = 0x00
+	socksUserPass     = 0x02
+	socksNoAcceptable = 0xff
+	socksConnect      = 0x01
+	socksIPv4         = 0x01
+	socksDomain       = 0x03
+	socksIPv6         = 0x04
+)
+
+func socks5Connect(rw io.ReadWriter, address, username, password string) error {
+	methods := []byte{socksNoAuth}
+	if username != "" {
+		methods = []byte{socksUserPass}
+	}
+	if _, err := rw.Write(append([]byte{socksVersion, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(rw, reply[:]); err != nil {
+		return err
+	}
+	switch {
+	case reply[0] != socksVersion:
+		return fmt.Errorf("tunnel: proxy speaks SOCKS version %d", reply[0])
+	case reply[1] == socksNoAcceptable || (reply[1] != socksNoAuth && reply[1] != socksUserPass):
+		return errors.New("tunnel: proxy accepts none of the offered authentication methods")
+	case reply[1] == socksUserPass:
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("tunnel: SOCKS5 username or password too long")
+		}
+		req := []byte{1, byte(len(username))}
+		req = append(req, username...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := rw.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(rw, reply[:]); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("tunnel: SOCKS5 authentication failed")
+		}
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("tunnel: bad port in %q", address)
+	}
+	req := []byte{socksVersion, socksConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("tunnel: host name %q too long", host)
+		}
+		req = append(req, socksDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socksIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socksIPv6)
+		req = append(req, ip...)
+	}
+	req = append(req, 0, 0)
+	binary.BigEndian.PutUint16(req[len(req)-2:], uint16(port))
+	if _, err := rw.Write(req); err != nil {
+		return err
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(rw, head[:]); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		return fmt.Errorf("tunnel: SOCKS5 connect to %s failed with code %d", address, head[1])
+	}
+	var skip int
+	switch head[3] {
+	case socksIPv4:
+		skip = net.IPv4len
+	case socksIPv6:
+		skip = net.IPv6len
+	case socksDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(rw, n[:]); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("tunnel: bad SOCKS5 address type %d", head[3])
+	}
+	// Skip the bound address and port.
+	_, err = io.ReadFull(rw, make([]byte, skip+2))
+	return err
+}
+
+// An SSHClient opens connections from the remote end of an SSH connection.
+// *ssh.Client of golang.org/x/crypto/ssh implements it, so that a client
+// authenticated to a bastion host can reach the servers behind it.
+type SSHClient interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// SSH returns a DialFunc connecting through client. Since SSHClient dials
+// cannot be canceled, a dial outliving its context is abandoned, and the
+// connection it eventually opens is closed.
+func SSH(client SSHClient) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		type result struct {
+			nc  net.Conn
+			err error
+		}
+		ch := make(chan result, 1)
+		go func() {
+			nc, err := client.Dial(network, address)
+			ch <- result{nc, err}
+		}()
+		select {
+		case r := <-ch:
+			return r.nc, r.err
+		case <-ctx.Done():
+			go func() {
+				if r := <-ch; r.nc != nil {
+					r.nc.Close()
+				}
+			}()
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package tunnel_test
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache"
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+	"github.com/skinass/gomemcache/memcache/tunnel"
+)
+
+// socksProxy is a minimal SOCKS5 proxy requiring username and password
+// authentication if user is set.
+type socksProxy struct {
+	ln         net.Listener
+	user, pass string
+	targets    chan string
+}
+
+func newSocksProxy(t *testing.T, user, pass string) *socksProxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &socksProxy{ln: ln, user: user, pass: pass, targets: make(chan string, 10)}
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(nc)
+		}
+	}()
+	return p
+}
+
+func (p *socksProxy) serve(nc net.Conn) {
+	defer nc.Close()
+	buf := make([]byte, 512)
+	if _, err := io.ReadFull(nc, buf[:2]); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(nc, buf[:buf[1]]); err != nil {
+		return
+	}
+	if p.user == "" {
+		nc.Write([]byte{5, 0})
+	} else {
+		nc.Write([]byte{5, 2})
+		io.ReadFull(nc, buf[:2])
+		user := make([]byte, buf[1])
+		io.ReadFull(nc, user)
+		io.ReadFull(nc, buf[:1])
+		pass := make([]byte, buf[0])
+		io.ReadFull(nc, pass)
+		if string(user) != p.user || string(pass) != p.pass {
+			nc.Write([]byte{1, 1})
+			return
+		}
+		nc.Write([]byte{1, 0})
+	}
+
+	if _, err := io.ReadFull(nc, buf[:4]); err != nil {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		io.ReadFull(nc, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		io.ReadFull(nc, buf[:1])
+		name := make([]byte, buf[0])
+		io.ReadFull(nc, name)
+		host = string(name)
+	default:
+		return
+	}
+	io.ReadFull(nc, buf[:2])
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+	p.targets <- target
+	up, err := net.Dial("tcp", target)
+	if err != nil {
+		nc.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer up.Close()
+	nc.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	go io.Copy(up, nc)
+	io.Copy(nc, up)
+}
+
+func TestSOCKS5(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for _, tt := range []struct{ user, pass string }{{"", ""}, {"ops", "secret"}} {
+		p := newSocksProxy(t, tt.user, tt.pass)
+		defer p.ln.Close()
+		c, err := memcache.NewClient([]string{s.Addr()}, memcache.WithDialContext(tunnel.SOCKS5(p.ln.Addr().String(), tt.user, tt.pass)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Set(&memcache.Item{Key: "k", Value: []byte("v")}); err != nil {
+			t.Fatalf("Set through the proxy (user %q): %v", tt.user, err)
+		}
+		if got := <-p.targets; got != s.Addr() {
+			t.Errorf("proxy connected to %s, want %s", got, s.Addr())
+		}
+		c.Close()
+	}
+}
+
+func TestSOCKS5AuthFailure(t *testing.T) {
+	p := newSocksProxy(t, "ops", "secret")
+	defer p.ln.Close()
+	dial := tunnel.SOCKS5(p.ln.Addr().String(), "ops", "wrong")
+	if _, err := dial(context.Background(), "tcp", "127.0.0.1:1"); err == nil {
+		t.Error("dial with a wrong password succeeded")
+	}
+}
+
+type dialerFunc func(network, address string) (net.Conn, error)
+
+func (f dialerFunc) Dial(network, address string) (net.Conn, error) { return f(network, address) }
+
+func TestSSH(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	var dialed []string
+	client := dialerFunc(func(network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return net.Dial(network, address)
+	})
+	c, err := memcache.NewClient([]string{s.Addr()}, memcache.WithDialContext(tunnel.SSH(client)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Set(&memcache.Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(dialed) != 1 || dialed[0] != s.Addr() {
+		t.Errorf("SSH client dialed %q, want [%s]", dialed, s.Addr())
+	}
+
+	hang := dialerFunc(func(network, address string) (net.Conn, error) {
+		time.Sleep(time.Second)
+		return nil, io.EOF
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := tunnel.SSH(hang)(ctx, "tcp", s.Addr()); err != context.DeadlineExceeded {
+		t.Errorf("SSH dial outliving its context error = %v, want context.DeadlineExceeded", err)
+	}
+}
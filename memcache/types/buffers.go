@@ -0,0 +1,11 @@
+package types
+
+// A BufferPool supplies the buffers the protocols read values into, when
+// values are borrowed rather than owned by the caller.
+type BufferPool interface {
+	// Get returns a buffer of length n.
+	Get(n int) []byte
+
+	// Put returns a buffer obtained from Get for reuse.
+	Put(b []byte)
+}
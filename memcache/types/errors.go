@@ -1,6 +1,9 @@
 package types
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrCacheMiss means that a Get failed because the item wasn't present.
@@ -39,4 +42,19 @@ var (
 	ErrUnknownCommand = errors.New("memcache: unknown command")
 	ErrOutOfMemory    = errors.New("memcache: out of memory")
 	ErrUnknownError   = errors.New("memcache: unknown error from server")
+
+	// ErrMalformedList is returned when a value read as a capped list
+	// can't be decoded.
+	ErrMalformedList = errors.New("memcache: malformed list value")
 )
+
+// ResponseTooLargeError is returned when a server response is larger than
+// the configured maximum. The connection it was read from is closed,
+// since the rest of the response is left unread.
+type ResponseTooLargeError struct {
+	Size, Max int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("memcache: response of %d bytes exceeds the maximum of %d", e.Size, e.Max)
+}
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"net"
+	"time"
+)
+
 // Item is an item to be got or stored in a memcached server.
 type Item struct {
 	// Key is the Item's key (250 bytes maximum).
@@ -9,7 +14,8 @@ type Item struct {
 	Value []byte
 
 	// Flags are server-opaque flags whose semantics are entirely
-	// up to the app.
+	// up to the app, except for the high bits reserved for client
+	// features, which memcache.ReservedFlags lists.
 	Flags uint32
 
 	// Expiration is the cache expiration time, in seconds: either a relative
@@ -19,4 +25,33 @@ type Item struct {
 
 	// Compare and swap ID.
 	Casid uint64
+
+	// Meta describes where and when a retrieved item was fetched. It is
+	// ignored when storing.
+	Meta ItemMeta
+}
+
+// ItemMeta is the metadata the client records about a retrieved Item.
+type ItemMeta struct {
+	// Addr is the address of the server the item was fetched from.
+	Addr net.Addr
+
+	// Fetched is when the item was received.
+	Fetched time.Time
+}
+
+// Age returns how long ago the item was fetched, as of now.
+func (m ItemMeta) Age(now time.Time) time.Duration {
+	return now.Sub(m.Fetched)
+}
+
+// RawResponse is a response packet of the binary protocol, as returned
+// for a raw command.
+type RawResponse struct {
+	Op     uint8
+	Status uint16
+	CAS    uint64
+	Extras []byte
+	Key    string
+	Value  []byte
 }
@@ -9,4 +9,6 @@ const (
 	Cas          = "cas"
 	Incr         = "incr"
 	Decr         = "decr"
+	Append       = "append"
+	Prepend      = "prepend"
 )
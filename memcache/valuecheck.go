@@ -0,0 +1,50 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ValueHeader returns a value validator, for Config.ValidateValue,
+// accepting the values starting with header, such as a magic number or a
+// format version byte.
+func ValueHeader(header []byte) func(value []byte) error {
+	header = append([]byte(nil), header...)
+	return func(value []byte) error {
+		if !bytes.HasPrefix(value, header) {
+			return fmt.Errorf("does not start with %q", header)
+		}
+		return nil
+	}
+}
+
+// ValidJSON is a value validator, for Config.ValidateValue, accepting the
+// values that are valid JSON.
+func ValidJSON(value []byte) error {
+	if !json.Valid(value) {
+		return errors.New("is not valid JSON")
+	}
+	return nil
+}
+
+// checkValue reports whether the value of it, fetched from addr under the
+// key as sent, passes Config.ValidateValue. Values that don't are
+// deleted in the background if Config.DeleteInvalid is set.
+func (c *Client) checkValue(addr net.Addr, it *Item) bool {
+	if c.validateValue == nil || c.validateValue(it.Value) == nil {
+		return true
+	}
+	if c.deleteInvalid {
+		key := it.Key
+		c.goFunc(func() {
+			c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+				return c.cmdRunner.Delete(rw, key)
+			})
+		})
+	}
+	return false
+}
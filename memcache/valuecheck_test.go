@@ -0,0 +1,62 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestValueValidator(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	for _, deleteInvalid := range []bool{false, true} {
+		c, err := NewClient([]string{s.Addr()}, WithValueValidator(ValidJSON, deleteInvalid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range map[string]string{"good": `{"a":1}`, "bad": `{"a":`} {
+			if err := c.Set(&Item{Key: key, Value: []byte(value)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := c.Get("good"); err != nil {
+			t.Errorf("Get of a valid value: %v", err)
+		}
+		if _, err := c.Get("bad"); err != ErrCacheMiss {
+			t.Errorf("Get of an invalid value error = %v, want ErrCacheMiss", err)
+		}
+		m, err := c.GetMulti([]string{"good", "bad"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := m["bad"]; ok || len(m) != 1 {
+			t.Errorf("GetMulti returned %v, want only the valid value", m)
+		}
+
+		direct := New(s.Addr())
+		if deleteInvalid {
+			waitFor(t, "the invalid value to be deleted", func() bool {
+				_, err := direct.Get("bad")
+				return err == ErrCacheMiss
+			})
+		} else if _, err := direct.Get("bad"); err != nil {
+			t.Errorf("invalid value was deleted without DeleteInvalid: %v", err)
+		}
+		direct.Close()
+		c.Close()
+	}
+
+	if _, err := NewWithOptions(Config{Servers: []string{s.Addr()}, DeleteInvalid: true}); err == nil {
+		t.Error("NewWithOptions accepted DeleteInvalid without ValidateValue")
+	}
+}
+
+func TestValueHeader(t *testing.T) {
+	check := ValueHeader([]byte{0xCA, 1})
+	if err := check([]byte{0xCA, 1, 'x'}); err != nil {
+		t.Errorf("value with the header rejected: %v", err)
+	}
+	if err := check([]byte{0xCA, 2, 'x'}); err == nil {
+		t.Error("value with another version accepted")
+	}
+}
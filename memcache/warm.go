@@ -0,0 +1,147 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WarmStats counts the keys handled by Warm.
+type WarmStats struct {
+	Keys    int64 // keys read from the manifest and handled
+	Stored  int64 // keys loaded and added to the cache
+	Skipped int64 // keys the loader had no item for, or already cached
+	Failed  int64 // keys whose loader or store failed
+}
+
+// WarmRate limits the rate at which Warm loads keys. Only PerSecond and
+// Burst are used: Warm always waits for the limit.
+func WarmRate(l RateLimit) CallOption {
+	return func(o *callOptions) { o.warmRate = l }
+}
+
+// WarmProgress makes Warm call fn with its counts every time it has
+// handled every keys, and once more when it is done. The calls are
+// serialized.
+func WarmProgress(every int, fn func(WarmStats)) CallOption {
+	return func(o *callOptions) {
+		o.progressEvery = every
+		o.progress = fn
+	}
+}
+
+// Warm populates the cache from a manifest of keys, to pre-warm it after
+// a flush or before a new cluster takes traffic. The manifest lists one
+// key per line; blank lines and lines starting with # are skipped. Up to
+// parallelism keys are loaded at once by calling loader, whose item is
+// stored under the key with Add, so that values written meanwhile by live
+// traffic are kept. A loader returning a nil item skips the key. The
+// WarmRate and WarmProgress call options limit the rate of loads and
+// report progress.
+//
+// Failures of single keys are counted rather than returned. Warm returns
+// when the manifest is exhausted, or early with the context's error when
+// ctx is done or with the manifest's read error.
+func (c *Client) Warm(ctx context.Context, manifest io.Reader, loader func(key string) (*Item, error), parallelism int, opts ...CallOption) (WarmStats, error) {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	o.warmRate.Block = true
+
+	var (
+		stats      WarmStats
+		progressMu sync.Mutex
+		wg         sync.WaitGroup
+		keys       = make(chan string)
+	)
+	report := func() {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		o.progress(WarmStats{
+			Keys:    atomic.LoadInt64(&stats.Keys),
+			Stored:  atomic.LoadInt64(&stats.Stored),
+			Skipped: atomic.LoadInt64(&stats.Skipped),
+			Failed:  atomic.LoadInt64(&stats.Failed),
+		})
+	}
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		c.goFunc(func() {
+			defer wg.Done()
+			for key := range keys {
+				atomic.AddInt64(c.warmKey(key, loader, &stats), 1)
+				n := atomic.AddInt64(&stats.Keys, 1)
+				if o.progress != nil && o.progressEvery > 0 && n%int64(o.progressEvery) == 0 {
+					report()
+				}
+			}
+		})
+	}
+
+	var bucket tokenBucket
+	sc := bufio.NewScanner(manifest)
+	err := func() error {
+		for sc.Scan() {
+			key := strings.TrimSpace(sc.Text())
+			if key == "" || strings.HasPrefix(key, "#") {
+				continue
+			}
+			if o.warmRate.enabled() {
+				wait, _ := bucket.reserve(o.warmRate, 1, time.Now(), math.MaxInt64)
+				if wait > 0 {
+					t := time.NewTimer(wait)
+					select {
+					case <-t.C:
+					case <-ctx.Done():
+						t.Stop()
+						return ctx.Err()
+					}
+				}
+			}
+			select {
+			case keys <- key:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return sc.Err()
+	}()
+	close(keys)
+	wg.Wait()
+	if o.progress != nil {
+		report()
+	}
+	return stats, err
+}
+
+// warmKey loads key and adds it to the cache, returning the counter of
+// stats the outcome goes to.
+func (c *Client) warmKey(key string, loader func(string) (*Item, error), stats *WarmStats) *int64 {
+	it, err := loader(key)
+	if err != nil {
+		return &stats.Failed
+	}
+	if it == nil {
+		return &stats.Skipped
+	}
+	if it.Key == "" {
+		it.Key = key
+	}
+	switch err := c.Add(it); err {
+	case nil:
+		return &stats.Stored
+	case ErrNotStored:
+		return &stats.Skipped
+	default:
+		return &stats.Failed
+	}
+}
@@ -0,0 +1,77 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestWarm(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.Set(&Item{Key: "live", Value: []byte("fresh")})
+
+	manifest := "# warm set\nk1\nk2\n\n  k3  \nlive\nnone\nbroken\n"
+	loader := func(key string) (*Item, error) {
+		switch key {
+		case "none":
+			return nil, nil
+		case "broken":
+			return nil, errors.New("database down")
+		}
+		return &Item{Value: []byte("loaded " + key)}, nil
+	}
+	var mu sync.Mutex
+	var reports []WarmStats
+	stats, err := c.Warm(context.Background(), strings.NewReader(manifest), loader, 3,
+		WarmProgress(2, func(ws WarmStats) {
+			mu.Lock()
+			reports = append(reports, ws)
+			mu.Unlock()
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (WarmStats{Keys: 6, Stored: 3, Skipped: 2, Failed: 1}); stats != want {
+		t.Errorf("Warm = %+v, want %+v", stats, want)
+	}
+	for _, key := range []string{"k1", "k2", "k3"} {
+		if it, err := c.Get(key); err != nil || string(it.Value) != "loaded "+key {
+			t.Errorf("Get(%q) = %v, %v", key, it, err)
+		}
+	}
+	if it, _ := c.Get("live"); string(it.Value) != "fresh" {
+		t.Errorf("Warm overwrote a cached value with %q", it.Value)
+	}
+	if len(reports) != 4 || reports[len(reports)-1] != stats {
+		t.Errorf("progress reports = %+v, want 3 periodic and a final one", reports)
+	}
+}
+
+func TestWarmRateAndCancel(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	var b strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&b, "key%d\n", i)
+	}
+	loader := func(key string) (*Item, error) { return &Item{Value: []byte("v")}, nil }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	stats, err := c.Warm(ctx, strings.NewReader(b.String()), loader, 4, WarmRate(RateLimit{PerSecond: 100, Burst: 1}))
+	if err != context.DeadlineExceeded {
+		t.Errorf("Warm error = %v, want the context's", err)
+	}
+	if stats.Keys < 5 || stats.Keys > 20 {
+		t.Errorf("warmed %d keys in 100ms at 100/s", stats.Keys)
+	}
+}
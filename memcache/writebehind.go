@@ -0,0 +1,266 @@
+package memcache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrQueueFull is returned by WriteBehind.Set and Delete for the
+	// writes dropped because the queue is full.
+	ErrQueueFull = errors.New("memcache: write-behind queue full")
+
+	// ErrQueueClosed is returned by WriteBehind.Set and Delete once the
+	// WriteBehind is closed.
+	ErrQueueClosed = errors.New("memcache: write-behind queue closed")
+)
+
+// OverflowPolicy says what a WriteBehind does with a write when its queue
+// is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest drops the new write, failing Set with ErrQueueFull.
+	DropNewest OverflowPolicy = iota
+	// DropOldest drops the oldest queued write to make room for the new
+	// one.
+	DropOldest
+	// Block makes Set wait for room in the queue.
+	Block
+)
+
+// WriteBehindStats counts the writes of a WriteBehind.
+type WriteBehindStats struct {
+	Queued  int    // writes waiting in the queue
+	Written uint64 // writes stored
+	Failed  uint64 // writes the client failed to store
+	Dropped uint64 // writes dropped because the queue was full
+
+	// Replayed counts the writes stored by a replay, after failing on a
+	// dropped connection; Failed counts them once.
+	Replayed uint64
+}
+
+// WriteBehind populates the cache in the background: Set and Delete only
+// enqueue the write, and workers run it with the client, so that
+// latency-critical request paths don't wait for memcache. Writes are best effort: they are
+// lost when the queue overflows, as the OverflowPolicy says, or when the
+// client fails to store them, which only shows in the stats.
+type WriteBehind struct {
+	client   *Client
+	overflow OverflowPolicy
+	queue    chan behindWrite
+	wg       sync.WaitGroup
+
+	// set stores an item, with client.Set unless the client's own
+	// writes behind need another path.
+	set func(*Item) error
+
+	mu     sync.RWMutex // held for reading while enqueueing
+	closed bool
+
+	written, failed, dropped, replayed uint64
+
+	// replay holds the writes lost to dropped connections, by key, up to
+	// replaySize of them, in the order they failed.
+	replayMu   sync.Mutex
+	replaySize int
+	replay     map[string]*Item
+	replayKeys []string
+}
+
+// NewWriteBehind returns a WriteBehind storing items through c, queueing
+// up to size of them for workers goroutines. Close must be called to stop
+// them.
+func NewWriteBehind(c *Client, size, workers int, overflow OverflowPolicy) *WriteBehind {
+	if workers < 1 {
+		workers = 1
+	}
+	w := &WriteBehind{
+		client:   c,
+		overflow: overflow,
+		queue:    make(chan behindWrite, size),
+	}
+	w.set = c.Set
+	w.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		c.goFunc(w.work)
+	}
+	return w
+}
+
+// SetReplay makes the WriteBehind keep up to n of the writes that fail
+// because their connection dropped, and send them again once a write to
+// the cache succeeds, so that a server restart or a network blip loses
+// fewer writes. Only sets are queued, which can be sent twice safely; a
+// kept write is forgotten when a newer write of its key is queued, so
+// that replays don't bring back old values. SetReplay must be called
+// before the first Set.
+func (w *WriteBehind) SetReplay(n int) {
+	w.replaySize = n
+	w.replay = make(map[string]*Item)
+}
+
+// behindWrite is a queued write: item is stored, or key deleted if item
+// is nil.
+type behindWrite struct {
+	item *Item
+	key  string
+}
+
+func (w *WriteBehind) work() {
+	defer w.wg.Done()
+	for bw := range w.queue {
+		if bw.item != nil {
+			w.store(bw.item)
+		} else {
+			w.delete(bw.key)
+		}
+	}
+}
+
+// delete deletes key; a key already missing counts as written.
+func (w *WriteBehind) delete(key string) {
+	if err := w.client.Delete(key); err == nil || err == ErrCacheMiss {
+		atomic.AddUint64(&w.written, 1)
+	} else {
+		atomic.AddUint64(&w.failed, 1)
+	}
+}
+
+// store sets item, keeping it for a replay if its connection dropped and
+// replaying the kept writes once it is stored.
+func (w *WriteBehind) store(item *Item) {
+	err := w.set(item)
+	if err == nil {
+		atomic.AddUint64(&w.written, 1)
+		w.replayKept()
+		return
+	}
+	atomic.AddUint64(&w.failed, 1)
+	if w.replaySize > 0 && isNetworkError(err) {
+		w.keep(item)
+	}
+}
+
+// keep keeps item for a replay, forgetting the oldest kept write if
+// there are too many.
+func (w *WriteBehind) keep(item *Item) {
+	w.replayMu.Lock()
+	defer w.replayMu.Unlock()
+	if _, ok := w.replay[item.Key]; !ok {
+		w.replayKeys = append(w.replayKeys, item.Key)
+	}
+	w.replay[item.Key] = item
+	for len(w.replay) > w.replaySize {
+		delete(w.replay, w.replayKeys[0])
+		w.replayKeys = w.replayKeys[1:]
+	}
+}
+
+// forget forgets the kept write of key, superseded by a newer one.
+func (w *WriteBehind) forget(key string) {
+	w.replayMu.Lock()
+	defer w.replayMu.Unlock()
+	delete(w.replay, key)
+}
+
+// replayKept sends the kept writes again, keeping those that fail on a
+// dropped connection again.
+func (w *WriteBehind) replayKept() {
+	w.replayMu.Lock()
+	if len(w.replay) == 0 {
+		w.replayMu.Unlock()
+		return
+	}
+	var items []*Item
+	for _, key := range w.replayKeys {
+		if it, ok := w.replay[key]; ok {
+			items = append(items, it)
+		}
+	}
+	w.replay = make(map[string]*Item)
+	w.replayKeys = nil
+	w.replayMu.Unlock()
+
+	for _, item := range items {
+		if err := w.set(item); err == nil {
+			atomic.AddUint64(&w.replayed, 1)
+		} else if isNetworkError(err) {
+			w.keep(item)
+		}
+	}
+}
+
+// Set queues item to be stored. The item is copied, but its Value is
+// only copied if the client was built with CopyOnSet, and must not be
+// modified afterwards otherwise. Set fails with ErrQueueFull if the
+// write is dropped and with ErrQueueClosed after Close.
+func (w *WriteBehind) Set(item *Item) error {
+	it := w.client.ownItem(item)
+	return w.enqueue(behindWrite{item: it, key: it.Key})
+}
+
+// Delete queues the deletion of key. It fails like Set.
+func (w *WriteBehind) Delete(key string) error {
+	return w.enqueue(behindWrite{key: key})
+}
+
+// enqueue queues bw as the OverflowPolicy says, forgetting the kept
+// write of its key it supersedes.
+func (w *WriteBehind) enqueue(bw behindWrite) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrQueueClosed
+	}
+	if w.replaySize > 0 {
+		w.forget(bw.key)
+	}
+	if w.overflow == Block {
+		w.queue <- bw
+		return nil
+	}
+	for {
+		select {
+		case w.queue <- bw:
+			return nil
+		default:
+		}
+		if w.overflow == DropNewest {
+			atomic.AddUint64(&w.dropped, 1)
+			return ErrQueueFull
+		}
+		select {
+		case <-w.queue:
+			atomic.AddUint64(&w.dropped, 1)
+		default:
+		}
+	}
+}
+
+// Stats returns the counts of the writes so far.
+func (w *WriteBehind) Stats() WriteBehindStats {
+	return WriteBehindStats{
+		Queued:  len(w.queue),
+		Written: atomic.LoadUint64(&w.written),
+		Failed:  atomic.LoadUint64(&w.failed),
+		Dropped: atomic.LoadUint64(&w.dropped),
+
+		Replayed: atomic.LoadUint64(&w.replayed),
+	}
+}
+
+// Close stops accepting writes and waits for the queued ones to be
+// stored.
+func (w *WriteBehind) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+	return nil
+}
@@ -0,0 +1,115 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/skinass/gomemcache/memcache/memcachetest"
+)
+
+func TestWriteBehind(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+
+	w := NewWriteBehind(c, 100, 4, DropNewest)
+	for i := 0; i < 50; i++ {
+		if err := w.Set(&Item{Key: fmt.Sprint("k", i), Value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	if st := w.Stats(); st.Written != 50 || st.Queued != 0 {
+		t.Errorf("stats after Close = %+v, want 50 written", st)
+	}
+	if _, err := c.Get("k49"); err != nil {
+		t.Errorf("queued write was not stored: %v", err)
+	}
+	if err := w.Set(&Item{Key: "late"}); err != ErrQueueClosed {
+		t.Errorf("Set after Close error = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestWriteBehindDelete(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+
+	w := NewWriteBehind(c, 10, 1, Block)
+	w.Set(&Item{Key: "k", Value: []byte("v")})
+	w.Delete("k")
+	w.Delete("absent")
+	w.Close()
+	if st := w.Stats(); st.Written != 3 || st.Failed != 0 {
+		t.Errorf("stats after Close = %+v, want 3 written", st)
+	}
+	if _, err := c.Get("k"); err != ErrCacheMiss {
+		t.Errorf("Get after the queued delete error = %v, want ErrCacheMiss", err)
+	}
+	if err := w.Delete("late"); err != ErrQueueClosed {
+		t.Errorf("Delete after Close error = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestWriteBehindOverflow(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+
+	for _, overflow := range []OverflowPolicy{DropNewest, DropOldest} {
+		// Fill the queue of an unstarted WriteBehind by hand, so that no
+		// worker drains it while writes overflow.
+		w := &WriteBehind{client: c, overflow: overflow, queue: make(chan behindWrite, 2)}
+		var full int
+		for i := 0; i < 5; i++ {
+			if err := w.Set(&Item{Key: fmt.Sprint("o", i), Value: []byte("v")}); err == ErrQueueFull {
+				full++
+			}
+		}
+		first := <-w.queue
+		st := w.Stats()
+		switch overflow {
+		case DropNewest:
+			if full != 3 || first.key != "o0" {
+				t.Errorf("DropNewest: %d writes refused, first queued %q; want 3 and o0", full, first.key)
+			}
+		case DropOldest:
+			if full != 0 || first.key != "o3" {
+				t.Errorf("DropOldest: %d writes refused, first queued %q; want 0 and o3", full, first.key)
+			}
+		}
+		if st.Dropped != 3 {
+			t.Errorf("overflow %d: Dropped = %d, want 3", overflow, st.Dropped)
+		}
+	}
+}
+
+func TestWriteBehindReplay(t *testing.T) {
+	s := memcachetest.NewServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+	w := NewWriteBehind(c, 10, 1, Block)
+	w.SetReplay(10)
+
+	s.SetFaults(memcachetest.Faults{DisconnectRate: 1})
+	w.Set(&Item{Key: "lost", Value: []byte("v")})
+	w.Set(&Item{Key: "superseded", Value: []byte("old")})
+	waitFor(t, "the writes to fail", func() bool { return w.Stats().Failed == 2 })
+
+	s.SetFaults(memcachetest.Faults{})
+	w.Set(&Item{Key: "superseded", Value: []byte("new")})
+	w.Close()
+	if st := w.Stats(); st.Replayed != 1 || st.Written != 1 {
+		t.Errorf("stats = %+v, want 1 written and 1 replayed", st)
+	}
+	if _, err := c.Get("lost"); err != nil {
+		t.Errorf("write lost to a dropped connection was not replayed: %v", err)
+	}
+	if it, err := c.Get("superseded"); err != nil || string(it.Value) != "new" {
+		t.Errorf("superseded = %v, %v; want the newer value", it, err)
+	}
+}